@@ -0,0 +1,274 @@
+// Package cluster fetches the OpenAPI spec of a live Kubernetes
+// cluster, described by a kubeconfig file, so that a library can be
+// generated that exactly matches the cluster, including its aggregated
+// APIs and installed CRDs.
+package cluster
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// SpecPath is the path of the API server's OpenAPI spec.
+const SpecPath = "/openapi/v2"
+
+// Config is the subset of a kubeconfig file that is needed to connect
+// to a cluster.
+type Config struct {
+	CurrentContext string         `yaml:"current-context"`
+	Clusters       []namedCluster `yaml:"clusters"`
+	Contexts       []namedContext `yaml:"contexts"`
+	Users          []namedUser    `yaml:"users"`
+
+	// `dir` is the directory of the kubeconfig file, which relative
+	// paths in it are relative to.
+	dir string
+}
+
+type namedCluster struct {
+	Name    string `yaml:"name"`
+	Cluster struct {
+		Server                   string `yaml:"server"`
+		CertificateAuthority     string `yaml:"certificate-authority"`
+		CertificateAuthorityData string `yaml:"certificate-authority-data"`
+		InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+	} `yaml:"cluster"`
+}
+
+type namedContext struct {
+	Name    string `yaml:"name"`
+	Context struct {
+		Cluster string `yaml:"cluster"`
+		User    string `yaml:"user"`
+	} `yaml:"context"`
+}
+
+type namedUser struct {
+	Name string `yaml:"name"`
+	User struct {
+		Token                 string `yaml:"token"`
+		TokenFile             string `yaml:"tokenFile"`
+		Username              string `yaml:"username"`
+		Password              string `yaml:"password"`
+		ClientCertificate     string `yaml:"client-certificate"`
+		ClientCertificateData string `yaml:"client-certificate-data"`
+		ClientKey             string `yaml:"client-key"`
+		ClientKeyData         string `yaml:"client-key-data"`
+	} `yaml:"user"`
+}
+
+// DefaultConfigPath returns the path of the kubeconfig file `kubectl`
+// would use: the first path in `$KUBECONFIG`, or `~/.kube/config`.
+func DefaultConfigPath() string {
+	if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 && paths[0] != "" {
+		return paths[0]
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".kube", "config")
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// LoadConfig reads the kubeconfig file at `path`.
+func LoadConfig(path string) (*Config, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read kubeconfig at '%s':\n%v", path, err)
+	}
+
+	config := Config{}
+	if err := yaml.Unmarshal(text, &config); err != nil {
+		return nil, fmt.Errorf("Could not deserialize kubeconfig at '%s':\n%v", path, err)
+	}
+	config.dir = filepath.Dir(path)
+	return &config, nil
+}
+
+// Endpoint is a cluster's API server, along with the credentials to
+// authenticate to it with.
+type Endpoint struct {
+	Server string
+	// Token, if set, is sent as a bearer token.
+	Token string
+	// Username and Password, if set, are sent with basic
+	// authentication.
+	Username string
+	Password string
+	// TLS is the configuration for `https` servers, including the CA
+	// to verify the server with, and the client certificate, if any.
+	TLS *tls.Config
+}
+
+// Endpoint resolves the API server and credentials of the context
+// named `context`, or, if it is "", of the current context.
+func (c *Config) Endpoint(context string) (*Endpoint, error) {
+	if context == "" {
+		context = c.CurrentContext
+	}
+	if context == "" {
+		return nil, fmt.Errorf("Kubeconfig has no current context, and no context was given")
+	}
+
+	var ctx *namedContext
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == context {
+			ctx = &c.Contexts[i]
+		}
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("Kubeconfig has no context named '%s'", context)
+	}
+
+	var cluster *namedCluster
+	for i := range c.Clusters {
+		if c.Clusters[i].Name == ctx.Context.Cluster {
+			cluster = &c.Clusters[i]
+		}
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf(
+			"Kubeconfig has no cluster named '%s', which context '%s' refers to",
+			ctx.Context.Cluster, context)
+	}
+	if cluster.Cluster.Server == "" {
+		return nil, fmt.Errorf("Cluster '%s' has no server", cluster.Name)
+	}
+
+	endpoint := Endpoint{
+		Server: strings.TrimSuffix(cluster.Cluster.Server, "/"),
+		TLS:    &tls.Config{InsecureSkipVerify: cluster.Cluster.InsecureSkipTLSVerify},
+	}
+
+	ca, err := c.readData(cluster.Cluster.CertificateAuthorityData, cluster.Cluster.CertificateAuthority)
+	if err != nil {
+		return nil, err
+	}
+	if ca != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("Could not parse certificate authority of cluster '%s'", cluster.Name)
+		}
+		endpoint.TLS.RootCAs = pool
+	}
+
+	if ctx.Context.User == "" {
+		return &endpoint, nil
+	}
+	var user *namedUser
+	for i := range c.Users {
+		if c.Users[i].Name == ctx.Context.User {
+			user = &c.Users[i]
+		}
+	}
+	if user == nil {
+		return nil, fmt.Errorf(
+			"Kubeconfig has no user named '%s', which context '%s' refers to",
+			ctx.Context.User, context)
+	}
+
+	endpoint.Token = user.User.Token
+	if endpoint.Token == "" && user.User.TokenFile != "" {
+		token, err := ioutil.ReadFile(c.resolve(user.User.TokenFile))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read token file of user '%s':\n%v", user.Name, err)
+		}
+		endpoint.Token = strings.TrimSpace(string(token))
+	}
+	endpoint.Username = user.User.Username
+	endpoint.Password = user.User.Password
+
+	cert, err := c.readData(user.User.ClientCertificateData, user.User.ClientCertificate)
+	if err != nil {
+		return nil, err
+	}
+	key, err := c.readData(user.User.ClientKeyData, user.User.ClientKey)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil || key != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load client certificate of user '%s':\n%v", user.Name, err)
+		}
+		endpoint.TLS.Certificates = []tls.Certificate{pair}
+	}
+
+	return &endpoint, nil
+}
+
+// FetchSpec downloads the OpenAPI spec of the API server, giving up
+// after `timeout` (or never, if it is 0).
+func (e *Endpoint) FetchSpec(timeout time.Duration) ([]byte, error) {
+	client := http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: e.TLS},
+	}
+
+	url := e.Server + SpecPath
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if e.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.Token)
+	} else if e.Username != "" {
+		req.SetBasicAuth(e.Username, e.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Could not fetch spec from '%s':\n%v", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read spec from '%s':\n%v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"Could not fetch spec from '%s': %s\n%s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// `readData` returns the contents of a kubeconfig field that is either
+// given inline, as base64-encoded `data`, or as the path of a file.
+func (c *Config) readData(data, path string) ([]byte, error) {
+	if data != "" {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode kubeconfig data:\n%v", err)
+		}
+		return decoded, nil
+	}
+	if path != "" {
+		text, err := ioutil.ReadFile(c.resolve(path))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read file at '%s':\n%v", path, err)
+		}
+		return text, nil
+	}
+	return nil, nil
+}
+
+// `resolve` resolves a path in the kubeconfig file, which is relative
+// to its directory.
+func (c *Config) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.dir, path)
+}
@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var kubeconfig = `apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+- name: insecure
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: admin
+- name: anonymous
+  context:
+    cluster: insecure
+- name: broken
+  context:
+    cluster: missing
+users:
+- name: admin
+  user:
+    tokenFile: token
+`
+
+func TestFetchSpec(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != SpecPath {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"swagger": "2.0"}`)
+	}))
+	defer server.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config")
+	text := fmt.Sprintf(kubeconfig, server.URL, base64.StdEncoding.EncodeToString(ca), server.URL)
+	if err := ioutil.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "token"), []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load kubeconfig:\n%v", err)
+	}
+
+	endpoint, err := config.Endpoint("")
+	if err != nil {
+		t.Fatalf("Failed to resolve current context:\n%v", err)
+	}
+	spec, err := endpoint.FetchSpec(10 * time.Second)
+	if err != nil {
+		t.Fatalf("Failed to fetch spec:\n%v", err)
+	}
+	if string(spec) != `{"swagger": "2.0"}` {
+		t.Errorf("Unexpected spec '%s'", spec)
+	}
+
+	// Without credentials, the server refuses, which is reported.
+	endpoint, err = config.Endpoint("anonymous")
+	if err != nil {
+		t.Fatalf("Failed to resolve context 'anonymous':\n%v", err)
+	}
+	if _, err := endpoint.FetchSpec(10 * time.Second); err == nil ||
+		!strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected an unauthorized error, got %v", err)
+	}
+
+	for _, context := range []string{"broken", "missing"} {
+		if _, err := config.Endpoint(context); err == nil {
+			t.Errorf("Expected context '%s' to be rejected", context)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/ksonnet"
+)
+
+var diffUsage = `Usage: ksonnet-gen diff [flags] [path or URL of previous swagger.json] [path or URL of current swagger.json]`
+
+// runDiff implements `ksonnet-gen diff`, which prints the API objects
+// and properties that changed between two specs (e.g., of two
+// Kubernetes releases) as a Markdown changelog.
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	output := flags.String(
+		"o", "", "Write the changelog to this file, rather than printing it")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, diffUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		log.Fatal(diffUsage)
+	}
+
+	previous, current := flags.Arg(0), flags.Arg(1)
+	previousSpec, err := readSpec(previous)
+	if err != nil {
+		log.Fatal(err)
+	}
+	currentSpec, err := readSpec(current)
+	if err != nil {
+		log.Fatal(err)
+	}
+	diff, err := ksonnet.DiffSpecs(previousSpec, currentSpec)
+	if err != nil {
+		log.Fatalf("Could not compare specs:\n%v", err)
+	}
+
+	if *output == "" {
+		fmt.Print(string(diff.Markdown()))
+		return
+	}
+	if err := ioutil.WriteFile(*output, diff.Markdown(), 0644); err != nil {
+		log.Fatalf("Could not write `%s`:\n%v", *output, err)
+	}
+}
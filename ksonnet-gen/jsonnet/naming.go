@@ -0,0 +1,107 @@
+package jsonnet
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingConvention is the casing convention of the identifiers the
+// rewrites emit (e.g., `withApiVersion` or `with_api_version`), as a
+// strategy that splits identifiers into words, and joins words back
+// into an identifier. Every rewrite that builds an identifier takes
+// the convention to build it in, rather than reading it from some
+// global state, so that libraries in different conventions can be
+// generated concurrently. Field keys are not identifiers, since they
+// must have precisely the text of the Kubernetes API spec, so they are
+// left alone.
+type NamingConvention interface {
+	// Split returns the words of `id`, an identifier in this
+	// convention, or in lowerCamelCase, which is what rewrite rules
+	// produce (e.g., `api` and `Version` for `apiVersion`).
+	Split(id string) []string
+	// Join returns the identifier made of `words`.
+	Join(words []string) string
+}
+
+// CamelCase is the default naming convention, lowerCamelCase (e.g.,
+// `withApiVersion`).
+var CamelCase NamingConvention = camelCase{}
+
+// SnakeCase is the snake_case naming convention (e.g.,
+// `with_api_version`).
+var SnakeCase NamingConvention = snakeCase{}
+
+// NamingConventions are the naming conventions, by the name options
+// refer to them by.
+var NamingConventions = map[string]NamingConvention{
+	"camelCase":  CamelCase,
+	"snake_case": SnakeCase,
+}
+
+type camelCase struct{}
+
+// Split splits `id` before every upper-case letter that does not follow
+// another one, so that initialisms that no rule rewrote (e.g., `IP`
+// in `podIP`) stay one word.
+func (camelCase) Split(id string) []string {
+	words := []string{}
+	runes := []rune(id)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1]) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+// Join capitalizes every word but the first, which it leaves alone.
+func (camelCase) Join(words []string) string {
+	id := ""
+	for i, word := range words {
+		if i == 0 {
+			id = word
+		} else {
+			id += strings.Title(word)
+		}
+	}
+	return id
+}
+
+type snakeCase struct{}
+
+func (snakeCase) Split(id string) []string {
+	words := []string{}
+	for _, part := range strings.Split(id, "_") {
+		if part != "" {
+			words = append(words, camelCase{}.Split(part)...)
+		}
+	}
+	return words
+}
+
+func (snakeCase) Join(words []string) string {
+	lower := make([]string, len(words))
+	for i, word := range words {
+		lower[i] = strings.ToLower(word)
+	}
+	return strings.Join(lower, "_")
+}
+
+// `words` returns the words of `texts`, each of which is an identifier
+// in `nc` or a word, in order.
+func words(nc NamingConvention, texts ...string) []string {
+	words := []string{}
+	for _, text := range texts {
+		words = append(words, nc.Split(text)...)
+	}
+	return words
+}
+
+// Join returns the identifier made of the words of `id` followed by
+// those of `suffixes`, in the naming convention `nc`, e.g.,
+// `containersType` (or `containers_type`) for `containers` and `Type`.
+func (id Identifier) Join(nc NamingConvention, suffixes ...string) Identifier {
+	return Identifier(nc.Join(words(nc, append([]string{string(id)}, suffixes...)...)))
+}
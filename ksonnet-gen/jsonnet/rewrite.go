@@ -35,12 +35,99 @@ type FuncParam string
 // Kubernetes version. For example, `fooAPI` becomes `fooApi`.
 type Identifier string
 
-func (id Identifier) ToSetterID() Identifier {
-	return Identifier("with" + strings.Title(string(id)))
+// ToSetterID returns the name of the setter of a property, which is
+// `with` followed by `id` (e.g., `withReplicas` for `replicas`).
+func (id Identifier) ToSetterID(nc NamingConvention) Identifier {
+	return Identifier("with").Join(nc, string(id))
 }
 
-func (id Identifier) ToMixinID() Identifier {
-	return Identifier("with" + strings.Title(string(id)) + "Mixin")
+// ToMixinID returns the name of the mixin of a property, which is its
+// setter followed by `Mixin` (e.g., `withLabelsMixin` for `labels`).
+func (id Identifier) ToMixinID(nc NamingConvention) Identifier {
+	return Identifier("with").Join(nc, string(id), "Mixin")
+}
+
+// ToItemSetterID returns the name of the setter that sets one entry
+// of a map, which is the singular of `id` if it is plural (e.g.,
+// `withLabel` for `labels`), and `id` followed by `Item` otherwise
+// (e.g., `withDataItem` for `data`).
+func (id Identifier) ToItemSetterID(nc NamingConvention) Identifier {
+	return Identifier("with").Join(nc, id.singular(nc)...)
+}
+
+// ToAddID returns the name of the function that appends one element to
+// an array, which is `add` followed by the singular of `id` if it is
+// plural (e.g., `addContainer` for `containers`), and by `id` and
+// `Item` otherwise.
+func (id Identifier) ToAddID(nc NamingConvention) Identifier {
+	return Identifier("add").Join(nc, id.singular(nc)...)
+}
+
+// ToNewID returns the name of the function that creates one element of
+// an array, which is `new` followed by the singular of `id` if it is
+// plural (e.g., `newContainer` for `containers`), and by `id` and
+// `Item` otherwise.
+func (id Identifier) ToNewID(nc NamingConvention) Identifier {
+	return Identifier("new").Join(nc, id.singular(nc)...)
+}
+
+// singular returns the words of the singular of `id` if it is plural,
+// and of `id` followed by `Item` otherwise. Only the last word is
+// made singular (e.g., `hostAlias` for `hostAliases`).
+func (id Identifier) singular(nc NamingConvention) []string {
+	ws := words(nc, string(id))
+	last := ws[len(ws)-1]
+	lower := strings.ToLower(last)
+	if singular, ok := irregularSingulars[lower]; ok {
+		if singular == "" {
+			return append(ws, "Item")
+		}
+		ws[len(ws)-1] = last[:1] + singular[1:]
+		return ws
+	}
+	for _, suffix := range pluralSuffixes {
+		if strings.HasSuffix(lower, suffix.plural) && len(lower) > len(suffix.plural) {
+			ws[len(ws)-1] = last[:len(last)-len(suffix.plural)] + suffix.singular
+			return ws
+		}
+	}
+	if strings.HasSuffix(lower, "s") && !strings.HasSuffix(lower, "ss") && !strings.HasSuffix(lower, "us") {
+		ws[len(ws)-1] = last[:len(last)-1]
+		return ws
+	}
+	return append(ws, "Item")
+}
+
+// irregularSingulars maps words that the rules of `pluralSuffixes`
+// would get wrong to their singular, or to "" if they are not plural,
+// even though they end with an `s` (e.g., acronyms like `tls`).
+var irregularSingulars = map[string]string{
+	"cephfs":    "",
+	"dns":       "",
+	"glusterfs": "",
+	"nfs":       "",
+	"series":    "",
+	"tls":       "",
+	"indices":   "index",
+}
+
+// pluralSuffixes are the endings of plurals that are not just their
+// singular followed by `s`, and the endings of their singular, in the
+// order they are tried. Other plurals that end with `ses` (e.g.,
+// `causes`) just drop the `s`.
+var pluralSuffixes = []struct{ plural, singular string }{
+	{"sses", "ss"},   // E.g., `addresses`.
+	{"tuses", "tus"}, // E.g., `statuses`.
+	{"iases", "ias"}, // E.g., `aliases`.
+	{"xes", "x"},     // E.g., `prefixes`.
+	{"ies", "y"},     // E.g., `policies`.
+}
+
+// ToReplaceByKeyID returns the name of the function that replaces the
+// elements of an array that have the same merge key `key` as the ones
+// it is passed (e.g., `replaceContainersByName` for `containers`).
+func (id Identifier) ToReplaceByKeyID(nc NamingConvention, key string) Identifier {
+	return Identifier("replace").Join(nc, string(id), "By", key)
 }
 
 // RewriteAsFieldKey takes a `PropertyName` and converts it to a valid
@@ -55,6 +142,10 @@ func RewriteAsFieldKey(text kubespec.PropertyName) FieldKey {
 	if _, ok := jsonnetKeywordSet[text]; ok {
 		return FieldKey(fmt.Sprintf("\"%s\"", text))
 	}
+	if strings.Contains(string(text), "$") {
+		// E.g., `$ref`, which is not a valid identifier.
+		return FieldKey(fmt.Sprintf("\"%s\"", text))
+	}
 	return FieldKey(text)
 }
 
@@ -70,26 +161,41 @@ func RewriteAsFieldKey(text kubespec.PropertyName) FieldKey {
 // Kubernetes version, according to identifiers that don't conform to
 // this style.
 func RewriteAsFuncParam(
-	k8sVersion string, text kubespec.PropertyName,
+	nc NamingConvention, k8sVersion string, text kubespec.PropertyName,
 ) FuncParam {
-	id := RewriteAsIdentifier(k8sVersion, text)
+	id := RewriteAsIdentifier(nc, k8sVersion, text)
 	if _, ok := jsonnetKeywordSet[kubespec.PropertyName(id)]; ok {
-		return FuncParam(fmt.Sprintf("%sParam", id))
+		return FuncParam(id.Join(nc, "Param"))
+	}
+	if ShadowsBuiltin(kubespec.PropertyName(id)) {
+		return FuncParam(id.Join(nc, "Param"))
 	}
 	return FuncParam(id)
 }
 
+// ShadowsBuiltin reports whether a function parameter named after
+// `text` would shadow one of the names Jsonnet binds in every scope
+// (`std`, `self`, `super`, and `$`). For example, in `withStd(std)::
+// self + {std: std}`, the body can no longer call the standard
+// library, and `self`, `super`, and `$` can't be bound at all.
+// `RewriteAsFuncParam` renames such parameters (e.g., to `stdParam`).
+func ShadowsBuiltin(text kubespec.PropertyName) bool {
+	_, ok := jsonnetBuiltinSet[text]
+	return ok || strings.Contains(string(text), "$")
+}
+
 // RewriteAsIdentifier takes a `GroupName`, `ObjectKind`,
 // `PropertyName`, or `string`, and converts it to a Jsonnet-style
-// Identifier. Typically this includes lower-casing the first letter,
-// but also changing initialisms like fooAPI -> fooApi.
+// Identifier in the naming convention `nc`. Typically this includes
+// lower-casing the first letter, but also changing initialisms like
+// fooAPI -> fooApi.
 //
 // NOTE: This transformation involves a hand-curated style change to
 // lowerCamelCase (e.g., `fooAPI` -> `fooApi`). This list changes per
 // Kubernetes version, according to identifiers that don't conform to
 // this style.
 func RewriteAsIdentifier(
-	k8sVersion string, rawID fmt.Stringer,
+	nc NamingConvention, k8sVersion string, rawID fmt.Stringer,
 ) Identifier {
 	var id = rawID.String()
 
@@ -98,8 +204,15 @@ func RewriteAsIdentifier(
 	}
 	kindString := kubeversion.MapIdentifier(k8sVersion, id)
 
+	// `$` is not valid in a Jsonnet identifier (e.g., in the setter for
+	// a property named `$ref`).
+	kindString = strings.Replace(kindString, "$", "", -1)
+	if kindString == "" {
+		kindString = "dollar"
+	}
+
 	upper := strings.ToLower(kindString[:1])
-	return Identifier(upper + kindString[1:])
+	return Identifier(nc.Join(words(nc, upper+kindString[1:])))
 }
 
 var jsonnetKeywordSet = map[kubespec.PropertyName]string{
@@ -121,3 +234,10 @@ var jsonnetKeywordSet = map[kubespec.PropertyName]string{
 	"super":      "super",
 	"true":       "true",
 }
+
+var jsonnetBuiltinSet = map[kubespec.PropertyName]string{
+	"std":   "std",
+	"self":  "self",
+	"super": "super",
+	"$":     "$",
+}
@@ -41,6 +41,8 @@ var funcParamTests = map[kubespec.PropertyName]FuncParam{
 	"self":       "selfParam",
 	"super":      "superParam",
 	"true":       "trueParam",
+	"std":        "stdParam",
+	"$ref":       "ref",
 }
 
 var identifierTests = map[kubespec.PropertyName]Identifier{
@@ -86,7 +88,7 @@ func TestRewriteAsFieldKey(t *testing.T) {
 
 func TestRewriteAsFuncParam(t *testing.T) {
 	for keyword, target := range funcParamTests {
-		actual := RewriteAsFuncParam("v1.7.0", keyword)
+		actual := RewriteAsFuncParam(CamelCase, "v1.7.0", keyword)
 		if target != actual {
 			t.Errorf("Expected '%s' got '%s'", target, actual)
 		}
@@ -94,7 +96,7 @@ func TestRewriteAsFuncParam(t *testing.T) {
 
 	// Test we also do aliasing for func parameters
 	for id, target := range identifierTests {
-		actual := RewriteAsFuncParam("v1.7.0", id)
+		actual := RewriteAsFuncParam(CamelCase, "v1.7.0", id)
 		if FuncParam(target) != actual {
 			t.Errorf("Expected '%s' got '%s'", target, actual)
 		}
@@ -103,7 +105,7 @@ func TestRewriteAsFuncParam(t *testing.T) {
 
 func TestRewriteAsIdentifier(t *testing.T) {
 	for id, target := range identifierTests {
-		actual := RewriteAsIdentifier("v1.7.0", id)
+		actual := RewriteAsIdentifier(CamelCase, "v1.7.0", id)
 		if target != actual {
 			t.Errorf("Expected '%s' got '%s'", target, actual)
 		}
@@ -112,9 +114,103 @@ func TestRewriteAsIdentifier(t *testing.T) {
 	// Test rewrite is a no-op for keywords.
 	for keyword := range fieldKeyTests {
 		target := Identifier(keyword)
-		actual := RewriteAsIdentifier("v1.7.0", kubespec.PropertyName(keyword))
+		actual := RewriteAsIdentifier(CamelCase, "v1.7.0", kubespec.PropertyName(keyword))
 		if target != actual {
 			t.Errorf("Expected '%s' got '%s'", target, actual)
 		}
 	}
 }
+
+var itemSetterIDTests = map[Identifier]Identifier{
+	"labels":       "withLabel",
+	"matchLabels":  "withMatchLabel",
+	"data":         "withDataItem",
+	"nodeSelector": "withNodeSelectorItem",
+	"address":      "withAddressItem",
+	"status":       "withStatusItem",
+	"hostAliases":  "withHostAlias",
+	"tls":          "withTlsItem",
+}
+
+func TestToItemSetterID(t *testing.T) {
+	for id, target := range itemSetterIDTests {
+		actual := id.ToItemSetterID(CamelCase)
+		if target != actual {
+			t.Errorf("Expected '%s' got '%s'", target, actual)
+		}
+	}
+}
+
+var addIDTests = map[kubespec.PropertyName]Identifier{
+	"containers":            "addContainer",
+	"hostAliases":           "addHostAlias",
+	"addresses":             "addAddress",
+	"notReadyAddresses":     "addNotReadyAddress",
+	"containerStatuses":     "addContainerStatus",
+	"initContainerStatuses": "addInitContainerStatus",
+	"tls":                   "addTlsItem",
+	"causes":                "addCause",
+	"prefixes":              "addPrefix",
+	"policies":              "addPolicy",
+	"series":                "addSeriesItem",
+	"data":                  "addDataItem",
+}
+
+func TestToAddID(t *testing.T) {
+	for name, target := range addIDTests {
+		actual := RewriteAsIdentifier(CamelCase, "v1.7.0", name).ToAddID(CamelCase)
+		if target != actual {
+			t.Errorf("Expected '%s' got '%s'", target, actual)
+		}
+	}
+}
+
+var newIDTests = map[kubespec.PropertyName]Identifier{
+	"containers":            "newContainer",
+	"hostAliases":           "newHostAlias",
+	"addresses":             "newAddress",
+	"notReadyAddresses":     "newNotReadyAddress",
+	"containerStatuses":     "newContainerStatus",
+	"initContainerStatuses": "newInitContainerStatus",
+	"tls":                   "newTlsItem",
+}
+
+func TestToNewID(t *testing.T) {
+	for name, target := range newIDTests {
+		actual := RewriteAsIdentifier(CamelCase, "v1.7.0", name).ToNewID(CamelCase)
+		if target != actual {
+			t.Errorf("Expected '%s' got '%s'", target, actual)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]struct{ actual, target Identifier }{
+		"identifier":    {RewriteAsIdentifier(SnakeCase, "v1.7.0", kubespec.PropertyName("targetCPUUtilizationPercentage")), "target_cpu_utilization_percentage"},
+		"initialism":    {RewriteAsIdentifier(SnakeCase, "v1.7.0", kubespec.PropertyName("podIPs")), "pod_ips"},
+		"kind":          {RewriteAsIdentifier(SnakeCase, "v1.7.0", kubespec.ObjectKind("PersistentVolumeClaim")), "persistent_volume_claim"},
+		"funcParam":     {Identifier(RewriteAsFuncParam(SnakeCase, "v1.7.0", "error")), "error_param"},
+		"setter":        {Identifier("api_version").ToSetterID(SnakeCase), "with_api_version"},
+		"mixin":         {Identifier("match_labels").ToMixinID(SnakeCase), "with_match_labels_mixin"},
+		"itemSetter":    {Identifier("match_labels").ToItemSetterID(SnakeCase), "with_match_label"},
+		"add":           {Identifier("init_containers").ToAddID(SnakeCase), "add_init_container"},
+		"addIrregular":  {Identifier("container_statuses").ToAddID(SnakeCase), "add_container_status"},
+		"new":           {Identifier("data").ToNewID(SnakeCase), "new_data_item"},
+		"replaceByKey":  {Identifier("ports").ToReplaceByKeyID(SnakeCase, "containerPortAndProtocol"), "replace_ports_by_container_port_and_protocol"},
+		"join":          {Identifier("containers").Join(SnakeCase, "Type"), "containers_type"},
+		"joinUnchanged": {Identifier("v1beta1").Join(SnakeCase), "v1beta1"},
+	}
+	for name, test := range tests {
+		if test.target != test.actual {
+			t.Errorf("[%s] Expected '%s' got '%s'", name, test.target, test.actual)
+		}
+	}
+}
+
+func TestCamelCaseRoundTrip(t *testing.T) {
+	for _, id := range []string{"withApiVersion", "podIP", "v1beta1", "x509Cert", "scale_io", "_ref"} {
+		if actual := CamelCase.Join(CamelCase.Split(id)); actual != id {
+			t.Errorf("Expected '%s' got '%s'", id, actual)
+		}
+	}
+}
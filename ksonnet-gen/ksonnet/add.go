@@ -0,0 +1,71 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// `addFunctionParam` is the parameter of the functions `emitAdd` emits.
+const addFunctionParam jsonnet.FuncParam = "item"
+
+// `addFunctionName` returns the name of the function that appends one
+// element to an array property whose elements are objects (e.g.,
+// `addContainer` for `containers`), or "" if `p` is not such an array,
+// or if another property of the same object already has a function
+// with that name, in which case the function is not emitted.
+func (p *property) addFunctionName() jsonnet.Identifier {
+	if p.schemaType == nil || *p.schemaType != "array" || !isMixinRef(p.itemTypes.Ref) {
+		return ""
+	}
+	item := p.root().getAPIObject(p.root().parseRef(p.itemTypes.Ref))
+	if len(item.emittedProperties) == 0 {
+		// E.g., an array of `Quantity`s, which are serialized as strings.
+		return ""
+	}
+
+	k8sVersion := p.root().spec.Info.Version
+	naming := p.root().naming
+	name := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name).ToAddID(naming)
+	for _, sibling := range p.parent.emittedProperties {
+		if sibling == p {
+			continue
+		}
+		id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, sibling.name)
+		if id == name || id.ToSetterID(naming) == name || id.ToMixinID(naming) == name || id.ToAddID(naming) == name {
+			return ""
+		}
+	}
+	return name
+}
+
+// `emitAdd` emits `name`, a function that appends one element to an
+// array property, keeping the elements it already has, so that users
+// can add, e.g., a sidecar container without wrapping it in an array,
+// or knowing the containers that were set before. Unlike the mixin of
+// the array, it appends even if the array has a merge key. `wrap`
+// places the object literal that sets the property where the property
+// is, e.g., in a call to the mixin of its parent.
+func (p *property) emitAdd(m *astWriter, name jsonnet.Identifier, wrap func(string) string) {
+	if name == "" {
+		return
+	}
+	help := fmt.Sprintf(
+		"Appends `%s` (or, if it is an array, its elements) to `%s`.", addFunctionParam, p.name)
+	p.comments.emit(m)
+	m.comment(help)
+	p.root().emitDocsonnetFunction(
+		m, string(name), append(p.comments[:len(p.comments):len(p.comments)], help),
+		fmt.Sprintf("d.arg(%s, d.T.object)", jsonnetString(string(addFunctionParam))))
+	m.method(
+		string(name), []string{string(addFunctionParam)},
+		fmt.Sprintf("%sself + %s", p.typeAssertions(addFunctionParam), wrap(p.addBody())))
+}
+
+// `addBody` returns the object literal that appends `addFunctionParam`
+// to `p`.
+func (p *property) addBody() string {
+	return fmt.Sprintf(
+		"{%s+: if std.isArray(%s) then %s else [%s]}",
+		jsonnet.RewriteAsFieldKey(p.name), addFunctionParam, addFunctionParam, addFunctionParam)
+}
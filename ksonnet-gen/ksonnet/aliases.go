@@ -0,0 +1,111 @@
+package ksonnet
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `shortNames` maps the short names `kubectl` knows kinds by to the
+// kinds, for `emitShortNames`.
+var shortNames = map[string]kubespec.ObjectKind{
+	"cm":     "ConfigMap",
+	"cj":     "CronJob",
+	"crd":    "CustomResourceDefinition",
+	"cs":     "ComponentStatus",
+	"csr":    "CertificateSigningRequest",
+	"deploy": "Deployment",
+	"ds":     "DaemonSet",
+	"ep":     "Endpoints",
+	"ev":     "Event",
+	"hpa":    "HorizontalPodAutoscaler",
+	"ing":    "Ingress",
+	"limits": "LimitRange",
+	"netpol": "NetworkPolicy",
+	"no":     "Node",
+	"ns":     "Namespace",
+	"pdb":    "PodDisruptionBudget",
+	"po":     "Pod",
+	"psp":    "PodSecurityPolicy",
+	"pv":     "PersistentVolume",
+	"pvc":    "PersistentVolumeClaim",
+	"quota":  "ResourceQuota",
+	"rc":     "ReplicationController",
+	"rs":     "ReplicaSet",
+	"sa":     "ServiceAccount",
+	"sc":     "StorageClass",
+	"sts":    "StatefulSet",
+	"svc":    "Service",
+}
+
+// `emitShortNames` emits an alias for every kind of `shortNames` the
+// library has, named after its short name, for the preferred version
+// of the kind (see `preferredObjectOfKind`), e.g.,
+// `deploy:: self.apps.v1beta1.deployment`. Since the aliases refer to
+// `self`, they also reach the helpers `k.libsonnet` adds. Aliases
+// whose name collides with a group are reported, and not emitted.
+func (root *root) emitShortNames(m *astWriter) {
+	if !root.options.ShortNames {
+		return
+	}
+
+	names := []string{}
+	for name := range shortNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	first := true
+	for _, name := range names {
+		ao := root.preferredObjectOfKind(shortNames[name])
+		if ao == nil {
+			continue
+		}
+		if _, ok := root.groups[kubespec.GroupName(name)]; ok {
+			root.report.errorf(
+				ao.parsedName.Unparse(),
+				"short name '%s' not emitted, because a group named '%s' already exists",
+				name, name)
+			continue
+		}
+
+		if first {
+			m.comment("Aliases of the preferred version of common objects, by their kubectl short names.")
+			first = false
+		}
+		m.hidden(name, fmt.Sprintf(
+			"self.%s.%s.%s", ao.parent.parent.identifier(), ao.parent.version, ao.jsonnetName))
+	}
+}
+
+// `preferredObjectOfKind` returns the top-level API object of kind
+// `kind` of the preferred version (see
+// `kubespec.VersionString.HasPriorityOver`), or nil if the library
+// doesn't have one. If several groups have the kind in versions of the
+// same priority (e.g., `Deployment`, in `apps/v1beta1` and
+// `extensions/v1beta1`), groups other than `extensions`, whose kinds
+// moved to other groups, are preferred, and then the first group in
+// sorted order.
+func (root *root) preferredObjectOfKind(kind kubespec.ObjectKind) *apiObject {
+	var preferred *apiObject
+	for _, group := range root.groups.toSortedSlice() {
+		var candidate *apiObject
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			if ao, ok := versionedAPI.apiObjects[kind]; ok && ao.isTopLevel {
+				candidate = ao
+				break
+			}
+		}
+
+		switch {
+		case candidate == nil:
+		case preferred == nil, candidate.parent.version.HasPriorityOver(preferred.parent.version):
+			preferred = candidate
+		case candidate.parent.version == preferred.parent.version &&
+			preferred.parent.parent.name == "extensions":
+			preferred = candidate
+		}
+	}
+	return preferred
+}
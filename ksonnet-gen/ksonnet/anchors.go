@@ -0,0 +1,26 @@
+package ksonnet
+
+import (
+	"fmt"
+)
+
+// `emitDiffAnchor` emits the comment that marks where the API object
+// `ao` starts, if `Options.DiffAnchors` is set, e.g.,
+// `// --- apps.v1beta1.deployment ---`, or
+// `// --- hidden.core.v1.podSpec ---` for an object that is not
+// top-level. An anchor only depends on the path of its object, so a
+// diff between libraries generated from different specs (e.g., of two
+// Kubernetes versions) matches the anchors of the objects both have,
+// and lines up by object, rather than drifting by however many lines
+// the objects before it gained or lost.
+func (ao *apiObject) emitDiffAnchor(m *astWriter) {
+	if !ao.root().options.DiffAnchors {
+		return
+	}
+
+	path := fmt.Sprintf("%s.%s.%s", ao.parent.parent.identifier(), ao.parent.version, ao.jsonnetName)
+	if !ao.isTopLevel {
+		path = "hidden." + path
+	}
+	m.comment(fmt.Sprintf("--- %s ---", path))
+}
@@ -0,0 +1,310 @@
+package ksonnet
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/formatter"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// astWriter builds an object of a go-jsonnet AST (see `jsonnetast.go`)
+// field by field, which is how the `jsonnet` backend emits the
+// library. Different components add the members of the object they
+// are responsible for in order (e.g., the setters of a property), and
+// nest objects with `namespace`, rather than writing lines, so that
+// braces and commas follow from the structure of the tree. Once built,
+// the tree is printed with `emitObject` (or `emitFile`).
+//
+// Each member is built from code the emitter already has as text
+// (see `astBuilder.code`), which is parsed when it is added, so that a
+// member that is not valid Jsonnet is caught when the library is
+// generated, rather than when it is evaluated.
+type astWriter struct {
+	*astBuilder
+	object *ast.Object
+	// `pending` are the comments of the next field added, one line
+	// each, "" for a blank line.
+	pending []string
+	// `commentLines` are the numbers of lines of comments (see
+	// `pending`) that precede each field.
+	commentLines []int
+	// `sources` are the ranges of fields added for definitions (see
+	// `source`).
+	sources []astSource
+	// `namespaces` are the writers of the objects nested in the tree,
+	// which it shares with them, like its `astBuilder`.
+	namespaces map[*ast.Object]*astWriter
+}
+
+// `astSource` is a range of fields an `astWriter` added for a
+// definition, or one of its properties: from the first comment after
+// the first `comments` of the field `start`, up to the field `end`,
+// excluded.
+type astSource struct {
+	start, end int
+	comments   int
+	definition kubespec.DefinitionName
+	property   kubespec.PropertyName
+}
+
+func newASTWriter() *astWriter {
+	m := &astWriter{
+		astBuilder: &astBuilder{},
+		object:     &ast.Object{},
+		namespaces: make(map[*ast.Object]*astWriter),
+	}
+	m.namespaces[m.object] = m
+	return m
+}
+
+// `comment` adds a line of comment to the next field, e.g., `Creates a
+// new Deployment.`, which is emitted as `// Creates a new Deployment.`.
+func (m *astWriter) comment(line string) {
+	m.pending = append(m.pending, comments{line}.lines()...)
+}
+
+// `blank` adds a blank line before the next field, e.g., to set apart
+// the helpers of `k.libsonnet`.
+func (m *astWriter) blank() {
+	m.pending = append(m.pending, "")
+}
+
+// `field` adds `field` on a line of its own, preceded by the comments
+// added since the previous field.
+func (m *astWriter) field(field ast.ObjectField) {
+	fodder := ast.Fodder{{Kind: ast.FodderLineEnd}}
+	for _, line := range m.pending {
+		if line == "" {
+			fodder[len(fodder)-1].Blanks++
+			continue
+		}
+		fodder = append(fodder, ast.FodderElement{
+			Kind: ast.FodderParagraph, Comment: []string{line},
+		})
+	}
+	fieldFodder := astFieldFodder(&field)
+	*fieldFodder = append(fodder, *fieldFodder...)
+
+	m.object.Fields = append(m.object.Fields, field)
+	m.commentLines = append(m.commentLines, len(m.pending))
+	m.pending = nil
+}
+
+// `method` adds the hidden method `name(params)`, which returns
+// `body`, e.g., `withName(name):: self + {name: name}`.
+func (m *astWriter) method(name string, params []string, body string) {
+	m.field(astMethod(name, m.function(params, m.code(body))))
+}
+
+// `hidden` adds the hidden field `name`, whose value is `body`.
+func (m *astWriter) hidden(name string, body string) {
+	m.field(astField(name, ast.ObjectFieldHidden, false, m.code(body)))
+}
+
+// `visible` adds the field `name`, whose value is `body`, and which is
+// output like any field of a Jsonnet object.
+func (m *astWriter) visible(name string, body string) {
+	m.field(astField(name, ast.ObjectFieldInherit, false, m.code(body)))
+}
+
+// `local` adds the local `name`, bound to `body`.
+func (m *astWriter) local(name string, body string) {
+	m.field(astLocal(name, m.code(body)))
+}
+
+// `namespace` adds the hidden field `name`, whose value is the object
+// `build` adds the members of, e.g., `mixin:: {...}`.
+func (m *astWriter) namespace(name string, build func(ns *astWriter)) {
+	m.field(astField(name, ast.ObjectFieldHidden, false, m.nested(build)))
+}
+
+// `extend` adds the hidden field `name`, whose value is `base` plus
+// the object `build` adds the members of, e.g., `apps:: apps + {...}`.
+func (m *astWriter) extend(name string, base ast.Node, build func(ns *astWriter)) {
+	m.field(astField(name, ast.ObjectFieldHidden, false, astPlus(base, m.nested(build))))
+}
+
+// `nested` returns the object `build` adds the members of, as the
+// value of a field of this object (e.g., of a local), which is written
+// one field per line, like this object.
+func (m *astWriter) nested(build func(ns *astWriter)) *ast.Object {
+	ns := &astWriter{
+		astBuilder: m.astBuilder,
+		object:     &ast.Object{},
+		namespaces: m.namespaces,
+	}
+	m.namespaces[ns.object] = ns
+	build(ns)
+	ns.flush()
+	return ns.object
+}
+
+// `flush` checks that every comment added precedes a field, and closes
+// the object on a line of its own.
+func (m *astWriter) flush() {
+	if len(m.pending) > 0 && m.err == nil {
+		m.err = fmt.Errorf("Generated comments that precede no field: %q", m.pending)
+	}
+	m.object.CloseFodder = ast.Fodder{{Kind: ast.FodderLineEnd}}
+	m.object.TrailingComma = len(m.object.Fields) > 0
+}
+
+// `source` marks the fields added from now on as generated from
+// `definition`, or from its property `property`, if it is not "",
+// until the function it returns is called, e.g.:
+//
+//	defer m.source(definition, "")()
+//
+// The comments already added to the next field are not part of it.
+func (m *astWriter) source(
+	definition kubespec.DefinitionName, property kubespec.PropertyName,
+) func() {
+	i := len(m.sources)
+	m.sources = append(m.sources, astSource{
+		start:      len(m.object.Fields),
+		end:        -1,
+		comments:   len(m.pending),
+		definition: definition,
+		property:   property,
+	})
+	return func() {
+		m.sources[i].end = len(m.object.Fields)
+	}
+}
+
+// `emitObject` writes the object `ns` built to `m`, and marks the
+// lines of its sources, and of those of the objects nested in it, in
+// `m`.
+func emitObject(m *indentWriter, ns *astWriter) {
+	ns.flush()
+	writeAST(m, ns, ns.object)
+}
+
+// `emitFile` writes a file of the library to `m`: the locals `file`
+// built, each followed by a semicolon, and then, after a blank line,
+// `value`, e.g., `k8s + {...}`, which may be (or extend) an object
+// built by `file.nested`.
+func emitFile(m *indentWriter, file *astWriter, value ast.Node) {
+	file.flush()
+	*astOpenFodder(value) = ast.Fodder{{Kind: ast.FodderLineEnd, Blanks: 1}}
+	body := value
+	for i := len(file.object.Fields) - 1; i >= 0; i-- {
+		field := file.object.Fields[i]
+		if field.Kind != ast.ObjectLocal {
+			if file.err == nil {
+				file.err = fmt.Errorf("Generated a field of a file that is not a local: %v", field.Expr1)
+			}
+			continue
+		}
+		body = &ast.Local{
+			NodeBase: ast.NodeBase{Fodder: field.Fodder1},
+			Binds:    ast.LocalBinds{{Variable: *field.Id, Body: field.Expr2, Fun: field.Method}},
+			Body:     body,
+		}
+	}
+	writeAST(m, file, body)
+}
+
+// `writeAST` writes `node`, the tree built by `ns` and its nested
+// writers, to `m` (see `emitAST`), and marks the lines of their sources
+// in `m`.
+func writeAST(m *indentWriter, ns *astWriter, node ast.Node) {
+	offset := m.lines
+	text, ok := emitAST(m, ns.astBuilder, node)
+	if !ok || !ns.hasSources() {
+		return
+	}
+
+	// The lines of the fields are those of the printed text, which are
+	// found by parsing it again, in parallel with the tree it was
+	// printed from.
+	parsed, _, err := formatter.SnippetToRawAST("<generated>", text)
+	if err != nil {
+		m.err = fmt.Errorf("Could not parse generated Jsonnet:\n%v", err)
+		return
+	}
+	ranges := []sourceRange{}
+	ns.sourceRanges(node, parsed, offset, &ranges)
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].startLine < ranges[j].startLine
+	})
+	m.sources = append(m.sources, ranges...)
+}
+
+// `hasSources` reports whether `m` or any writer nested in it marked
+// a source.
+func (m *astWriter) hasSources() bool {
+	for _, nested := range m.namespaces {
+		if len(nested.sources) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// `sourceRanges` adds the ranges of lines of the sources of the
+// objects built by writers of the tree to `ranges`, where `node` is a
+// node of the tree, `parsed` is the node it was printed as, and the
+// text it was printed in started after line `offset`.
+func (m *astWriter) sourceRanges(node, parsed ast.Node, offset int, ranges *[]sourceRange) {
+	switch node := node.(type) {
+	case *ast.Object:
+		parsed, ok := parsed.(*ast.Object)
+		writer := m.namespaces[node]
+		if !ok || writer == nil || len(parsed.Fields) != len(node.Fields) {
+			return
+		}
+		// `line` returns the first line of the comments of the field
+		// `i`, or that of the closing brace.
+		line := func(i int) int {
+			if i == len(parsed.Fields) {
+				return offset + parsed.LocRange.End.Line
+			}
+			return offset + parsed.Fields[i].LocRange.Begin.Line - writer.commentLines[i]
+		}
+		for _, source := range writer.sources {
+			if source.end < 0 {
+				continue
+			}
+			*ranges = append(*ranges, sourceRange{
+				startLine:  line(source.start) + source.comments,
+				endLine:    line(source.end) - 1,
+				definition: source.definition,
+				property:   source.property,
+			})
+		}
+		for i, field := range node.Fields {
+			m.sourceRanges(field.Expr2, parsed.Fields[i].Expr2, offset, ranges)
+		}
+	case *ast.Binary:
+		if parsed, ok := parsed.(*ast.Binary); ok {
+			m.sourceRanges(node.Right, parsed.Right, offset, ranges)
+		}
+	case *ast.Local:
+		if parsed, ok := parsed.(*ast.Local); ok && len(parsed.Binds) == len(node.Binds) {
+			for i, bind := range node.Binds {
+				m.sourceRanges(bind.Body, parsed.Binds[i].Body, offset, ranges)
+			}
+			m.sourceRanges(node.Body, parsed.Body, offset, ranges)
+		}
+	}
+}
+
+// `astOpenFodder` returns the fodder that precedes `node`, which is
+// that of its leftmost operand, e.g., of `k8s` in `k8s + {...}`.
+func astOpenFodder(node ast.Node) *ast.Fodder {
+	for {
+		switch inner := node.(type) {
+		case *ast.Binary:
+			node = inner.Left
+		case *ast.Apply:
+			node = inner.Target
+		case *ast.Index:
+			node = inner.Target
+		default:
+			return node.OpenFodder()
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package ksonnet
+
+import (
+	"fmt"
+	"sort"
+)
+
+// backend renders a fully-constructed `root` as a set of files in some
+// target language, keyed by file name (e.g., `k8s.libsonnet`).
+type backend func(root *root) (map[string][]byte, error)
+
+// Names of the backends in `backends`.
+const (
+	JsonnetBackend      = "jsonnet"
+	StarlarkBackend     = "starlark"
+	DhallBackend        = "dhall"
+	K8sLibsonnetBackend = "k8s-libsonnet"
+)
+
+// backends is the registry of every language `ksonnet-gen` can
+// generate code for. Backends are selected by name with
+// `Options.Backend`.
+var backends = map[string]backend{
+	JsonnetBackend:      emitJsonnet,
+	StarlarkBackend:     emitStarlark,
+	DhallBackend:        emitDhall,
+	K8sLibsonnetBackend: emitK8sLibsonnet,
+}
+
+// Backends returns the names of all registered backends, in sorted
+// order.
+func Backends() []string {
+	names := []string{}
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// `lookupBackend` returns the backend with some name, defaulting to
+// `JsonnetBackend` if the name is empty.
+func lookupBackend(name string) (backend, error) {
+	if name == "" {
+		name = JsonnetBackend
+	}
+
+	emit, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf(
+			"Unrecognized backend '%s'; expected one of: %v", name, Backends())
+	}
+	return emit, nil
+}
@@ -21,6 +21,11 @@ type indentWriter struct {
 	depth  int
 	err    error
 	buffer bytes.Buffer
+	// `lines` is the number of lines written so far, and `sources` the
+	// ranges of them that were written for definitions (see
+	// `emitObject`).
+	lines   int
+	sources []sourceRange
 }
 
 func newIndentWriter() *indentWriter {
@@ -37,10 +42,23 @@ func (m *indentWriter) writeLine(text string) {
 		return
 	}
 	prefix := strings.Repeat("  ", m.depth)
+	if text == "" {
+		// Don't leave trailing whitespace on empty lines.
+		prefix = ""
+	}
 	line := fmt.Sprintf("%s%s\n", prefix, text)
+	m.lines += strings.Count(line, "\n")
 	_, m.err = m.buffer.WriteString(line)
 }
 
+// `writeText` writes the lines of `text`, which do not end with a
+// newline.
+func (m *indentWriter) writeText(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		m.writeLine(line)
+	}
+}
+
 func (m *indentWriter) bytes() ([]byte, error) {
 	if m.err != nil {
 		return nil, m.err
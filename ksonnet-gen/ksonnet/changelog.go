@@ -0,0 +1,172 @@
+package ksonnet
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangelogFile is the name of the file `Options.PreviousNameMap`
+// causes to be emitted.
+const ChangelogFile = "CHANGELOG.md"
+
+// The version bumps `Changelog.SuggestedBump` can suggest, following
+// semantic versioning.
+const (
+	BumpMajor = "major"
+	BumpMinor = "minor"
+	BumpPatch = "patch"
+)
+
+// Changelog lists the functions and namespaces that were added to,
+// removed from, or changed in a library since a previous version of it,
+// as described by the name maps of both (see `NameMap`).
+type Changelog struct {
+	From    string       `json:"from"` // Kubernetes version of the previous library.
+	To      string       `json:"to"`   // Kubernetes version of the current library.
+	Added   []Name       `json:"added"`
+	Removed []Name       `json:"removed"`
+	Changed []NameChange `json:"changed"`
+}
+
+// NameChange is a function or namespace whose path is the same in both
+// libraries, but whose parameters or meaning changed.
+type NameChange struct {
+	Previous Name `json:"previous"`
+	Current  Name `json:"current"`
+}
+
+// Compatible reports whether code written against the previous
+// version of a name still works with the current one, i.e., whether it
+// stands for the same thing, and every parameter it gained has a
+// default value.
+func (c NameChange) Compatible() bool {
+	if c.Previous.Key() != c.Current.Key() || len(c.Current.Params) < len(c.Previous.Params) {
+		return false
+	}
+	for i, param := range c.Current.Params {
+		if i >= len(c.Previous.Params) {
+			if !strings.Contains(param, "=") {
+				return false
+			}
+			continue
+		}
+		if paramName(param) != paramName(c.Previous.Params[i]) ||
+			strings.Contains(c.Previous.Params[i], "=") && !strings.Contains(param, "=") {
+			return false
+		}
+	}
+	return true
+}
+
+func paramName(param string) string {
+	return strings.SplitN(param, "=", 2)[0]
+}
+
+// NewChangelog compares the name maps of two versions of a library,
+// matching names by path.
+func NewChangelog(previous, current *NameMap) *Changelog {
+	changelog := Changelog{
+		From:    previous.KubernetesVersion,
+		To:      current.KubernetesVersion,
+		Added:   []Name{},
+		Removed: []Name{},
+		Changed: []NameChange{},
+	}
+
+	previousNames := make(map[string]Name)
+	for _, name := range previous.Names {
+		previousNames[name.Path] = name
+	}
+	currentNames := make(map[string]Name)
+	for _, name := range current.Names {
+		currentNames[name.Path] = name
+
+		old, ok := previousNames[name.Path]
+		if !ok {
+			changelog.Added = append(changelog.Added, name)
+		} else if old.Key() != name.Key() ||
+			strings.Join(old.Params, ", ") != strings.Join(name.Params, ", ") {
+			changelog.Changed = append(changelog.Changed, NameChange{Previous: old, Current: name})
+		}
+	}
+	for _, name := range previous.Names {
+		if _, ok := currentNames[name.Path]; !ok {
+			changelog.Removed = append(changelog.Removed, name)
+		}
+	}
+
+	sort.Slice(changelog.Added, func(i, j int) bool {
+		return changelog.Added[i].Path < changelog.Added[j].Path
+	})
+	sort.Slice(changelog.Removed, func(i, j int) bool {
+		return changelog.Removed[i].Path < changelog.Removed[j].Path
+	})
+	sort.Slice(changelog.Changed, func(i, j int) bool {
+		return changelog.Changed[i].Current.Path < changelog.Changed[j].Current.Path
+	})
+	return &changelog
+}
+
+// SuggestedBump returns the semantic version bump that publishing the
+// current library as a new version of the previous one requires:
+// `BumpMajor` if anything was removed or changed incompatibly,
+// `BumpMinor` if anything was added or changed compatibly, and
+// `BumpPatch` otherwise.
+func (c *Changelog) SuggestedBump() string {
+	if len(c.Removed) > 0 {
+		return BumpMajor
+	}
+	for _, change := range c.Changed {
+		if !change.Compatible() {
+			return BumpMajor
+		}
+	}
+	if len(c.Added) > 0 || len(c.Changed) > 0 {
+		return BumpMinor
+	}
+	return BumpPatch
+}
+
+// Markdown renders the changelog as a Markdown document.
+func (c *Changelog) Markdown() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Changes from Kubernetes %s to %s\n\n", c.From, c.To)
+	fmt.Fprintf(&b, "Suggested version bump: **%s**.\n", c.SuggestedBump())
+
+	if len(c.Removed) > 0 {
+		fmt.Fprintf(&b, "\n## Removed (%d)\n\n", len(c.Removed))
+		for _, name := range c.Removed {
+			fmt.Fprintf(&b, "* `%s`\n", name.signature())
+		}
+	}
+	if len(c.Changed) > 0 {
+		fmt.Fprintf(&b, "\n## Changed (%d)\n\n", len(c.Changed))
+		for _, change := range c.Changed {
+			compatibility := "incompatible"
+			if change.Compatible() {
+				compatibility = "compatible"
+			}
+			fmt.Fprintf(&b, "* `%s` is now `%s` (%s)\n",
+				change.Previous.signature(), change.Current.signature(), compatibility)
+		}
+	}
+	if len(c.Added) > 0 {
+		fmt.Fprintf(&b, "\n## Added (%d)\n\n", len(c.Added))
+		for _, name := range c.Added {
+			fmt.Fprintf(&b, "* `%s`\n", name.signature())
+		}
+	}
+	return b.Bytes()
+}
+
+// `signature` returns the path of a name, followed by its parameters
+// if it is a function, e.g., `deployment.withReplicas(replicas)`.
+func (n Name) signature() string {
+	switch n.Role {
+	case NameRoleObject, NameRoleNamespace:
+		return n.Path
+	}
+	return fmt.Sprintf("%s(%s)", n.Path, strings.Join(n.Params, ", "))
+}
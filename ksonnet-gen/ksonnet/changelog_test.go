@@ -0,0 +1,75 @@
+package ksonnet
+
+import (
+	"strings"
+	"testing"
+)
+
+func changelogName(path, field, role string, params ...string) Name {
+	return Name{
+		Path: path, Group: "apps", Version: "v1beta1", Kind: "Deployment",
+		Field: field, Role: role, Params: params,
+	}
+}
+
+func TestChangelog(t *testing.T) {
+	previous := &NameMap{KubernetesVersion: "v1.6.0", Names: []Name{
+		changelogName("apps.v1beta1.deployment", "", NameRoleObject),
+		changelogName("apps.v1beta1.deployment.new", "new", NameRoleConstructor, "name"),
+		changelogName("apps.v1beta1.deployment.withPaused", "paused", NameRoleSetter, "paused"),
+	}}
+
+	tests := map[string]struct {
+		names    []Name
+		bump     string
+		expected string
+	}{
+		"unchanged": {
+			names: previous.Names,
+			bump:  BumpPatch,
+		},
+		"added": {
+			names: append(previous.Names[:3:3],
+				changelogName("apps.v1beta1.deployment.withReplicas", "replicas", NameRoleSetter, "replicas")),
+			bump:     BumpMinor,
+			expected: "## Added (1)\n\n* `apps.v1beta1.deployment.withReplicas(replicas)`\n",
+		},
+		"compatible": {
+			names: []Name{
+				previous.Names[0],
+				changelogName("apps.v1beta1.deployment.new", "new", NameRoleConstructor, "name", "replicas=1"),
+				previous.Names[2],
+			},
+			bump:     BumpMinor,
+			expected: "* `apps.v1beta1.deployment.new(name)` is now `apps.v1beta1.deployment.new(name, replicas=1)` (compatible)\n",
+		},
+		"incompatible": {
+			names: []Name{
+				previous.Names[0],
+				changelogName("apps.v1beta1.deployment.new", "new", NameRoleConstructor, "name", "replicas"),
+				previous.Names[2],
+			},
+			bump:     BumpMajor,
+			expected: "(incompatible)",
+		},
+		"removed": {
+			names:    previous.Names[:2],
+			bump:     BumpMajor,
+			expected: "## Removed (1)\n\n* `apps.v1beta1.deployment.withPaused(paused)`\n",
+		},
+	}
+	for name, test := range tests {
+		changelog := NewChangelog(previous, &NameMap{KubernetesVersion: "v1.7.0", Names: test.names})
+		if bump := changelog.SuggestedBump(); bump != test.bump {
+			t.Errorf("[%s] Expected bump '%s', got '%s'", name, test.bump, bump)
+		}
+
+		text := string(changelog.Markdown())
+		if !strings.HasPrefix(text, "# Changes from Kubernetes v1.6.0 to v1.7.0\n") {
+			t.Errorf("[%s] Unexpected title in:\n%s", name, text)
+		}
+		if !strings.Contains(text, test.expected) {
+			t.Errorf("[%s] Expected '%s' in:\n%s", name, test.expected, text)
+		}
+	}
+}
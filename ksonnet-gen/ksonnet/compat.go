@@ -0,0 +1,154 @@
+package ksonnet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-jsonnet/ast"
+)
+
+// CompatFile is the name of the file `Options.CompatShim` causes to be
+// emitted.
+const CompatFile = "compat.libsonnet"
+
+// `compatAlias` is a name of a previous version of the library that
+// stands for the same thing as a name of the current one (see
+// `Name.Key`), but whose path changed along with the rename rules of
+// `kubeversion`, e.g., `...mixin.spec.scaleIO`, which became
+// `...mixin.spec.scaleIo`.
+type compatAlias struct {
+	// `container` is the path, in the current library, of the object
+	// the alias is a field of.
+	container string
+	name      string // The last segment of the previous path, e.g., `scaleIO`.
+	target    string // e.g., `self.scaleIo`.
+}
+
+// `compatAliases` returns the aliases that map the names of `previous`
+// that `current` renamed to their new names, sorted by previous path.
+// A name that is still in `current` is left alone, and so is a name
+// whose path only changed because an enclosing namespace was renamed,
+// since the alias of the namespace covers it.
+func compatAliases(previous, current *NameMap) []compatAlias {
+	byKey := make(map[string]string)
+	currentPaths := make(map[string]bool)
+	for _, name := range current.Names {
+		byKey[name.Key()] = name.Path
+		currentPaths[name.Path] = true
+	}
+	renames := make(map[string]string)
+	for _, name := range previous.Names {
+		if path, ok := byKey[name.Key()]; ok && path != name.Path && !currentPaths[name.Path] {
+			renames[name.Path] = path
+		}
+	}
+
+	previousPaths := []string{}
+	for path := range renames {
+		previousPaths = append(previousPaths, path)
+	}
+	sort.Strings(previousPaths)
+
+	aliases := []compatAlias{}
+	for _, path := range previousPaths {
+		i := strings.LastIndex(path, ".")
+		alias := compatAlias{container: translatePath(path[:i], renames), name: path[i+1:]}
+		target := renames[path]
+		if target == alias.container+"."+alias.name {
+			continue
+		}
+		if relative := strings.TrimPrefix(target, alias.container+"."); relative != target && !strings.Contains(relative, ".") {
+			alias.target = "self." + relative
+		} else {
+			alias.target = "$." + target
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+// `translatePath` returns the path in the current library of the
+// previous `path`, given the previous paths that were renamed, and
+// their new paths.
+func translatePath(path string, renames map[string]string) string {
+	segments := strings.Split(path, ".")
+	translated := []string{}
+	for i, segment := range segments {
+		if renamed, ok := renames[strings.Join(segments[:i+1], ".")]; ok {
+			translated = strings.Split(renamed, ".")
+			continue
+		}
+		translated = append(translated, segment)
+	}
+	return strings.Join(translated, ".")
+}
+
+// `compatNamespace` is an object of the library the compat shim adds
+// aliases to, or that encloses one.
+type compatNamespace struct {
+	fields  map[string]*compatNamespace
+	aliases []compatAlias
+}
+
+// `emitCompatShim` returns the text of the compat shim, which extends
+// `k.libsonnet` with an alias for every name of the library described
+// by `previous` that the current library renamed (see
+// `compatAliases`), e.g.:
+//
+//	k + {
+//	  core+:: {
+//	    v1+:: {
+//	      persistentVolume+:: {
+//	        mixin+:: {
+//	          spec+:: {
+//	            scaleIO:: self.scaleIo,
+//	          },
+//	        },
+//	      },
+//	    },
+//	  },
+//	}
+//
+// so that code written against the previous library keeps working if
+// it imports `compat.libsonnet` instead of `k.libsonnet`.
+func (root *root) emitCompatShim(previous *NameMap) ([]byte, error) {
+	top := &compatNamespace{fields: make(map[string]*compatNamespace)}
+	for _, alias := range compatAliases(previous, root.nameMap()) {
+		ns := top
+		for _, segment := range strings.Split(alias.container, ".") {
+			if _, ok := ns.fields[segment]; !ok {
+				ns.fields[segment] = &compatNamespace{fields: make(map[string]*compatNamespace)}
+			}
+			ns = ns.fields[segment]
+		}
+		ns.aliases = append(ns.aliases, alias)
+	}
+
+	file := newASTWriter()
+	file.comment(fmt.Sprintf(
+		"Aliases of the names of the library generated from the Kubernetes %s",
+		previous.KubernetesVersion))
+	file.comment("spec that this library renamed, for code that imports this file")
+	file.comment("instead of k.libsonnet.")
+	file.local("k", fmt.Sprintf("import %q", kFile))
+
+	m := newIndentWriter()
+	root.emitHeader(m)
+	emitFile(m, file, astPlus(astVar("k"), file.nested(top.emit)))
+	return m.bytes()
+}
+
+func (ns *compatNamespace) emit(m *astWriter) {
+	for _, alias := range ns.aliases {
+		m.hidden(alias.name, alias.target)
+	}
+	names := []string{}
+	for name := range ns.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.field(astField(name, ast.ObjectFieldHidden, true, m.nested(ns.fields[name].emit)))
+	}
+}
@@ -0,0 +1,77 @@
+package ksonnet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompatAliases(t *testing.T) {
+	current := &NameMap{KubernetesVersion: "v1.7.0", Names: []Name{
+		changelogName("apps.v1beta1.deployment", "", NameRoleObject),
+		changelogName("apps.v1beta1.deployment.mixin.spec.scaleIo", "spec.scaleIo", NameRoleNamespace),
+		changelogName("apps.v1beta1.deployment.mixin.spec.scaleIo.withGateway", "spec.scaleIo.gateway", NameRoleSetter, "gateway"),
+		changelogName("apps.v1beta1.deployment.mixin.spec.scaleIo.withReadOnly", "spec.scaleIo.readOnly", NameRoleSetter, "readOnly"),
+		changelogName("apps.v1beta1.deployment.withPaused", "paused", NameRoleSetter, "paused"),
+	}}
+	previous := &NameMap{KubernetesVersion: "v1.6.0", Names: []Name{
+		changelogName("apps.v1beta1.deployment", "", NameRoleObject),
+		changelogName("apps.v1beta1.deployment.mixin.spec.scaleIO", "spec.scaleIo", NameRoleNamespace),
+		changelogName("apps.v1beta1.deployment.mixin.spec.scaleIO.withGateway", "spec.scaleIo.gateway", NameRoleSetter, "gateway"),
+		changelogName("apps.v1beta1.deployment.mixin.spec.scaleIO.withReadonly", "spec.scaleIo.readOnly", NameRoleSetter, "readOnly"),
+		changelogName("apps.v1beta1.deployment.withPaused", "paused", NameRoleSetter, "paused"),
+		changelogName("apps.v1beta1.deployment.withRemoved", "removed", NameRoleSetter, "removed"),
+	}}
+
+	// The namespace is aliased, which covers its setters, and only the
+	// setter that was renamed itself gets another alias, in the renamed
+	// namespace.
+	expected := []compatAlias{
+		{"apps.v1beta1.deployment.mixin.spec", "scaleIO", "self.scaleIo"},
+		{"apps.v1beta1.deployment.mixin.spec.scaleIo", "withReadonly", "self.withReadOnly"},
+	}
+	if aliases := compatAliases(previous, current); !reflect.DeepEqual(aliases, expected) {
+		t.Errorf("Expected aliases %v, got %v", expected, aliases)
+	}
+}
+
+func TestEmitCompatShim(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, customConstructorSpec), nil, nil, Options{NameMap: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	previous, err := ParseNameMap(files[NamesFile])
+	if err != nil {
+		t.Fatalf("Failed to parse name map:\n%v", err)
+	}
+	for i, name := range previous.Names {
+		previous.Names[i].Path = strings.Replace(name.Path, "withSelector", "withSelectors", 1)
+	}
+
+	files, _, err = EmitFiles(
+		parseSpec(t, customConstructorSpec), nil, nil, Options{PreviousNameMap: previous, CompatShim: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if !strings.Contains(string(files[CompatFile]), "withSelectors:: self.withSelector,") {
+		t.Errorf("Expected an alias of the renamed setter, got:\n%s", files[CompatFile])
+	}
+
+	// Code written against the previous library evaluates against the
+	// shim.
+	programs := map[string]string{
+		"selector": `local k = import "compat.libsonnet"; k.core.v1.service.mixin.spec.withSelectors({app: "web"})`,
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["selector"]; ok {
+		t.Fatalf("Failed to evaluate the shim:\n%v", err)
+	}
+	if !strings.Contains(outputs["selector"], `"app": "web"`) {
+		t.Errorf("Expected the selector, got:\n%s", outputs["selector"])
+	}
+
+	_, _, err = EmitFiles(parseSpec(t, customConstructorSpec), nil, nil, Options{CompatShim: true})
+	if err == nil || !strings.Contains(err.Error(), "requires the name map") {
+		t.Errorf("Expected error for a compat shim without a previous name map, got: %v", err)
+	}
+}
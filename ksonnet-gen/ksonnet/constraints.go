@@ -0,0 +1,271 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `regexMatchNative` is the native function the assertions of a
+// `pattern` call, if the Jsonnet VM evaluating the library has it
+// (e.g., kubecfg registers it). Jsonnet has no regular expressions of
+// its own, so without it, patterns are not checked.
+const regexMatchNative = "regexMatch"
+
+// `dateTimePattern` is the pattern of the `date-time` format, i.e., an
+// RFC 3339 timestamp (e.g., `2017-09-01T12:00:00Z`), which is checked
+// the same way as a `pattern`.
+const dateTimePattern = `^[0-9]{4}-[0-9]{2}-[0-9]{2}[Tt][0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?([Zz]|[+-][0-9]{2}:[0-9]{2})$`
+
+// `base64Alphabet` is the characters of the `byte` format, i.e.,
+// base64-encoded data.
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/="
+
+// `constraintAssertions` returns the Jsonnet `assert` expressions
+// (including their trailing `;`s) that check that the argument of a
+// setter, `paramName`, satisfies the validation keywords of the
+// property (e.g., `maxLength`), or "" if `Options.Strict` is not set,
+// or there's nothing to check. This way, invalid values fail when the
+// library is evaluated, rather than when the API server receives them.
+func (p *property) constraintAssertions(paramName jsonnet.FuncParam) string {
+	if !p.root().options.Strict {
+		return ""
+	}
+
+	c := p.constraints
+	param := string(paramName)
+	assertions := []string{}
+	assert := func(check, message string) {
+		assertions = append(assertions, fmt.Sprintf(
+			"assert %s : %s; ", check, jsonnetString(fmt.Sprintf("%s %s", p.name, message))))
+	}
+
+	if p.intOrString {
+		assert(
+			fmt.Sprintf("std.isNumber(%s) || std.isString(%s)", param, param),
+			"must be an integer or a string")
+		return strings.Join(assertions, "")
+	}
+	if p.schemaType == nil {
+		return ""
+	}
+
+	switch *p.schemaType {
+	case "string":
+		native := fmt.Sprintf("std.native(\"%s\")", regexMatchNative)
+		if c.Pattern != "" {
+			assert(
+				fmt.Sprintf("std.type(%s) != \"function\" || %s(%s, %s)",
+					native, native, jsonnetString(c.Pattern), param),
+				fmt.Sprintf("must match the pattern '%s'", c.Pattern))
+		}
+		switch c.Format {
+		case "byte":
+			assert(
+				fmt.Sprintf("std.length(%s) %% 4 == 0 && std.all([std.length(std.findSubstr(c, %s)) > 0 for c in std.stringChars(%s)])",
+					param, jsonnetString(base64Alphabet), param),
+				"must be base64-encoded")
+		case "date-time":
+			assert(
+				fmt.Sprintf("std.type(%s) != \"function\" || %s(%s, %s)",
+					native, native, jsonnetString(dateTimePattern), param),
+				"must be an RFC 3339 timestamp (e.g., '2017-09-01T12:00:00Z')")
+		}
+		if c.MinLength != nil {
+			assert(
+				fmt.Sprintf("std.length(%s) >= %d", param, *c.MinLength),
+				fmt.Sprintf("must be at least %d characters long", *c.MinLength))
+		}
+		if c.MaxLength != nil {
+			assert(
+				fmt.Sprintf("std.length(%s) <= %d", param, *c.MaxLength),
+				fmt.Sprintf("must be at most %d characters long", *c.MaxLength))
+		}
+	case "integer", "number":
+		switch c.Format {
+		case "int32":
+			assert(
+				fmt.Sprintf("%s == std.floor(%s) && %s >= -2147483648 && %s <= 2147483647",
+					param, param, param, param),
+				"must be a 32-bit integer")
+		case "int64":
+			assert(
+				fmt.Sprintf("%s == std.floor(%s)", param, param),
+				"must be an integer")
+		}
+		if c.Minimum != nil {
+			minimum := formatNumber(*c.Minimum)
+			if c.ExclusiveMinimum {
+				assert(
+					fmt.Sprintf("%s > %s", param, minimum),
+					fmt.Sprintf("must be greater than %s", minimum))
+			} else {
+				assert(
+					fmt.Sprintf("%s >= %s", param, minimum),
+					fmt.Sprintf("must be at least %s", minimum))
+			}
+		}
+		if c.Maximum != nil {
+			maximum := formatNumber(*c.Maximum)
+			if c.ExclusiveMaximum {
+				assert(
+					fmt.Sprintf("%s < %s", param, maximum),
+					fmt.Sprintf("must be less than %s", maximum))
+			} else {
+				assert(
+					fmt.Sprintf("%s <= %s", param, maximum),
+					fmt.Sprintf("must be at most %s", maximum))
+			}
+		}
+	case "array":
+		// Array setters also accept a single element.
+		length := fmt.Sprintf(
+			"std.length(if std.type(%s) == \"array\" then %s else [%s])", param, param, param)
+		if c.MinItems != nil {
+			assert(
+				fmt.Sprintf("%s >= %d", length, *c.MinItems),
+				fmt.Sprintf("must have at least %d items", *c.MinItems))
+		}
+		if c.MaxItems != nil {
+			assert(
+				fmt.Sprintf("%s <= %d", length, *c.MaxItems),
+				fmt.Sprintf("must have at most %d items", *c.MaxItems))
+		}
+		// `std.set` can only sort scalars, so only arrays of them are
+		// checked for duplicates.
+		if c.UniqueItems && p.itemTypes.Type != nil {
+			switch *p.itemTypes.Type {
+			case "integer", "number", "string", "boolean":
+				items := fmt.Sprintf(
+					"(if std.type(%s) == \"array\" then %s else [%s])", param, param, param)
+				assert(
+					fmt.Sprintf("std.length(std.set(%s)) == std.length(%s)", items, items),
+					"must not have duplicate items")
+			}
+		}
+	}
+	if len(p.enum) > 0 {
+		assert(
+			fmt.Sprintf("std.count(%s, %s) > 0", jsonnetValue(p.enum), param),
+			fmt.Sprintf("must be one of %s", strings.Replace(jsonnetValue(p.enum), "\"", "'", -1)))
+	}
+	return strings.Join(assertions, "")
+}
+
+// `setterAssertions` returns the assertions of the setter of `p`: the
+// type of its argument is checked first, so that its constraints are
+// only checked against values they apply to.
+func (p *property) setterAssertions(paramName jsonnet.FuncParam) string {
+	return p.typeAssertions(paramName) + p.constraintAssertions(paramName)
+}
+
+// `typeAssertions` returns the Jsonnet `assert` expressions (including
+// their trailing `;`s) that check that the argument of a setter or
+// mixin, `paramName`, has the type of the property (e.g., that the
+// argument of `withReplicas` is an integer), or "" if
+// `Options.TypeAssertions` is not set, or the property's type is not
+// known. Since array setters and mixins also accept a single element,
+// each element is checked against the type of the items.
+func (p *property) typeAssertions(paramName jsonnet.FuncParam) string {
+	if !p.root().options.TypeAssertions {
+		return ""
+	}
+	// `Options.Strict` checks these already.
+	if p.intOrString && p.root().options.Strict {
+		return ""
+	}
+
+	param := string(paramName)
+	if p.schemaType != nil && *p.schemaType == "array" {
+		check, description := p.root().typeCheck(p.itemTypes.Ref, p.itemTypes.Type, "x")
+		if check == "" {
+			return ""
+		}
+		return fmt.Sprintf(
+			"assert std.all([%s for x in (if std.type(%s) == \"array\" then %s else [%s])]) : %s; ",
+			check, param, param, param,
+			jsonnetString(fmt.Sprintf("%s must be %s, or an array of them", p.name, description)))
+	}
+
+	ref := p.ref
+	if p.intOrString {
+		// The property may be marked `int-or-string` rather than `$ref`
+		// `IntOrString`.
+		intOrString := kubespec.ObjectRef(intOrStringRef)
+		ref = &intOrString
+	}
+	check, description := p.root().typeCheck(ref, p.schemaType, param)
+	if check == "" {
+		return ""
+	}
+	return fmt.Sprintf(
+		"assert %s : %s + std.type(%s); ",
+		check, jsonnetString(fmt.Sprintf("%s must be %s, got ", p.name, description)), param)
+}
+
+// `typeCheck` returns a Jsonnet expression that checks that `value`
+// has the type `ref` or `schemaType` describes, along with a
+// description of the type (e.g., `an integer`), or "" if the type is
+// not known, or is not checked (e.g., objects that are serialized as
+// strings, such as `Time`).
+func (root *root) typeCheck(
+	ref *kubespec.ObjectRef, schemaType *kubespec.SchemaType, value string,
+) (string, string) {
+	switch {
+	case isIntOrStringRef(ref):
+		return fmt.Sprintf("std.isNumber(%s) || std.isString(%s)", value, value),
+			"an integer or a string"
+	case isMixinRef(ref):
+		if len(root.getAPIObject(root.parseRef(ref)).emittedProperties) == 0 {
+			return "", ""
+		}
+		return fmt.Sprintf("std.isObject(%s)", value), "an object"
+	case schemaType == nil:
+		return "", ""
+	}
+
+	switch *schemaType {
+	case "string":
+		return fmt.Sprintf("std.isString(%s)", value), "a string"
+	case "integer":
+		return fmt.Sprintf("std.isNumber(%s) && %s == std.floor(%s)", value, value, value),
+			"an integer"
+	case "number":
+		return fmt.Sprintf("std.isNumber(%s)", value), "a number"
+	case "boolean":
+		return fmt.Sprintf("std.isBoolean(%s)", value), "a boolean"
+	case "object":
+		return fmt.Sprintf("std.isObject(%s)", value), "an object"
+	case "array":
+		return fmt.Sprintf("std.isArray(%s)", value), "an array"
+	}
+	return "", ""
+}
+
+// `jsonnetString` quotes `s` as a Jsonnet string literal.
+func jsonnetString(s string) string {
+	quoted, err := json.Marshal(s)
+	if err != nil {
+		log.Panicf("Could not quote string '%s':\n%v", s, err)
+	}
+	return string(quoted)
+}
+
+// `jsonnetValue` renders `value`, which was deserialized from JSON,
+// as a Jsonnet literal.
+func jsonnetValue(value interface{}) string {
+	text, err := json.Marshal(value)
+	if err != nil {
+		log.Panicf("Could not serialize value '%v':\n%v", value, err)
+	}
+	return string(text)
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
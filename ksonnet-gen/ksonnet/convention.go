@@ -0,0 +1,38 @@
+package ksonnet
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// NamingConventionNames returns the names of the naming conventions
+// `Options.NamingConvention` accepts, sorted.
+func NamingConventionNames() []string {
+	names := []string{}
+	for name := range jsonnet.NamingConventions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckNamingConvention returns an error if `name` is not one of
+// `NamingConventionNames`, or "".
+func CheckNamingConvention(name string) error {
+	if _, ok := jsonnet.NamingConventions[name]; !ok && name != "" {
+		return fmt.Errorf(
+			"Unrecognized naming convention '%s'; expected one of: %v", name, NamingConventionNames())
+	}
+	return nil
+}
+
+// `namingConvention` returns the naming convention `opts` asks for,
+// which is `jsonnet.CamelCase` if it asks for none.
+func namingConvention(opts Options) jsonnet.NamingConvention {
+	if nc, ok := jsonnet.NamingConventions[opts.NamingConvention]; ok {
+		return nc
+	}
+	return jsonnet.CamelCase
+}
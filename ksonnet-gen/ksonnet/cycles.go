@@ -0,0 +1,39 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// `emitAsRecursiveRef` emits a property that `$ref`s an API object
+// that is already being emitted further up (e.g., `not` in
+// `JSONSchemaProps`, which refers to `JSONSchemaProps` itself), as a
+// setter and a mixin of a plain object, rather than as a mixin
+// namespace, which would nest the object in itself forever. `wrap`
+// places the object literal that sets the property where the property
+// is, e.g., in a call to the mixin of its parent.
+func (p *property) emitAsRecursiveRef(
+	m *astWriter, ao *apiObject, wrap func(string) string,
+) {
+	k8sVersion := p.root().spec.Info.Version
+	naming := p.root().naming
+	id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name)
+	paramName := jsonnet.RewriteAsFuncParam(naming, k8sVersion, p.name)
+	fieldName := jsonnet.RewriteAsFieldKey(p.name)
+
+	comment := fmt.Sprintf(
+		"`%s` is a `%s`, which is part of a reference cycle, so it is set as a whole, rather than with a `mixin` namespace.",
+		p.name, ao.name)
+	m.comment(comment)
+	p.emitDocsonnetFunction(m, id.ToSetterID(naming), paramName)
+	m.method(
+		string(id.ToSetterID(naming)), []string{string(paramName)},
+		fmt.Sprintf("%sself + %s", p.typeAssertions(paramName), wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName))))
+	p.comments.emit(m)
+	m.comment(comment)
+	p.emitDocsonnetFunction(m, id.ToMixinID(naming), paramName)
+	m.method(
+		string(id.ToMixinID(naming)), []string{string(paramName)},
+		fmt.Sprintf("%sself + %s", p.typeAssertions(paramName), wrap(fmt.Sprintf("{%s+: %s}", fieldName, paramName))))
+}
@@ -0,0 +1,47 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubeversion"
+)
+
+// `paramWithDefault` returns the parameter of the setter of `p`, with
+// the `default` of the property as its default value, if it has one,
+// e.g., `replicas=1`.
+func (p *property) paramWithDefault(paramName jsonnet.FuncParam) string {
+	if p.defaultValue == nil {
+		return string(paramName)
+	}
+	return fmt.Sprintf("%s=%s", paramName, jsonnetValue(p.defaultValue))
+}
+
+// `defaultParams` returns optional parameters of a constructor for the
+// properties of the object that have a `default` in the spec, and are
+// not in `exclude`, so that the objects the constructor creates have
+// the same values as the API server would give them. Properties that
+// `$ref` other API objects are set through their `mixin` namespace
+// instead.
+func (ao *apiObject) defaultParams(
+	exclude map[kubespec.PropertyName]bool,
+) []kubeversion.CustomConstructorParam {
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	params := []kubeversion.CustomConstructorParam{}
+	for _, pm := range ao.emittedProperties {
+		if exclude[pm.name] || pm.kind != method || pm.defaultValue == nil ||
+			isMixinRef(pm.ref) || isSpecialProperty(pm.name) {
+			continue
+		}
+		relativePath := string(pm.name)
+		defaultValue := jsonnetValue(pm.defaultValue)
+		params = append(params, kubeversion.CustomConstructorParam{
+			ID:           string(jsonnet.RewriteAsFuncParam(naming, k8sVersion, pm.name)),
+			DefaultValue: &defaultValue,
+			RelativePath: &relativePath,
+		})
+	}
+	return params
+}
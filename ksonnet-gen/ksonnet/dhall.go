@@ -0,0 +1,320 @@
+package ksonnet
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+const dhallFile = "k8s.dhall"
+
+// `emitDhall` is a backend that emits a Dhall package, `k8s.dhall`,
+// for users who prefer a typed configuration language.
+//
+// Every API object becomes a schema, i.e., a record with a `Type` (a
+// record type in which every property is `Optional`) and a `default`
+// (a value of that type in which every property is `None`, and
+// `apiVersion` and `kind` are filled in for top-level objects). These
+// are exported by group and version, so that objects can be built
+// with Dhall's record completion operator, e.g.:
+//
+//	let k8s = ./k8s.dhall
+//	in  k8s.apps.v1beta1.Deployment::{
+//	      , metadata = Some k8s.meta.v1.ObjectMeta::{ name = Some "nginx" }
+//	      }
+func emitDhall(root *root) (map[string][]byte, error) {
+	m := newIndentWriter()
+
+	root.emitCommentHeader(m, "--")
+	m.writeLine("let IntOrString = < Int : Integer | String : Text >")
+
+	// Dhall doesn't allow forward references, so emit every object
+	// after the objects it refers to.
+	objects, cyclic := root.dhallOrder()
+	for _, object := range objects {
+		m.writeLine("")
+		object.emitDhall(m, cyclic)
+	}
+
+	m.writeLine("")
+	m.writeLine("in  { IntOrString = IntOrString")
+	m.indent()
+	m.indent()
+	// A group's objects may be split between `groups` and
+	// `hiddenGroups`, but must be exported as a single record.
+	seen := make(map[kubespec.GroupName]bool)
+	groupNames := []string{}
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for groupName := range groups {
+			if !seen[groupName] {
+				seen[groupName] = true
+				groupNames = append(groupNames, string(groupName))
+			}
+		}
+	}
+	sort.Strings(groupNames)
+	for _, groupName := range groupNames {
+		root.emitDhallGroup(m, kubespec.GroupName(groupName))
+	}
+	m.writeLine("}")
+	m.dedent()
+	m.dedent()
+
+	k8sBytes, err := m.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{dhallFile: k8sBytes}, nil
+}
+
+// `emitDhallGroup` emits the record exporting the schemas of every
+// object of some group, grouped by version.
+func (root *root) emitDhallGroup(m *indentWriter, groupName kubespec.GroupName) {
+	versions := make(map[kubespec.VersionString]apiObjectSlice)
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		if group, ok := groups[groupName]; ok {
+			for version, versionedAPI := range group.versionedAPIs {
+				versions[version] = append(
+					versions[version], versionedAPI.apiObjects.toSortedSlice()...)
+			}
+		}
+	}
+
+	m.writeLine(fmt.Sprintf(", %s =", dhallLabel(string(groupName))))
+	m.indent()
+	versionNames := []kubespec.VersionString{}
+	for version := range versions {
+		versionNames = append(versionNames, version)
+	}
+	kubespec.SortVersions(versionNames)
+	for i, version := range versionNames {
+		m.writeLine(fmt.Sprintf("%s %s =", dhallSeparator(i == 0, "{"), dhallLabel(string(version))))
+
+		m.indent()
+		objects := versions[version]
+		sort.Slice(objects, func(i, j int) bool {
+			return objects[i].name < objects[j].name
+		})
+		for i, object := range objects {
+			m.writeLine(fmt.Sprintf(
+				"%s %s = %s",
+				dhallSeparator(i == 0, "{"), dhallLabel(string(object.name)), object.dhallName()))
+		}
+		m.writeLine("}")
+		m.dedent()
+	}
+	m.writeLine("}")
+	m.dedent()
+}
+
+// `dhallOrder` returns every API object in `root`, sorted so that
+// each object comes after every object its properties refer to. The
+// properties that would make this impossible (because they are part
+// of a reference cycle) are returned separately.
+func (root *root) dhallOrder() (apiObjectSlice, map[*property]bool) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*apiObject]int)
+	cyclic := make(map[*property]bool)
+	ordered := apiObjectSlice{}
+
+	var visit func(ao *apiObject)
+	visit = func(ao *apiObject) {
+		state[ao] = visiting
+		for _, pm := range ao.emittedProperties {
+			ref := pm.dhallRef()
+			if pm.kind == typeAlias || ref == nil {
+				continue
+			}
+
+			switch state[ref] {
+			case unvisited:
+				visit(ref)
+			case visiting:
+				ao.root().report.warnf(
+					ao.parsedName.Unparse(),
+					"property '%s' is part of a reference cycle, and has type 'Text' in Dhall",
+					pm.name)
+				cyclic[pm] = true
+			}
+		}
+		state[ao] = visited
+		ordered = append(ordered, ao)
+	}
+
+	for _, ao := range root.sortedAPIObjects() {
+		if state[ao] == unvisited {
+			visit(ao)
+		}
+	}
+	return ordered, cyclic
+}
+
+func (ao *apiObject) emitDhall(m *indentWriter, cyclic map[*property]bool) {
+	for _, comment := range ao.comments {
+		if comment != "" {
+			m.writeLine("-- " + comment)
+		}
+	}
+	m.writeLine(fmt.Sprintf("let %s =", ao.dhallName()))
+	m.indent()
+
+	types := []string{}
+	defaults := []string{}
+	if ao.isTopLevel {
+		types = append(types, "apiVersion : Text", "kind : Text")
+		defaults = append(
+			defaults,
+			fmt.Sprintf("apiVersion = %s", dhallText(ao.parent.apiVersion())),
+			fmt.Sprintf("kind = %s", dhallText(string(ao.name))))
+	}
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == typeAlias || (ao.isTopLevel && isSpecialProperty(pm.name)) {
+			continue
+		}
+
+		label := dhallLabel(string(pm.name))
+		propType := "Text"
+		if !cyclic[pm] {
+			propType = pm.dhallType()
+		}
+		types = append(types, fmt.Sprintf("%s : Optional %s", label, propType))
+		defaults = append(defaults, fmt.Sprintf("%s = None %s", label, propType))
+	}
+
+	m.writeLine("{ Type =")
+	m.indent()
+	emitDhallRecord(m, types, "{}")
+	m.dedent()
+	m.writeLine(", default =")
+	m.indent()
+	emitDhallRecord(m, defaults, "{=}")
+	m.dedent()
+	m.writeLine("}")
+
+	m.dedent()
+}
+
+func emitDhallRecord(m *indentWriter, fields []string, empty string) {
+	if len(fields) == 0 {
+		m.writeLine(empty)
+		return
+	}
+	for i, field := range fields {
+		m.writeLine(fmt.Sprintf("%s %s", dhallSeparator(i == 0, "{"), field))
+	}
+	m.writeLine("}")
+}
+
+func dhallSeparator(first bool, open string) string {
+	if first {
+		return open
+	}
+	return ","
+}
+
+// `dhallName` is the name of the `let` binding for the schema of an
+// API object, e.g., `apps_v1beta1_Deployment`.
+func (ao *apiObject) dhallName() string {
+	return nonIdentifierChars.ReplaceAllString(fmt.Sprintf(
+		"%s_%s_%s", ao.parent.parent.name, ao.parent.version, ao.name), "_")
+}
+
+// `dhallRef` returns the API object that a property (or, for arrays,
+// its elements) refers to, or nil if it is not a reference to an
+// object with properties.
+func (p *property) dhallRef() *apiObject {
+	ref := p.ref
+	if p.schemaType != nil && *p.schemaType == "array" {
+		ref = p.itemTypes.Ref
+	}
+	if !isMixinRef(ref) {
+		return nil
+	}
+
+	ao := p.root().getAPIObject(p.root().parseRef(ref))
+	if len(ao.emittedProperties) == 0 {
+		// E.g., `Time` and `Quantity`, which are serialized as strings.
+		return nil
+	}
+	return ao
+}
+
+// `dhallType` returns the Dhall type of a property, e.g., `Text`, or
+// `List core_v1_Container.Type`.
+func (p *property) dhallType() string {
+	if p.ref != nil {
+		return dhallTypeOf(p.root(), p.ref, nil)
+	}
+	if p.schemaType != nil && *p.schemaType == "array" {
+		return fmt.Sprintf("(List %s)", dhallTypeOf(p.root(), p.itemTypes.Ref, p.itemTypes.Type))
+	}
+	return dhallTypeOf(p.root(), nil, p.schemaType)
+}
+
+func dhallTypeOf(
+	root *root, ref *kubespec.ObjectRef, schemaType *kubespec.SchemaType,
+) string {
+	if ref != nil {
+		if !isMixinRef(ref) {
+			return "IntOrString"
+		}
+		ao := root.getAPIObject(root.parseRef(ref))
+		if len(ao.emittedProperties) == 0 {
+			return "Text"
+		}
+		return fmt.Sprintf("%s.Type", ao.dhallName())
+	}
+
+	if schemaType == nil {
+		return "Text"
+	}
+	switch *schemaType {
+	case "integer":
+		return "Integer"
+	case "number":
+		return "Double"
+	case "boolean":
+		return "Bool"
+	case "object":
+		return "(List { mapKey : Text, mapValue : Text })"
+	case "array":
+		return "(List Text)"
+	default:
+		return "Text"
+	}
+}
+
+var dhallKeywords = map[string]bool{
+	"if": true, "then": true, "else": true, "let": true, "in": true,
+	"as": true, "using": true, "merge": true, "missing": true,
+	"Infinity": true, "NaN": true, "Some": true, "toMap": true,
+	"assert": true, "forall": true, "with": true, "None": true,
+	"Type": true, "Kind": true, "Sort": true, "True": true, "False": true,
+	"Bool": true, "Natural": true, "Integer": true, "Double": true,
+	"Text": true, "List": true, "Optional": true,
+}
+
+var dhallSimpleLabel = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_/-]*$`)
+
+// `dhallLabel` quotes a field name with backticks if it is a Dhall
+// keyword or builtin, or is not a valid Dhall identifier.
+func dhallLabel(name string) string {
+	if dhallKeywords[name] || !dhallSimpleLabel.MatchString(name) {
+		return fmt.Sprintf("`%s`", name)
+	}
+	return name
+}
+
+func dhallText(text string) string {
+	text = strings.Replace(text, "\\", "\\\\", -1)
+	text = strings.Replace(text, "\"", "\\\"", -1)
+	return fmt.Sprintf("\"%s\"", strings.Replace(text, "$", "\\u0024", -1))
+}
@@ -0,0 +1,225 @@
+package ksonnet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-jsonnet/formatter"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// Differential tests check that two backends that emit Jsonnet (e.g.,
+// the `jsonnet` backend, and one that prints the same AST differently)
+// produce libraries that behave identically, by evaluating the same probes
+// against both with go-jsonnet and comparing the results.
+//
+// By default they run against the small spec below. To run them
+// against a full Kubernetes spec, set `KSONNET_GEN_DIFF_SPEC` to the
+// path of its `swagger.json`.
+
+// `differentialBackends` lists the backends that must be
+// indistinguishable from `JsonnetBackend`. Each must be registered
+// (see `registerDifferentialBackends`).
+var differentialBackends = []string{reformattedBackend}
+
+// `reformattedBackend` is the `jsonnet` backend, whose output is parsed
+// back into a go-jsonnet AST, and printed again by the go-jsonnet
+// formatter, rather than by the printer of `emitFile`.
+const reformattedBackend = "jsonnet-reformatted"
+
+// `registerDifferentialBackends` registers the backends that only exist
+// to be compared with `JsonnetBackend`, and returns a function that
+// unregisters them.
+func registerDifferentialBackends() func() {
+	backends[reformattedBackend] = func(root *root) (map[string][]byte, error) {
+		files, err := emitJsonnet(root)
+		if err != nil {
+			return nil, err
+		}
+		if err := format(files, formatter.DefaultOptions()); err != nil {
+			return nil, err
+		}
+		return files, nil
+	}
+	return func() { delete(backends, reformattedBackend) }
+}
+
+// `differentialDepth` bounds how deep the structure probe descends into
+// the library, since type aliases make it (finitely, but
+// exponentially) recursive.
+const differentialDepth = 7
+
+// `structureProbe` describes the shape of the library: every field,
+// hidden (suffixed with `::`) or not, down to `differentialDepth`, with
+// functions replaced by their arity, which is all we can compare.
+const structureProbe = `
+local k8s = import "k8s.libsonnet";
+local describe(x, depth) =
+  if std.isFunction(x) then "function/%%d" %% std.length(x)
+  else if std.isObject(x) then
+    if depth == 0 then "object"
+    else {
+      [if std.objectHas(x, f) then f else f + "::"]: describe(x[f], depth - 1)
+      for f in std.objectFieldsAll(x)
+    }
+  else x;
+describe(k8s, %d)
+`
+
+var differentialSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Widget": {
+      "description": "Widget is a test object.",
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec": {
+      "properties": {
+        "replicas": {"type": "integer"},
+        "paused": {"type": "boolean"},
+        "ports": {"type": "array", "items": {"type": "integer"}},
+        "selector": {"type": "object"},
+        "error": {"type": "string"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "properties": {
+        "name": {"type": "string"},
+        "labels": {"type": "object"}
+      }
+    }
+  }
+}`
+
+func TestDifferentialBackends(t *testing.T) {
+	spec := differentialTestSpec(t)
+	defer registerDifferentialBackends()()
+	for _, candidate := range differentialBackends {
+		if _, ok := backends[candidate]; !ok {
+			t.Fatalf("Backend '%s' is not registered", candidate)
+		}
+		for name, opts := range differentialOptions {
+			if err := diffBackends(spec, JsonnetBackend, candidate, opts); err != nil {
+				t.Errorf("[%s] Backend '%s' differs from '%s':\n%v",
+					name, candidate, JsonnetBackend, err)
+			}
+		}
+	}
+}
+
+// `TestDifferentialHarness` checks the harness itself: a backend must
+// be indistinguishable from itself, but not from a broken copy.
+func TestDifferentialHarness(t *testing.T) {
+	spec := parseSpec(t, differentialSpec)
+	backends["jsonnet-broken"] = func(root *root) (map[string][]byte, error) {
+		files, err := emitJsonnet(root)
+		if err != nil {
+			return nil, err
+		}
+		files[k8sFile] = []byte(strings.Replace(
+			string(files[k8sFile]), "{replicas: replicas}", "{replicas: replicas + 1}", -1))
+		return files, nil
+	}
+	defer delete(backends, "jsonnet-broken")
+
+	if err := diffBackends(spec, JsonnetBackend, JsonnetBackend, Options{}); err != nil {
+		t.Errorf("Expected backend to be indistinguishable from itself:\n%v", err)
+	}
+	if err := diffBackends(spec, JsonnetBackend, "jsonnet-broken", Options{}); err == nil {
+		t.Errorf("Expected broken backend to differ")
+	}
+}
+
+var differentialOptions = map[string]Options{
+	"default":  {},
+	"optional": {ConstructorsTakeName: true, FlattenDepth: 3, KustomizeHelpers: true},
+}
+
+// `diffBackends` emits a library from `spec` with two backends, and
+// returns an error describing the first probe whose result differs.
+func diffBackends(
+	spec *kubespec.APISpec, reference, candidate string, opts Options,
+) error {
+	probes, err := differentialProbes(spec, opts)
+	if err != nil {
+		return err
+	}
+
+	results := make(map[string]map[string]string)
+	for _, backend := range []string{reference, candidate} {
+		opts.Backend = backend
+		files, _, err := EmitFiles(spec, nil, nil, opts)
+		if err != nil {
+			return fmt.Errorf("Backend '%s' failed to emit:\n%v", backend, err)
+		}
+
+		results[backend] = evaluateProbes(files, probes)
+	}
+
+	for name := range probes {
+		if expected, actual := results[reference][name], results[candidate][name]; expected != actual {
+			return fmt.Errorf(
+				"Probe '%s' evaluated to:\n%s\nwith backend '%s', but to:\n%s\nwith backend '%s'",
+				name, expected, reference, actual, candidate)
+		}
+	}
+	return nil
+}
+
+// `differentialProbes` returns the programs to evaluate against each
+// library: the structure probe, and the probes `Options.Verify` uses.
+func differentialProbes(
+	spec *kubespec.APISpec, opts Options,
+) (map[string]string, error) {
+	probes := map[string]string{
+		"structure": fmt.Sprintf(structureProbe, differentialDepth),
+	}
+
+	root, err := newRoot(spec, nil, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	root.filterBlacklisted()
+	for _, probe := range root.probes() {
+		probes[probe.name] = probe.text()
+	}
+	return probes, nil
+}
+
+// `evaluateProbes` evaluates every probe against the emitted files,
+// and returns the output of each, or the error it failed with, since
+// backends should also fail in the same way.
+func evaluateProbes(
+	files map[string][]byte, probes map[string]string,
+) map[string]string {
+	outputs, errs := evaluate(files, probes)
+	for name, err := range errs {
+		// Only the message is comparable; the stack trace refers to
+		// lines of the emitted code.
+		outputs[name] = firstLine(err.Error())
+	}
+	return outputs
+}
+
+func differentialTestSpec(t *testing.T) *kubespec.APISpec {
+	path := os.Getenv("KSONNET_GEN_DIFF_SPEC")
+	if path == "" {
+		return parseSpec(t, differentialSpec)
+	}
+
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read file at '%s':\n%v", path, err)
+	}
+	return parseSpec(t, string(text))
+}
@@ -0,0 +1,268 @@
+package ksonnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// DocsDir is the directory `Options.Docs` causes the reference docs
+// to be emitted to.
+const DocsDir = "docs"
+
+// FunctionIndexFile is the name of the file `Options.FunctionIndex`
+// causes to be emitted.
+const FunctionIndexFile = "functions.json"
+
+// FunctionIndex lists every function of a generated library, with its
+// documentation, for editor tooling (e.g., completion and hover docs).
+type FunctionIndex struct {
+	KubernetesVersion string            `json:"kubernetesVersion"`
+	Functions         []IndexedFunction `json:"functions"`
+}
+
+// IndexedFunction is a single function of a `FunctionIndex`.
+type IndexedFunction struct {
+	Path   string   `json:"path"`   // e.g., `apps.v1beta1.deployment.mixin.spec.withReplicas`.
+	Role   string   `json:"role"`   // one of `NameRoleConstructor`, `NameRoleSetter`, or `NameRoleMixin`.
+	Params []string `json:"params"` // e.g., `replicas`, or `podLabels={app: name}`.
+	// Type is the type of the property a setter or mixin is for (e.g.,
+	// `array of Container`); empty for constructors.
+	Type string `json:"type,omitempty"`
+	Doc  string `json:"doc"`
+}
+
+// `kindDoc` documents a top-level object of the library, e.g.,
+// `apps.v1beta1.deployment`, and every function it has, at any depth.
+type kindDoc struct {
+	object       Name
+	description  comments
+	constructors []functionDoc
+	setters      []functionDoc
+	mixins       []functionDoc
+}
+
+// `functionDoc` documents a single function of a `kindDoc`.
+type functionDoc struct {
+	name Name
+	// `typeName` is the type of the property a setter or mixin is for
+	// (e.g., `array of Container`); empty for constructors.
+	typeName    string
+	description comments
+}
+
+// `docs` walks the library the same way `nameMap` does, and returns
+// the documentation of every top-level object, sorted by path, along
+// with the descriptions the spec has for them and their properties.
+func (root *root) docs() []*kindDoc {
+	type objectDoc struct {
+		ao   *apiObject
+		kind *kindDoc
+	}
+	objects := make(map[string]*objectDoc)
+	for _, group := range root.groups.toSortedSlice() {
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				if ao.isTopLevel {
+					key := fmt.Sprintf("%s/%s/%s", group.name, versionedAPI.version, ao.name)
+					objects[key] = &objectDoc{ao: ao}
+				}
+			}
+		}
+	}
+
+	names := root.nameMap().Names
+	kinds := []*kindDoc{}
+	for _, name := range names {
+		if name.Role == NameRoleObject {
+			object := objects[fmt.Sprintf("%s/%s/%s", name.Group, name.Version, name.Kind)]
+			object.kind = &kindDoc{object: name, description: object.ao.comments}
+			kinds = append(kinds, object.kind)
+		}
+	}
+
+	for _, name := range names {
+		object := objects[fmt.Sprintf("%s/%s/%s", name.Group, name.Version, name.Kind)]
+		kind := object.kind
+		switch name.Role {
+		case NameRoleConstructor:
+			kind.constructors = append(kind.constructors, functionDoc{
+				name:        name,
+				description: comments{fmt.Sprintf("Creates a new `%s`.", name.Kind)},
+			})
+		case NameRoleSetter, NameRoleMixin:
+			fn := functionDoc{name: name}
+			if p := object.ao.propertyAt(name.Field); p != nil {
+				fn.typeName = p.typeName()
+				fn.description = p.comments
+			}
+			if name.Role == NameRoleSetter {
+				kind.setters = append(kind.setters, fn)
+			} else {
+				kind.mixins = append(kind.mixins, fn)
+			}
+		}
+	}
+	return kinds
+}
+
+// `propertyAt` returns the property at the dotted path `field` of
+// `ao` (e.g., `spec.template.spec.containers`), following the
+// properties that refer to other objects, or nil if there is none.
+// `podTemplate` refers to the shared pod template namespace (see
+// `podTemplateNames`).
+func (ao *apiObject) propertyAt(field string) *property {
+	current := ao
+	segments := strings.Split(field, ".")
+	if _, ok := ao.properties["podTemplate"]; !ok && segments[0] == "podTemplate" {
+		current = ao.root().podTemplateSpec()
+		segments = segments[1:]
+	}
+
+	for i, segment := range segments {
+		p, ok := current.properties[kubespec.PropertyName(segment)]
+		if !ok {
+			return nil
+		}
+		if i == len(segments)-1 {
+			return p
+		}
+		if !isMixinRef(p.ref) {
+			return nil
+		}
+		current = ao.root().getAPIObject(ao.root().parseRef(p.ref))
+	}
+	return nil
+}
+
+// `typeName` describes the type of the values of `p`, e.g., `string`,
+// `array of Container`, or `map of string`.
+func (p *property) typeName() string {
+	switch {
+	case p.intOrString:
+		return "integer or string"
+	case isMixinRef(p.ref):
+		return string(p.parent.root().parseRef(p.ref).Kind)
+	case p.schemaType == nil:
+		return "object"
+	case *p.schemaType == "array":
+		switch {
+		case isMixinRef(p.itemTypes.Ref):
+			return fmt.Sprintf("array of %s", p.parent.root().parseRef(p.itemTypes.Ref).Kind)
+		case p.itemTypes.Type != nil:
+			return fmt.Sprintf("array of %s", *p.itemTypes.Type)
+		}
+	case *p.schemaType == "object" && p.valueSchema != nil:
+		switch {
+		case isMixinRef(p.valueSchema.Ref):
+			return fmt.Sprintf("map of %s", p.parent.root().parseRef(p.valueSchema.Ref).Kind)
+		case p.valueSchema.Type != nil:
+			return fmt.Sprintf("map of %s", *p.valueSchema.Type)
+		}
+	}
+	return string(*p.schemaType)
+}
+
+// `emitFunctionIndex` returns the text of the function index of the
+// library (see `FunctionIndex`), sorted by path.
+func (root *root) emitFunctionIndex() ([]byte, error) {
+	index := FunctionIndex{
+		KubernetesVersion: root.spec.Info.Version,
+		Functions:         []IndexedFunction{},
+	}
+	for _, kind := range root.docs() {
+		for _, functions := range [][]functionDoc{kind.constructors, kind.setters, kind.mixins} {
+			for _, fn := range functions {
+				params := fn.name.Params
+				if params == nil {
+					params = []string{}
+				}
+				index.Functions = append(index.Functions, IndexedFunction{
+					Path:   fn.name.Path,
+					Role:   fn.name.Role,
+					Params: params,
+					Type:   fn.typeName,
+					Doc:    strings.TrimSpace(strings.Join(fn.description, "\n")),
+				})
+			}
+		}
+	}
+
+	sort.Slice(index.Functions, func(i, j int) bool {
+		return index.Functions[i].Path < index.Functions[j].Path
+	})
+	return json.MarshalIndent(index, "", "  ")
+}
+
+// `emitDocs` returns Markdown reference docs for the library: a page
+// per top-level object, e.g., `docs/apps/v1beta1/Deployment.md`,
+// listing its constructors, setters, and mixins, and `docs/README.md`,
+// an index of the pages by group and version.
+func (root *root) emitDocs() map[string][]byte {
+	files := make(map[string][]byte)
+
+	var index bytes.Buffer
+	fmt.Fprintf(&index, "# Kubernetes %s\n", root.spec.Info.Version)
+	group, version := "", ""
+	for _, kind := range root.docs() {
+		if kind.object.Group != group {
+			group, version = kind.object.Group, ""
+			fmt.Fprintf(&index, "\n## %s\n", group)
+		}
+		if kind.object.Version != version {
+			version = kind.object.Version
+			fmt.Fprintf(&index, "\n### %s/%s\n\n", group, version)
+		}
+
+		page := path.Join(group, version, fmt.Sprintf("%s.md", kind.object.Kind))
+		fmt.Fprintf(&index, "* [%s](%s)\n", kind.object.Kind, page)
+		files[path.Join(DocsDir, page)] = kind.markdown()
+	}
+	files[path.Join(DocsDir, "README.md")] = index.Bytes()
+	return files
+}
+
+// `markdown` returns the page of `kind` (see `emitDocs`).
+func (kind *kindDoc) markdown() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n\n", kind.object.Kind)
+	fmt.Fprintf(&b, "`%s` (`%s/%s`)\n", kind.object.Path, kind.object.Group, kind.object.Version)
+	kind.description.emitMarkdown(&b)
+
+	sections := []struct {
+		title     string
+		functions []functionDoc
+	}{
+		{"Constructors", kind.constructors},
+		{"Setters", kind.setters},
+		{"Mixins", kind.mixins},
+	}
+	for _, section := range sections {
+		if len(section.functions) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n", section.title)
+		for _, fn := range section.functions {
+			signature := strings.TrimPrefix(fn.name.signature(), kind.object.Path+".")
+			fmt.Fprintf(&b, "\n### `%s`\n", signature)
+			if fn.typeName != "" {
+				fmt.Fprintf(&b, "\nProperty: `%s`, of type `%s`.\n", fn.name.Field, fn.typeName)
+			}
+			fn.description.emitMarkdown(&b)
+		}
+	}
+	return b.Bytes()
+}
+
+// `emitMarkdown` writes `cs` as Markdown paragraphs, if there are any.
+func (cs comments) emitMarkdown(b *bytes.Buffer) {
+	text := strings.TrimSpace(strings.Join(cs, "\n"))
+	if text != "" {
+		fmt.Fprintf(b, "\n%s\n", text)
+	}
+}
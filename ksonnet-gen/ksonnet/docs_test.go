@@ -0,0 +1,154 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var docsSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Widget": {
+      "description": "Widget is a thing made of parts.",
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "labels": {
+          "description": "Labels of the widget.",
+          "type": "object",
+          "additionalProperties": {"type": "string"}
+        },
+        "parts": {
+          "description": "The parts the widget is made of.",
+          "type": "array",
+          "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.Part"}
+        },
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.Part"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Part": {
+      "properties": {
+        "size": {
+          "description": "Size of the part.",
+          "type": "string",
+          "enum": ["small", "large"]
+        },
+        "port": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.util.intstr.IntOrString"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.util.intstr.IntOrString": {
+      "type": "string",
+      "format": "int-or-string"
+    }
+  }
+}`
+
+func TestEmitDocs(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, docsSpec), nil, nil, Options{Docs: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	index := string(files["docs/README.md"])
+	if !strings.Contains(index, "* [Widget](apps/v1beta1/Widget.md)") {
+		t.Errorf("Expected index to link to the page of 'Widget', got:\n%s", index)
+	}
+
+	page := string(files["docs/apps/v1beta1/Widget.md"])
+	expected := []string{
+		"`apps.v1beta1.widget` (`apps/v1beta1`)\n\nWidget is a thing made of parts.",
+		"## Constructors\n\n### `new()`",
+		"### `withLabels(labels)`\n\nProperty: `labels`, of type `map of string`.\n\nLabels of the widget.",
+		"### `withParts(parts)`\n\nProperty: `parts`, of type `array of Part`.\n\nThe parts the widget is made of.",
+		"### `mixin.spec.withSize(size)`\n\nProperty: `spec.size`, of type `string`.\n\nSize of the part.",
+		"### `mixin.spec.withPort(port)`\n\nProperty: `spec.port`, of type `integer or string`.",
+		"## Mixins\n\n### `withLabelsMixin(labels)`",
+	}
+	for _, text := range expected {
+		if !strings.Contains(page, text) {
+			t.Errorf("Expected page of 'Widget' to contain:\n%s\ngot:\n%s", text, page)
+		}
+	}
+	if strings.Contains(page, "mixin.spec(") {
+		t.Errorf("Expected page of 'Widget' not to list namespaces as functions, got:\n%s", page)
+	}
+
+	_, _, err = EmitFiles(
+		parseSpec(t, docsSpec), nil, nil, Options{Docs: true, Backend: DhallBackend})
+	if err == nil || !strings.Contains(err.Error(), "Reference docs require") {
+		t.Errorf("Expected error for docs with the dhall backend, got: %v", err)
+	}
+}
+
+func TestEmitSite(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, docsSpec), nil, nil, Options{Site: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if _, ok := files["site/style.css"]; !ok {
+		t.Errorf("Expected stylesheet of the site to be emitted")
+	}
+
+	index := string(files["site/index.html"])
+	expected := []string{
+		`<h3 id="apps/v1beta1">apps/v1beta1</h3>`,
+		`<li data-search="apps.v1beta1.widget"><a href="apps/v1beta1/Widget.html">Widget</a></li>`,
+		`<input id="search"`,
+	}
+	for _, text := range expected {
+		if !strings.Contains(index, text) {
+			t.Errorf("Expected index of the site to contain:\n%s\ngot:\n%s", text, index)
+		}
+	}
+
+	page := string(files["site/apps/v1beta1/Widget.html"])
+	expected = []string{
+		`<link rel="stylesheet" href="../../style.css">`,
+		`<section id="mixin.spec.withSize" data-search="mixin.spec.withsize">`,
+		`<h3><a href="#mixin.spec.withSize"><code>mixin.spec.withSize(size)</code></a></h3>`,
+		"<p>Size of the part.\nMust be one of <code>small</code>, <code>large</code>.</p>",
+		"or a string (e.g., <code>&#34;http&#34;</code>",
+	}
+	for _, text := range expected {
+		if !strings.Contains(page, text) {
+			t.Errorf("Expected page of 'Widget' to contain:\n%s\ngot:\n%s", text, page)
+		}
+	}
+}
+
+func TestEmitFunctionIndex(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, docsSpec), nil, nil, Options{FunctionIndex: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	index := FunctionIndex{}
+	if err := json.Unmarshal(files[FunctionIndexFile], &index); err != nil {
+		t.Fatalf("Could not deserialize function index:\n%v", err)
+	}
+	functions := make(map[string]IndexedFunction)
+	for _, fn := range index.Functions {
+		functions[fn.Path] = fn
+	}
+
+	size, ok := functions["apps.v1beta1.widget.mixin.spec.withSize"]
+	if !ok {
+		t.Fatalf("Expected setter of 'spec.size' in function index, got: %v", index.Functions)
+	}
+	if size.Role != NameRoleSetter || len(size.Params) != 1 || size.Params[0] != "size" ||
+		size.Type != "string" || !strings.HasPrefix(size.Doc, "Size of the part.\nMust be one of") {
+		t.Errorf("Unexpected setter of 'spec.size' in function index: %+v", size)
+	}
+	if fn, ok := functions["apps.v1beta1.widget.new"]; !ok || fn.Role != NameRoleConstructor {
+		t.Errorf("Expected constructor of 'Widget' in function index, got: %+v", fn)
+	}
+	if fn, ok := functions["apps.v1beta1.widget.withPartsMixin"]; !ok || fn.Type != "array of Part" {
+		t.Errorf("Expected mixin of 'parts' in function index, got: %+v", fn)
+	}
+	if _, ok := functions["apps.v1beta1.widget.mixin.spec"]; ok {
+		t.Errorf("Expected namespaces not to be in function index")
+	}
+}
@@ -0,0 +1,173 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubeversion"
+)
+
+// `docsonnetImport` is the path `Options.Docsonnet` causes the library
+// to import docsonnet's `doc-util` from, as installed by
+// jsonnet-bundler (e.g., `jb install
+// github.com/jsonnet-libs/docsonnet/doc-util`).
+const docsonnetImport = "doc-util/main.libsonnet"
+
+// `emitDocsonnetPackage` emits, at the start of an object of the
+// library (the root, a group, a version, or an API object), the import
+// of docsonnet, and the `"#"` field that documents the object as a
+// docsonnet package, if `Options.Docsonnet` is set. Every package
+// imports docsonnet itself, so that the files of a split library (see
+// `Options.Split`) each have it in scope.
+func (root *root) emitDocsonnetPackage(m *astWriter, name string, help comments) {
+	if !root.options.Docsonnet {
+		return
+	}
+	root.emitDocsonnetImport(m)
+	m.hidden("#", docsonnetPackage(name, help))
+}
+
+// `docsonnetPackage` returns the docsonnet annotation of the package
+// `name`.
+func docsonnetPackage(name string, help comments) string {
+	return fmt.Sprintf("d.pkg(name=%s, url=\"\", help=%s)", jsonnetString(name), help.docsonnetHelp())
+}
+
+// `emitDocsonnetImport` emits the import of docsonnet, as `d`, if
+// `Options.Docsonnet` is set.
+func (root *root) emitDocsonnetImport(m *astWriter) {
+	if root.options.Docsonnet {
+		m.field(astLocal("d", astImport(docsonnetImport)))
+	}
+}
+
+// `docsonnetHelp` is the help text of the docsonnet package of the
+// library itself.
+func (root *root) docsonnetHelp() comments {
+	return comments{fmt.Sprintf(
+		"The Kubernetes %s API, generated from its OpenAPI specification.", root.spec.Info.Version)}
+}
+
+// `emitDocsonnetPackage` emits the docsonnet package of a group.
+func (group *group) emitDocsonnetPackage(m *astWriter) {
+	group.root().emitDocsonnetPackage(
+		m, string(group.identifier()), comments{fmt.Sprintf("The `%s` API group.", group.name)})
+}
+
+// `emitDocsonnetObject` emits the `"#<field>"` field that documents
+// the namespace `field` (e.g., `mixin`, or `spec` in it).
+func (root *root) emitDocsonnetObject(m *astWriter, field jsonnet.Identifier, help comments) {
+	if !root.options.Docsonnet {
+		return
+	}
+	m.hidden("#"+string(field), docsonnetObject(help))
+}
+
+// `docsonnetObject` returns the docsonnet annotation of a namespace.
+func docsonnetObject(help comments) string {
+	return fmt.Sprintf("d.obj(help=%s)", help.docsonnetHelp())
+}
+
+// `emitDocsonnetFunction` emits the `"#<name>"` field that documents
+// the function `name` of the property `p` (e.g., its setter), which
+// takes the value of the property as `paramName`.
+func (p *property) emitDocsonnetFunction(
+	m *astWriter, name jsonnet.Identifier, paramName jsonnet.FuncParam,
+) {
+	p.root().emitDocsonnetFunction(m, string(name), p.comments, p.docsonnetArg(paramName))
+}
+
+// `docsonnetArg` returns the docsonnet annotation of the parameter
+// `paramName`, which takes the value of `p`.
+func (p *property) docsonnetArg(paramName jsonnet.FuncParam) string {
+	return fmt.Sprintf("d.arg(%s, %s)", jsonnetString(string(paramName)), p.docsonnetType())
+}
+
+// `emitDocsonnetFunction` emits the `"#<name>"` field that documents
+// the function `name`, whose arguments are documented by `args` (e.g.,
+// `d.arg("replicas", d.T.number)`).
+func (root *root) emitDocsonnetFunction(
+	m *astWriter, name string, help comments, args ...string,
+) {
+	if !root.options.Docsonnet {
+		return
+	}
+	m.hidden("#"+name, docsonnetFunction(help, args...))
+}
+
+// `docsonnetFunction` returns the docsonnet annotation of a function,
+// whose arguments are documented by `args`.
+func docsonnetFunction(help comments, args ...string) string {
+	return fmt.Sprintf("d.fn(help=%s, args=[%s])", help.docsonnetHelp(), strings.Join(args, ", "))
+}
+
+// `emitDocsonnetConstructor` emits the `"#<id>"` field that documents
+// a constructor of `ao` with the parameters `params`.
+func (ao *apiObject) emitDocsonnetConstructor(
+	m *astWriter, id string, params []kubeversion.CustomConstructorParam,
+) {
+	ao.root().emitDocsonnetFunction(m, id, ao.constructorHelp(), ao.docsonnetConstructorArgs(params)...)
+}
+
+// `constructorHelp` is the help text of the constructors of `ao`.
+func (ao *apiObject) constructorHelp() comments {
+	return comments{fmt.Sprintf("Creates a new `%s`.", ao.name)}
+}
+
+// `docsonnetConstructorArgs` returns the docsonnet annotations of the
+// parameters `params` of a constructor of `ao`.
+func (ao *apiObject) docsonnetConstructorArgs(
+	params []kubeversion.CustomConstructorParam,
+) []string {
+	args := []string{}
+	for _, param := range params {
+		field := param.ID
+		if param.RelativePath != nil {
+			field = *param.RelativePath
+		}
+		argType := "d.T.any"
+		if p := ao.propertyAt(field); p != nil {
+			argType = p.docsonnetType()
+		}
+		args = append(args, fmt.Sprintf("d.arg(%s, %s)", jsonnetString(param.ID), argType))
+	}
+	return args
+}
+
+// `docsonnetType` returns the docsonnet type of the values of `p`,
+// e.g., `d.T.string`.
+func (p *property) docsonnetType() string {
+	switch {
+	case p.intOrString:
+		return "d.T.any"
+	case isMixinRef(p.ref):
+		if len(p.root().getAPIObject(p.root().parseRef(p.ref)).emittedProperties) == 0 {
+			// E.g., `Time` and `Quantity`, which are serialized as strings.
+			return "d.T.any"
+		}
+		return "d.T.object"
+	case p.schemaType == nil:
+		return "d.T.any"
+	}
+
+	switch *p.schemaType {
+	case "string":
+		return "d.T.string"
+	case "integer", "number":
+		return "d.T.number"
+	case "boolean":
+		return "d.T.bool"
+	case "object":
+		return "d.T.object"
+	case "array":
+		return "d.T.array"
+	}
+	return "d.T.any"
+}
+
+// `docsonnetHelp` returns `cs` as a Jsonnet string literal, to be the
+// help text of a docsonnet field.
+func (cs comments) docsonnetHelp() string {
+	return jsonnetString(strings.TrimSpace(strings.Join(cs, "\n")))
+}
@@ -1,10 +1,12 @@
 package ksonnet
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
 	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
@@ -12,20 +14,341 @@ import (
 )
 
 // Emit takes a swagger API specification, and returns the text of
-// `ksonnet-lib`, written in Jsonnet.
-func Emit(spec *kubespec.APISpec, ksonnetLibSHA, k8sSHA *string) ([]byte, []byte, error) {
-	root := newRoot(spec, ksonnetLibSHA, k8sSHA)
+// `ksonnet-lib`, written in Jsonnet, along with a `Report` describing
+// any non-fatal decisions made while generating it. `opts.Backend` is
+// ignored; to generate code for some other backend, use `EmitFiles`.
+func Emit(
+	spec *kubespec.APISpec, ksonnetLibSHA, k8sSHA *string, opts Options,
+) ([]byte, []byte, *Report, error) {
+	opts.Backend = JsonnetBackend
+	files, report, err := EmitFiles(spec, ksonnetLibSHA, k8sSHA, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	m := newIndentWriter()
-	root.emit(m)
-	k8sBytes, err := m.bytes()
+	return files[kFile], files[k8sFile], report, nil
+}
+
+// Generate takes the text of a swagger API specification, and returns
+// every artifact generated from it with `opts` (the library, and any
+// schemas, name maps, changelogs, and so on that `opts` asks for),
+// keyed by their paths relative to the output directory, along with a
+// `Report` describing any non-fatal decisions made while generating
+// them. Unlike the `ksonnet-gen` command, it never touches the
+// filesystem, so servers and tests can generate and dispose of a
+// library entirely in memory; the generated code does not record the
+// git revisions it was generated from. The spec may be compressed (see
+// `kubespec.Decompress`).
+func Generate(specText []byte, opts Options) (map[string][]byte, *Report, error) {
+	specText, err := kubespec.Decompress(specText)
 	if err != nil {
 		return nil, nil, err
 	}
+	spec := kubespec.APISpec{}
+	if err := json.Unmarshal(specText, &spec); err != nil {
+		return nil, nil, fmt.Errorf("Could not deserialize schema:\n%v", err)
+	}
+	spec.Text = specText
+
+	return EmitFiles(&spec, nil, nil, opts)
+}
+
+// EmitFiles takes a swagger API specification, and returns the files
+// generated from it by the backend named in `opts.Backend` (by
+// default, `k.libsonnet` and `k8s.libsonnet`), keyed by file name,
+// along with a `Report` describing any non-fatal decisions made while
+// generating them. The spec is normalized (see `kubespec.Normalize`)
+// and validated (see `kubespec.Validate`) first; if it has problems
+// that keep the library from being generated, the error is returned
+// along with a `Report` of them.
+func EmitFiles(
+	spec *kubespec.APISpec, ksonnetLibSHA, k8sSHA *string, opts Options,
+) (map[string][]byte, *Report, error) {
+	emit, err := lookupBackend(opts.Backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := CheckUnknownTypes(opts.UnknownTypes); err != nil {
+		return nil, nil, err
+	}
+	if err := CheckSplit(opts.Split); err != nil {
+		return nil, nil, err
+	}
+	if err := CheckNamingConvention(opts.NamingConvention); err != nil {
+		return nil, nil, err
+	}
+	if namingConvention(opts) != jsonnet.CamelCase && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"Naming conventions other than camelCase require the '%s' backend", JsonnetBackend)
+	}
+	if opts.Split != "" && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"Splitting the library requires the '%s' backend", JsonnetBackend)
+	}
+	if (opts.Docs || opts.Site) && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"Reference docs require the '%s' backend", JsonnetBackend)
+	}
+	if opts.Docsonnet && opts.Backend != "" &&
+		opts.Backend != JsonnetBackend && opts.Backend != K8sLibsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"Docsonnet annotations require the '%s' or '%s' backend",
+			JsonnetBackend, K8sLibsonnetBackend)
+	}
+	if opts.ShortNames && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"Short names require the '%s' backend", JsonnetBackend)
+	}
+	if opts.GVKLookup && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"The GVK lookup requires the '%s' backend", JsonnetBackend)
+	}
+	if opts.ExposeTypes && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"Exposing the hidden types requires the '%s' backend", JsonnetBackend)
+	}
+	if opts.SourceMap && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"The source map requires the '%s' backend", JsonnetBackend)
+	}
+	if opts.SourceMap && (opts.Format != nil || opts.NoComments || opts.Minify) {
+		return nil, nil, fmt.Errorf(
+			"The source map can't be emitted for a formatted or stripped library, whose lines formatting moves")
+	}
+	if opts.Snippets && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"Snippets require the '%s' backend", JsonnetBackend)
+	}
+	if opts.Prototypes && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"Prototypes require the '%s' backend", JsonnetBackend)
+	}
+	if opts.CompatShim && opts.PreviousNameMap == nil {
+		return nil, nil, fmt.Errorf(
+			"The compat shim requires the name map of the previous library")
+	}
+	if opts.CompatShim && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"The compat shim requires the '%s' backend", JsonnetBackend)
+	}
+	if opts.FunctionIndex && opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return nil, nil, fmt.Errorf(
+			"The function index requires the '%s' backend", JsonnetBackend)
+	}
+	if err := checkKindPatterns(opts); err != nil {
+		return nil, nil, err
+	}
+	if err := checkGoPackage(opts); err != nil {
+		return nil, nil, err
+	}
+
+	start := time.Now()
+	conflicts := []kubespec.OverlayConflict{}
+	if opts.Overlay != nil {
+		spec, conflicts, err = applyOverlay(spec, opts.Overlay)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	kubespec.Normalize(spec)
+	validation := newReport()
+	if !validation.validate(spec) {
+		return nil, validation, fmt.Errorf(
+			"Spec failed validation; see the errors in the report")
+	}
+	root, err := newRoot(spec, ksonnetLibSHA, k8sSHA, opts)
+	if err != nil {
+		return nil, validation, err
+	}
+	root.report.Warnings = append(validation.Warnings, root.report.Warnings...)
+	for _, conflict := range conflicts {
+		root.report.warnf(conflict.Definition, "%s: %s", conflict.Field, conflict.Message)
+	}
+	for _, conflict := range spec.MergeConflicts {
+		root.report.warnf(
+			conflict.Definition,
+			"definition in spec %d differs from the one in spec %d, which was kept",
+			conflict.Dropped+1, conflict.Kept+1)
+	}
+	root.report.addTiming("model construction", start)
 
-	kBytes := []byte(kubeversion.KSource(spec.Info.Version))
+	start = time.Now()
+	root.filterBlacklisted()
+	root.report.addTiming("blacklist filtering", start)
 
-	return kBytes, k8sBytes, nil
+	start = time.Now()
+	files, err := emit(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := root.unknownTypesError(); err != nil {
+		return nil, nil, err
+	}
+	if opts.NameMap {
+		names, err := root.emitNameMap()
+		if err != nil {
+			return nil, nil, err
+		}
+		files[NamesFile] = names
+	}
+	if opts.SourceMap {
+		sourceMap, err := root.emitSourceMap()
+		if err != nil {
+			return nil, nil, err
+		}
+		files[SourceMapFile] = sourceMap
+	}
+	if opts.SkippedReport {
+		skipped, err := root.emitSkipped()
+		if err != nil {
+			return nil, nil, err
+		}
+		files[SkippedFile] = skipped
+	}
+	if opts.PreviousNameMap != nil {
+		changelog := NewChangelog(opts.PreviousNameMap, root.nameMap())
+		files[ChangelogFile] = changelog.Markdown()
+		root.report.Changelog = changelog
+	}
+	if opts.CompatShim {
+		compat, err := root.emitCompatShim(opts.PreviousNameMap)
+		if err != nil {
+			return nil, nil, err
+		}
+		files[CompatFile] = compat
+	}
+	if opts.JSONSchemas {
+		schemas, err := root.emitJSONSchemas()
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, text := range schemas {
+			files[name] = text
+		}
+	}
+	if opts.Docs {
+		for name, text := range root.emitDocs() {
+			files[name] = text
+		}
+	}
+	if opts.FunctionIndex {
+		index, err := root.emitFunctionIndex()
+		if err != nil {
+			return nil, nil, err
+		}
+		files[FunctionIndexFile] = index
+	}
+	if opts.Snippets {
+		snippets, err := root.emitSnippets()
+		if err != nil {
+			return nil, nil, err
+		}
+		files[SnippetsFile] = snippets
+	}
+	if opts.Prototypes {
+		parts, err := root.emitPrototypes()
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, text := range parts {
+			files[name] = text
+		}
+	}
+	if opts.Site {
+		for name, text := range root.emitSite() {
+			files[name] = text
+		}
+	}
+	root.report.addTiming("emission", start)
+
+	if opts.Format != nil {
+		start = time.Now()
+		if err := format(files, *opts.Format); err != nil {
+			return nil, nil, err
+		}
+		root.report.addTiming("formatting", start)
+	}
+
+	if opts.NoComments || opts.Minify {
+		start = time.Now()
+		if err := root.strip(files, opts.Minify); err != nil {
+			return nil, nil, err
+		}
+		root.report.addTiming("stripping", start)
+	}
+
+	// The output is validated once it is final, i.e., formatted and
+	// stripped, so that what is written is what was parsed.
+	start = time.Now()
+	if err := root.validateJsonnet(files); err != nil {
+		return nil, nil, err
+	}
+	root.report.addTiming("output parsing", start)
+
+	if opts.Verify {
+		start = time.Now()
+		if err := root.verify(files); err != nil {
+			return nil, nil, err
+		}
+		root.report.addTiming("verification", start)
+	}
+
+	if opts.GoPackage != "" {
+		goFile, err := root.emitGoFile(files, opts.GoPackage)
+		if err != nil {
+			return nil, nil, err
+		}
+		files[GoFile] = goFile
+	}
+
+	root.report.Stats = root.stats()
+
+	return files, root.report, nil
+}
+
+const (
+	kFile   = "k.libsonnet"
+	k8sFile = "k8s.libsonnet"
+)
+
+// `emitJsonnet` is the default backend, which emits ksonnet-lib as
+// `k8s.libsonnet`, which is generated from the spec (and, if
+// `Options.Split` is set, split into several files; see `emitSplit`),
+// and `k.libsonnet`, which adds higher-level helpers to it (see
+// `emitK`).
+func emitJsonnet(root *root) (map[string][]byte, error) {
+	var files map[string][]byte
+	if root.options.Split != "" {
+		split, err := root.emitSplit()
+		if err != nil {
+			return nil, err
+		}
+		files = split
+	} else {
+		m := newIndentWriter()
+		root.emitHeader(m)
+		emitObject(m, root.emit())
+		k8sBytes, err := m.bytes()
+		if err != nil {
+			return nil, err
+		}
+		root.addSources(k8sFile, m, k8sBytes)
+		files = map[string][]byte{k8sFile: k8sBytes}
+	}
+	kBytes, err := root.emitK()
+	if err != nil {
+		return nil, err
+	}
+	files[kFile] = kBytes
+
+	if root.options.TestSuite {
+		testBytes, err := root.emitTestSuite()
+		if err != nil {
+			return nil, err
+		}
+		files[testSuiteFile] = testBytes
+	}
+	return files, nil
 }
 
 //-----------------------------------------------------------------------------
@@ -43,100 +366,302 @@ type root struct {
 	spec         *kubespec.APISpec
 	groups       groupSet // set of groups, e.g., core, apps, extensions.
 	hiddenGroups groupSet
+	options      Options
+	report       *Report
+	// `naming` is the naming convention of the identifiers of the
+	// library (see `Options.NamingConvention`), which every rewrite of
+	// a name into an identifier is passed.
+	naming jsonnet.NamingConvention
+	// `parsedNames` maps the name of every definition that is in the
+	// library to where it is in the library.
+	parsedNames map[kubespec.DefinitionName]*kubespec.ParsedDefinitionName
+	// `usesMergeByKey` is set once a mixin that merges the elements of
+	// an array by their patch merge key is emitted, so that the
+	// function it calls is emitted too. `usesMergeSet` is the same for
+	// mixins of `set` lists.
+	usesMergeByKey bool
+	usesMergeSet   bool
+	// `emitting` is the set of API objects that are being emitted,
+	// either as themselves or as the mixin namespace of a property, at
+	// the current point of emission, to detect reference cycles.
+	emitting map[*apiObject]bool
+	// `unknownTypes` maps every property (e.g.,
+	// `io.k8s.kubernetes.pkg.api.v1.Container.image`) that was emitted
+	// with a setter for values of any type, because its type isn't
+	// supported, to why.
+	unknownTypes map[string]string
 
 	ksonnetLibSHA *string
 	k8sSHA        *string
+	// `headerLines` are the lines of the comment every file starts
+	// with (see `Options.Header`).
+	headerLines []string
+	// `sourceMap` are the entries of the source map of the files
+	// emitted so far (see `Options.SourceMap`).
+	sourceMap []SourceMapEntry
+	// `skipped` are the definitions and properties left out of the
+	// library so far (see `Options.SkippedReport`).
+	skipped []SkippedEntry
 }
 
-func newRoot(spec *kubespec.APISpec, ksonnetLibSHA, k8sSHA *string) *root {
+// `newRoot` builds the model of `spec` the library is generated from.
+// It fails, naming the offending definition, if a definition can't be
+// placed in the library (e.g., its name is malformed), which
+// validating the spec first rules out.
+func newRoot(
+	spec *kubespec.APISpec, ksonnetLibSHA, k8sSHA *string, opts Options,
+) (*root, error) {
+	headerLines, err := renderHeader(opts, spec.Info.Version, ksonnetLibSHA, k8sSHA)
+	if err != nil {
+		return nil, err
+	}
 	root := root{
 		spec:         spec,
 		groups:       make(groupSet),
 		hiddenGroups: make(groupSet),
+		parsedNames:  make(map[kubespec.DefinitionName]*kubespec.ParsedDefinitionName),
+		emitting:     make(map[*apiObject]bool),
+		unknownTypes: make(map[string]string),
+		options:      opts,
+		report:       newReport(),
+		naming:       namingConvention(opts),
 
 		ksonnetLibSHA: ksonnetLibSHA,
 		k8sSHA:        k8sSHA,
+		headerLines:   headerLines,
 	}
 
-	for defName, def := range spec.Definitions {
-		root.addDefinition(defName, def)
+	// Definitions are added in sorted order, so that if several are
+	// malformed, the same one is reported every time.
+	defNames := []string{}
+	for defName := range spec.Definitions {
+		defNames = append(defNames, string(defName))
 	}
-
-	return &root
-}
-
-func (root *root) emit(m *indentWriter) {
-	m.writeLine("// AUTOGENERATED from the Kubernetes OpenAPI specification. DO NOT MODIFY.")
-	m.writeLine(fmt.Sprintf("// Kubernetes version: %s", root.spec.Info.Version))
-
-	if root.ksonnetLibSHA != nil {
-		m.writeLine(fmt.Sprintf(
-			"// SHA of ksonnet-lib HEAD: %s", *root.ksonnetLibSHA))
+	sort.Strings(defNames)
+	for _, name := range defNames {
+		defName := kubespec.DefinitionName(name)
+		if err := root.addDefinition(defName, spec.Definitions[defName]); err != nil {
+			return nil, fmt.Errorf("Could not add definition '%s' to the library:\n%v", defName, err)
+		}
 	}
+	root.filterKinds()
 
-	if root.ksonnetLibSHA != nil {
-		m.writeLine(fmt.Sprintf(
-			"// SHA of Kubernetes HEAD OpenAPI spec is generated from: %s",
-			*root.k8sSHA))
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for _, group := range groups.toSortedSlice() {
+			for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+				versionedAPI.resolveObjectNames()
+			}
+		}
 	}
-	m.writeLine("")
 
-	m.writeLine("{")
-	m.indent()
+	return &root, nil
+}
+
+// `emit` builds the library, as the object `k8s.libsonnet` evaluates
+// to.
+func (root *root) emit() *astWriter {
+	m := newASTWriter()
+	root.emitDocsonnetPackage(m, "k8s", root.docsonnetHelp())
 
 	// Emit in sorted order so that we can diff the output.
 	for _, group := range root.groups.toSortedSlice() {
 		group.emit(m)
 	}
 
-	m.writeLine("local hidden = {")
-	m.indent()
+	root.emitRenderListHelper(m)
+	root.emitUtilHelpers(m)
+	root.emitTypes(m, "hidden")
+	root.emitShortNames(m)
+	root.emitGVKLookup(m)
+	root.emitMetadata(m)
 
-	for _, hiddenGroup := range root.hiddenGroups.toSortedSlice() {
-		hiddenGroup.emit(m)
-	}
+	m.field(astLocal("hidden", m.nested(func(hidden *astWriter) {
+		for _, hiddenGroup := range root.hiddenGroups.toSortedSlice() {
+			hiddenGroup.emit(hidden)
+		}
+
+		root.emitPodTemplateMixins(hidden)
+		root.emitQuantityFunctions(hidden)
+	})))
+
+	root.emitMergeByKeyFunction(m)
+	root.emitMergeSetFunction(m)
+	return m
+}
+
+// `emitHeader` emits the comment every file of the library starts
+// with, which records what it was generated from (see
+// `Options.Header`).
+func (root *root) emitHeader(m *indentWriter) {
+	root.emitCommentHeader(m, "//")
+}
 
-	m.dedent()
-	m.writeLine("},")
+// `filterBlacklisted` computes, for every API object, the sorted list
+// of properties that will be emitted, i.e., all of its properties
+// except those blacklisted for this version of Kubernetes. This must
+// be called before `emit`.
+func (root *root) filterBlacklisted() {
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for _, group := range groups {
+			for _, versionedAPI := range group.versionedAPIs {
+				for _, object := range versionedAPI.apiObjects {
+					object.emittedProperties = object.properties.sortAndFilterBlacklisted()
+				}
+			}
+		}
+	}
+}
 
-	m.dedent()
-	m.writeLine("}")
+// `stats` counts the groups, versioned APIs, objects, and properties
+// that `root` emits. Blacklisted properties are not counted.
+func (root *root) stats() Stats {
+	stats := Stats{}
+	groupNames := make(map[kubespec.GroupName]bool)
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for name, group := range groups {
+			groupNames[name] = true
+			stats.VersionedAPIs += len(group.versionedAPIs)
+			for _, versionedAPI := range group.versionedAPIs {
+				for _, object := range versionedAPI.apiObjects {
+					if object.isTopLevel {
+						stats.TopLevelObjects++
+					} else {
+						stats.HiddenObjects++
+					}
+
+					for _, pm := range object.emittedProperties {
+						if pm.kind == typeAlias {
+							stats.TypeAliases++
+						} else {
+							stats.Properties++
+						}
+					}
+				}
+			}
+		}
+	}
+	stats.Groups = len(groupNames)
+	return stats
 }
 
 func (root *root) addDefinition(
 	path kubespec.DefinitionName, def *kubespec.SchemaDefinition,
-) {
-	parsedName := path.Parse()
+) error {
+	parsedName, err := path.ParseGroupVersionKindE(def)
+	if err != nil {
+		return err
+	}
 	if parsedName.Version == nil {
-		return
+		root.skip(path, "", SkipNoVersion, "definition has no version, so it is left out of the library")
+		return nil
+	}
+	root.parsedNames[path] = parsedName
+	apiObject, err := root.createAPIObject(parsedName, def)
+	if err != nil {
+		return err
 	}
-	apiObject := root.createAPIObject(parsedName, def)
 
 	for propName, prop := range def.Properties {
 		pm := newPropertyMethod(propName, path, prop, apiObject)
 		apiObject.properties[propName] = pm
+	}
+
+	// Type aliases are added only after every property is in place, and
+	// in sorted order, so that collisions between a type alias and a
+	// property (e.g., a property `fooType` and the alias for `foo`) are
+	// detected and resolved the same way regardless of map ordering.
+	propNames := []string{}
+	for propName := range def.Properties {
+		propNames = append(propNames, string(propName))
+	}
+	sort.Strings(propNames)
+
+	k8sVersion := root.spec.Info.Version
+	naming := root.naming
+	for _, name := range propNames {
+		propName := kubespec.PropertyName(name)
+		prop := def.Properties[propName]
+		if jsonnet.ShadowsBuiltin(propName) {
+			root.report.warnf(
+				path,
+				"parameter for property '%s' renamed to '%s', because it would shadow a Jsonnet built-in",
+				propName, jsonnet.RewriteAsFuncParam(naming, k8sVersion, propName))
+		}
 
 		st := prop.Type
-		if isMixinRef(pm.ref) ||
-			(st != nil && *st == "array" && prop.Items.Ref != nil) {
-			typeAliasName := propName + "Type"
-			ta, ok := apiObject.properties[typeAliasName]
-			if ok && ta.kind != typeAlias {
-				log.Panicf(
-					"Can't create type alias '%s' because a property with that name already exists", typeAliasName)
-			}
+		if !isMixinRef(prop.Ref) &&
+			!(st != nil && *st == "array" && prop.Items.Ref != nil) {
+			continue
+		}
 
-			ta = newPropertyTypeAlias(typeAliasName, path, prop, apiObject)
-			apiObject.properties[typeAliasName] = ta
+		typeAliasName, ok := root.typeAliasName(path, propName, apiObject)
+		if !ok {
+			continue
 		}
+
+		ta := newPropertyTypeAlias(typeAliasName, propName, path, prop, apiObject)
+		apiObject.properties[typeAliasName] = ta
 	}
+	return nil
+}
+
+// `typeAliasNameOverride` and `objectNameOverride` look up the names
+// specified for type aliases and API objects in `kubeversion`. They are
+// variables so that tests can specify names too.
+var (
+	typeAliasNameOverride = kubeversion.TypeAliasName
+	objectNameOverride    = kubeversion.ObjectName
+)
+
+// `typeAliasName` picks a name for the type alias of some property
+// that does not collide with any other member of the API object.
+// Typically the type alias of a property `foo` is `fooType`, but if
+// the object already has a property called `fooType`, we fall back to
+// `fooTypeRef`, and record the decision in the report. Names can also
+// be specified explicitly for a Kubernetes version, using
+// `kubeversion.TypeAliasName`; one that collides too is reported, and
+// the name is picked as if none was specified.
+func (root *root) typeAliasName(
+	path kubespec.DefinitionName, propName kubespec.PropertyName,
+	apiObject *apiObject,
+) (kubespec.PropertyName, bool) {
+	k8sVersion := root.spec.Info.Version
+	if name, ok := typeAliasNameOverride(k8sVersion, path, propName); ok {
+		if _, ok := apiObject.properties[name]; !ok {
+			return name, true
+		}
+		root.report.warnf(
+			path,
+			"type alias name '%s' specified for property '%s' not used, because a property with that name already exists",
+			name, propName)
+	}
+
+	defaultName := propName + "Type"
+	if _, ok := apiObject.properties[defaultName]; !ok {
+		return defaultName, true
+	}
+
+	fallbackName := propName + "TypeRef"
+	if _, ok := apiObject.properties[fallbackName]; !ok {
+		root.report.warnf(
+			path,
+			"type alias for property '%s' renamed to '%s', because a property named '%s' already exists",
+			propName, fallbackName, defaultName)
+		return fallbackName, true
+	}
+
+	root.report.errorf(
+		path,
+		"type alias for property '%s' not emitted, because properties named '%s' and '%s' already exist",
+		propName, defaultName, fallbackName)
+	return "", false
 }
 
 func (root *root) createAPIObject(
 	parsedName *kubespec.ParsedDefinitionName, def *kubespec.SchemaDefinition,
-) *apiObject {
+) (*apiObject, error) {
 	if parsedName.Version == nil {
-		log.Panicf(
+		return nil, fmt.Errorf(
 			"Can't make API object from name with nil version in path: '%s'",
 			parsedName.Unparse())
 	}
@@ -148,16 +673,17 @@ func (root *root) createAPIObject(
 		groupName = *parsedName.Group
 	}
 
+	gvk := def.GroupVersionKind(parsedName.Unparse())
 	var qualifiedName kubespec.GroupName
-	if len(def.TopLevelSpecs) > 0 && def.TopLevelSpecs[0].Group != "" {
-		qualifiedName = def.TopLevelSpecs[0].Group
+	if gvk != nil && gvk.Group != "" {
+		qualifiedName = gvk.Group
 	} else {
 		qualifiedName = groupName
 	}
 
 	// Separate out top-level definitions from everything else.
 	var groups groupSet
-	if len(def.TopLevelSpecs) > 0 {
+	if gvk != nil {
 		groups = root.groups
 	} else {
 		groups = root.hiddenGroups
@@ -177,11 +703,33 @@ func (root *root) createAPIObject(
 
 	apiObject, ok := versionedAPI.apiObjects[parsedName.Kind]
 	if ok {
-		log.Panicf("Duplicate object kinds with name '%s'", parsedName.Unparse())
+		return nil, fmt.Errorf(
+			"Duplicate object kinds with name '%s', also defined by '%s'",
+			parsedName.Unparse(), apiObject.parsedName.Unparse())
 	}
 	apiObject = newAPIObject(parsedName, versionedAPI, def)
 	versionedAPI.apiObjects[parsedName.Kind] = apiObject
-	return apiObject
+	return apiObject, nil
+}
+
+// `parseDefinitionName` parses the name of a definition into where it
+// is in the library: for top-level definitions, that's the group,
+// version, and kind they declare (see
+// `kubespec.ParseGroupVersionKind`), and for the rest, those their
+// name suggests.
+func (root *root) parseDefinitionName(
+	name kubespec.DefinitionName,
+) *kubespec.ParsedDefinitionName {
+	if parsed, ok := root.parsedNames[name]; ok {
+		return parsed
+	}
+	return name.Parse()
+}
+
+// `parseRef` parses the definition name of `ref` like
+// `parseDefinitionName`.
+func (root *root) parseRef(ref *kubespec.ObjectRef) *kubespec.ParsedDefinitionName {
+	return root.parseDefinitionName(*ref.Name())
 }
 
 func (root *root) getAPIObject(
@@ -277,20 +825,22 @@ func (group *group) root() *root {
 	return group.parent
 }
 
-func (group *group) emit(m *indentWriter) {
-	k8sVersion := group.root().spec.Info.Version
-	mixinName := jsonnet.RewriteAsIdentifier(k8sVersion, group.name)
-	line := fmt.Sprintf("%s:: {", mixinName)
-	m.writeLine(line)
-	m.indent()
+func (group *group) emit(m *astWriter) {
+	m.namespace(string(group.identifier()), group.emitVersionedAPIs)
+}
+
+// `identifier` is the Jsonnet identifier the group is emitted as,
+// e.g., `apps`.
+func (group *group) identifier() jsonnet.Identifier {
+	return jsonnet.RewriteAsIdentifier(group.root().naming, group.root().spec.Info.Version, group.name)
+}
 
+func (group *group) emitVersionedAPIs(m *astWriter) {
+	group.emitDocsonnetPackage(m)
 	// Emit in sorted order so that we can diff the output.
 	for _, versioned := range group.versionedAPIs.toSortedSlice() {
 		versioned.emit(m)
 	}
-
-	m.dedent()
-	m.writeLine("},")
 }
 
 func (gs groupSet) toSortedSlice() groupSlice {
@@ -338,28 +888,97 @@ func (va *versionedAPI) root() *root {
 	return va.parent.parent
 }
 
-func (va *versionedAPI) emit(m *indentWriter) {
+func (va *versionedAPI) emit(m *astWriter) {
 	// NOTE: Do not need to call `jsonnet.RewriteAsIdentifier`.
-	line := fmt.Sprintf("%s:: {", va.version)
-	m.writeLine(line)
-	m.indent()
+	m.namespace(string(va.version), va.emitObjects)
+}
 
-	gn := va.parent.qualifiedName
-	if gn == "core" {
-		m.writeLine(fmt.Sprintf(
-			"local apiVersion = {apiVersion: \"%s\"},", va.version))
-	} else {
-		m.writeLine(fmt.Sprintf(
-			"local apiVersion = {apiVersion: \"%s/%s\"},", gn, va.version))
-	}
+func (va *versionedAPI) emitObjects(m *astWriter) {
+	m.local("apiVersion", fmt.Sprintf("{apiVersion: %s}", jsonnetString(va.apiVersion())))
+	va.root().emitDocsonnetPackage(
+		m, string(va.version), comments{fmt.Sprintf("Version `%s` of the API.", va.apiVersion())})
 
 	// Emit in sorted order so that we can diff the output.
 	for _, object := range va.apiObjects.toSortedSlice() {
 		object.emit(m)
 	}
+	va.emitReflectionIndex(m)
+}
+
+// `resolveObjectNames` assigns each API object in a versioned API the
+// Jsonnet identifier it will be emitted with. Usually this is just the
+// kind with its first character lower-cased (e.g., `Binding` ->
+// `binding`), but that can collide with another kind in the same
+// version (e.g., if there is also a kind named `binding`). Identifiers
+// specified with `kubeversion.ObjectName` are assigned first; any
+// remaining collision is resolved by suffixing the later kind (in
+// sorted order) with `Kind`, and recording the decision in the report.
+// A collision that involves a specified identifier is a mistake in
+// `kubeversion`, and is reported as an error.
+func (va *versionedAPI) resolveObjectNames() {
+	k8sVersion := va.root().spec.Info.Version
+	naming := va.root().naming
+	taken := make(map[jsonnet.Identifier]*apiObject)
+
+	specified := make(map[*apiObject]bool)
+	objects := apiObjectSlice{}
+	for _, object := range va.apiObjects.toSortedSlice() {
+		if name, ok := objectNameOverride(k8sVersion, object.parsedName.Unparse()); ok {
+			object.jsonnetName = jsonnet.Identifier(name).Join(naming)
+			specified[object] = true
+			objects = append(objects, object)
+		}
+	}
+	for _, object := range va.apiObjects.toSortedSlice() {
+		if !specified[object] {
+			object.jsonnetName = jsonnet.RewriteAsIdentifier(naming, k8sVersion, object.name)
+			objects = append(objects, object)
+		}
+	}
 
-	m.dedent()
-	m.writeLine("},")
+	for _, object := range objects {
+		name := object.jsonnetName
+		if other, ok := taken[name]; ok {
+			renamed := name.Join(naming, "Kind")
+			for i := 2; taken[renamed] != nil; i++ {
+				renamed = name.Join(naming, fmt.Sprintf("Kind%d", i))
+			}
+
+			path := object.parsedName.Unparse()
+			switch {
+			case specified[object]:
+				va.root().report.errorf(
+					path,
+					"object emitted as '%s', because the name '%s' specified for it is already used by '%s' in version '%s'",
+					renamed, name, other.parsedName.Unparse(), va.version)
+			case specified[other]:
+				va.root().report.errorf(
+					path,
+					"object emitted as '%s', because '%s' is the name specified for '%s' in version '%s'",
+					renamed, name, other.parsedName.Unparse(), va.version)
+			default:
+				va.root().report.warnf(
+					path,
+					"object emitted as '%s', because '%s' is already used by '%s' in version '%s'",
+					renamed, name, other.parsedName.Unparse(), va.version)
+			}
+			name = renamed
+		}
+
+		object.jsonnetName = name
+		taken[name] = object
+	}
+}
+
+// `apiVersion` returns the value of the `apiVersion` field of objects
+// in this versioned API, e.g., `v1` for core, and `apps/v1beta1` for
+// apps.
+func (va *versionedAPI) apiVersion() string {
+	gn := va.parent.qualifiedName
+	if gn == "core" {
+		return string(va.version)
+	}
+	return fmt.Sprintf("%s/%s", gn, va.version)
 }
 
 func (vas versionedAPISet) toSortedSlice() versionedAPISlice {
@@ -368,7 +987,7 @@ func (vas versionedAPISet) toSortedSlice() versionedAPISlice {
 		versionedAPIs = append(versionedAPIs, va)
 	}
 	sort.Slice(versionedAPIs, func(i, j int) bool {
-		return versionedAPIs[i].version < versionedAPIs[j].version
+		return versionedAPIs[i].version.HasPriorityOver(versionedAPIs[j].version)
 	})
 	return versionedAPIs
 }
@@ -385,12 +1004,15 @@ func (vas versionedAPISet) toSortedSlice() versionedAPISlice {
 // formulate the basis of much of ksonnet-lib's programming surface.
 // The logic for creating them is handled largely by `root`.
 type apiObject struct {
-	name       kubespec.ObjectKind // e.g., `Container` in `v1.Container`
-	properties propertySet         // e.g., container.image, container.env
-	parsedName *kubespec.ParsedDefinitionName
-	comments   comments
-	parent     *versionedAPI
-	isTopLevel bool
+	name              kubespec.ObjectKind // e.g., `Container` in `v1.Container`
+	jsonnetName       jsonnet.Identifier  // e.g., `container` in `v1.container`
+	properties        propertySet         // e.g., container.image, container.env
+	emittedProperties propertySlice       // sorted, without blacklisted properties.
+	parsedName        *kubespec.ParsedDefinitionName
+	comments          comments
+	parent            *versionedAPI
+	isTopLevel        bool
+	required          []kubespec.PropertyName
 }
 type apiObjectSet map[kubespec.ObjectKind]*apiObject
 type apiObjectSlice []*apiObject
@@ -401,6 +1023,10 @@ func newAPIObject(
 ) *apiObject {
 	isTopLevel := len(def.TopLevelSpecs) > 0
 	comments := newComments(def.Description)
+	required := []kubespec.PropertyName{}
+	for _, name := range def.Required {
+		required = append(required, kubespec.PropertyName(name))
+	}
 	return &apiObject{
 		name:       name.Kind,
 		parsedName: name,
@@ -408,6 +1034,7 @@ func newAPIObject(
 		comments:   comments,
 		parent:     parent,
 		isTopLevel: isTopLevel,
+		required:   required,
 	}
 }
 
@@ -429,57 +1056,57 @@ func (ao *apiObject) root() *root {
 	return ao.parent.parent.parent
 }
 
-func (ao *apiObject) emit(m *indentWriter) {
-	k8sVersion := ao.root().spec.Info.Version
-	jsonnetName := kubespec.ObjectKind(
-		jsonnet.RewriteAsIdentifier(k8sVersion, ao.name))
-	if _, ok := ao.parent.apiObjects[jsonnetName]; ok {
-		log.Panicf(
-			"Tried to lowercase first character of object kind '%s', but lowercase name was already present in version '%s'",
-			jsonnetName,
-			ao.parent.version)
-	}
-
+func (ao *apiObject) emit(m *astWriter) {
+	ao.emitDiffAnchor(m)
+	defer m.source(ao.parsedName.Unparse(), "")()
 	ao.comments.emit(m)
 
-	m.writeLine(fmt.Sprintf("%s:: {", jsonnetName))
-	m.indent()
+	m.namespace(string(ao.jsonnetName), func(m *astWriter) {
+		ao.root().emitDocsonnetPackage(m, string(ao.jsonnetName), ao.comments)
+		ao.root().emitting[ao] = true
+		defer delete(ao.root().emitting, ao)
 
-	if ao.isTopLevel {
-		// NOTE: It is important to NOT capitalize `ao.name` here.
-		m.writeLine(fmt.Sprintf("local kind = {kind: \"%s\"},", ao.name))
-	}
-	ao.emitConstructors(m)
-
-	for _, pm := range ao.properties.sortAndFilterBlacklisted() {
-		// Skip special properties and fields that `$ref` another API
-		// object type, since those will go in the `mixin` namespace.
-		if isSpecialProperty(pm.name) || isMixinRef(pm.ref) {
-			continue
+		if ao.isTopLevel {
+			// NOTE: It is important to NOT capitalize `ao.name` here.
+			m.local("kind", fmt.Sprintf("{kind: %s}", jsonnetString(string(ao.name))))
 		}
-		pm.emit(m)
-	}
-
-	// Emit the properties that `$ref` another API object type in the
-	// `mixin:: {` namespace.
-	m.writeLine("mixin:: {")
-	m.indent()
-
-	for _, pm := range ao.properties.sortAndFilterBlacklisted() {
-		// TODO: Emit mixin code also for arrays whose elements are
-		// `$ref`.
-		if !isMixinRef(pm.ref) {
-			continue
+		ao.emitConstructors(m)
+		ao.emitFromJSON(m)
+		ao.emitMixinInstance(m)
+
+		for _, pm := range ao.emittedProperties {
+			// Skip special properties and fields that `$ref` another API
+			// object type, since those will go in the `mixin` namespace.
+			if isSpecialProperty(pm.name) || isMixinRef(pm.ref) {
+				continue
+			}
+			pm.emit(m)
 		}
 
-		pm.emit(m)
-	}
-
-	m.dedent()
-	m.writeLine("},")
+		ao.emitFlattenedSetters(m)
+		ao.emitKustomizeHelpers(m)
+		ao.emitRenderHelpers(m)
+		ao.emitQuantityHelpers(m)
+		ao.emitEnvHelpers(m)
+
+		// Emit the properties that `$ref` another API object type in the
+		// `mixin:: {` namespace.
+		ao.root().emitDocsonnetObject(m, "mixin", comments{fmt.Sprintf(
+			"Setters and mixins of the properties of `%s` that refer to other objects.", ao.name)})
+		m.namespace("mixin", func(m *astWriter) {
+			for _, pm := range ao.emittedProperties {
+				// TODO: Emit mixin code also for arrays whose elements are
+				// `$ref`.
+				if !isMixinRef(pm.ref) {
+					continue
+				}
+
+				pm.emit(m)
+			}
 
-	m.dedent()
-	m.writeLine("},")
+			ao.emitPodTemplateRef(m)
+		})
+	})
 }
 
 // `emitAsRefMixins` recursively emits an API object as a collection
@@ -491,22 +1118,30 @@ func (ao *apiObject) emit(m *indentWriter) {
 // recursively capture all the properties of `v1beta1.DeploymentSpec`
 // and create mixin methods, so that we can do something like
 // `someDeployment + deployment.mixin.spec.minReadySeconds(3)`.
+//
+// The function that nests a value at the right place in the object
+// (e.g., `__specMixin(spec):: {spec+: spec}`) is emitted as a hidden
+// field rather than a `local`, so that users can override it to
+// customize how values are merged. Every method in the namespace
+// refers to it through a `local` bound to the namespace object itself
+// (e.g., `local __specNs = self`), which is late-bound, so an override
+// also applies to all of the nested namespaces.
 func (ao *apiObject) emitAsRefMixins(
-	m *indentWriter, p *property, parentMixinName *string,
+	m *astWriter, p *property, parentMixinName *string,
 ) {
 	k8sVersion := ao.root().spec.Info.Version
-	functionName := jsonnet.RewriteAsIdentifier(k8sVersion, p.name)
-	paramName := jsonnet.RewriteAsFuncParam(k8sVersion, p.name)
+	naming := ao.root().naming
+	functionName := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name)
+	paramName := jsonnet.RewriteAsFuncParam(naming, k8sVersion, p.name)
 	fieldName := jsonnet.RewriteAsFieldKey(p.name)
+	namespaceName := fmt.Sprintf("__%sNs", functionName)
 	mixinName := fmt.Sprintf("__%sMixin", functionName)
-	var mixinText string
+	mixinRef := fmt.Sprintf("%s.%s", namespaceName, mixinName)
+	var mixinBody string
 	if parentMixinName == nil {
-		mixinText = fmt.Sprintf(
-			"local %s(%s) = {%s+: %s},", mixinName, paramName, fieldName, paramName)
+		mixinBody = fmt.Sprintf("{%s+: %s}", fieldName, paramName)
 	} else {
-		mixinText = fmt.Sprintf(
-			"local %s(%s) = %s({%s+: %s}),",
-			mixinName, paramName, *parentMixinName, fieldName, paramName)
+		mixinBody = fmt.Sprintf("%s({%s+: %s})", *parentMixinName, fieldName, paramName)
 	}
 
 	if _, ok := ao.parent.apiObjects[kubespec.ObjectKind(functionName)]; ok {
@@ -519,31 +1154,71 @@ func (ao *apiObject) emitAsRefMixins(
 	// NOTE: Comments are emitted by `property#emit`, before we
 	// call this method.
 
-	line := fmt.Sprintf("%s:: {", functionName)
-	m.writeLine(line)
-	m.indent()
+	m.namespace(string(functionName), func(m *astWriter) {
+		m.local(namespaceName, "self")
+		m.method(mixinName, []string{string(paramName)}, mixinBody)
+		p.emitDocsonnetFunction(m, "mixinInstance", paramName)
+		m.method(
+			"mixinInstance", []string{string(paramName)},
+			fmt.Sprintf("%s%s(%s)", p.typeAssertions(paramName), mixinRef, paramName))
 
-	m.writeLine(mixinText)
-	m.writeLine(
-		fmt.Sprintf("mixinInstance(%s):: %s(%s),", paramName, mixinName, paramName))
+		for _, pm := range ao.emittedProperties {
+			if isSpecialProperty(pm.name) {
+				continue
+			}
+			pm.emitAsRefMixin(m, mixinRef)
+		}
+		ao.emitQuantityHelpers(m)
+		ao.emitEnvHelpers(m)
+	})
+}
 
-	for _, pm := range ao.properties.sortAndFilterBlacklisted() {
-		if isSpecialProperty(pm.name) {
-			continue
+// `emitMixinInstance` emits the `mixinInstance` of an API object (or
+// of a hidden type, e.g., `containersType.mixinInstance`), which merges
+// an object built elsewhere (e.g., a fragment shared between
+// components) into the object, as the `mixinInstance` of the
+// namespaces of `mixin` does at their property, so that a fragment can
+// be grafted at any point of the tree the same way:
+//
+//	deployment.new(...) + deployment.mixinInstance(fragment)
+//
+// It is not emitted for objects without properties (e.g., `Quantity`,
+// which is serialized as a string), or that have a property whose
+// functions would clash with it.
+func (ao *apiObject) emitMixinInstance(m *astWriter) {
+	if len(ao.emittedProperties) == 0 {
+		return
+	}
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	for _, pm := range ao.emittedProperties {
+		id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name)
+		if id == "mixinInstance" || id.ToSetterID(naming) == "mixinInstance" || id.ToMixinID(naming) == "mixinInstance" {
+			ao.root().report.errorf(
+				ao.parsedName.Unparse(),
+				"'mixinInstance' not emitted, because property '%s' already has a function with that name", pm.name)
+			return
 		}
-		pm.emitAsRefMixin(m, mixinName)
 	}
 
-	m.dedent()
-	m.writeLine("},")
+	assertion := ""
+	if ao.root().options.TypeAssertions {
+		assertion = fmt.Sprintf(
+			"assert std.isObject(obj) : %s + std.type(obj); ", jsonnetString("obj must be an object, got "))
+	}
+	help := fmt.Sprintf("Merges `obj`, a fragment built elsewhere (e.g., shared between components), into this `%s`.", ao.name)
+	m.comment(help)
+	ao.root().emitDocsonnetFunction(m, "mixinInstance", comments{help}, "d.arg(\"obj\", d.T.object)")
+	m.method("mixinInstance", []string{"obj"}, fmt.Sprintf("%sself + obj", assertion))
 }
 
-func (ao *apiObject) emitConstructors(m *indentWriter) {
-	k8sVersion := ao.root().spec.Info.Version
-	path := ao.parsedName.Unparse()
-
-	specs, ok := kubeversion.ConstructorSpec(k8sVersion, path)
-	if !ok {
+func (ao *apiObject) emitConstructors(m *astWriter) {
+	named, specs := ao.constructors()
+	if named == "" && specs == nil {
+		if ao.root().options.RequiredConstructors {
+			ao.emitConstructor(m, constructorName, ao.requiredParams(true))
+			return
+		}
 		ao.emitConstructor(m, constructorName, []kubeversion.CustomConstructorParam{})
 		return
 	}
@@ -551,10 +1226,75 @@ func (ao *apiObject) emitConstructors(m *indentWriter) {
 	for _, spec := range specs {
 		ao.emitConstructor(m, spec.ID, spec.Params)
 	}
+	if named != "" {
+		// We use `mixinInstance` rather than `withName`, because the
+		// latter returns the whole `metadata` namespace, whose hidden
+		// fields (e.g., `initializers`) would then hide properties of
+		// the same name set on the new object.
+		ao.emitDocsonnetConstructor(m, named, namedConstructorParams())
+		m.method(
+			named, []string{"name", "namespace=null"},
+			"apiVersion + kind + self.mixin.metadata.mixinInstance("+
+				"{name: name} + if namespace == null then {} else {namespace: namespace})")
+	}
+}
+
+// `constructors` returns the name of the constructor that
+// `Options.ConstructorsTakeName` gives `ao` (or "" if it gives it
+// none), and the custom constructors specified in `kubeversion` that
+// `ao` keeps (or nil if none is specified). The named constructor is
+// `new`, unless a custom constructor of that name takes more than the
+// name (e.g., `new(name, replicas, containers)` of `Deployment`), in
+// which case it is `newNamed`; a custom constructor that only takes
+// the name (e.g., `new(name)` of `Namespace`) is replaced by it.
+func (ao *apiObject) constructors() (string, []kubeversion.CustomConstructorSpec) {
+	specs, _ := kubeversion.ConstructorSpec(ao.root().spec.Info.Version, ao.parsedName.Unparse())
+	if !ao.root().options.ConstructorsTakeName || !ao.hasObjectMeta() {
+		return "", specs
+	}
+
+	named := constructorName
+	kept := []kubeversion.CustomConstructorSpec{}
+	for _, spec := range specs {
+		if spec.ID == constructorName {
+			if len(spec.Params) == 1 && spec.Params[0].RelativePath != nil &&
+				*spec.Params[0].RelativePath == "metadata.name" {
+				continue
+			}
+			named = namedConstructorName
+		}
+		kept = append(kept, spec)
+	}
+	return named, kept
+}
+
+// `namedConstructorParams` returns the parameters of the constructors
+// `Options.ConstructorsTakeName` emits.
+func namedConstructorParams() []kubeversion.CustomConstructorParam {
+	namePath, namespacePath, null := "metadata.name", "metadata.namespace", "null"
+	return []kubeversion.CustomConstructorParam{
+		{ID: "name", RelativePath: &namePath},
+		{ID: "namespace", RelativePath: &namespacePath, DefaultValue: &null},
+	}
+}
+
+// `hasObjectMeta` reports whether a top-level API object has an
+// emitted `metadata` property of type `meta.v1.ObjectMeta`.
+func (ao *apiObject) hasObjectMeta() bool {
+	if !ao.isTopLevel {
+		return false
+	}
+
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == method && pm.name == "metadata" && isMixinRef(pm.ref) {
+			return ao.root().parseRef(pm.ref).Kind == "ObjectMeta"
+		}
+	}
+	return false
 }
 
 func (ao *apiObject) emitConstructor(
-	m *indentWriter, id string, params []kubeversion.CustomConstructorParam,
+	m *astWriter, id string, params []kubeversion.CustomConstructorParam,
 ) {
 	// Panic if a function with the constructor's name already exists.
 	specName := kubespec.PropertyName(id)
@@ -576,10 +1316,10 @@ func (ao *apiObject) emitConstructor(
 	// Build parameters and body of constructor. Considering the example
 	// of the constructor of `v1.Container`:
 	//
-	//   new(name, image):: self.name(name) + self.image(image),
+	//   new(name, image):: self.withName(name) + self.withImage(image),
 	//
 	// Here we want to (1) assemble the parameter list (i.e., `name` and
-	// `image`), as well as the body (i.e., the calls to `self.name` and
+	// `image`), as well as the body (i.e., the calls to `self.withName` and
 	// so on).
 	paramLiterals := []string{}
 	setters := defaultSetters
@@ -593,30 +1333,52 @@ func (ao *apiObject) emitConstructor(
 			paramLiterals = append(paramLiterals, param.ID)
 		}
 
-		// Add an element to the body (e.g., `self.name` above).
-		if param.RelativePath == nil {
-			prop, ok := ao.properties[kubespec.PropertyName(param.ID)]
-			if !ok {
-				log.Panicf(
-					"Attempted to create constructor, but property '%s' does not exist",
-					param.ID)
-			}
-			k8sVersion := ao.root().spec.Info.Version
-			propMethodName := jsonnet.RewriteAsIdentifier(k8sVersion, prop.name).ToSetterID()
-			setters = append(
-				setters, fmt.Sprintf("self.%s(%s)", propMethodName, param.ID))
-		} else {
-			// TODO(hausdorff): We may want to verify this relative path
-			// exists.
-			setters = append(
-				setters, fmt.Sprintf("self.%s(%s)", *param.RelativePath, param.ID))
+		// Add an element to the body (e.g., `self.withName` above).
+		field := param.ID
+		if param.RelativePath != nil {
+			field = *param.RelativePath
+		} else if _, ok := ao.properties[kubespec.PropertyName(param.ID)]; !ok {
+			log.Panicf(
+				"Attempted to create constructor, but property '%s' does not exist",
+				param.ID)
 		}
+		setters = append(
+			setters, fmt.Sprintf("self.%s(%s)", ao.setterPath(field), param.ID))
 	}
 
-	// Write out constructor.
-	paramsText := strings.Join(paramLiterals, ", ")
-	bodyText := strings.Join(setters, " + ")
-	m.writeLine(fmt.Sprintf("%s(%s):: %s,", specName, paramsText, bodyText))
+	// Add constructor.
+	ao.emitDocsonnetConstructor(m, id, params)
+	m.method(string(specName), paramLiterals, strings.Join(setters, " + "))
+}
+
+// `setterPath` returns the path, relative to `ao`, of the function
+// that sets the property at the dotted path `field` (e.g.,
+// `mixin.spec.template.spec.withContainers` for
+// `spec.template.spec.containers`). Properties that `$ref` other API
+// objects are set with the `mixinInstance` of their `mixin` namespace
+// instead. Constructors refer to the properties they set by path,
+// rather than by the name of their setter, so that they follow however
+// setters are named (see `jsonnet.Identifier.ToSetterID`). Paths are
+// not checked: if `ao` has no property at `field`, it is assumed to
+// have a setter.
+func (ao *apiObject) setterPath(field string) string {
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	segments := []string{}
+	for _, name := range strings.Split(field, ".") {
+		segments = append(
+			segments, string(jsonnet.RewriteAsIdentifier(naming, k8sVersion, kubespec.PropertyName(name))))
+	}
+	if p := ao.propertyAt(field); p != nil && isMixinRef(p.ref) {
+		return fmt.Sprintf("mixin.%s.mixinInstance", strings.Join(segments, "."))
+	}
+
+	last := len(segments) - 1
+	segments[last] = string(jsonnet.Identifier(segments[last]).ToSetterID(naming))
+	if last == 0 {
+		return segments[0]
+	}
+	return fmt.Sprintf("mixin.%s", strings.Join(segments, "."))
 }
 
 func (aos apiObjectSet) toSortedSlice() apiObjectSlice {
@@ -658,14 +1420,24 @@ const (
 //
 // The logic for creating them is handled largely by `root`.
 type property struct {
-	kind       propertyKind
-	ref        *kubespec.ObjectRef
-	schemaType *kubespec.SchemaType
-	itemTypes  kubespec.Items
-	name       kubespec.PropertyName // e.g., image in container.image.
-	path       kubespec.DefinitionName
-	comments   comments
-	parent     *apiObject
+	kind          propertyKind
+	ref           *kubespec.ObjectRef
+	schemaType    *kubespec.SchemaType
+	itemTypes     kubespec.Items
+	patchStrategy string
+	patchMergeKey string
+	listType      string
+	listMapKeys   []string
+	valueSchema   *kubespec.Property // values of maps (e.g., labels) only.
+	enum          []interface{}
+	defaultValue  interface{}
+	intOrString   bool // `$ref`s `IntOrString`, or is `x-kubernetes-int-or-string`.
+	constraints   kubespec.Constraints
+	name          kubespec.PropertyName // e.g., image in container.image.
+	aliasOf       kubespec.PropertyName // e.g., spec for specType; type aliases only.
+	path          kubespec.DefinitionName
+	comments      comments
+	parent        *apiObject
 }
 type propertySet map[kubespec.PropertyName]*property
 type propertySlice []*property
@@ -675,20 +1447,56 @@ func newPropertyMethod(
 	prop *kubespec.Property, parent *apiObject,
 ) *property {
 	comments := newComments(prop.Description)
+	if len(prop.Enum) > 0 {
+		comments = append(comments, enumComment(prop.Enum))
+	}
+	if len(prop.Items.Enum) > 0 {
+		comments = append(comments, itemsEnumComment(prop.Items.Enum))
+	}
+	intOrString := isIntOrStringRef(prop.Ref) || prop.IntOrString
+	if intOrString {
+		comments = append(comments, intOrStringComment)
+	} else if prop.Format != "" {
+		comments = append(comments, fmt.Sprintf("Format: `%s`.", prop.Format))
+	}
+	if prop.Default != nil {
+		comments = append(comments, fmt.Sprintf("Defaults to `%s`.", jsonnetValue(prop.Default)))
+	}
+	var valueSchema *kubespec.Property
+	if prop.AdditionalProperties != nil {
+		valueSchema = prop.AdditionalProperties.Schema
+	}
+	// Objects whose fields the spec doesn't describe often don't
+	// declare their type either, but are set and mixed in like any
+	// other object.
+	schemaType := prop.Type
+	if prop.PreserveUnknownFields && prop.Type == nil && prop.Ref == nil && !intOrString {
+		objectType := kubespec.SchemaType("object")
+		schemaType = &objectType
+	}
 	return &property{
-		kind:       method,
-		ref:        prop.Ref,
-		schemaType: prop.Type,
-		itemTypes:  prop.Items,
-		name:       name,
-		path:       path,
-		comments:   comments,
-		parent:     parent,
+		kind:          method,
+		ref:           prop.Ref,
+		schemaType:    schemaType,
+		itemTypes:     prop.Items,
+		patchStrategy: prop.PatchStrategy,
+		patchMergeKey: prop.PatchMergeKey,
+		listType:      prop.ListType,
+		listMapKeys:   prop.ListMapKeys,
+		valueSchema:   valueSchema,
+		enum:          prop.Enum,
+		defaultValue:  prop.Default,
+		intOrString:   intOrString,
+		constraints:   prop.Constraints,
+		name:          name,
+		path:          path,
+		comments:      comments,
+		parent:        parent,
 	}
 }
 
 func newPropertyTypeAlias(
-	name kubespec.PropertyName, path kubespec.DefinitionName,
+	name, aliasOf kubespec.PropertyName, path kubespec.DefinitionName,
 	prop *kubespec.Property, parent *apiObject,
 ) *property {
 	comments := newComments(prop.Description)
@@ -698,6 +1506,7 @@ func newPropertyTypeAlias(
 		schemaType: prop.Type,
 		itemTypes:  prop.Items,
 		name:       name,
+		aliasOf:    aliasOf,
 		path:       path,
 		comments:   comments,
 		parent:     parent,
@@ -708,7 +1517,7 @@ func (p *property) root() *root {
 	return p.parent.parent.parent.parent
 }
 
-func (p *property) emit(m *indentWriter) {
+func (p *property) emit(m *astWriter) {
 	p.emitHelper(m, nil)
 }
 
@@ -723,27 +1532,27 @@ func (p *property) emit(m *indentWriter) {
 // This method will take the `property`, which specifies a
 // property method, and use it to emit such a "mixin method".
 func (p *property) emitAsRefMixin(
-	m *indentWriter, parentMixinName string,
+	m *astWriter, parentMixinName string,
 ) {
 	p.emitHelper(m, &parentMixinName)
 }
 
-func (p *property) emitAsTypeAlias(m *indentWriter) {
+func (p *property) emitAsTypeAlias(m *astWriter) {
 	var path kubespec.DefinitionName
 	if p.ref != nil {
 		path = *p.ref.Name()
 	} else {
 		path = *p.itemTypes.Ref.Name()
 	}
-	parsedPath := path.Parse()
+	parsedPath := p.root().parseDefinitionName(path)
 	if parsedPath.Version == nil {
 		log.Printf("Could not emit type alias for '%s'\n", path)
 		return
 	}
 
-	// Chop the `Type` off the end of the type alias name, rewrite the
-	// "base" of the type alias, and then append `Type` to the end
-	// again.
+	// Chop the property name off the front of the type alias name
+	// (usually leaving `Type`), rewrite the property name, and then
+	// append the suffix again.
 	//
 	// Why: the desired behavior is for a rewrite rule to apply to both
 	// a method and its type alias. For example, if we specify that
@@ -752,8 +1561,13 @@ func (p *property) emitAsTypeAlias(m *indentWriter) {
 	// automatically, so that the user doesn't have to specify another,
 	// separate rule for the type alias itself.
 	k8sVersion := p.root().spec.Info.Version
-	trimmedName := kubespec.PropertyName(strings.TrimSuffix(string(p.name), "Type"))
-	typeName := jsonnet.RewriteAsIdentifier(k8sVersion, trimmedName) + "Type"
+	naming := p.root().naming
+	var typeName jsonnet.Identifier
+	if suffix := strings.TrimPrefix(string(p.name), string(p.aliasOf)); suffix != string(p.name) {
+		typeName = jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.aliasOf).Join(naming, suffix)
+	} else {
+		typeName = jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name)
+	}
 
 	var group kubespec.GroupName
 	if parsedPath.Group == nil {
@@ -762,12 +1576,12 @@ func (p *property) emitAsTypeAlias(m *indentWriter) {
 		group = *parsedPath.Group
 	}
 
-	id := jsonnet.RewriteAsIdentifier(k8sVersion, parsedPath.Kind)
-	line := fmt.Sprintf(
-		"%s:: hidden.%s.%s.%s,",
-		typeName, group, parsedPath.Version, id)
-
-	m.writeLine(line)
+	id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, parsedPath.Kind)
+	if ao, err := p.root().getAPIObjectHelper(parsedPath, false); err == nil {
+		id = ao.jsonnetName
+	}
+	m.hidden(string(typeName), fmt.Sprintf("hidden.%s.%s.%s", group, parsedPath.Version, id))
+	p.emitElementConstructor(m, typeName)
 }
 
 // `emitHelper` emits the Jsonnet program text for a `property`,
@@ -780,8 +1594,9 @@ func (p *property) emitAsTypeAlias(m *indentWriter) {
 // `emitHelper` to emit this property as a normal, non-mixin property
 // method, it is necessary for `parentMixinName == nil`.
 func (p *property) emitHelper(
-	m *indentWriter, parentMixinName *string,
+	m *astWriter, parentMixinName *string,
 ) {
+	defer m.source(p.parent.parsedName.Unparse(), p.name)()
 	if p.kind == typeAlias {
 		p.emitAsTypeAlias(m)
 		return
@@ -790,26 +1605,35 @@ func (p *property) emitHelper(
 	p.comments.emit(m)
 
 	k8sVersion := p.root().spec.Info.Version
-	setterFunctionName := jsonnet.RewriteAsIdentifier(k8sVersion, p.name).ToSetterID()
-	mixinFunctionName := jsonnet.RewriteAsIdentifier(k8sVersion, p.name).ToMixinID()
-	paramName := jsonnet.RewriteAsFuncParam(k8sVersion, p.name)
+	naming := p.root().naming
+	setterFunctionName := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name).ToSetterID(naming)
+	mixinFunctionName := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name).ToMixinID(naming)
+	paramName := jsonnet.RewriteAsFuncParam(naming, k8sVersion, p.name)
 	fieldName := jsonnet.RewriteAsFieldKey(p.name)
-	setterSignature := fmt.Sprintf("%s(%s)::", setterFunctionName, paramName)
-	mixinSignature := fmt.Sprintf("%s(%s)::", mixinFunctionName, paramName)
+	wrap := func(inner string) string {
+		if parentMixinName == nil {
+			return inner
+		}
+		return fmt.Sprintf("%s(%s)", *parentMixinName, inner)
+	}
 
 	if isMixinRef(p.ref) {
-		parsedRefPath := p.ref.Name().Parse()
+		parsedRefPath := p.root().parseRef(p.ref)
 		apiObject := p.root().getAPIObject(parsedRefPath)
-		apiObject.emitAsRefMixins(m, p, parentMixinName)
-	} else if p.ref != nil && !isMixinRef(p.ref) {
-		var body string
-		if parentMixinName == nil {
-			body = fmt.Sprintf("{%s: %s}", fieldName, paramName)
-		} else {
-			body = fmt.Sprintf("%s({%s: %s})", *parentMixinName, fieldName, paramName)
+		if p.root().emitting[apiObject] {
+			p.emitAsRecursiveRef(m, apiObject, wrap)
+			return
 		}
-		line := fmt.Sprintf("%s %s,", setterSignature, body)
-		m.writeLine(line)
+		p.root().emitting[apiObject] = true
+		p.root().emitDocsonnetObject(m, jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name), p.comments)
+		apiObject.emitAsRefMixins(m, p, parentMixinName)
+		delete(p.root().emitting, apiObject)
+	} else if p.intOrString {
+		body := wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName))
+		p.emitDocsonnetFunction(m, setterFunctionName, paramName)
+		m.method(
+			string(setterFunctionName), []string{p.paramWithDefault(paramName)},
+			fmt.Sprintf("%sself + %s", p.setterAssertions(paramName), body))
 	} else if p.schemaType != nil {
 		paramType := *p.schemaType
 
@@ -832,23 +1656,16 @@ func (p *property) emitHelper(
 					"if std.type(%s) == \"array\" then {%s: %s} else {%s: [%s]}",
 					paramName, fieldName, paramName, fieldName, paramName,
 				)
-				mixinBody = fmt.Sprintf(
-					"if std.type(%s) == \"array\" then {%s+: %s} else {%s+: [%s]}",
-					paramName, fieldName, paramName, fieldName, paramName,
-				)
+				mixinBody = p.arrayMixinBody(paramName, false, wrap)
 			} else {
 				setterBody = fmt.Sprintf(
 					"if std.type(%s) == \"array\" then %s({%s: %s}) else %s({%s: [%s]})",
 					paramName, *parentMixinName, fieldName, paramName, *parentMixinName,
 					fieldName, paramName,
 				)
-				mixinBody = fmt.Sprintf(
-					"if std.type(%s) == \"array\" then %s({%s+: %s}) else %s({%s+: [%s]})",
-					paramName, *parentMixinName, fieldName, paramName, *parentMixinName,
-					fieldName, paramName,
-				)
+				mixinBody = p.arrayMixinBody(paramName, false, wrap)
 			}
-		case "integer", "string", "boolean":
+		case "integer", "number", "string", "boolean":
 			if parentMixinName == nil {
 				setterBody = fmt.Sprintf("{%s: %s}", fieldName, paramName)
 			} else {
@@ -864,23 +1681,39 @@ func (p *property) emitHelper(
 				mixinBody = fmt.Sprintf("%s({%s+: %s})", *parentMixinName, fieldName, paramName)
 			}
 		default:
-			log.Panicf("Unrecognized type '%s'", paramType)
+			p.emitAsUnknownType(
+				m, setterFunctionName, fieldName, paramName, wrap,
+				fmt.Sprintf("has unrecognized type '%s'", paramType))
+			return
 		}
 
 		//
 		// Emit.
 		//
 
-		line := fmt.Sprintf("%s self + %s,", setterSignature, setterBody)
-		m.writeLine(line)
+		p.emitDocsonnetFunction(m, setterFunctionName, paramName)
+		m.method(
+			string(setterFunctionName), []string{p.paramWithDefault(paramName)},
+			fmt.Sprintf("%sself + %s", p.setterAssertions(paramName), setterBody))
+		p.emitEnumConstants(m)
 
 		if emitMixin {
 			p.comments.emit(m)
-			line = fmt.Sprintf("%s self + %s,", mixinSignature, mixinBody)
-			m.writeLine(line)
+			p.emitPatchStrategyComment(m)
+			p.emitDocsonnetFunction(m, mixinFunctionName, paramName)
+			m.method(
+				string(mixinFunctionName), []string{string(paramName)},
+				fmt.Sprintf("%sself + %s", p.typeAssertions(paramName), mixinBody))
+			p.emitReplaceByKey(
+				m,
+				jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name).ToReplaceByKeyID(naming, p.mergeKey()),
+				paramName, wrap)
+			p.emitItemSetter(m, p.itemSetterName(), wrap)
+			p.emitAdd(m, p.addFunctionName(), wrap)
 		}
 	} else {
-		log.Panicf("Neither a type nor a ref")
+		p.emitAsUnknownType(
+			m, setterFunctionName, fieldName, paramName, wrap, "has neither a type nor a `$ref`")
 	}
 }
 
@@ -890,15 +1723,27 @@ func (aos propertySet) sortAndFilterBlacklisted() propertySlice {
 		k8sVersion := pm.root().spec.Info.Version
 		var name kubespec.PropertyName
 		if pm.kind == typeAlias {
-			name = kubespec.PropertyName(strings.TrimSuffix(string(pm.name), "Type"))
+			name = pm.aliasOf
 		} else {
 			name = pm.name
 		}
+		// Type aliases are left out along with their property, which is
+		// the one recorded as skipped.
 		if kubeversion.IsBlacklistedProperty(k8sVersion, pm.path, name) {
+			if pm.kind != typeAlias {
+				pm.root().skip(
+					pm.path, pm.name, SkipBlacklisted,
+					"property is blacklisted for Kubernetes version %s", k8sVersion)
+			}
 			continue
 		} else if pm.ref != nil {
-			if parsed := pm.ref.Name().Parse(); parsed.Version == nil {
+			if parsed := pm.root().parseRef(pm.ref); parsed.Version == nil {
 				// TODO: Might want to error out here.
+				if pm.kind != typeAlias {
+					pm.root().skip(
+						pm.path, pm.name, SkipNoVersion,
+						"property refers to '%s', which has no version", *pm.ref.Name())
+				}
 				continue
 			}
 		}
@@ -920,13 +1765,22 @@ func newComments(text string) comments {
 	return strings.Split(text, "\n")
 }
 
-func (cs *comments) emit(m *indentWriter) {
+func (cs *comments) emit(m *astWriter) {
 	for _, comment := range *cs {
+		m.comment(comment)
+	}
+}
+
+// `lines` returns the comments as lines of Jsonnet comments.
+func (cs comments) lines() []string {
+	lines := []string{}
+	for _, comment := range cs {
 		if comment == "" {
 			// Don't create trailing space if comment is empty.
-			m.writeLine("//")
+			lines = append(lines, "//")
 		} else {
-			m.writeLine(fmt.Sprintf("// %s", comment))
+			lines = append(lines, fmt.Sprintf("// %s", comment))
 		}
 	}
+	return lines
 }
@@ -0,0 +1,3694 @@
+package ksonnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-jsonnet/ast"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+func parseSpec(t *testing.T, text string) *kubespec.APISpec {
+	s := kubespec.APISpec{}
+	if err := json.Unmarshal([]byte(text), &s); err != nil {
+		t.Fatalf("Could not deserialize schema:\n%v", err)
+	}
+	s.Text = []byte(text)
+	return &s
+}
+
+var typeAliasCollisionSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Foo": {
+      "description": "Foo has a property that collides with a type alias.",
+      "properties": {
+        "bar": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Bar"},
+        "barType": {"type": "string"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Foo"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Bar": {
+      "properties": {
+        "baz": {"type": "string"}
+      }
+    }
+  }
+}`
+
+func TestEmitTypeAliasCollision(t *testing.T) {
+	_, k8sBytes, report, err := Emit(
+		parseSpec(t, typeAliasCollisionSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(k8sBytes)
+	if !strings.Contains(text, "withBarType(barType)::") {
+		t.Errorf("Expected setter for property 'barType' to be emitted")
+	}
+	if !strings.Contains(text, "barTypeRef:: hidden.core.v1.bar,") {
+		t.Errorf("Expected type alias 'barTypeRef' to be emitted")
+	}
+
+	if len(report.Warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(report.Warnings))
+	}
+	warning := report.Warnings[0]
+	if warning.Path != "io.k8s.kubernetes.pkg.api.v1.Foo" ||
+		!strings.Contains(warning.Message, "barTypeRef") {
+		t.Errorf("Unexpected warning '%s'", warning)
+	}
+}
+
+func TestEmitTypeAliasOverrideCollision(t *testing.T) {
+	defer func(override func(
+		string, kubespec.DefinitionName, kubespec.PropertyName,
+	) (kubespec.PropertyName, bool)) {
+		typeAliasNameOverride = override
+	}(typeAliasNameOverride)
+	typeAliasNameOverride = func(
+		_ string, path kubespec.DefinitionName, propName kubespec.PropertyName,
+	) (kubespec.PropertyName, bool) {
+		if path == "io.k8s.kubernetes.pkg.api.v1.Foo" && propName == "bar" {
+			return "barType", true
+		}
+		return "", false
+	}
+
+	_, k8sBytes, report, err := Emit(
+		parseSpec(t, typeAliasCollisionSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if !strings.Contains(string(k8sBytes), "barTypeRef:: hidden.core.v1.bar,") {
+		t.Errorf("Expected type alias 'barTypeRef' to be emitted")
+	}
+
+	if len(report.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %d", len(report.Warnings))
+	}
+	if warning := report.Warnings[0]; !strings.Contains(warning.Message, "type alias name 'barType' specified") {
+		t.Errorf("Unexpected warning '%s'", warning)
+	}
+}
+
+var kindCollisionSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Binding": {
+      "properties": {"target": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Binding"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.binding": {
+      "properties": {"target": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "binding"}]
+    }
+  }
+}`
+
+func TestEmitKindCollision(t *testing.T) {
+	_, k8sBytes, report, err := Emit(parseSpec(t, kindCollisionSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(k8sBytes)
+	for _, name := range []string{"binding:: {", "bindingKind:: {"} {
+		if !strings.Contains(text, name) {
+			t.Errorf("Expected object '%s' to be emitted", name)
+		}
+	}
+
+	if len(report.Warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d", len(report.Warnings))
+	}
+	if report.Warnings[0].Path != "io.k8s.kubernetes.pkg.api.v1.binding" {
+		t.Errorf("Unexpected warning '%s'", report.Warnings[0])
+	}
+}
+
+func TestEmitObjectNameOverrideCollision(t *testing.T) {
+	defer func(override func(string, kubespec.DefinitionName) (string, bool)) {
+		objectNameOverride = override
+	}(objectNameOverride)
+
+	tests := map[string]struct {
+		names   map[kubespec.DefinitionName]string
+		emitted []string
+		message string
+	}{
+		"computed": {
+			names:   map[kubespec.DefinitionName]string{"io.k8s.kubernetes.pkg.api.v1.Binding": "binding"},
+			emitted: []string{"binding:: {", "bindingKind:: {"},
+			message: "'binding' is the name specified for 'io.k8s.kubernetes.pkg.api.v1.Binding'",
+		},
+		"specified": {
+			names: map[kubespec.DefinitionName]string{
+				"io.k8s.kubernetes.pkg.api.v1.Binding": "target",
+				"io.k8s.kubernetes.pkg.api.v1.binding": "target",
+			},
+			emitted: []string{"target:: {", "targetKind:: {"},
+			message: "the name 'target' specified for it is already used by 'io.k8s.kubernetes.pkg.api.v1.Binding'",
+		},
+	}
+	for name, test := range tests {
+		objectNameOverride = func(_ string, path kubespec.DefinitionName) (string, bool) {
+			name, ok := test.names[path]
+			return name, ok
+		}
+
+		_, k8sBytes, report, err := Emit(parseSpec(t, kindCollisionSpec), nil, nil, Options{})
+		if err != nil {
+			t.Fatalf("[%s] Failed to emit:\n%v", name, err)
+		}
+		for _, object := range test.emitted {
+			if !strings.Contains(string(k8sBytes), object) {
+				t.Errorf("[%s] Expected object '%s' to be emitted", name, object)
+			}
+		}
+
+		if len(report.Warnings) != 1 {
+			t.Fatalf("[%s] Expected 1 error, got %d", name, len(report.Warnings))
+		}
+		warning := report.Warnings[0]
+		if warning.Severity != SeverityError ||
+			warning.Path != "io.k8s.kubernetes.pkg.api.v1.binding" ||
+			!strings.Contains(warning.Message, test.message) {
+			t.Errorf("[%s] Unexpected warning '%s'", name, warning)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	files, report, err := Generate(
+		[]byte(differentialSpec), Options{NameMap: true, JSONSchemas: true})
+	if err != nil {
+		t.Fatalf("Failed to generate:\n%v", err)
+	}
+	if report == nil {
+		t.Fatalf("Expected a report")
+	}
+
+	for _, name := range []string{
+		kFile, k8sFile, NamesFile, "schemas/apps/v1beta1/Widget.json",
+	} {
+		if len(files[name]) == 0 {
+			t.Errorf("Expected artifact '%s' to be generated", name)
+		}
+	}
+
+	if _, _, err := Generate([]byte("{"), Options{}); err == nil {
+		t.Errorf("Expected a malformed spec to be rejected")
+	}
+}
+
+func TestEmitOverlay(t *testing.T) {
+	overlay := `{
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec": {
+      "properties": {
+        "replicas": {"type": "string"},
+        "minReadySeconds": {"type": "integer"}
+      }
+    }
+  }
+}`
+	spec := parseSpec(t, differentialSpec)
+	_, k8sBytes, report, err := Emit(spec, nil, nil, Options{Overlay: []byte(overlay)})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(k8sBytes)
+	if !strings.Contains(text, "withMinReadySeconds(minReadySeconds)::") {
+		t.Errorf("Expected setter for overlaid property 'minReadySeconds' to be emitted")
+	}
+
+	if len(report.Warnings) != 1 ||
+		!strings.Contains(report.Warnings[0].Message, "properties.replicas.type") {
+		t.Errorf("Expected the replaced type to be reported, got %v", report.Warnings)
+	}
+	if _, ok := spec.Definitions["io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec"].Properties["minReadySeconds"]; ok {
+		t.Errorf("Expected the spec passed in to be left untouched")
+	}
+}
+
+func TestEmitHeader(t *testing.T) {
+	spec := parseSpec(t, differentialSpec)
+	sha := "abc123"
+	files, _, err := EmitFiles(spec, &sha, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	expected := "// AUTOGENERATED from the Kubernetes OpenAPI specification. DO NOT MODIFY.\n" +
+		"// Kubernetes version: v1.7.0\n// SHA of ksonnet-lib HEAD: abc123\n\n{"
+	if !strings.HasPrefix(string(files[k8sFile]), expected) {
+		t.Errorf("Expected default header, got:\n%s", files[k8sFile])
+	}
+
+	header := "SPDX-License-Identifier: Apache-2.0\n\nKubernetes {{.KubernetesVersion}} ({{.Backend}})\n"
+	files, _, err = EmitFiles(spec, nil, nil, Options{Header: header, Backend: DhallBackend})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	expected = "-- SPDX-License-Identifier: Apache-2.0\n--\n-- Kubernetes v1.7.0 (dhall)\n\nlet"
+	if !strings.HasPrefix(string(files[dhallFile]), expected) {
+		t.Errorf("Expected custom header, got:\n%s", files[dhallFile])
+	}
+
+	_, _, err = EmitFiles(spec, nil, nil, Options{Header: "{{.Author}}"})
+	if err == nil || !strings.Contains(err.Error(), "Could not execute header template") {
+		t.Errorf("Expected error for header template with unknown field, got: %v", err)
+	}
+}
+
+func TestEmitFormat(t *testing.T) {
+	spec := parseSpec(t, differentialSpec)
+	formatOpts, err := NewFormatOptions(4, 1, "d", "h")
+	if err != nil {
+		t.Fatalf("Failed to build format options:\n%v", err)
+	}
+	files, _, err := EmitFiles(spec, nil, nil, Options{Format: formatOpts, Verify: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	text := string(files[k8sFile])
+	for _, expected := range []string{"# Kubernetes version: v1.7.0\n", "\n    apps:: {\n"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in formatted library, got:\n%s", expected, text)
+		}
+	}
+	if strings.Contains(text, "\n\n\n") {
+		t.Errorf("Expected at most one consecutive blank line, got:\n%s", text)
+	}
+
+	if _, err := NewFormatOptions(2, 2, "x", "s"); err == nil ||
+		!strings.Contains(err.Error(), "Unknown string style 'x'") {
+		t.Errorf("Expected error for unknown string style, got: %v", err)
+	}
+}
+
+func TestEmitStripped(t *testing.T) {
+	spec := parseSpec(t, differentialSpec)
+	full, _, err := EmitFiles(spec, nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	header := string(full[k8sFile][:strings.Index(string(full[k8sFile]), "\n\n")+2])
+
+	for _, opts := range []Options{{NoComments: true, Verify: true}, {Minify: true, Verify: true}} {
+		files, _, err := EmitFiles(spec, nil, nil, opts)
+		if err != nil {
+			t.Fatalf("Failed to emit %+v:\n%v", opts, err)
+		}
+		text := string(files[k8sFile])
+		if !strings.HasPrefix(text, header) {
+			t.Errorf("Expected the header in stripped library, got:\n%s", text)
+		}
+		body := strings.TrimPrefix(text, header)
+		if strings.Contains(body, "//") || strings.Contains(body, "\n\n") {
+			t.Errorf("Expected no comments or blank lines in stripped library, got:\n%s", body)
+		}
+		if len(text) >= len(full[k8sFile]) {
+			t.Errorf("Expected stripped library to be smaller than the full one")
+		}
+		if opts.Minify && strings.Count(strings.TrimSpace(body), "\n") != 0 {
+			t.Errorf("Expected minified library on a single line, got:\n%s", body)
+		}
+
+		programs := map[string]string{
+			"full":     fmt.Sprintf("std.objectFieldsAll((import %q).apps.v1beta1)", "full/"+k8sFile),
+			"stripped": fmt.Sprintf("std.objectFieldsAll((import %q).apps.v1beta1)", k8sFile),
+		}
+		files["full/"+k8sFile] = full[k8sFile]
+		outputs, errs := evaluate(files, programs)
+		for name, err := range errs {
+			t.Fatalf("[%s] Failed to evaluate:\n%v", name, err)
+		}
+		if outputs["stripped"] != outputs["full"] {
+			t.Errorf("Expected the same fields as the full library, got:\n%s", outputs["stripped"])
+		}
+	}
+}
+
+func TestEmitDiffAnchors(t *testing.T) {
+	spec := parseSpec(t, differentialSpec)
+	files, _, err := EmitFiles(spec, nil, nil, Options{DiffAnchors: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	text := string(files[k8sFile])
+	for _, expected := range []string{
+		"// --- apps.v1beta1.widget ---\n",
+		"// --- hidden.apps.v1beta1.widgetSpec ---\n",
+		"// --- hidden.meta.v1.objectMeta ---\n",
+	} {
+		if strings.Count(text, expected) != 1 {
+			t.Errorf("Expected anchor '%s' once in library, got:\n%s", expected, text)
+		}
+	}
+
+	files, _, err = EmitFiles(spec, nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if strings.Contains(string(files[k8sFile]), "// --- ") {
+		t.Errorf("Expected no anchors by default")
+	}
+}
+
+func TestValidateJsonnet(t *testing.T) {
+	root, err := newRoot(parseSpec(t, differentialSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to build model:\n%v", err)
+	}
+	files := map[string][]byte{
+		k8sFile: []byte(`{
+  apps:: {
+    v1beta1:: {
+      widget:: {
+        new():: {},
+        withName(name):: {name: name} +,
+      },
+    },
+  },
+}
+`),
+		"README.md": []byte("Not Jsonnet {"),
+	}
+	err = root.validateJsonnet(files)
+	expected := "Generated invalid Jsonnet in 'k8s.libsonnet' at line 6, " +
+		"in the code of 'io.k8s.kubernetes.pkg.apis.apps.v1beta1.Widget':\n" +
+		"  withName(name):: {name: name} +,\n"
+	if err == nil || !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("Expected error starting with '%s', got: %v", expected, err)
+	}
+
+	files[k8sFile] = []byte("{\n  apps:: {},\n}\n")
+	if err := root.validateJsonnet(files); err != nil {
+		t.Errorf("Expected valid Jsonnet, got: %v", err)
+	}
+}
+
+func TestEmitSourceMap(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, differentialSpec), nil, nil, Options{SourceMap: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	sourceMap := SourceMap{}
+	if err := json.Unmarshal(files[SourceMapFile], &sourceMap); err != nil {
+		t.Fatalf("Failed to deserialize source map:\n%v", err)
+	}
+
+	lines := strings.Split(string(files[k8sFile]), "\n")
+	found := false
+	for _, entry := range sourceMap.Entries {
+		if entry.Path != "apps.v1beta1.widget.mixin.metadata.withName" {
+			continue
+		}
+		found = true
+		if entry.Definition != "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta" || entry.Property != "name" {
+			t.Errorf("Expected 'withName' to map to 'ObjectMeta.name', got: %+v", entry)
+		}
+		if !strings.Contains(lines[entry.EndLine-1], "withName(name)::") {
+			t.Errorf("Expected entry to end on 'withName', got '%s'", lines[entry.EndLine-1])
+		}
+	}
+	if !found {
+		t.Errorf("Expected an entry for 'withName', got:\n%s", files[SourceMapFile])
+	}
+
+	formatOpts, _ := NewFormatOptions(2, 2, "s", "s")
+	_, _, err = EmitFiles(
+		parseSpec(t, differentialSpec), nil, nil, Options{SourceMap: true, Format: formatOpts})
+	if err == nil || !strings.Contains(err.Error(), "formatted or stripped library") {
+		t.Errorf("Expected error for source map of formatted library, got: %v", err)
+	}
+}
+
+var skippedSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Pod": {
+      "properties": {
+        "raw": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.runtime.RawExtension"},
+        "status": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodStatus"},
+        "weird": {"type": "tuple"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Pod"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.PodStatus": {
+      "properties": {"phase": {"type": "string"}}
+    },
+    "io.k8s.apimachinery.pkg.runtime.RawExtension": {
+      "properties": {"Raw": {"type": "string"}}
+    }
+  }
+}`
+
+func TestEmitSkippedReport(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, skippedSpec), nil, nil, Options{SkippedReport: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	skipped := Skipped{}
+	if err := json.Unmarshal(files[SkippedFile], &skipped); err != nil {
+		t.Fatalf("Failed to parse skipped report:\n%v", err)
+	}
+
+	pod := kubespec.DefinitionName("io.k8s.kubernetes.pkg.api.v1.Pod")
+	expected := []SkippedEntry{
+		{Definition: "io.k8s.apimachinery.pkg.runtime.RawExtension", Reason: SkipNoVersion},
+		{Definition: pod, Property: "raw", Reason: SkipNoVersion},
+		{Definition: pod, Property: "status", Reason: SkipBlacklisted},
+		{Definition: pod, Property: "weird", Reason: SkipUnsupportedType},
+	}
+	if skipped.KubernetesVersion != "v1.7.0" || len(skipped.Entries) != len(expected) {
+		t.Fatalf("Expected %d entries for v1.7.0, got:\n%s", len(expected), files[SkippedFile])
+	}
+	for i, entry := range skipped.Entries {
+		entry.Detail = ""
+		if entry != expected[i] {
+			t.Errorf("Expected entry %+v, got %+v", expected[i], entry)
+		}
+	}
+
+	files, _, err = EmitFiles(parseSpec(t, skippedSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if _, ok := files[SkippedFile]; ok {
+		t.Errorf("Expected no skipped report by default")
+	}
+}
+
+func TestReportFails(t *testing.T) {
+	warning := Warning{Path: "io.k8s.kubernetes.pkg.api.v1.Foo", Severity: SeverityWarning}
+	dropped := Warning{Path: "io.k8s.kubernetes.pkg.api.v1.Bar", Severity: SeverityError}
+	tests := map[string]struct {
+		warnings []Warning
+		failOn   string
+		fails    bool
+	}{
+		"never fails":              {[]Warning{warning, dropped}, FailOnNever, false},
+		"warning fails on warning": {[]Warning{warning}, FailOnWarning, true},
+		"warning fails on error":   {[]Warning{dropped}, FailOnWarning, true},
+		"error ignores warning":    {[]Warning{warning}, FailOnError, false},
+		"error fails on error":     {[]Warning{warning, dropped}, FailOnError, true},
+		"no warnings never fails":  {[]Warning{}, FailOnWarning, false},
+	}
+
+	for name, test := range tests {
+		report := &Report{Warnings: test.warnings}
+		fails, err := report.Fails(test.failOn)
+		if err != nil {
+			t.Errorf("[%s] Unexpected error:\n%v", name, err)
+		} else if fails != test.fails {
+			t.Errorf("[%s] Expected Fails to return %v, got %v", name, test.fails, fails)
+		}
+	}
+
+	if _, err := (&Report{}).Fails("sometimes"); err == nil {
+		t.Errorf("Expected unrecognized level to be rejected, even without warnings")
+	}
+}
+
+func TestEmitReportsSeverity(t *testing.T) {
+	_, _, report, err := Emit(parseSpec(t, typeAliasCollisionSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0].Severity != SeverityWarning {
+		t.Fatalf("Expected a single renaming warning, got %v", report.Warnings)
+	}
+	if fails, _ := report.Fails(FailOnError); fails {
+		t.Errorf("Expected a renamed type alias not to fail with --fail-on=error")
+	}
+	if fails, _ := report.Fails(FailOnWarning); !fails {
+		t.Errorf("Expected a renamed type alias to fail with --fail-on=warning")
+	}
+}
+
+var groupVersionKindSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Widget": {
+      "properties": {
+        "kind": {"type": "string"},
+        "size": {"type": "integer"}
+      },
+      "x-kubernetes-group-version-kind": [
+        {"Group": "gadgets.example.com", "Version": "v1", "Kind": "LegacyGadget"},
+        {"Group": "gadgets.example.com", "Version": "v1", "Kind": "Widget"}
+      ]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Holder": {
+      "properties": {
+        "widget": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.Widget"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Holder"}]
+    }
+  }
+}`
+
+func TestEmitGroupVersionKind(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, groupVersionKindSpec), nil, nil, Options{JSONSchemas: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	programs := map[string]string{
+		"widget": "k8s.gadgets.v1.widget.new() + k8s.gadgets.v1.widget.withSize(3)",
+		"holder": "k8s.core.v1.holder.new() + k8s.core.v1.holder.mixin.widget.withSize(3)",
+	}
+	tests := map[string]string{
+		"widget": `{"apiVersion":"gadgets.example.com/v1","kind":"Widget","size":3}`,
+		"holder": `{"apiVersion":"v1","kind":"Holder","widget":{"size":3}}`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if actual.String() != expected {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, expected, actual.String())
+		}
+	}
+
+	if _, ok := files["schemas/gadgets/v1/Widget.json"]; !ok {
+		t.Errorf("Expected the schema of 'Widget' to be found under its declared group")
+	}
+}
+
+var builtinShadowingSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "std": {"type": "array", "items": {"type": "string"}},
+        "$ref": {"type": "string"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitBuiltinShadowing(t *testing.T) {
+	files, report, err := EmitFiles(
+		parseSpec(t, builtinShadowingSpec), nil, nil, Options{Verify: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files[k8sFile])
+	tests := map[string]string{
+		"std":  `withStd(stdParam):: self + if std.type(stdParam) == "array" then {std: stdParam} else {std: [stdParam]},`,
+		"$ref": `withRef(ref):: self + {"$ref": ref},`,
+	}
+	for name, expected := range tests {
+		if !strings.Contains(text, expected) {
+			t.Errorf("[%s] Expected '%s' in emitted library", name, expected)
+		}
+	}
+
+	if len(report.Warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %d", len(report.Warnings))
+	}
+	for i, param := range []string{"ref", "stdParam"} {
+		if !strings.Contains(report.Warnings[i].Message, param) {
+			t.Errorf("Unexpected warning '%s'", report.Warnings[i])
+		}
+	}
+}
+
+var namedConstructorSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "properties": {
+        "name": {"type": "string"}
+      }
+    }
+  }
+}`
+
+func TestEmitNamedConstructors(t *testing.T) {
+	spec := parseSpec(t, namedConstructorSpec)
+	_, k8sBytes, _, err := Emit(spec, nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if !strings.Contains(string(k8sBytes), "new():: apiVersion + kind,") {
+		t.Errorf("Expected zero-argument constructor by default")
+	}
+
+	_, k8sBytes, _, err = Emit(
+		spec, nil, nil, Options{ConstructorsTakeName: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	expected := "new(name, namespace=null):: apiVersion + kind + self.mixin.metadata.mixinInstance({name: name} + if namespace == null then {} else {namespace: namespace}),"
+	if !strings.Contains(string(k8sBytes), expected) {
+		t.Errorf("Expected constructor '%s'", expected)
+	}
+
+	// A custom `new` that takes more than the name is kept, and the
+	// named constructor is `newNamed`.
+	files, _, err := EmitFiles(
+		parseSpec(t, customConstructorSpec), nil, nil, Options{ConstructorsTakeName: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	for _, text := range []string{"new(name, selector, ports)::", "newNamed(name, namespace=null)::"} {
+		if !strings.Contains(string(files[k8sFile]), text) {
+			t.Errorf("Expected constructor '%s'", text)
+		}
+	}
+
+	programs := map[string]string{
+		"name":      "k8s.core.v1.service.newNamed(\"foo\")",
+		"namespace": "k8s.core.v1.service.newNamed(\"foo\", \"bar\")",
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+	outputs, errs := evaluate(files, programs)
+	tests := map[string]string{
+		"name":      `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "foo"}}`,
+		"namespace": `{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "foo", "namespace": "bar"}}`,
+	}
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Fatalf("[%s] Failed to evaluate:\n%v", name, err)
+		}
+		var actual, want interface{}
+		json.Unmarshal([]byte(outputs[name]), &actual)
+		json.Unmarshal([]byte(expected), &want)
+		if !reflect.DeepEqual(actual, want) {
+			t.Errorf("[%s] Expected %s, got:\n%s", name, expected, outputs[name])
+		}
+	}
+}
+
+var customConstructorSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Service": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.ServiceSpec"},
+        "volume": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Volume"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Service"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.ServiceSpec": {
+      "properties": {
+        "ports": {"type": "array", "items": {"type": "object"}},
+        "selector": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Volume": {
+      "properties": {
+        "name": {"type": "string"},
+        "emptyDir": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.EmptyDirVolumeSource"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.EmptyDirVolumeSource": {
+      "properties": {
+        "medium": {"type": "string"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "properties": {
+        "name": {"type": "string"}
+      }
+    }
+  }
+}`
+
+func TestEmitCustomConstructors(t *testing.T) {
+	_, k8sBytes, _, err := Emit(parseSpec(t, customConstructorSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	// The properties custom constructors set are named by path, and
+	// set with their setters, or with the `mixinInstance` of their
+	// namespace if they refer to another object.
+	expected := []string{
+		"new(name, selector, ports):: apiVersion + kind + self.mixin.metadata.withName(name) + self.mixin.spec.withSelector(selector) + self.mixin.spec.withPorts(ports),",
+		"fromEmptyDir(name, emptyDir={}):: {} + self.withName(name) + self.mixin.emptyDir.mixinInstance(emptyDir),",
+	}
+	for _, text := range expected {
+		if !strings.Contains(string(k8sBytes), text) {
+			t.Errorf("Expected constructor '%s'", text)
+		}
+	}
+}
+
+func TestEmitSnippets(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, customConstructorSpec), nil, nil, Options{Snippets: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	snippets := map[string]Snippet{}
+	if err := json.Unmarshal(files[SnippetsFile], &snippets); err != nil {
+		t.Fatalf("Failed to parse snippets:\n%v", err)
+	}
+
+	expected := map[string]string{
+		"k.service.new":         "k.core.v1.service.new(${1:name}, ${2:selector}, ${3:ports})",
+		"k.volume.fromEmptyDir": "k.core.v1.service.mixin.volumeType.fromEmptyDir(${1:name})",
+	}
+	for _, snippet := range snippets {
+		if body, ok := expected[snippet.Prefix]; ok {
+			if len(snippet.Body) != 1 || snippet.Body[0] != body {
+				t.Errorf("Expected snippet '%s' to expand to '%s', got %v", snippet.Prefix, body, snippet.Body)
+			}
+			delete(expected, snippet.Prefix)
+		}
+	}
+	for prefix := range expected {
+		t.Errorf("Expected snippet '%s', got:\n%s", prefix, files[SnippetsFile])
+	}
+
+	// The paths of the snippets are those of the library.
+	programs := map[string]string{
+		"volume": fmt.Sprintf(
+			"local k = import %q; k.core.v1.service.mixin.volumeType.fromEmptyDir(\"cache\")", kFile),
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["volume"]; ok {
+		t.Fatalf("Failed to evaluate snippet:\n%v", err)
+	}
+	if !strings.Contains(outputs["volume"], `"emptyDir": { }`) {
+		t.Errorf("Expected a volume, got:\n%s", outputs["volume"])
+	}
+
+	_, _, err = EmitFiles(
+		parseSpec(t, customConstructorSpec), nil, nil, Options{Snippets: true, Backend: DhallBackend})
+	if err == nil || !strings.Contains(err.Error(), "Snippets require") {
+		t.Errorf("Expected error for snippets of another backend, got: %v", err)
+	}
+}
+
+func TestEmitStarlark(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, namedConstructorSpec), nil, nil, Options{Backend: StarlarkBackend})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text, ok := files["k8s.star"]
+	if !ok || len(files) != 1 {
+		t.Fatalf("Expected only 'k8s.star' to be emitted, got %d files", len(files))
+	}
+	for _, expected := range []string{
+		"def core_v1_widget(metadata = None):",
+		`"apiVersion": "v1",`,
+		`"kind": "Widget",`,
+		"def meta_v1_object_meta(name = None):",
+	} {
+		if !strings.Contains(string(text), expected) {
+			t.Errorf("Expected '%s' in emitted Starlark", expected)
+		}
+	}
+
+	_, _, err = EmitFiles(
+		parseSpec(t, namedConstructorSpec), nil, nil, Options{Backend: "cobol"})
+	if err == nil {
+		t.Errorf("Expected unrecognized backend to fail")
+	}
+}
+
+func TestEmitDhall(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, namedConstructorSpec), nil, nil, Options{Backend: DhallBackend})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files["k8s.dhall"])
+	for _, expected := range []string{
+		"let core_v1_Widget =",
+		"{ apiVersion : Text",
+		", metadata : Optional meta_v1_ObjectMeta.Type",
+		`{ apiVersion = "v1"`,
+		"{ Widget = core_v1_Widget",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted Dhall", expected)
+		}
+	}
+
+	// `ObjectMeta` must be defined before `Widget`, which refers to it.
+	if strings.Index(text, "let meta_v1_ObjectMeta =") > strings.Index(text, "let core_v1_Widget =") {
+		t.Errorf("Expected 'ObjectMeta' to be defined before 'Widget'")
+	}
+}
+
+func TestEmitKustomizeHelpers(t *testing.T) {
+	spec := parseSpec(t, namedConstructorSpec)
+	_, k8sBytes, _, err := Emit(spec, nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if strings.Contains(string(k8sBytes), "kustomize::") {
+		t.Errorf("Expected no kustomize helpers by default")
+	}
+
+	_, k8sBytes, _, err = Emit(spec, nil, nil, Options{KustomizeHelpers: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	expected := `json6902Target(name, namespace=null):: {version: "v1", kind: "Widget", name: name}`
+	if !strings.Contains(string(k8sBytes), expected) {
+		t.Errorf("Expected kustomize target '%s'", expected)
+	}
+}
+
+func TestEmitTestSuite(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, namedConstructorSpec), nil, nil, Options{TestSuite: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files["k8s_test.jsonnet"])
+	for _, expected := range []string{
+		`"core.v1.widget.new": {`,
+		"actual: k8s.core.v1.widget.new() + k8s.core.v1.widget.mixin.metadata.withName(\"test\"),",
+		`expect: {apiVersion: "v1", kind: "Widget"} + {metadata+: {name: "test"}},`,
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted test suite", expected)
+		}
+	}
+
+	// Custom constructors are tested too, with made-up arguments, as
+	// are those of objects that are not top-level, through a type alias.
+	files, _, err = EmitFiles(
+		parseSpec(t, customConstructorSpec), nil, nil, Options{TestSuite: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	text = string(files["k8s_test.jsonnet"])
+	for _, expected := range []string{
+		`actual: local o = k8s.core.v1.service.new("test", {}, {}); [o.apiVersion, o.kind, o["metadata"]["name"], o["spec"]["selector"], o["spec"]["ports"]],`,
+		`expect: ["v1", "Service", "test", {}, [{}]],`,
+		`actual: local o = k8s.core.v1.service.mixin.volumeType.fromEmptyDir("test"); [o["name"]],`,
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted test suite, got:\n%s", expected, text)
+		}
+	}
+	outputs, errs := evaluate(files, map[string]string{"suite": "import \"k8s_test.jsonnet\""})
+	if err, ok := errs["suite"]; ok {
+		t.Fatalf("Failed to run test suite:\n%v", err)
+	}
+	if !strings.Contains(outputs["suite"], `"passed": 3`) {
+		t.Errorf("Expected the tests to pass, got:\n%s", outputs["suite"])
+	}
+}
+
+func TestEmitRenderHelpers(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, namedConstructorSpec), nil, nil, Options{RenderHelpers: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	widget := `k8s.core.v1.widget.new() + k8s.core.v1.widget.mixin.metadata.withName("w")`
+	tests := map[string]string{
+		"yaml":      "\"apiVersion\": \"v1\"\n\"kind\": \"Widget\"\n\"metadata\":\n  \"name\": \"w\"",
+		"json":      "{\n  \"apiVersion\": \"v1\",",
+		"list":      "---\n\"apiVersion\": \"v1\"",
+		"wrongKind": "expected an object of kind 'Widget'",
+	}
+	programs := map[string]string{
+		"yaml":      fmt.Sprintf("k8s.core.v1.widget.renderYaml(%s)", widget),
+		"json":      fmt.Sprintf("k8s.core.v1.widget.renderJson(%s)", widget),
+		"list":      fmt.Sprintf("k8s.renderYamlList({kind: \"List\", items: [%s, %s]})", widget, widget),
+		"wrongKind": "k8s.core.v1.widget.renderYaml({kind: \"Gadget\"})",
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		var actual string
+		if err, ok := errs[name]; ok {
+			actual = err.Error()
+		} else if err := json.Unmarshal([]byte(outputs[name]), &actual); err != nil {
+			t.Fatalf("[%s] Expected a string, got:\n%s", name, outputs[name])
+		}
+		if !strings.Contains(actual, expected) {
+			t.Errorf("[%s] Expected '%s' in:\n%s", name, expected, actual)
+		}
+	}
+}
+
+func TestEmitFromJSON(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, namedConstructorSpec), nil, nil, Options{FromJSON: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	widget := "k8s.core.v1.widget"
+	tests := map[string]string{
+		"manifest":        `{"apiVersion":"v1","kind":"Widget","metadata":{"name":"w"}}`,
+		"wrongKind":       "Expected a manifest of kind 'Widget', got 'Gadget'",
+		"wrongAPIVersion": "Expected a manifest of apiVersion 'v1', got 'v2'",
+		"notAnObject":     "Expected a manifest of kind 'Widget', got array",
+	}
+	programs := map[string]string{
+		"manifest":        fmt.Sprintf(`%[1]s.fromJson({kind: "Widget", metadata: {name: "x"}}) + %[1]s.mixin.metadata.withName("w")`, widget),
+		"wrongKind":       fmt.Sprintf(`%s.fromJson({kind: "Gadget"})`, widget),
+		"wrongAPIVersion": fmt.Sprintf(`%s.fromJson({apiVersion: "v2"})`, widget),
+		"notAnObject":     fmt.Sprintf(`%s.fromJson([])`, widget),
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		var actual string
+		if err, ok := errs[name]; ok {
+			actual = err.Error()
+		} else {
+			compacted := bytes.Buffer{}
+			if err := json.Compact(&compacted, []byte(outputs[name])); err != nil {
+				t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+			}
+			actual = compacted.String()
+		}
+		if !strings.Contains(actual, expected) {
+			t.Errorf("[%s] Expected '%s' in:\n%s", name, expected, actual)
+		}
+	}
+}
+
+// `docUtilStub` stands in for docsonnet's `doc-util`, returning its
+// arguments, so that tests can check the annotations of the library.
+const docUtilStub = `{
+  pkg(name, url, help):: {name: name, help: help},
+  obj(help):: {help: help},
+  fn(help, args=[]):: {help: help, args: args},
+  arg(name, type):: {name: name, type: type},
+  T:: {string: "string", number: "number", bool: "bool", object: "object", array: "array", any: "any"},
+}`
+
+func TestEmitDocsonnet(t *testing.T) {
+	spec := parseSpec(t, customConstructorSpec)
+	files, _, err := EmitFiles(spec, nil, nil, Options{Docsonnet: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	files[docsonnetImport] = []byte(docUtilStub)
+
+	service := fmt.Sprintf("(import %q).core.v1.service", k8sFile)
+	tests := map[string]string{
+		"package":  `{"help":"","name":"service"}`,
+		"new":      `{"args":[{"name":"name","type":"string"},{"name":"selector","type":"object"},{"name":"ports","type":"array"}],"help":"Creates a new ` + "`Service`" + `."}`,
+		"setter":   `{"args":[{"name":"selector","type":"object"}],"help":""}`,
+		"instance": `{"args":[{"name":"spec","type":"object"}],"help":""}`,
+		"version":  `{"help":"Version ` + "`v1`" + ` of the API.","name":"v1"}`,
+	}
+	programs := map[string]string{
+		"package":  fmt.Sprintf(`%s["#"]`, service),
+		"new":      fmt.Sprintf(`%s["#new"]`, service),
+		"setter":   fmt.Sprintf(`%s.mixin.spec["#withSelector"]`, service),
+		"instance": fmt.Sprintf(`%s.mixin.spec["#mixinInstance"]`, service),
+		"version":  fmt.Sprintf(`(import %q).core.v1["#"]`, k8sFile),
+	}
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if actual.String() != expected {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, expected, actual.String())
+		}
+	}
+
+	_, _, err = EmitFiles(spec, nil, nil, Options{Docsonnet: true, Backend: DhallBackend})
+	if err == nil || !strings.Contains(err.Error(), "Docsonnet annotations require") {
+		t.Errorf("Expected error for docsonnet annotations with the dhall backend, got: %v", err)
+	}
+}
+
+func TestEmitReflectionIndex(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, differentialSpec), nil, nil, Options{ReflectionIndex: true, RenderHelpers: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	programs := map[string]string{
+		"widget": fmt.Sprintf("local k8s = import %q; k8s.apps.v1beta1.__index.widget", k8sFile),
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["widget"]; ok {
+		t.Fatalf("Failed to evaluate index:\n%v", err)
+	}
+
+	index := struct {
+		APIVersion string   `json:"apiVersion"`
+		Kind       string   `json:"kind"`
+		Functions  []string `json:"functions"`
+		Mixins     []string `json:"mixins"`
+	}{}
+	if err := json.Unmarshal([]byte(outputs["widget"]), &index); err != nil {
+		t.Fatalf("Could not deserialize index:\n%v", err)
+	}
+	if index.APIVersion != "apps/v1beta1" || index.Kind != "Widget" {
+		t.Errorf("Unexpected apiVersion '%s' and kind '%s'", index.APIVersion, index.Kind)
+	}
+	if strings.Join(index.Functions, ",") != "mixinInstance,new,renderJson,renderYaml" {
+		t.Errorf("Unexpected functions %v", index.Functions)
+	}
+	if strings.Join(index.Mixins, ",") != "metadata,spec" {
+		t.Errorf("Unexpected mixins %v", index.Mixins)
+	}
+
+	_, k8sBytes, _, err := Emit(parseSpec(t, differentialSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if strings.Contains(string(k8sBytes), reflectionIndexName) {
+		t.Errorf("Expected no index to be emitted by default")
+	}
+}
+
+func TestEmitUtilHelpers(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, differentialSpec), nil, nil, Options{UtilHelpers: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	tests := map[string]string{
+		"mergePatch":    `{"a":1,"b":{"d":3,"e":4}}`,
+		"removeField":   `{"spec":{"paused":true}}`,
+		"removeMissing": `{"spec":{"replicas":3}}`,
+		"mapValues":     `{"a":2,"b":4}`,
+		"pruneNulls":    `{"a":[1,{}]}`,
+	}
+	programs := map[string]string{
+		"mergePatch":    `k8s.util.mergePatch({a: 1, b: {c: 2, d: 3}}, {b: {c: null, e: 4}})`,
+		"removeField":   `k8s.util.removeField({spec: {replicas: 3, paused: true}}, "spec.replicas")`,
+		"removeMissing": `k8s.util.removeField({spec: {replicas: 3}}, ["spec", "paused", "x"])`,
+		"mapValues":     `k8s.util.mapValues(function(v) v * 2, {a: 1, b: 2})`,
+		"pruneNulls":    `k8s.util.pruneNulls({a: [1, null, {c: null}], b: null})`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if actual.String() != expected {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, expected, actual.String())
+		}
+	}
+}
+
+var constraintsSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "name": {"type": "string", "pattern": "^[a-z]+$", "minLength": 1, "maxLength": 5},
+        "replicas": {"type": "integer", "minimum": 0, "maximum": 10},
+        "ports": {"type": "array", "items": {"type": "integer"}, "maxItems": 2, "uniqueItems": true},
+        "weight": {"type": "number", "minimum": 0, "exclusiveMinimum": true, "maximum": 1, "exclusiveMaximum": true}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitStrictConstraints(t *testing.T) {
+	spec := parseSpec(t, constraintsSpec)
+	_, k8sBytes, _, err := Emit(spec, nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if strings.Contains(string(k8sBytes), "assert") {
+		t.Errorf("Expected no assertions by default")
+	}
+
+	files, _, err := EmitFiles(spec, nil, nil, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if !strings.Contains(string(files[k8sFile]), `std.native("regexMatch")("^[a-z]+$", name)`) {
+		t.Errorf("Expected the pattern of 'name' to be checked")
+	}
+
+	tests := map[string]string{
+		"valid":      "",
+		"tooShort":   "name must be at least 1 characters long",
+		"tooLong":    "name must be at most 5 characters long",
+		"tooFew":     "replicas must be at least 0",
+		"tooMany":    "replicas must be at most 10",
+		"tooManyArr": "ports must have at most 2 items",
+		"duplicates": "ports must not have duplicate items",
+		"weightMin":  "weight must be greater than 0",
+		"weightMax":  "weight must be less than 1",
+	}
+	widget := "k8s.core.v1.widget"
+	programs := map[string]string{
+		"valid":      fmt.Sprintf(`%[1]s.withName("abc") + %[1]s.withReplicas(10) + %[1]s.withPorts(80) + %[1]s.withWeight(0.5)`, widget),
+		"tooShort":   fmt.Sprintf(`%s.withName("")`, widget),
+		"tooLong":    fmt.Sprintf(`%s.withName("abcdef")`, widget),
+		"tooFew":     fmt.Sprintf(`%s.withReplicas(-1)`, widget),
+		"tooMany":    fmt.Sprintf(`%s.withReplicas(11)`, widget),
+		"tooManyArr": fmt.Sprintf(`%s.withPorts([1, 2, 3])`, widget),
+		"duplicates": fmt.Sprintf(`%s.withPorts([80, 80])`, widget),
+		"weightMin":  fmt.Sprintf(`%s.withWeight(0)`, widget),
+		"weightMax":  fmt.Sprintf(`%s.withWeight(1)`, widget),
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	_, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		err, failed := errs[name]
+		switch {
+		case expected == "" && failed:
+			t.Errorf("[%s] Expected no error, got:\n%v", name, err)
+		case expected != "" && !failed:
+			t.Errorf("[%s] Expected error '%s'", name, expected)
+		case expected != "" && !strings.Contains(err.Error(), expected):
+			t.Errorf("[%s] Expected error '%s', got:\n%v", name, expected, err)
+		}
+	}
+}
+
+func TestEmitExposeTypes(t *testing.T) {
+	spec := parseSpec(t, differentialSpec)
+	for _, split := range []string{"", SplitGroup} {
+		files, _, err := EmitFiles(spec, nil, nil, Options{ExposeTypes: true, Split: split})
+		if err != nil {
+			t.Fatalf("Failed to emit:\n%v", err)
+		}
+		programs := map[string]string{
+			"types": `local k = import "k8s.libsonnet";
+k.types.apps.v1beta1.widgetSpec.withReplicas(3)`,
+		}
+		outputs, errs := evaluate(files, programs)
+		if err, ok := errs["types"]; ok {
+			t.Fatalf("[split=%s] Failed to evaluate:\n%v", split, err)
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs["types"])); err != nil {
+			t.Fatalf("Expected JSON, got:\n%s", outputs["types"])
+		}
+		if expected := `{"replicas":3}`; actual.String() != expected {
+			t.Errorf("[split=%s] Expected '%s', got '%s'", split, expected, actual.String())
+		}
+	}
+
+	files, _, err := EmitFiles(spec, nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if strings.Contains(string(files[k8sFile]), "types:: {") {
+		t.Errorf("Expected no 'types' namespace by default")
+	}
+}
+
+var shortNamesSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.extensions.v1beta1.Deployment": {
+      "properties": {"status": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "extensions", "Version": "v1beta1", "Kind": "Deployment"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Deployment": {
+      "properties": {"status": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Deployment"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.autoscaling.v2alpha1.HorizontalPodAutoscaler": {
+      "properties": {"status": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "autoscaling", "Version": "v2alpha1", "Kind": "HorizontalPodAutoscaler"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.autoscaling.v1.HorizontalPodAutoscaler": {
+      "properties": {"status": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "autoscaling", "Version": "v1", "Kind": "HorizontalPodAutoscaler"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.sts.v1.Widget": {
+      "properties": {"status": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "sts", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.StatefulSet": {
+      "properties": {"status": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "StatefulSet"}]
+    }
+  }
+}`
+
+func TestEmitShortNames(t *testing.T) {
+	files, report, err := EmitFiles(parseSpec(t, shortNamesSpec), nil, nil, Options{ShortNames: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	text := string(files[k8sFile])
+	for _, expected := range []string{
+		"deploy:: self.apps.v1beta1.deployment,",
+		"hpa:: self.autoscaling.v1.horizontalPodAutoscaler,",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted library, got:\n%s", expected, text)
+		}
+	}
+	if strings.Contains(text, "sts:: self.") {
+		t.Errorf("Expected short name that collides with a group not to be emitted, got:\n%s", text)
+	}
+	found := false
+	for _, warning := range report.Warnings {
+		found = found || strings.Contains(warning.Message, "short name 'sts' not emitted")
+	}
+	if !found {
+		t.Errorf("Expected collision of short name 'sts' to be reported, got: %v", report.Warnings)
+	}
+
+	programs := map[string]string{
+		"deploy": `local k = import "k8s.libsonnet"; (k + {apps+:: {v1beta1+:: {deployment+:: {x:: 1}}}}).deploy.x`,
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["deploy"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	if strings.TrimSpace(outputs["deploy"]) != "1" {
+		t.Errorf("Expected alias to be late-bound, got '%s'", outputs["deploy"])
+	}
+}
+
+func TestEmitGVKLookup(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, shortNamesSpec), nil, nil, Options{GVKLookup: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	k8s := fmt.Sprintf(
+		"local k = (import %q) + {apps+:: {v1beta1+:: {deployment+:: {x:: 1}}}, extensions+:: {v1beta1+:: {deployment+:: {x:: 2}}}}; ",
+		k8sFile)
+	tests := map[string]string{
+		"byGVK":  `k.byGVK("extensions", "v1beta1", "Deployment").x`,
+		"byKind": `k.byKind("Deployment").x`,
+		"gvk":    `std.objectFields(k.gvk.autoscaling)`,
+	}
+	expected := map[string]string{
+		"byGVK":  `2`,
+		"byKind": `1`,
+		"gvk":    `["v1", "v2alpha1"]`,
+	}
+	programs := map[string]string{
+		"unknownVersion": k8s + `k.byGVK("apps", "v1", "Deployment")`,
+		"unknownKind":    k8s + `k.byKind("Widget2")`,
+	}
+	for name, program := range tests {
+		programs[name] = k8s + program
+	}
+	outputs, errs := evaluate(files, programs)
+	for name, want := range expected {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		var actual, wanted interface{}
+		json.Unmarshal([]byte(outputs[name]), &actual)
+		json.Unmarshal([]byte(want), &wanted)
+		if !reflect.DeepEqual(actual, wanted) {
+			t.Errorf("[%s] Expected %s, got:\n%s", name, want, outputs[name])
+		}
+	}
+	for name, want := range map[string]string{
+		"unknownVersion": "The library has no kind 'Deployment' in version 'v1' of group 'apps'",
+		"unknownKind":    "The library has no kind 'Widget2'",
+	} {
+		if err, ok := errs[name]; !ok || !strings.Contains(err.Error(), want) {
+			t.Errorf("[%s] Expected error '%s', got: %v", name, want, err)
+		}
+	}
+}
+
+func TestEmitTypeAssertions(t *testing.T) {
+	spec := parseSpec(t, constraintsSpec)
+	files, _, err := EmitFiles(spec, nil, nil, Options{TypeAssertions: true, Strict: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	// Types are checked before constraints, which don't apply to values
+	// of other types (e.g., `std.length` of a number).
+	tests := map[string]string{
+		"valid":    "",
+		"name":     "name must be a string, got number",
+		"replicas": "replicas must be an integer, got number",
+		"ports":    "ports must be an integer, or an array of them",
+		"weight":   "weight must be a number, got string",
+	}
+	widget := "k8s.core.v1.widget"
+	programs := map[string]string{
+		"valid":    fmt.Sprintf(`%[1]s.withName("abc") + %[1]s.withReplicas(3) + %[1]s.withPortsMixin(80) + %[1]s.withWeight(0.5)`, widget),
+		"name":     fmt.Sprintf(`%s.withName(3)`, widget),
+		"replicas": fmt.Sprintf(`%s.withReplicas(1.5)`, widget),
+		"ports":    fmt.Sprintf(`%s.withPortsMixin(["80"])`, widget),
+		"weight":   fmt.Sprintf(`%s.withWeight("1")`, widget),
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	_, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		err, failed := errs[name]
+		switch {
+		case expected == "" && failed:
+			t.Errorf("[%s] Expected no error, got:\n%v", name, err)
+		case expected != "" && !failed:
+			t.Errorf("[%s] Expected error '%s'", name, expected)
+		case expected != "" && !strings.Contains(err.Error(), expected):
+			t.Errorf("[%s] Expected error '%s', got:\n%v", name, expected, err)
+		}
+	}
+}
+
+var formatSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "replicas": {"type": "integer", "format": "int32"},
+        "generation": {"type": "integer", "format": "int64"},
+        "data": {"type": "string", "format": "byte"},
+        "created": {"type": "string", "format": "date-time"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitFormats(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, formatSpec), nil, nil, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	k8s := string(files[k8sFile])
+	if !strings.Contains(k8s, "// Format: `int32`.") {
+		t.Errorf("Expected the format of 'replicas' in its comment")
+	}
+	if !strings.Contains(k8s, `std.native("regexMatch")("^[0-9]{4}-`) {
+		t.Errorf("Expected the format of 'created' to be checked")
+	}
+
+	tests := map[string]string{
+		"valid":         "",
+		"fraction":      "replicas must be a 32-bit integer",
+		"outOfRange":    "replicas must be a 32-bit integer",
+		"int64Fraction": "generation must be an integer",
+		"notBase64":     "data must be base64-encoded",
+	}
+	widget := "k8s.core.v1.widget"
+	programs := map[string]string{
+		"valid":         fmt.Sprintf(`%[1]s.withReplicas(3) + %[1]s.withGeneration(4294967296) + %[1]s.withData("aGk=")`, widget),
+		"fraction":      fmt.Sprintf(`%s.withReplicas(1.5)`, widget),
+		"outOfRange":    fmt.Sprintf(`%s.withReplicas(2147483648)`, widget),
+		"int64Fraction": fmt.Sprintf(`%s.withGeneration(0.5)`, widget),
+		"notBase64":     fmt.Sprintf(`%s.withData("hi!")`, widget),
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	_, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		err, failed := errs[name]
+		switch {
+		case expected == "" && failed:
+			t.Errorf("[%s] Expected no error, got:\n%v", name, err)
+		case expected != "" && !failed:
+			t.Errorf("[%s] Expected error '%s'", name, expected)
+		case expected != "" && !strings.Contains(err.Error(), expected):
+			t.Errorf("[%s] Expected error '%s', got:\n%v", name, expected, err)
+		}
+	}
+}
+
+var patchStrategySpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "ports": {"type": "array", "items": {"type": "integer"}, "x-kubernetes-patch-strategy": "merge"},
+        "hooks": {"type": "array", "items": {"type": "object"}, "x-kubernetes-patch-strategy": "merge", "x-kubernetes-patch-merge-key": "name"},
+        "args": {"type": "array", "items": {"type": "string"}}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitPatchStrategy(t *testing.T) {
+	_, k8sBytes, _, err := Emit(
+		parseSpec(t, patchStrategySpec), nil, nil, Options{PatchStrategyMixins: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(k8sBytes)
+	tests := map[string]string{
+		"merge":  "// Appends to the existing elements, because the patch strategy of `ports` is `merge`.\n        withPortsMixin(ports):: self + if std.type(ports) == \"array\" then {ports+: ports} else {ports+: [ports]},",
+		"atomic": "// Replaces the existing elements, because `args` has no patch strategy, so Kubernetes treats it as atomic.\n        withArgsMixin(args):: self + if std.type(args) == \"array\" then {args: args} else {args: [args]},",
+	}
+	for name, expected := range tests {
+		if !strings.Contains(text, expected) {
+			t.Errorf("[%s] Expected '%s' in emitted library", name, expected)
+		}
+	}
+}
+
+func TestEmitPatchStrategyDefault(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, patchStrategySpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	k8s := string(files[k8sFile])
+	if strings.Contains(k8s, "Kubernetes treats it as atomic") {
+		t.Errorf("Expected no patch strategy comments by default")
+	}
+	for _, name := range []string{"replaceHooksByName", mergeByKeyFunction} {
+		if strings.Contains(k8s, name) {
+			t.Errorf("Expected no '%s' by default", name)
+		}
+	}
+
+	tests := map[string]string{
+		"atomic": `["a","b","c"]`,
+		"keyed":  `[{"image":"x","name":"a"},{"name":"a","port":1}]`,
+	}
+	programs := map[string]string{
+		"atomic": `k8s.core.v1.widget.withArgs("a").withArgsMixin(["b", "c"]).args`,
+		"keyed":  `k8s.core.v1.widget.withHooks({name: "a", image: "x"}).withHooksMixin({name: "a", port: 1}).hooks`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if actual.String() != expected {
+			t.Errorf("[%s] Expected the mixin to append by default, got '%s'", name, actual.String())
+		}
+	}
+}
+
+func TestEmitPatchMergeKey(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, patchStrategySpec), nil, nil, Options{PatchStrategyMixins: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	widget := `k8s.core.v1.widget.withHooks([{name: "a", image: "x"}, {name: "b", image: "y"}])`
+	tests := map[string]string{
+		"merged":   `[{"image":"z","name":"a","port":1},{"image":"y","name":"b"},{"name":"c"}]`,
+		"single":   `[{"image":"x","name":"a","port":1},{"image":"y","name":"b"}]`,
+		"empty":    `[{"name":"a"}]`,
+		"replaced": `[{"name":"a","port":1},{"image":"y","name":"b"}]`,
+	}
+	programs := map[string]string{
+		"merged":   widget + `.withHooksMixin([{name: "a", image: "z", port: 1}, {name: "c"}]).hooks`,
+		"single":   widget + `.withHooksMixin({name: "a", port: 1}).hooks`,
+		"empty":    `k8s.core.v1.widget.withHooksMixin({name: "a"}).hooks`,
+		"replaced": widget + `.replaceHooksByName({name: "a", port: 1}).hooks`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if actual.String() != expected {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, expected, actual.String())
+		}
+	}
+}
+
+var listTypeSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "finalizers": {"type": "array", "items": {"type": "string"}, "x-kubernetes-list-type": "set"},
+        "ports": {"type": "array", "items": {"type": "object"}, "x-kubernetes-list-type": "map", "x-kubernetes-list-map-keys": ["containerPort", "protocol"]},
+        "hosts": {"type": "array", "items": {"type": "string"}, "x-kubernetes-list-type": "atomic", "x-kubernetes-patch-strategy": "merge"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitListTypes(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, listTypeSpec), nil, nil, Options{PatchStrategyMixins: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	widget := "k8s.core.v1.widget"
+	ports := widget + `.withPorts([{containerPort: 80, protocol: "TCP", name: "a"}, {containerPort: 80, protocol: "UDP"}])`
+	tests := map[string]string{
+		"set":      `["a","b","c"]`,
+		"map":      `[{"containerPort":80,"name":"b","protocol":"TCP"},{"containerPort":80,"protocol":"UDP"},{"containerPort":81,"protocol":"TCP"}]`,
+		"replaced": `[{"containerPort":80,"protocol":"TCP"},{"containerPort":80,"protocol":"UDP"}]`,
+		"atomic":   `["b"]`,
+	}
+	programs := map[string]string{
+		"set":      widget + `.withFinalizers(["a", "b"]).withFinalizersMixin(["b", "c", "c"]).finalizers`,
+		"map":      ports + `.withPortsMixin([{containerPort: 80, protocol: "TCP", name: "b"}, {containerPort: 81, protocol: "TCP"}]).ports`,
+		"replaced": ports + `.replacePortsByContainerPortAndProtocol({containerPort: 80, protocol: "TCP"}).ports`,
+		"atomic":   widget + `.withHosts("a").withHostsMixin("b").hosts`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if actual.String() != expected {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, expected, actual.String())
+		}
+	}
+
+	if !strings.Contains(string(files[k8sFile]), "because `hosts` is a list of type `atomic`") {
+		t.Errorf("Expected the mixin of 'hosts' to explain that it replaces")
+	}
+}
+
+func TestEmitListTypesDefault(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, listTypeSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if strings.Contains(string(files[k8sFile]), mergeSetFunction) {
+		t.Errorf("Expected no '%s' by default", mergeSetFunction)
+	}
+
+	program := fmt.Sprintf(
+		`local k8s = import %q; k8s.core.v1.widget.withFinalizers(["a", "b"]).withFinalizersMixin(["b", "c"]).finalizers`,
+		k8sFile)
+	outputs, errs := evaluate(files, map[string]string{"set": program})
+	if err, ok := errs["set"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	actual := bytes.Buffer{}
+	if err := json.Compact(&actual, []byte(outputs["set"])); err != nil {
+		t.Fatalf("Expected JSON, got:\n%s", outputs["set"])
+	}
+	if expected := `["a","b","b","c"]`; actual.String() != expected {
+		t.Errorf("Expected the mixin of a set list to append by default, got '%s'", actual.String())
+	}
+}
+
+var mapSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+        "data": {"type": "object", "additionalProperties": {"type": "array", "items": {"type": "string"}}},
+        "options": {"type": "object", "additionalProperties": true},
+        "tags": {"type": "object", "additionalProperties": {"type": "string"}},
+        "tag": {"type": "string"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitItemSetters(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, mapSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files[k8sFile])
+	for _, expected := range []string{
+		"// Sets the entry `key` of `labels` to `value`, which is a string, and keeps the other entries.\n        withLabel(key, value):: self + {labels+: {[key]: value}},",
+		"// Sets the entry `key` of `data` to `value`, which is an array of strings, and keeps the other entries.\n        withDataItem(key, value):: self + {data+: {[key]: value}},",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted library", expected)
+		}
+	}
+	for _, unexpected := range []string{"withOption(", "withTag(key, value)"} {
+		if strings.Contains(text, unexpected) {
+			t.Errorf("Expected no '%s' in emitted library", unexpected)
+		}
+	}
+
+	programs := map[string]string{
+		"labels": fmt.Sprintf(
+			"local k8s = import %q; k8s.core.v1.widget.withLabels({a: \"1\"}).withLabel(\"b\", \"2\").labels", k8sFile),
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["labels"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	actual := bytes.Buffer{}
+	if err := json.Compact(&actual, []byte(outputs["labels"])); err != nil {
+		t.Fatalf("Expected JSON, got:\n%s", outputs["labels"])
+	}
+	if expected := `{"a":"1","b":"2"}`; actual.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, actual.String())
+	}
+}
+
+var addSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "containers": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Container"}, "x-kubernetes-patch-strategy": "merge", "x-kubernetes-patch-merge-key": "name"},
+        "args": {"type": "array", "items": {"type": "string"}}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Container": {
+      "properties": {
+        "name": {"type": "string"},
+        "image": {"type": "string"}
+      }
+    }
+  }
+}`
+
+func TestEmitAddFunctions(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, addSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files[k8sFile])
+	if expected := "addContainer(item):: self + {containers+: if std.isArray(item) then item else [item]},"; !strings.Contains(text, expected) {
+		t.Errorf("Expected '%s' in emitted library", expected)
+	}
+	if strings.Contains(text, "addArg(") {
+		t.Error("Expected no 'addArg' for an array of strings")
+	}
+
+	widget := `k8s.core.v1.widget.withContainers({name: "a", image: "x"})`
+	tests := map[string]string{
+		"single": `[{"image":"x","name":"a"},{"image":"y","name":"a"}]`,
+		"array":  `[{"image":"x","name":"a"},{"name":"b"},{"name":"c"}]`,
+		"empty":  `[{"name":"b"}]`,
+	}
+	programs := map[string]string{
+		"single": widget + `.addContainer({name: "a", image: "y"}).containers`,
+		"array":  widget + `.addContainer([{name: "b"}, {name: "c"}]).containers`,
+		"empty":  `k8s.core.v1.widget.addContainer({name: "b"}).containers`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if actual.String() != expected {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, expected, actual.String())
+		}
+	}
+}
+
+var enumSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "imagePullPolicy": {"type": "string", "description": "Image pull policy.", "enum": ["Always", "IfNotPresent", "Never"]},
+        "protocol": {"type": "string", "enum": ["TCP", "HTTPGet", "read-only", "true"]},
+        "accessModes": {"type": "array", "items": {"type": "string", "enum": ["ReadWriteOnce", "ReadOnlyMany"]}, "default": ["ReadWriteOnce"]}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitEnums(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, enumSpec), nil, nil, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files[k8sFile])
+	for _, expected := range []string{
+		"// Image pull policy.\n        // Must be one of `Always`, `IfNotPresent`, `Never`.\n        withImagePullPolicy(",
+		"imagePullPolicy:: {always: \"Always\", ifNotPresent: \"IfNotPresent\", never: \"Never\"},",
+		"protocol:: {tcp: \"TCP\", httpGet: \"HTTPGet\", \"read-only\": \"read-only\", \"true\": \"true\"},",
+		"// Each element must be one of `ReadWriteOnce`, `ReadOnlyMany`.\n        // Defaults to `[\"ReadWriteOnce\"]`.\n        withAccessModes(",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted library", expected)
+		}
+	}
+
+	widget := "k8s.core.v1.widget"
+	tests := map[string]string{
+		"constant": `"IfNotPresent"`,
+		"invalid":  "imagePullPolicy must be one of ['Always','IfNotPresent','Never']",
+	}
+	programs := map[string]string{
+		"constant": fmt.Sprintf("%s.withImagePullPolicy(%s.imagePullPolicy.ifNotPresent).imagePullPolicy", widget, widget),
+		"invalid":  fmt.Sprintf("%s.withImagePullPolicy(\"Sometimes\")", widget),
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		actual := outputs[name]
+		if err, ok := errs[name]; ok {
+			actual = err.Error()
+		}
+		if !strings.Contains(actual, expected) {
+			t.Errorf("[%s] Expected '%s' in:\n%s", name, expected, actual)
+		}
+	}
+}
+
+var requiredSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "required": ["kind", "metadata", "size", "missing"],
+      "properties": {
+        "kind": {"type": "string"},
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "size": {"type": "integer"},
+        "color": {"type": "string", "default": "red"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "properties": {
+        "name": {"type": "string"}
+      }
+    }
+  }
+}`
+
+func TestEmitRequiredConstructors(t *testing.T) {
+	files, report, err := EmitFiles(
+		parseSpec(t, requiredSpec), nil, nil, Options{RequiredConstructors: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	for _, expected := range []string{
+		"new(metadata, size, color=\"red\"):: apiVersion + kind + self.mixin.metadata.mixinInstance(metadata) + self.withSize(size) + self.withColor(color),",
+		"// Defaults to `\"red\"`.\n        withColor(color=\"red\"):: self + {color: color},",
+	} {
+		if !strings.Contains(string(files[k8sFile]), expected) {
+			t.Errorf("Expected '%s' in emitted library", expected)
+		}
+	}
+	warning := "required property 'missing' is not a parameter of the constructor"
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0].Message, warning) {
+		t.Errorf("Expected a single warning '%s', got %v", warning, report.Warnings)
+	}
+
+	programs := map[string]string{
+		"widget": fmt.Sprintf(
+			"local k8s = import %q; k8s.core.v1.widget.new({name: \"w\"}, 3)", k8sFile),
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["widget"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	actual := bytes.Buffer{}
+	if err := json.Compact(&actual, []byte(outputs["widget"])); err != nil {
+		t.Fatalf("Expected JSON, got:\n%s", outputs["widget"])
+	}
+	if expected := `{"apiVersion":"v1","color":"red","kind":"Widget","metadata":{"name":"w"},"size":3}`; actual.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, actual.String())
+	}
+}
+
+var elementConstructorSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetSpec": {
+      "properties": {
+        "gadgets": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Gadget"}},
+        "tags": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Tag"}},
+        "hostAliases": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.HostAlias"}},
+        "tls": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetTLS"}}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.HostAlias": {
+      "required": ["ip"],
+      "properties": {"ip": {"type": "string"}, "hostnames": {"type": "array", "items": {"type": "string"}}}
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetTLS": {
+      "required": ["secretName"],
+      "properties": {"secretName": {"type": "string"}}
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Gadget": {
+      "required": ["name", "size"],
+      "properties": {
+        "name": {"type": "string"},
+        "size": {"type": "integer", "default": 1},
+        "color": {"type": "string"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Tag": {
+      "properties": {"value": {"type": "string"}}
+    }
+  }
+}`
+
+func TestEmitElementConstructors(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, elementConstructorSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files[k8sFile])
+	if expected := "newGadget(name, size=1):: {} + self.gadgetsType.withName(name) + self.gadgetsType.withSize(size),"; !strings.Contains(text, expected) {
+		t.Errorf("Expected '%s' in emitted library", expected)
+	}
+	if strings.Contains(text, "newTag(") {
+		t.Error("Expected no 'newTag' for an element without required properties")
+	}
+	for _, expected := range []string{"newHostAlias(ip)::", "newTlsItem(secretName)::"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted library", expected)
+		}
+	}
+
+	spec := "k8s.core.v1.widget.mixin.spec"
+	programs := map[string]string{
+		"gadgets": fmt.Sprintf(
+			"local k8s = import %q; %s.withGadgets([%s.newGadget(\"a\"), %s.newGadget(\"b\", 2) + %s.gadgetsType.withColor(\"red\")]).spec",
+			k8sFile, spec, spec, spec, spec),
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["gadgets"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	actual := bytes.Buffer{}
+	if err := json.Compact(&actual, []byte(outputs["gadgets"])); err != nil {
+		t.Fatalf("Expected JSON, got:\n%s", outputs["gadgets"])
+	}
+	if expected := `{"gadgets":[{"name":"a","size":1},{"color":"red","name":"b","size":2}]}`; actual.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, actual.String())
+	}
+}
+
+var refMixinSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetSpec": {
+      "properties": {
+        "replicas": {"type": "integer"},
+        "template": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetTemplate"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetTemplate": {
+      "properties": {"image": {"type": "string"}}
+    }
+  }
+}`
+
+func TestEmitRefMixinOverride(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, refMixinSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files[k8sFile])
+	for _, expected := range []string{
+		"local __specNs = self,",
+		"__specMixin(spec):: {spec+: spec},",
+		"__templateMixin(template):: __specNs.__specMixin({template+: template}),",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted library", expected)
+		}
+	}
+
+	// Overriding `__specMixin` changes what the setters of `spec`, and
+	// of the namespaces nested in it, produce.
+	widget := "local k8s = import \"k8s.libsonnet\"; local widget = k8s.core.v1.widget + " +
+		"{mixin+: {spec+: {__specMixin(spec):: {spec+: spec, labels: {patched: \"true\"}}}}}; "
+	programs := map[string]string{
+		"default": "local k8s = import \"k8s.libsonnet\"; " +
+			"k8s.core.v1.widget.mixin.spec.withReplicas(2) + k8s.core.v1.widget.mixin.spec.template.withImage(\"nginx\")",
+		"spec":   widget + "widget.mixin.spec.withReplicas(2)",
+		"nested": widget + "widget.mixin.spec.template.withImage(\"nginx\")",
+	}
+	expected := map[string]string{
+		"default": `{"spec":{"replicas":2,"template":{"image":"nginx"}}}`,
+		"spec":    `{"labels":{"patched":"true"},"spec":{"replicas":2}}`,
+		"nested":  `{"labels":{"patched":"true"},"spec":{"template":{"image":"nginx"}}}`,
+	}
+	outputs, errs := evaluate(files, programs)
+	for name, target := range expected {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Errorf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+			continue
+		}
+		if actual.String() != target {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, target, actual.String())
+		}
+	}
+}
+
+var flattenSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetSpec"},
+        "specFoo": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetFoo"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetSpec": {
+      "properties": {
+        "replicas": {"type": "integer"},
+        "hosts": {"type": "array", "items": {"type": "string"}},
+        "fooBar": {"type": "string"},
+        "template": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetTemplate"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetFoo": {
+      "properties": {"bar": {"type": "string"}}
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetTemplate": {
+      "properties": {
+        "image": {"type": "string"},
+        "labels": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+func TestEmitFlattenedSetters(t *testing.T) {
+	files, report, err := EmitFiles(parseSpec(t, flattenSpec), nil, nil, Options{FlattenDepth: 3})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text := string(files[k8sFile])
+	for _, expected := range []string{
+		"withSpecReplicas(replicas):: self + {spec+: {replicas: replicas}},",
+		"withSpecHosts(hosts):: self + if std.type(hosts) == \"array\" then {spec+: {hosts: hosts}} else {spec+: {hosts: [hosts]}},",
+		"withSpecHostsMixin(hosts)::",
+		"withSpecFooBar(fooBar):: self + {spec+: {fooBar: fooBar}},",
+		"withSpecTemplateImage(image):: self + {spec+: {template+: {image: image}}},",
+		"withSpecTemplateLabelsMixin(labels):: self + {spec+: {template+: {labels+: labels}}},",
+		"withSpecTemplateLabel(",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted library", expected)
+		}
+	}
+	if strings.Contains(text, "withSpecFooBar(bar)") {
+		t.Error("Expected 'specFoo.bar' not to get a flattened setter, since 'spec.fooBar' has the same name")
+	}
+
+	collisions := []Warning{}
+	for _, warning := range report.Warnings {
+		if strings.Contains(warning.Message, "flattened setter") {
+			collisions = append(collisions, warning)
+		}
+	}
+	if len(collisions) != 1 ||
+		collisions[0].Path != "io.k8s.kubernetes.pkg.api.v1.Widget" ||
+		!strings.Contains(collisions[0].Message, "'withSpecFooBar'") {
+		t.Errorf("Expected the collision of 'withSpecFooBar' to be reported, got %v", collisions)
+	}
+
+	programs := map[string]string{
+		"widget": "local widget = (import \"k8s.libsonnet\").core.v1.widget; " +
+			"widget.withSpecReplicas(2) + widget.withSpecHosts(\"a\") + widget.withSpecFooBar(\"baz\") + " +
+			"widget.withSpecTemplateImage(\"nginx\") + widget.withSpecTemplateLabels({app: \"web\"}) + " +
+			"widget.withSpecTemplateLabelsMixin({tier: \"frontend\"})",
+		"array": "local widget = (import \"k8s.libsonnet\").core.v1.widget; widget.withSpecHosts([\"a\", \"b\"])",
+	}
+	expected := map[string]string{
+		"widget": `{"spec":{"fooBar":"baz","hosts":["a"],"replicas":2,"template":{"image":"nginx","labels":{"app":"web","tier":"frontend"}}}}`,
+		"array":  `{"spec":{"hosts":["a","b"]}}`,
+	}
+	outputs, errs := evaluate(files, programs)
+	for name, target := range expected {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Errorf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+			continue
+		}
+		if actual.String() != target {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, target, actual.String())
+		}
+	}
+}
+
+var podTemplateSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Deployment": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.DeploymentSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Deployment"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.DeploymentSpec": {
+      "properties": {
+        "replicas": {"type": "integer"},
+        "template": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodTemplateSpec"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.apis.batch.v2alpha1.CronJob": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.batch.v2alpha1.CronJobSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "batch", "Version": "v2alpha1", "Kind": "CronJob"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.batch.v2alpha1.CronJobSpec": {
+      "properties": {
+        "schedule": {"type": "string"},
+        "jobTemplate": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.batch.v2alpha1.JobTemplateSpec"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.apis.batch.v2alpha1.JobTemplateSpec": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.batch.v2alpha1.JobSpec"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.apis.batch.v2alpha1.JobSpec": {
+      "properties": {
+        "template": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodTemplateSpec"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.PodTemplateSpec": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodSpec"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.PodSpec": {
+      "properties": {
+        "hostname": {"type": "string"},
+        "containers": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Container"}}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Container": {
+      "properties": {
+        "name": {"type": "string"},
+        "image": {"type": "string"}
+      }
+    }
+  }
+}`
+
+func TestEmitPodTemplateHelpers(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, podTemplateSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if strings.Contains(string(files[k8sFile]), "podTemplate::") {
+		t.Error("Expected no pod template helpers without `PodTemplateHelpers`")
+	}
+
+	files, _, err = EmitFiles(parseSpec(t, podTemplateSpec), nil, nil, Options{PodTemplateHelpers: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	text := string(files[k8sFile])
+	for _, expected := range []string{
+		"podTemplate:: hidden.podTemplate + {__podTemplateMixin(podTemplate):: {spec+: {template+: podTemplate}}},",
+		"podTemplate:: hidden.podTemplate + {__podTemplateMixin(podTemplate):: {spec+: {jobTemplate+: {spec+: {template+: podTemplate}}}}},",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted library", expected)
+		}
+	}
+
+	// Pod-level configuration written once, against the shared
+	// namespace, applies to every workload kind.
+	pod := "local k8s = import \"k8s.libsonnet\"; " +
+		"local pod(podTemplate) = podTemplate.spec.withContainers([{name: \"app\", image: \"app:v1\"}]) + " +
+		"podTemplate.spec.withHostname(\"app\"); "
+	programs := map[string]string{
+		"deployment": pod + "k8s.apps.v1beta1.deployment.mixin.spec.withReplicas(2) + " +
+			"pod(k8s.apps.v1beta1.deployment.mixin.podTemplate)",
+		"cronJob": pod + "k8s.batch.v2alpha1.cronJob.mixin.spec.withSchedule(\"@daily\") + " +
+			"pod(k8s.batch.v2alpha1.cronJob.mixin.podTemplate)",
+	}
+	podSpec := `{"spec":{"containers":[{"image":"app:v1","name":"app"}],"hostname":"app"}}`
+	expected := map[string]string{
+		"deployment": `{"spec":{"replicas":2,"template":` + podSpec + `}}`,
+		"cronJob":    `{"spec":{"jobTemplate":{"spec":{"template":` + podSpec + `}},"schedule":"@daily"}}`,
+	}
+	outputs, errs := evaluate(files, programs)
+	for name, target := range expected {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		actual := bytes.Buffer{}
+		if err := json.Compact(&actual, []byte(outputs[name])); err != nil {
+			t.Errorf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+			continue
+		}
+		if actual.String() != target {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, target, actual.String())
+		}
+	}
+}
+
+var intOrStringSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "name": {"type": "string"},
+        "port": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.util.intstr.IntOrString"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.apimachinery.pkg.util.intstr.IntOrString": {
+      "type": "string",
+      "format": "int-or-string"
+    }
+  }
+}`
+
+func TestEmitIntOrString(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, intOrStringSpec), nil, nil, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	expected := "// " + intOrStringComment + "\n        withPort(port):: assert std.isNumber(port) || std.isString(port) : \"port must be an integer or a string\"; self + {port: port},"
+	if !strings.Contains(string(files[k8sFile]), expected) {
+		t.Errorf("Expected '%s' in emitted library", expected)
+	}
+
+	widget := `k8s.core.v1.widget.withName("w")`
+	tests := map[string]string{
+		"integer": `{"name":"w","port":8080}`,
+		"string":  `{"name":"w","port":"http"}`,
+		"object":  "port must be an integer or a string",
+	}
+	programs := map[string]string{
+		"integer": widget + ".withPort(8080)",
+		"string":  widget + `.withPort("http")`,
+		"object":  widget + ".withPort({port: 8080})",
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		var actual string
+		if err, ok := errs[name]; ok {
+			actual = err.Error()
+		} else {
+			compact := bytes.Buffer{}
+			if err := json.Compact(&compact, []byte(outputs[name])); err != nil {
+				t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+			}
+			actual = compact.String()
+		}
+		if !strings.Contains(actual, expected) {
+			t.Errorf("[%s] Expected '%s' in:\n%s", name, expected, actual)
+		}
+	}
+}
+
+var selfReferentialSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "schema": {"$ref": "#/definitions/io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1beta1.JSONSchemaProps"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1beta1.JSONSchemaProps": {
+      "properties": {
+        "type": {"type": "string"},
+        "not": {"$ref": "#/definitions/io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1beta1.JSONSchemaProps"},
+        "items": {"$ref": "#/definitions/io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1beta1.JSONSchemaPropsOrArray"}
+      }
+    },
+    "io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1beta1.JSONSchemaPropsOrArray": {
+      "properties": {
+        "schema": {"$ref": "#/definitions/io.k8s.apiextensions-apiserver.pkg.apis.apiextensions.v1beta1.JSONSchemaProps"}
+      }
+    }
+  }
+}`
+
+func TestEmitK8sLibsonnet(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, customConstructorSpec), nil, nil, Options{Backend: K8sLibsonnetBackend})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	for _, name := range []string{
+		"main.libsonnet", "gen.libsonnet", "_gen/core/main.libsonnet",
+		"_gen/core/v1/main.libsonnet", "_gen/core/v1/service.libsonnet",
+		"_gen/meta/v1/objectMeta.libsonnet",
+	} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected '%s' to be emitted", name)
+		}
+	}
+
+	text := string(files["_gen/core/v1/service.libsonnet"])
+	for _, expected := range []string{
+		`new(name):: {apiVersion: "v1", kind: "Service"} + self.metadata.withName(name),`,
+		"withSelectorMixin(selector):: {spec+: {selector+: selector}},",
+		"withMedium(medium):: {volume+: {emptyDir+: {medium: medium}}},",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected '%s' in emitted library of 'Service', got:\n%s", expected, text)
+		}
+	}
+
+	programs := map[string]string{
+		"service": `local service = (import "main.libsonnet").core.v1.service;
+service.new("web") + service.spec.withPorts({port: 80}) + service.spec.withPortsMixin([{port: 443}])`,
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["service"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	actual := bytes.Buffer{}
+	if err := json.Compact(&actual, []byte(outputs["service"])); err != nil {
+		t.Fatalf("Expected JSON, got:\n%s", outputs["service"])
+	}
+	expected := `{"apiVersion":"v1","kind":"Service","metadata":{"name":"web"},"spec":{"ports":[{"port":80},{"port":443}]}}`
+	if actual.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, actual.String())
+	}
+}
+
+func TestEmitAST(t *testing.T) {
+	b := &astBuilder{}
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{
+			astApply(astIndex(astVar("self"), "metadata", "withName"), astVar("name")),
+			"self.metadata.withName(name)",
+		},
+		{
+			// Operands that bind more loosely are parenthesized.
+			astIndex(astPlus(astVar("a"), astVar("b")), "c"),
+			"(a + b).c",
+		},
+		{
+			astPlus(astVar("a"), astPlus(astVar("b"), astVar("c"))),
+			"a + (b + c)",
+		},
+		{
+			// Keywords and non-identifiers are quoted as field names.
+			astObject(
+				astField("local", ast.ObjectFieldInherit, false, astString("x")),
+				astField("#", ast.ObjectFieldInherit, true, b.code("{}"))),
+			`{"local": "x", "#"+: {}}`,
+		},
+		{
+			astMultiline(astObject(
+				astComment(astMethod("new", b.function([]string{"name", "namespace=null"}, b.asserted(
+					"assert std.isString(name); ", astVar("name")))), comments{"Creates a new object."}),
+				astLocal("x", astString("a\"b")))),
+			"{\n" +
+				"  // Creates a new object.\n" +
+				"  new(name, namespace=null):: assert std.isString(name); name,\n" +
+				"  local x = \"a\\\"b\",\n" +
+				"}",
+		},
+	}
+	for _, test := range tests {
+		m := newIndentWriter()
+		emitAST(m, b, test.node)
+		actual, err := m.bytes()
+		if err != nil {
+			t.Fatalf("Failed to print '%s':\n%v", test.expected, err)
+		}
+		if string(actual) != test.expected+"\n" {
+			t.Errorf("Expected '%s', got '%s'", test.expected, actual)
+		}
+	}
+}
+
+func TestEmitASTInvalidCode(t *testing.T) {
+	b := &astBuilder{}
+	node := astObject(astField("x", ast.ObjectFieldInherit, false, b.code("{x: }")))
+	m := newIndentWriter()
+	emitAST(m, b, node)
+	if _, err := m.bytes(); err == nil || !strings.Contains(err.Error(), "Generated invalid Jsonnet") {
+		t.Errorf("Expected an error for invalid code, got: %v", err)
+	}
+}
+
+func TestEmitRecursiveRefs(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, selfReferentialSpec), nil, nil, Options{
+		FlattenDepth: 4, KustomizeHelpers: true, JSONSchemas: true, TestSuite: true,
+		NameMap: true, RenderHelpers: true, Verify: true, ReflectionIndex: true,
+		UtilHelpers: true, Strict: true, RequiredConstructors: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	expected := "withNot(not):: self + __schemaNs.__schemaMixin({not: not}),"
+	if !strings.Contains(string(files[k8sFile]), expected) {
+		t.Errorf("Expected '%s' in emitted library", expected)
+	}
+
+	programs := map[string]string{
+		"not": fmt.Sprintf(
+			"local schema = (import %q).core.v1.widget.mixin.schema; schema.withType(\"object\") + schema.withNot({type: \"string\"})",
+			k8sFile),
+	}
+	outputs, errs := evaluate(files, programs)
+	if err, ok := errs["not"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	actual := bytes.Buffer{}
+	if err := json.Compact(&actual, []byte(outputs["not"])); err != nil {
+		t.Fatalf("Expected JSON, got:\n%s", outputs["not"])
+	}
+	if expected := `{"schema":{"not":{"type":"string"},"type":"object"}}`; actual.String() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, actual.String())
+	}
+
+	for _, backend := range Backends() {
+		if _, _, err := EmitFiles(
+			parseSpec(t, selfReferentialSpec), nil, nil, Options{Backend: backend}); err != nil {
+			t.Errorf("[%s] Failed to emit:\n%v", backend, err)
+		}
+	}
+}
+
+var danglingRefSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "status": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetStatus"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitValidatesSpec(t *testing.T) {
+	_, report, err := EmitFiles(parseSpec(t, danglingRefSpec), nil, nil, Options{})
+	if err == nil {
+		t.Fatal("Expected a spec with a dangling reference to fail validation")
+	}
+	if report == nil || len(report.Warnings) != 1 {
+		t.Fatalf("Expected a report with one problem, got:\n%v", report)
+	}
+	if w := report.Warnings[0]; w.Check != kubespec.CheckDanglingRef || w.Severity != SeverityError {
+		t.Errorf("Expected a dangling reference error, got:\n%v", w)
+	}
+
+	text, err := report.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), `"check": "dangling-ref"`) {
+		t.Errorf("Expected the JSON report to record the check, got:\n%s", text)
+	}
+}
+
+func TestNewRootMalformedDefinition(t *testing.T) {
+	spec := parseSpec(t, namedConstructorSpec)
+	spec.Definitions["io.k8s.kubernetes.pkg.apis.v1"] = &kubespec.SchemaDefinition{}
+
+	_, err := newRoot(spec, nil, nil, Options{})
+	if err == nil {
+		t.Fatal("Expected a malformed definition name to fail")
+	}
+	if !strings.Contains(err.Error(), "'io.k8s.kubernetes.pkg.apis.v1'") {
+		t.Errorf("Expected the error to name the definition, got:\n%v", err)
+	}
+}
+
+var unknownTypeSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "blob": {"type": "file"},
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetSpec": {
+      "properties": {
+        "extra": {"description": "Extra has no type."}
+      }
+    }
+  }
+}`
+
+func TestEmitUnknownTypes(t *testing.T) {
+	files, report, err := EmitFiles(parseSpec(t, unknownTypeSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if len(report.Warnings) != 2 {
+		t.Errorf("Expected a warning for each property of an unknown type, got:\n%v", report.Warnings)
+	}
+
+	tests := map[string]string{
+		"blob":  `{"blob":"data"}`,
+		"extra": `{"spec":{"extra":{"a":1}}}`,
+	}
+	programs := map[string]string{
+		"blob":  `k8s.core.v1.widget.withBlob("data")`,
+		"extra": "k8s.core.v1.widget.mixin.spec.withExtra({a: 1})",
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		compact := bytes.Buffer{}
+		if err := json.Compact(&compact, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if compact.String() != expected {
+			t.Errorf("[%s] Expected '%s', got:\n%s", name, expected, compact.String())
+		}
+	}
+
+	_, _, err = EmitFiles(
+		parseSpec(t, unknownTypeSpec), nil, nil, Options{UnknownTypes: UnknownTypesStrict})
+	if err == nil {
+		t.Fatal("Expected properties of unknown types to fail generation")
+	}
+	for _, expected := range []string{
+		"io.k8s.kubernetes.pkg.api.v1.Widget.blob: has unrecognized type 'file'",
+		"io.k8s.kubernetes.pkg.api.v1.WidgetSpec.extra: has neither a type nor a `$ref`",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected '%s' in error:\n%v", expected, err)
+		}
+	}
+}
+
+var numberSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.autoscaling.v1.Scaler": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.autoscaling.v1.ScalerSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "autoscaling", "Version": "v1", "Kind": "Scaler"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.autoscaling.v1.ScalerSpec": {
+      "properties": {
+        "targetRatio": {"type": "number", "format": "double", "minimum": 0}
+      }
+    }
+  }
+}`
+
+func TestEmitNumber(t *testing.T) {
+	files, report, err := EmitFiles(parseSpec(t, numberSpec), nil, nil, Options{Strict: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("Expected no warnings for number properties, got:\n%v", report.Warnings)
+	}
+
+	tests := map[string]string{
+		"float":    `{"spec":{"targetRatio":0.75}}`,
+		"negative": "targetRatio must be at least 0",
+	}
+	programs := map[string]string{
+		"float":    "k8s.autoscaling.v1.scaler.mixin.spec.withTargetRatio(0.75)",
+		"negative": "k8s.autoscaling.v1.scaler.mixin.spec.withTargetRatio(-1.5)",
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		var actual string
+		if err, ok := errs[name]; ok {
+			actual = err.Error()
+		} else {
+			compact := bytes.Buffer{}
+			if err := json.Compact(&compact, []byte(outputs[name])); err != nil {
+				t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+			}
+			actual = compact.String()
+		}
+		if !strings.Contains(actual, expected) {
+			t.Errorf("[%s] Expected '%s' in:\n%s", name, expected, actual)
+		}
+	}
+}
+
+var kindFilterSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Deployment": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.DeploymentSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Deployment"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.DeploymentSpec": {
+      "properties": {
+        "template": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Pod"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.apis.batch.v1.Job": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.batch.v1.JobSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "batch", "Version": "v1", "Kind": "Job"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.batch.v1.JobSpec": {
+      "properties": {"parallelism": {"type": "integer"}}
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Pod": {
+      "properties": {"nodeName": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Pod"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Service": {
+      "properties": {"clusterIP": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Service"}]
+    }
+  }
+}`
+
+func TestEmitKindFilters(t *testing.T) {
+	tests := map[string]struct {
+		opts     Options
+		included []string
+		excluded []string
+	}{
+		"group": {
+			// `Pod` is kept, because `Deployment` refers to it.
+			opts:     Options{Include: []string{"apps"}},
+			included: []string{"deployment::", "pod::", "deploymentSpec::"},
+			excluded: []string{"batch::", "service::", "jobSpec::"},
+		},
+		"exclude": {
+			opts:     Options{Exclude: []string{"batch/*/*", "core/v1/Service"}},
+			included: []string{"deployment::", "pod::"},
+			excluded: []string{"batch::", "service::", "jobSpec::"},
+		},
+		"include and exclude": {
+			opts:     Options{Include: []string{"core/v1"}, Exclude: []string{"*/*/Pod"}},
+			included: []string{"service::"},
+			excluded: []string{"apps::", "batch::", "pod::"},
+		},
+		"manifest kinds": {
+			opts: Options{Kinds: []ManifestKind{
+				{APIVersion: "apps/v1beta1", Kind: "Deployment"},
+				{APIVersion: "v1", Kind: "Service"},
+			}},
+			included: []string{"deployment::", "deploymentSpec::", "pod::", "service::"},
+			excluded: []string{"batch::", "jobSpec::"},
+		},
+	}
+	for name, test := range tests {
+		files, _, err := EmitFiles(parseSpec(t, kindFilterSpec), nil, nil, test.opts)
+		if err != nil {
+			t.Fatalf("[%s] Failed to emit:\n%v", name, err)
+		}
+		library := string(files[k8sFile])
+		for _, expected := range test.included {
+			if !strings.Contains(library, expected) {
+				t.Errorf("[%s] Expected '%s' in emitted library", name, expected)
+			}
+		}
+		for _, unexpected := range test.excluded {
+			if strings.Contains(library, unexpected) {
+				t.Errorf("[%s] Expected no '%s' in emitted library", name, unexpected)
+			}
+		}
+	}
+
+	_, report, err := EmitFiles(parseSpec(t, kindFilterSpec), nil, nil, Options{
+		Kinds: []ManifestKind{{APIVersion: "apps/v1", Kind: "StatefulSet"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0].Message, "apps/v1/StatefulSet") {
+		t.Errorf("Expected a warning about the undefined kind, got %v", report.Warnings)
+	}
+
+	_, _, err = EmitFiles(
+		parseSpec(t, kindFilterSpec), nil, nil, Options{Include: []string{"apps/[v1"}})
+	if err == nil {
+		t.Error("Expected a malformed pattern to fail generation")
+	}
+}
+
+var kHelpersSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.extensions.v1beta1.Deployment": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.extensions.v1beta1.DeploymentSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "extensions", "Version": "v1beta1", "Kind": "Deployment"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.extensions.v1beta1.DeploymentSpec": {
+      "properties": {
+        "selector": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.LabelSelector"},
+        "template": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodTemplateSpec"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.LabelSelector": {
+      "properties": {
+        "matchExpressions": {"type": "array", "items": {"type": "object"}},
+        "matchLabels": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.PodTemplateSpec": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodSpec"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.PodSpec": {
+      "properties": {
+        "containers": {"type": "array", "items": {"type": "object"}}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Pod": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Pod"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Service": {
+      "properties": {"clusterIP": {"type": "string"}},
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Service"}]
+    }
+  }
+}`
+
+func TestEmitKHelpers(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, kHelpersSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	deployment := "k.extensions.v1beta1.deployment"
+	tests := map[string]string{
+		"mapContainers": `[{"image":"nginx:1.13","name":"nginx"},{"image":"envoy","name":"sidecar"}]`,
+		"pod":           `{"containers":[{"image":"nginx:1.13","name":"nginx"}]}`,
+		"list":          `{"apiVersion":"v1","items":[{"kind":"Service"}],"kind":"List"}`,
+		"asList":        `{"apiVersion":"v1","items":[{"kind":"Pod"},{"kind":"Service"},{"kind":"Deployment"}],"kind":"List"}`,
+		"selector":      `{"matchExpressions":[{"key":"tier","operator":"In","values":["web"]},{"key":"canary","operator":"DoesNotExist"}],"matchLabels":{"app":"web"}}`,
+	}
+	programs := map[string]string{
+		"mapContainers": fmt.Sprintf(
+			`(%[1]s.mixin.spec.template.spec.withContainers([{name: "nginx", image: "nginx"}, {name: "sidecar", image: "envoy"}]) + `+
+				`%[1]s.mapContainersWithName("nginx", function(c) c + {image: "nginx:1.13"})).spec.template.spec.containers`,
+			deployment),
+		"pod": `(k.core.v1.pod.mixin.spec.withContainers({name: "nginx"}) + ` +
+			`k.core.v1.pod.mapContainers(function(c) c + {image: "nginx:1.13"})).spec`,
+		"list":   `k.core.v1.list.new({kind: "Service"})`,
+		"asList": `k.asList([{pod: {kind: "Pod"}, service: {kind: "Service"}}, null, [{kind: "Deployment"}]])`,
+		"selector": fmt.Sprintf(
+			`local selector = k.labelSelector; (%[1]s.withSelectorLabels({app: "web"}) + `+
+				`%[1]s.withSelectorExpressions([selector.keyIn("tier", ["web"]), selector.keyDoesNotExist("canary")])).spec.selector`,
+			deployment),
+		"requirement": `k.labelSelector.requirement("tier", k.labelSelector.operators.Exists, ["web"])`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k = import %q; %s", kFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		compacted := bytes.Buffer{}
+		if err := json.Compact(&compacted, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if actual := compacted.String(); actual != expected {
+			t.Errorf("[%s] Expected:\n%s\ngot:\n%s", name, expected, actual)
+		}
+	}
+	if err, ok := errs["requirement"]; !ok || !strings.Contains(err.Error(), "operator 'Exists' takes no values") {
+		t.Errorf("Expected error for a requirement with extra values, got: %v", err)
+	}
+
+	// Helpers are only emitted for the kinds the library has.
+	files, _, err = EmitFiles(parseSpec(t, kHelpersSpec), nil, nil, Options{
+		Exclude: []string{"extensions", "core/v1/Pod"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if library := string(files[kFile]); strings.Contains(library, "extensions") ||
+		strings.Contains(library, "local hidden") || !strings.Contains(library, "list::") {
+		t.Errorf("Expected only the 'list' helper in:\n%s", library)
+	}
+}
+
+var splitSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Deployment": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.DeploymentSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Deployment"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.DeploymentSpec": {
+      "properties": {
+        "hooks": {"type": "array", "items": {"type": "object"}, "x-kubernetes-patch-strategy": "merge", "x-kubernetes-patch-merge-key": "name"},
+        "widget": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetSpec"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "ports": {"type": "array", "items": {"type": "object"}, "x-kubernetes-patch-strategy": "merge", "x-kubernetes-patch-merge-key": "port"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetSpec": {
+      "properties": {"size": {"type": "integer"}}
+    }
+  }
+}`
+
+func TestEmitSplit(t *testing.T) {
+	single, _, err := EmitFiles(parseSpec(t, splitSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	deployment := "k8s.apps.v1beta1.deployment"
+	widget := "k8s.core.v1.widget"
+	programs := map[string]string{
+		"hidden": fmt.Sprintf(
+			`%[1]s.mixin.spec.withHooks([{name: "a", x: 1}]) + %[1]s.mixin.spec.withHooksMixin([{name: "a", y: 2}]) + %[1]s.mixin.spec.widget.withSize(3)`,
+			deployment),
+		"group": fmt.Sprintf(
+			`%[1]s.withPorts([{port: 80}]) + %[1]s.withPortsMixin([{port: 80, name: "http"}, {port: 443}])`,
+			widget),
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+	expected, errs := evaluate(single, programs)
+	for name, err := range errs {
+		t.Fatalf("[%s] Failed to evaluate single file library:\n%v", name, err)
+	}
+
+	// The import every file is expected to have, by layout.
+	tests := map[string]map[string]string{
+		SplitGroup: {
+			k8sFile:          `apps:: import "apps.libsonnet",`,
+			"apps.libsonnet": `local hidden = import "hidden.libsonnet";`,
+			"core.libsonnet": `local hidden = import "hidden.libsonnet";`,
+			hiddenFile:       "local hidden = self,",
+		},
+		SplitVersion: {
+			k8sFile:                  `apps:: import "apps.libsonnet",`,
+			"apps.libsonnet":         `v1beta1:: import "apps/v1beta1.libsonnet",`,
+			"apps/v1beta1.libsonnet": `local hidden = import "../hidden.libsonnet";`,
+			"core.libsonnet":         `v1:: import "core/v1.libsonnet",`,
+			"core/v1.libsonnet":      `local hidden = import "../hidden.libsonnet";`,
+			hiddenFile:               "local hidden = self,",
+		},
+	}
+	for layout, imports := range tests {
+		split, _, err := EmitFiles(parseSpec(t, splitSpec), nil, nil, Options{Split: layout})
+		if err != nil {
+			t.Fatalf("[%s] Failed to emit:\n%v", layout, err)
+		}
+		if len(split) != len(imports)+1 {
+			t.Errorf("[%s] Expected %s and %d files, got %d", layout, kFile, len(imports), len(split))
+		}
+		for name, expectedImport := range imports {
+			if !strings.Contains(string(split[name]), expectedImport) {
+				t.Errorf("[%s] Expected '%s' in file '%s'", layout, expectedImport, name)
+			}
+		}
+		if strings.Contains(string(split[k8sFile]), "deployment") {
+			t.Errorf("[%s] Expected '%s' to only import the groups", layout, k8sFile)
+		}
+
+		actual, errs := evaluate(split, programs)
+		for name, err := range errs {
+			t.Fatalf("[%s/%s] Failed to evaluate split library:\n%v", layout, name, err)
+		}
+		for name := range programs {
+			if actual[name] != expected[name] {
+				t.Errorf("[%s/%s] Expected split library to evaluate to:\n%s\ngot:\n%s",
+					layout, name, expected[name], actual[name])
+			}
+		}
+	}
+
+	hiddenGroupSpec := `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "com.example.hidden.v1.Thing": {
+      "properties": {"size": {"type": "integer"}},
+      "x-kubernetes-group-version-kind": [{"Group": "hidden.example.com", "Version": "v1", "Kind": "Thing"}]
+    }
+  }
+}`
+	_, _, err = EmitFiles(parseSpec(t, hiddenGroupSpec), nil, nil, Options{Split: SplitGroup})
+	if err == nil || !strings.Contains(err.Error(), hiddenFile) {
+		t.Errorf("Expected a group named 'hidden' to fail generation, got %v", err)
+	}
+
+	for layout, backend := range map[string]string{"rows": "", SplitGroup: DhallBackend} {
+		opts := Options{Split: layout, Backend: backend}
+		if _, _, err := EmitFiles(parseSpec(t, splitSpec), nil, nil, opts); err == nil {
+			t.Errorf("[%s] Expected options %+v to fail generation", layout, opts)
+		}
+	}
+}
+
+var customGroupSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "com.example.operator.v1.Widget": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "spec": {"$ref": "#/definitions/com.example.operator.v1.WidgetSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "operator.example.com", "Version": "v1", "Kind": "Widget"}]
+    },
+    "com.example.operator.v1.WidgetSpec": {
+      "properties": {"size": {"type": "integer"}}
+    },
+    "io.cert-manager.cert-manager.v1alpha2.Certificate": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "secretName": {"type": "string"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "cert-manager.io", "Version": "v1alpha2", "Kind": "Certificate"}]
+    }
+  }
+}`
+
+func TestEmitCustomGroups(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, customGroupSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	tests := map[string]string{
+		"widget":      `{"apiVersion":"operator.example.com/v1","kind":"Widget","spec":{"size":3}}`,
+		"certificate": `{"apiVersion":"cert-manager.io/v1alpha2","kind":"Certificate","secretName":"tls"}`,
+	}
+	programs := map[string]string{
+		"widget":      "k8s.operator.v1.widget.new() + k8s.operator.v1.widget.mixin.spec.withSize(3)",
+		"certificate": `k8s.certManager.v1alpha2.certificate.new() + k8s.certManager.v1alpha2.certificate.withSecretName("tls")`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		compact := bytes.Buffer{}
+		if err := json.Compact(&compact, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if compact.String() != expected {
+			t.Errorf("[%s] Expected '%s', got:\n%s", name, expected, compact.String())
+		}
+	}
+}
+
+var openShiftSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "com.github.openshift.api.route.v1.Route": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "spec": {"$ref": "#/definitions/com.github.openshift.api.route.v1.RouteSpec"}
+      },
+      "x-kubernetes-group-version-kind": [
+        {"group": "", "version": "v1", "kind": "Route"},
+        {"group": "route.openshift.io", "version": "v1", "kind": "Route"}
+      ]
+    },
+    "com.github.openshift.api.route.v1.RouteSpec": {
+      "properties": {"host": {"type": "string"}}
+    },
+    "com.github.openshift.api.authorization.v1.SubjectAccessReview": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "status": {"$ref": "#/definitions/com.github.openshift.api.authorization.v1.SubjectRulesReviewStatus"}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "authorization.openshift.io", "version": "v1", "kind": "SubjectAccessReview"}]
+    },
+    "com.github.openshift.api.authorization.v1.SubjectRulesReviewStatus": {
+      "properties": {"evaluationError": {"type": "string"}}
+    },
+    "io.k8s.api.authorization.v1.SubjectAccessReview": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "status": {"$ref": "#/definitions/io.k8s.api.authorization.v1.SubjectRulesReviewStatus"}
+      },
+      "x-kubernetes-group-version-kind": [{"group": "authorization.k8s.io", "version": "v1", "kind": "SubjectAccessReview"}]
+    },
+    "io.k8s.api.authorization.v1.SubjectRulesReviewStatus": {
+      "properties": {"incomplete": {"type": "boolean"}}
+    },
+    "com.github.openshift.api.image.docker10.DockerImage": {
+      "properties": {"Size": {"type": "integer"}}
+    }
+  }
+}`
+
+func TestEmitOpenShift(t *testing.T) {
+	files, report, err := EmitFiles(parseSpec(t, openShiftSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0].Check != kubespec.CheckMissingVersion {
+		t.Errorf("Expected a single warning about the Docker image format, got:\n%v", report.Warnings)
+	}
+
+	tests := map[string]string{
+		"route":            `{"apiVersion":"route.openshift.io/v1","kind":"Route","spec":{"host":"example.com"}}`,
+		"openShiftReview":  `{"apiVersion":"authorization.openshift.io/v1","kind":"SubjectAccessReview","status":{"evaluationError":"none"}}`,
+		"kubernetesReview": `{"apiVersion":"authorization.k8s.io/v1","kind":"SubjectAccessReview","status":{"incomplete":true}}`,
+	}
+	programs := map[string]string{
+		"route":            `k8s.route.v1.route.new() + k8s.route.v1.route.mixin.spec.withHost("example.com")`,
+		"openShiftReview":  `k8s.openshiftAuthorization.v1.subjectAccessReview.new() + k8s.openshiftAuthorization.v1.subjectAccessReview.mixin.status.withEvaluationError("none")`,
+		"kubernetesReview": `k8s.authorization.v1.subjectAccessReview.new() + k8s.authorization.v1.subjectAccessReview.mixin.status.withIncomplete(true)`,
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		compact := bytes.Buffer{}
+		if err := json.Compact(&compact, []byte(outputs[name])); err != nil {
+			t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+		}
+		if compact.String() != expected {
+			t.Errorf("[%s] Expected '%s', got:\n%s", name, expected, compact.String())
+		}
+	}
+}
+
+var structuralExtensionsSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "com.example.operator.v1.Widget": {
+      "properties": {
+        "config": {"description": "Config is passed to the widget as is.", "x-kubernetes-preserve-unknown-fields": true},
+        "port": {"x-kubernetes-int-or-string": true, "anyOf": [{"type": "integer"}, {"type": "string"}]}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "operator.example.com", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestEmitStructuralExtensions(t *testing.T) {
+	files, report, err := EmitFiles(
+		parseSpec(t, structuralExtensionsSpec), nil, nil,
+		Options{Strict: true, UnknownTypes: UnknownTypesStrict})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got:\n%v", report.Warnings)
+	}
+
+	widget := "k8s.operator.v1.widget"
+	tests := map[string]string{
+		"config":      `{"config":{"a":1}}`,
+		"configMixin": `{"config":{"a":1,"b":2}}`,
+		"port":        `{"port":8080}`,
+		"portName":    `{"port":"http"}`,
+		"portObject":  "port must be an integer or a string",
+	}
+	programs := map[string]string{
+		"config":      widget + ".withConfig({a: 1})",
+		"configMixin": widget + ".withConfig({a: 1}) + " + widget + ".withConfigMixin({b: 2})",
+		"port":        widget + ".withPort(8080)",
+		"portName":    widget + `.withPort("http")`,
+		"portObject":  widget + ".withPort({})",
+	}
+	for name, program := range programs {
+		programs[name] = fmt.Sprintf("local k8s = import %q; %s", k8sFile, program)
+	}
+
+	outputs, errs := evaluate(files, programs)
+	for name, expected := range tests {
+		var actual string
+		if err, ok := errs[name]; ok {
+			actual = err.Error()
+		} else {
+			compact := bytes.Buffer{}
+			if err := json.Compact(&compact, []byte(outputs[name])); err != nil {
+				t.Fatalf("[%s] Expected JSON, got:\n%s", name, outputs[name])
+			}
+			actual = compact.String()
+		}
+		if !strings.Contains(actual, expected) {
+			t.Errorf("[%s] Expected '%s' in:\n%s", name, expected, actual)
+		}
+	}
+}
+
+var prototypesSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.ConfigMap": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "data": {"type": "object", "additionalProperties": {"type": "string"}}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "ConfigMap"}]
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "properties": {
+        "name": {"type": "string"}
+      }
+    }
+  }
+}`
+
+func TestEmitPrototypes(t *testing.T) {
+	files, report, err := EmitFiles(parseSpec(t, prototypesSpec), nil, nil, Options{Prototypes: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if _, ok := files["parts/parts.yaml"]; !ok {
+		t.Errorf("Expected 'parts/parts.yaml'")
+	}
+	prototype := string(files["parts/prototypes/configmap.jsonnet"])
+	expected := []string{
+		"// @name io.ksonnet.pkg.k8s-configmap",
+		"// @param name string Name to give the config map.",
+		"// @optionalParam data object {} Data for the config map.",
+		"  k.core.v1.configMap.new(name=name, data=data);",
+		"k.asList([configMap])",
+	}
+	for _, text := range expected {
+		if !strings.Contains(prototype, text) {
+			t.Errorf("Expected '%s' in the prototype, got:\n%s", text, prototype)
+		}
+	}
+
+	// The prototype builds its objects with the library, once ksonnet
+	// has substituted its parameters.
+	program := strings.NewReplacer(
+		`import "param://name"`, `"settings"`,
+		`import "param://data"`, `{color: "blue"}`,
+	).Replace(prototype)
+	outputs, errs := evaluate(files, map[string]string{"configmap": program})
+	if err, ok := errs["configmap"]; ok {
+		t.Fatalf("Failed to evaluate prototype:\n%v", err)
+	}
+	var actual, want interface{}
+	json.Unmarshal([]byte(outputs["configmap"]), &actual)
+	json.Unmarshal([]byte(`{"apiVersion": "v1", "kind": "List", "items": [
+		{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "settings"}, "data": {"color": "blue"}}
+	]}`), &want)
+	if !reflect.DeepEqual(actual, want) {
+		t.Errorf("Expected a list of the config map, got:\n%s", outputs["configmap"])
+	}
+
+	// The prototypes of kinds the library doesn't have are left out.
+	if _, ok := files["parts/prototypes/deployed-service.jsonnet"]; ok {
+		t.Errorf("Expected no prototype of a deployment without the kind")
+	}
+	found := false
+	for _, warning := range report.Warnings {
+		if strings.Contains(warning.Message, "prototype 'deployed-service' not emitted: the library has no 'ServicePort'") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected an error for the left-out prototype, got: %v", report.Warnings)
+	}
+
+	_, _, err = EmitFiles(
+		parseSpec(t, prototypesSpec), nil, nil, Options{Prototypes: true, Backend: DhallBackend})
+	if err == nil || !strings.Contains(err.Error(), "Prototypes require") {
+		t.Errorf("Expected error for prototypes of another backend, got: %v", err)
+	}
+}
+
+func TestEmitGoFile(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, customConstructorSpec), nil, nil, Options{GoPackage: "k8slib"})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	goFile := string(files[GoFile])
+	if _, err := parser.ParseFile(token.NewFileSet(), GoFile, goFile, 0); err != nil {
+		t.Fatalf("Failed to parse the Go file:\n%v", err)
+	}
+	expected := []string{
+		"package k8slib",
+		`const KubernetesVersion = "v1.7.0"`,
+		fmt.Sprintf("%q: `", kFile),
+		fmt.Sprintf("%q: `", k8sFile),
+		"func Files() map[string][]byte {",
+	}
+	for _, text := range expected {
+		if !strings.Contains(goFile, text) {
+			t.Errorf("Expected '%s' in the Go file, got:\n%s", text, goFile)
+		}
+	}
+
+	// Backquotes, which raw string literals can't contain, are embedded
+	// as they are.
+	text := "// `new` builds it.\r\n{}"
+	tv, err := types.Eval(token.NewFileSet(), nil, token.NoPos, goRawString(text))
+	if err != nil {
+		t.Fatalf("Failed to evaluate '%s':\n%v", goRawString(text), err)
+	}
+	if actual := constant.StringVal(tv.Value); actual != text {
+		t.Errorf("Expected %q, got %q", text, actual)
+	}
+
+	_, _, err = EmitFiles(parseSpec(t, customConstructorSpec), nil, nil, Options{GoPackage: "k8s-lib"})
+	if err == nil || !strings.Contains(err.Error(), "is not an identifier") {
+		t.Errorf("Expected error for an invalid package name, got: %v", err)
+	}
+}
+
+var quantitySpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Pod": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "resources": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.ResourceRequirements"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Pod"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.ResourceRequirements": {
+      "properties": {
+        "limits": {"type": "object", "additionalProperties": {"type": "string"}},
+        "requests": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+func TestEmitQuantityHelpers(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, quantitySpec), nil, nil, Options{QuantityHelpers: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	resources := fmt.Sprintf("local resources = (import %q).core.v1.pod.mixin.resources; ", k8sFile)
+	tests := map[string]struct{ program, expected string }{
+		"parse": {
+			`[resources.parseQuantity(q) for q in ["500m", "1Gi", "1.5k", "-2", "1e3", 3, "1.2.3", "5Q", ""]]`,
+			`[0.5, 1073741824, 1500, -2, 1000, 3, null, null, null]`,
+		},
+		"resourceList": {
+			`resources.resourceList(cpu="500m")`,
+			`{"cpu": "500m"}`,
+		},
+		"withLimits": {
+			`resources.withLimits(resources.resourceList(cpu=1, memory=resources.memory("1Gi")))`,
+			`{"resources": {"limits": {"cpu": 1, "memory": "1Gi"}}}`,
+		},
+	}
+	programs := map[string]string{
+		"invalid":  resources + `resources.cpu("lots")`,
+		"negative": resources + `resources.resourceList(memory="-1Gi")`,
+	}
+	for name, test := range tests {
+		programs[name] = resources + test.program
+	}
+	outputs, errs := evaluate(files, programs)
+	for name, test := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		var actual, want interface{}
+		json.Unmarshal([]byte(outputs[name]), &actual)
+		json.Unmarshal([]byte(test.expected), &want)
+		if !reflect.DeepEqual(actual, want) {
+			t.Errorf("[%s] Expected %s, got:\n%s", name, test.expected, outputs[name])
+		}
+	}
+	for name, expected := range map[string]string{
+		"invalid":  "'lots' is not a valid CPU quantity",
+		"negative": "memory quantity '-1Gi' must not be negative",
+	} {
+		if err, ok := errs[name]; !ok || !strings.Contains(err.Error(), expected) {
+			t.Errorf("[%s] Expected error '%s', got: %v", name, expected, err)
+		}
+	}
+}
+
+var envSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Pod": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "containers": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Container"}}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Pod"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Container": {
+      "properties": {
+        "name": {"type": "string"},
+        "image": {"type": "string"},
+        "env": {
+          "type": "array",
+          "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.EnvVar"},
+          "x-kubernetes-patch-merge-key": "name",
+          "x-kubernetes-patch-strategy": "merge"
+        },
+        "envFrom": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.EnvFromSource"}}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.EnvVar": {
+      "properties": {
+        "name": {"type": "string"},
+        "value": {"type": "string"},
+        "valueFrom": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.EnvVarSource"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.EnvVarSource": {
+      "properties": {
+        "fieldRef": {"type": "object"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.EnvFromSource": {
+      "properties": {
+        "configMapRef": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.ConfigMapEnvSource"},
+        "prefix": {"type": "string"},
+        "secretRef": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.SecretEnvSource"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.ConfigMapEnvSource": {
+      "properties": {
+        "name": {"type": "string"},
+        "optional": {"type": "boolean"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.SecretEnvSource": {
+      "properties": {
+        "name": {"type": "string"},
+        "optional": {"type": "boolean"}
+      }
+    }
+  }
+}`
+
+func TestEmitEnvHelpers(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, envSpec), nil, nil, Options{EnvHelpers: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	container := fmt.Sprintf("local container = (import %q).core.v1.pod.containersType; ", k8sFile)
+	tests := map[string]struct{ program, expected string }{
+		"withEnvMap": {
+			`container.withEnvMap({PORT: 8080, DEBUG: null, POD: {fieldRef: {fieldPath: "metadata.name"}}, LOG: "debug"})`,
+			`{"env": [{"name": "LOG", "value": "debug"}, {"name": "POD", "valueFrom": {"fieldRef": {"fieldPath": "metadata.name"}}}, {"name": "PORT", "value": "8080"}]}`,
+		},
+		"withEnvMapMixin": {
+			`container.withEnvMap({A: "1"}) + container.withEnvMapMixin({B: "2"})`,
+			`{"env": [{"name": "A", "value": "1"}, {"name": "B", "value": "2"}]}`,
+		},
+		"envFrom": {
+			`container.withEnvFrom([container.envFromConfigMapRef("config", prefix="APP_"), container.envFromSecretRef("secrets", optional=false)])`,
+			`{"envFrom": [{"configMapRef": {"name": "config"}, "prefix": "APP_"}, {"secretRef": {"name": "secrets", "optional": false}}]}`,
+		},
+	}
+	programs := make(map[string]string)
+	for name, test := range tests {
+		programs[name] = container + test.program
+	}
+	outputs, errs := evaluate(files, programs)
+	for name, test := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		var actual, want interface{}
+		json.Unmarshal([]byte(outputs[name]), &actual)
+		json.Unmarshal([]byte(test.expected), &want)
+		if !reflect.DeepEqual(actual, want) {
+			t.Errorf("[%s] Expected %s, got:\n%s", name, test.expected, outputs[name])
+		}
+	}
+
+	files, _, err = EmitFiles(parseSpec(t, envSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if strings.Contains(string(files[k8sFile]), "withEnvMap") {
+		t.Errorf("Expected no env helpers without `EnvHelpers`")
+	}
+}
+
+func TestEmitNamingConvention(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, envSpec), nil, nil, Options{NamingConvention: "snake_case", EnvHelpers: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	k8s := string(files[k8sFile])
+	for _, expected := range []string{"containers_type::", "with_env_from(env_from)::", "with_env_map(vars)::", "env_from_config_map_ref(name, optional=null, prefix=null)::"} {
+		if !strings.Contains(k8s, expected) {
+			t.Errorf("Expected '%s' in the library", expected)
+		}
+	}
+	if strings.Contains(k8s, "withEnv") {
+		t.Errorf("Expected no camelCase setters in the library")
+	}
+
+	program := fmt.Sprintf(
+		`local container = (import %q).core.v1.pod.containers_type; container.with_env_map({PORT: 80}) + container.with_name("app")`,
+		k8sFile)
+	outputs, errs := evaluate(files, map[string]string{"snake_case": program})
+	if err, ok := errs["snake_case"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	var actual, want interface{}
+	json.Unmarshal([]byte(outputs["snake_case"]), &actual)
+	json.Unmarshal([]byte(`{"env": [{"name": "PORT", "value": "80"}], "name": "app"}`), &want)
+	if !reflect.DeepEqual(actual, want) {
+		t.Errorf("Expected the fields of the Kubernetes API, got:\n%s", outputs["snake_case"])
+	}
+
+	files, _, err = EmitFiles(parseSpec(t, envSpec), nil, nil, Options{})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if !strings.Contains(string(files[k8sFile]), "containersType::") {
+		t.Errorf("Expected camelCase identifiers without a naming convention")
+	}
+
+	_, _, err = EmitFiles(parseSpec(t, envSpec), nil, nil, Options{NamingConvention: "kebab-case"})
+	if err == nil || !strings.Contains(err.Error(), "Unrecognized naming convention") {
+		t.Errorf("Expected error for an unknown naming convention, got: %v", err)
+	}
+}
+
+func TestGenerateConcurrentNamingConventions(t *testing.T) {
+	// Libraries in different conventions are generated concurrently,
+	// e.g., by a server, so none must leak into another (which `go test
+	// -race` also checks).
+	tests := map[string]struct{ expected, unexpected string }{
+		"camelCase":  {"containersType::", "containers_type::"},
+		"snake_case": {"containers_type::", "containersType::"},
+	}
+	errs := make(chan error, 2*len(tests))
+	wg := sync.WaitGroup{}
+	for convention, test := range tests {
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(convention, expected, unexpected string) {
+				defer wg.Done()
+				files, _, err := Generate([]byte(envSpec), Options{NamingConvention: convention})
+				if err != nil {
+					errs <- err
+					return
+				}
+				text := string(files[k8sFile])
+				if !strings.Contains(text, expected) || strings.Contains(text, unexpected) {
+					errs <- fmt.Errorf("Expected the library in %s to have '%s', and not '%s'", convention, expected, unexpected)
+				}
+			}(convention, test.expected, test.unexpected)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestEmitMetadata(t *testing.T) {
+	ksonnetLibSHA, k8sSHA := "abc123", ""
+	generatedAt := time.Date(2017, 8, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	tests := map[string]struct {
+		options  Options
+		expected string
+	}{
+		"default": {
+			Options{},
+			`{"k8sVersion": "v1.7.0", "generatorVersion": "abc123", "specSHA": null, "generatedAt": null}`,
+		},
+		"generatedAt": {
+			Options{GeneratedAt: generatedAt, Split: SplitGroup},
+			`{"k8sVersion": "v1.7.0", "generatorVersion": "abc123", "specSHA": null, "generatedAt": "2017-08-01T12:00:00Z"}`,
+		},
+	}
+	for name, test := range tests {
+		files, _, err := EmitFiles(parseSpec(t, shortNamesSpec), &ksonnetLibSHA, &k8sSHA, test.options)
+		if err != nil {
+			t.Fatalf("[%s] Failed to emit:\n%v", name, err)
+		}
+		programs := map[string]string{name: fmt.Sprintf("(import %q).__ksonnetLib", k8sFile)}
+		outputs, errs := evaluate(files, programs)
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		var actual, want interface{}
+		json.Unmarshal([]byte(outputs[name]), &actual)
+		json.Unmarshal([]byte(test.expected), &want)
+		if !reflect.DeepEqual(actual, want) {
+			t.Errorf("[%s] Expected %s, got:\n%s", name, test.expected, outputs[name])
+		}
+	}
+}
+
+func TestEmitMixinInstance(t *testing.T) {
+	files, _, err := EmitFiles(parseSpec(t, envSpec), nil, nil, Options{TypeAssertions: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	pod := fmt.Sprintf("local pod = (import %q).core.v1.pod; ", k8sFile)
+	tests := map[string]struct{ program, expected string }{
+		"object": {
+			`pod.new() + pod.mixinInstance({metadata: {name: "web"}})`,
+			`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web"}}`,
+		},
+		"hiddenType": {
+			`pod.containersType.new("web", "nginx") + pod.containersType.mixinInstance({env+: [{name: "A", value: "1"}]})`,
+			`{"name": "web", "image": "nginx", "env": [{"name": "A", "value": "1"}]}`,
+		},
+	}
+	programs := map[string]string{
+		"notObject": pod + `pod.mixinInstance("web")`,
+	}
+	for name, test := range tests {
+		programs[name] = pod + test.program
+	}
+	outputs, errs := evaluate(files, programs)
+	for name, test := range tests {
+		if err, ok := errs[name]; ok {
+			t.Errorf("[%s] Failed to evaluate:\n%v", name, err)
+			continue
+		}
+		var actual, want interface{}
+		json.Unmarshal([]byte(outputs[name]), &actual)
+		json.Unmarshal([]byte(test.expected), &want)
+		if !reflect.DeepEqual(actual, want) {
+			t.Errorf("[%s] Expected %s, got:\n%s", name, test.expected, outputs[name])
+		}
+	}
+	if err, ok := errs["notObject"]; !ok || !strings.Contains(err.Error(), "obj must be an object, got string") {
+		t.Errorf("Expected error for a fragment that is not an object, got: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package ksonnet
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// `enumComment` returns the line of the comments of a property that
+// lists the values of its `enum`.
+func enumComment(enum []interface{}) string {
+	return fmt.Sprintf("Must be one of %s.", enumValuesText(enum))
+}
+
+// `itemsEnumComment` returns the line of the comments of an array
+// property that lists the values of the `enum` of its elements.
+func itemsEnumComment(enum []interface{}) string {
+	return fmt.Sprintf("Each element must be one of %s.", enumValuesText(enum))
+}
+
+// `enumValuesText` returns the values of an `enum`, quoted as code, and
+// separated by commas.
+func enumValuesText(enum []interface{}) string {
+	values := []string{}
+	for _, value := range enum {
+		values = append(values, fmt.Sprintf("`%s`", enumValueText(value)))
+	}
+	return strings.Join(values, ", ")
+}
+
+// `emitEnumConstants` emits, for a property with an `enum`, an object
+// named after the property that has a constant for each of its
+// values, e.g., `imagePullPolicy:: {always: "Always", ...}`, so that
+// users can write `container.withImagePullPolicy(
+// container.imagePullPolicy.ifNotPresent)` rather than repeating the
+// value.
+func (p *property) emitEnumConstants(m *astWriter) {
+	if len(p.enum) == 0 {
+		return
+	}
+
+	seen := make(map[jsonnet.FieldKey]bool)
+	constants := []string{}
+	for _, value := range p.enum {
+		name := enumConstantName(value)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		constants = append(constants, fmt.Sprintf("%s: %s", name, jsonnetValue(value)))
+	}
+
+	m.comment(fmt.Sprintf("The values `%s` can have.", p.name))
+	m.hidden(
+		string(jsonnet.RewriteAsFieldKey(p.name)), fmt.Sprintf("{%s}", strings.Join(constants, ", ")))
+}
+
+// `enumConstantName` returns the field name of the constant of an
+// enum value, which is the value in lowerCamelCase (e.g.,
+// `ifNotPresent` for `IfNotPresent`, and `tcp` for `TCP`), quoted if
+// it is not a valid identifier (e.g., `"read-only"`).
+func enumConstantName(value interface{}) jsonnet.FieldKey {
+	text := enumValueText(value)
+	runes := []rune(text)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	switch {
+	case upper == len(runes):
+		text = strings.ToLower(text)
+	case upper > 1:
+		// E.g., `HTTPGet`, whose last upper case letter starts a word.
+		text = strings.ToLower(string(runes[:upper-1])) + string(runes[upper-1:])
+	case upper == 1:
+		text = strings.ToLower(string(runes[:1])) + string(runes[1:])
+	}
+
+	if !identifierPattern.MatchString(text) {
+		return jsonnet.FieldKey(jsonnetString(text))
+	}
+	return jsonnet.RewriteAsFieldKey(kubespec.PropertyName(text))
+}
+
+// `enumValueText` returns the text of an enum value, which is usually,
+// but not always, a string.
+func enumValueText(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return jsonnetValue(value)
+}
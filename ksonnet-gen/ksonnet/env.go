@@ -0,0 +1,168 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+const (
+	// envVarKind is the kind of the elements of the `env` of a
+	// container, which set one environment variable each, to a `value`
+	// or from a `valueFrom` source.
+	envVarKind kubespec.ObjectKind = "EnvVar"
+	// envFromSourceKind is the kind of the elements of the `envFrom` of
+	// a container, which set an environment variable for every key of
+	// a config map (`configMapRef`) or a secret (`secretRef`).
+	envFromSourceKind kubespec.ObjectKind = "EnvFromSource"
+)
+
+// `emitEnvHelpers` emits, if `Options.EnvHelpers` is set, the helpers
+// of the arrays of `EnvVar`s and `EnvFromSource`s of `ao` (i.e., the
+// `env` and `envFrom` of containers), e.g.,
+//
+//	container.withEnvMap({LOG_LEVEL: "debug", PORT: 8080}) +
+//	container.withEnvFrom([container.envFromConfigMapRef("app-config")])
+//
+// The helpers are derived from the definitions of the elements, so
+// that they are only emitted if the elements have the properties they
+// set, and they are left out if their names collide with a function
+// of another property of `ao`.
+func (ao *apiObject) emitEnvHelpers(m *astWriter) {
+	if !ao.root().options.EnvHelpers {
+		return
+	}
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == typeAlias || pm.schemaType == nil || *pm.schemaType != "array" ||
+			!isMixinRef(pm.itemTypes.Ref) {
+			continue
+		}
+		element := ao.root().getAPIObject(ao.root().parseRef(pm.itemTypes.Ref))
+		switch element.name {
+		case envVarKind:
+			pm.emitEnvMapHelpers(m, element)
+		case envFromSourceKind:
+			pm.emitEnvFromBuilders(m, element)
+		}
+	}
+}
+
+// `emitEnvMapHelpers` emits the setter and mixin of `p`, an array of
+// `EnvVar`s (`element`), that take a plain map of the names of the
+// variables to their values (e.g., `withEnvMap` and `withEnvMapMixin`
+// for `env`). Values that are objects are `valueFrom` sources, values
+// that are null are left out, and other values are converted to
+// strings, as `value` is one.
+func (p *property) emitEnvMapHelpers(m *astWriter, element *apiObject) {
+	if element.propertyAt("name") == nil || element.propertyAt("value") == nil {
+		return
+	}
+	k8sVersion := p.root().spec.Info.Version
+	naming := p.root().naming
+	id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name)
+	mapID := id.Join(naming, "Map")
+	if p.takesFunctionName(mapID.ToSetterID(naming)) || p.takesFunctionName(mapID.ToMixinID(naming)) {
+		return
+	}
+
+	value := "{name: name, value: if std.isString(vars[name]) then vars[name] else std.toString(vars[name])}"
+	if element.propertyAt("valueFrom") != nil {
+		value = fmt.Sprintf("if std.isObject(vars[name]) then {name: name, valueFrom: vars[name]} else %s", value)
+	}
+	local := fmt.Sprintf("__%sOfMap", id)
+	m.field(astLocalMethod(local, m.function([]string{"vars"}, m.code(fmt.Sprintf(
+		"[%s for name in std.objectFields(vars) if vars[name] != null]", value)))))
+	for _, fn := range []struct{ name, setter jsonnet.Identifier }{
+		{mapID.ToSetterID(naming), id.ToSetterID(naming)},
+		{mapID.ToMixinID(naming), id.ToMixinID(naming)},
+	} {
+		m.comment(fmt.Sprintf(
+			"Like `%s`, but takes a map of the names of the variables to their values (e.g., `{LOG_LEVEL: \"debug\"}`).",
+			fn.setter))
+		m.method(string(fn.name), []string{"vars"}, fmt.Sprintf("self.%s(%s(vars))", fn.setter, local))
+	}
+}
+
+// `emitEnvFromBuilders` emits a builder of an element of `p`, an array
+// of `EnvFromSource`s (`element`), for each of its properties that
+// refer to a named source (e.g., `envFromConfigMapRef(name,
+// optional=null, prefix=null)` for the `configMapRef` of `envFrom`). A
+// builder takes the name of the source, the other properties of the
+// source, and the properties of `element` that are not sources, which
+// are left out if they are null.
+func (p *property) emitEnvFromBuilders(m *astWriter, element *apiObject) {
+	k8sVersion := p.root().spec.Info.Version
+	naming := p.root().naming
+	id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name)
+
+	shared := []*property{}
+	for _, pm := range element.emittedProperties {
+		if pm.kind != typeAlias && !isMixinRef(pm.ref) {
+			shared = append(shared, pm)
+		}
+	}
+	for _, source := range element.emittedProperties {
+		if source.kind == typeAlias || !isMixinRef(source.ref) {
+			continue
+		}
+		sourceObject := p.root().getAPIObject(p.root().parseRef(source.ref))
+		if sourceObject.propertyAt("name") == nil {
+			continue
+		}
+		name := id.Join(naming, string(jsonnet.RewriteAsIdentifier(naming, k8sVersion, source.name)))
+		if p.takesFunctionName(name) {
+			continue
+		}
+
+		params := []string{"name"}
+		sourceFields := []string{"name: name"}
+		taken := map[jsonnet.FuncParam]bool{"name": true}
+		for _, pm := range sourceObject.emittedProperties {
+			if pm.kind == typeAlias || pm.name == "name" {
+				continue
+			}
+			param := jsonnet.RewriteAsFuncParam(naming, k8sVersion, pm.name)
+			taken[param] = true
+			params = append(params, fmt.Sprintf("%s=null", param))
+			sourceFields = append(sourceFields, fmt.Sprintf("%s: %s", jsonnet.RewriteAsFieldKey(pm.name), param))
+		}
+		fields := []string{fmt.Sprintf(
+			"%s: {%s}", jsonnet.RewriteAsFieldKey(source.name), strings.Join(sourceFields, ", "))}
+		collides := false
+		for _, pm := range shared {
+			param := jsonnet.RewriteAsFuncParam(naming, k8sVersion, pm.name)
+			if taken[param] {
+				collides = true
+				break
+			}
+			params = append(params, fmt.Sprintf("%s=null", param))
+			fields = append(fields, fmt.Sprintf("%s: %s", jsonnet.RewriteAsFieldKey(pm.name), param))
+		}
+		if collides {
+			continue
+		}
+
+		m.comment(fmt.Sprintf(
+			"Returns an element of `%s` that sets the environment variables of the `%s` named `name`.",
+			p.name, sourceObject.name))
+		m.method(string(name), params, fmt.Sprintf("std.prune({%s})", strings.Join(fields, ", ")))
+	}
+}
+
+// `takesFunctionName` reports whether `name` is the name of another
+// property of the object `p` belongs to, or of its setter, mixin, or
+// add function, so that a helper of `p` with that name would shadow
+// it.
+func (p *property) takesFunctionName(name jsonnet.Identifier) bool {
+	k8sVersion := p.root().spec.Info.Version
+	naming := p.root().naming
+	for _, sibling := range p.parent.emittedProperties {
+		id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, sibling.name)
+		if id == name || id.ToSetterID(naming) == name || id.ToMixinID(naming) == name || id.ToAddID(naming) == name {
+			return true
+		}
+	}
+	return false
+}
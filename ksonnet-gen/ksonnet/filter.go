@@ -0,0 +1,130 @@
+package ksonnet
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `kindPattern` completes a pattern of `Options.Include` or
+// `Options.Exclude` that leaves out the version or kind with `*`, e.g.,
+// `apps` becomes `apps/*/*`.
+func kindPattern(pattern string) string {
+	for strings.Count(pattern, "/") < 2 {
+		pattern += "/*"
+	}
+	return pattern
+}
+
+// `checkKindPatterns` returns an error if any pattern of
+// `Options.Include` or `Options.Exclude` is malformed.
+func checkKindPatterns(opts Options) error {
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := path.Match(kindPattern(pattern), ""); err != nil {
+			return fmt.Errorf("Malformed kind pattern '%s':\n%v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// `matchesKind` reports whether `Options.Include`, `Options.Kinds`,
+// and `Options.Exclude` keep the top-level API object `ao`: it has to
+// match some pattern of `Include` or be one of `Kinds`, if there are
+// any, and match no pattern of `Exclude`.
+func (opts Options) matchesKind(ao *apiObject) bool {
+	gvk := fmt.Sprintf("%s/%s/%s", ao.parent.parent.name, ao.parent.version, ao.name)
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(kindPattern(pattern), gvk); ok {
+				return true
+			}
+		}
+		return false
+	}
+	isKind := func() bool {
+		kind := ManifestKind{APIVersion: ao.parent.apiVersion(), Kind: string(ao.name)}
+		for _, k := range opts.Kinds {
+			if k == kind {
+				return true
+			}
+		}
+		return false
+	}
+	included := len(opts.Include) == 0 && len(opts.Kinds) == 0 ||
+		matches(opts.Include) || isKind()
+	return included && !matches(opts.Exclude)
+}
+
+// `filterKinds` removes the top-level API objects that
+// `Options.Include` and `Options.Exclude` filter out, along with every
+// object that only they refer to, so that the library only has the
+// APIs they ask for. Objects that the objects that are kept refer to
+// are kept too, even if they were filtered out, so that their mixins
+// still work.
+func (root *root) filterKinds() {
+	opts := root.options
+	if len(opts.Include) == 0 && len(opts.Exclude) == 0 && len(opts.Kinds) == 0 {
+		return
+	}
+
+	kept := make(map[*apiObject]bool)
+	var keep func(ao *apiObject)
+	keep = func(ao *apiObject) {
+		if kept[ao] {
+			return
+		}
+		kept[ao] = true
+		for _, p := range ao.properties {
+			for _, ref := range []*kubespec.ObjectRef{p.ref, p.itemTypes.Ref} {
+				if !isMixinRef(ref) {
+					continue
+				}
+				parsed := root.parseRef(ref)
+				if parsed.Version == nil {
+					continue
+				}
+				if referenced, err := root.getAPIObjectHelper(parsed, false); err == nil {
+					keep(referenced)
+				} else if referenced, err := root.getAPIObjectHelper(parsed, true); err == nil {
+					keep(referenced)
+				}
+			}
+		}
+	}
+	defined := make(map[ManifestKind]bool)
+	for _, group := range root.groups {
+		for _, versionedAPI := range group.versionedAPIs {
+			for _, ao := range versionedAPI.apiObjects {
+				defined[ManifestKind{APIVersion: versionedAPI.apiVersion(), Kind: string(ao.name)}] = true
+				if opts.matchesKind(ao) {
+					keep(ao)
+				}
+			}
+		}
+	}
+	for _, kind := range opts.Kinds {
+		if !defined[kind] {
+			root.report.warnf("", "kind '%s' is not defined by the spec, so it is not in the library", kind)
+		}
+	}
+
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for groupName, group := range groups {
+			for version, versionedAPI := range group.versionedAPIs {
+				for kind, ao := range versionedAPI.apiObjects {
+					if !kept[ao] {
+						delete(versionedAPI.apiObjects, kind)
+					}
+				}
+				if len(versionedAPI.apiObjects) == 0 {
+					delete(group.versionedAPIs, version)
+				}
+			}
+			if len(group.versionedAPIs) == 0 {
+				delete(groups, groupName)
+			}
+		}
+	}
+}
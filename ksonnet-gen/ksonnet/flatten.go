@@ -0,0 +1,150 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// `emitFlattenedSetters` emits, for a top-level API object, setters
+// that reach through nested `$ref` properties in a single call. For
+// example, rather than writing
+// `deployment.mixin.spec.template.spec.withContainers(c)`, a user can
+// write `deployment.withSpecTemplateSpecContainers(c)`.
+//
+// These are generated by walking the properties that `$ref` other API
+// objects, up to `Options.FlattenDepth` properties deep (counting the
+// property being set). A depth of less than 2 disables this feature,
+// since the single-property setters already exist.
+func (ao *apiObject) emitFlattenedSetters(m *astWriter) {
+	depth := ao.root().options.FlattenDepth
+	if !ao.isTopLevel || depth < 2 {
+		return
+	}
+
+	taken := make(map[jsonnet.Identifier]bool)
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	for _, pm := range ao.emittedProperties {
+		id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name)
+		taken[id.ToSetterID(naming)] = true
+		taken[id.ToMixinID(naming)] = true
+		if key := pm.mergeKey(); key != "" {
+			taken[id.ToReplaceByKeyID(naming, key)] = true
+		}
+		if pm.isMap() {
+			taken[id.ToItemSetterID(naming)] = true
+		}
+	}
+
+	ao.emitFlattenedSettersHelper(m, ao, []*property{}, depth, taken)
+}
+
+func (ao *apiObject) emitFlattenedSettersHelper(
+	m *astWriter, topLevel *apiObject, path []*property, depth int,
+	taken map[jsonnet.Identifier]bool,
+) {
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == typeAlias || isSpecialProperty(pm.name) {
+			continue
+		}
+
+		if isMixinRef(pm.ref) {
+			if len(path)+2 > depth {
+				continue
+			}
+			ref := ao.root().getAPIObject(ao.root().parseRef(pm.ref))
+			ref.emitFlattenedSettersHelper(
+				m, topLevel, append(path[:len(path):len(path)], pm), depth, taken)
+		} else if len(path) > 0 {
+			pm.emitFlattened(m, topLevel, path, taken)
+		}
+	}
+}
+
+// `emitFlattened` emits a setter (and, for arrays and objects, a
+// mixin) for `p`, reached from the top-level API object through the
+// properties in `path`.
+func (p *property) emitFlattened(
+	m *astWriter, topLevel *apiObject, path []*property,
+	taken map[jsonnet.Identifier]bool,
+) {
+	k8sVersion := p.root().spec.Info.Version
+	naming := p.root().naming
+
+	segments := []string{}
+	for _, ancestor := range path[1:] {
+		segments = append(
+			segments, string(jsonnet.RewriteAsIdentifier(naming, k8sVersion, ancestor.name)))
+	}
+	segments = append(
+		segments, string(jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name)))
+	id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, path[0].name).Join(naming, segments...)
+
+	setterName := id.ToSetterID(naming)
+	mixinName := id.ToMixinID(naming)
+	replaceName := id.ToReplaceByKeyID(naming, p.mergeKey())
+	itemSetterName := id.ToItemSetterID(naming)
+	if taken[setterName] || taken[mixinName] ||
+		(p.mergeKey() != "" && taken[replaceName]) || (p.isMap() && taken[itemSetterName]) {
+		p.root().report.errorf(
+			topLevel.parsedName.Unparse(),
+			"flattened setter '%s' not emitted, because a function with that name already exists",
+			setterName)
+		return
+	}
+	taken[setterName] = true
+	taken[mixinName] = true
+	if p.mergeKey() != "" {
+		taken[replaceName] = true
+	}
+	if p.isMap() {
+		taken[itemSetterName] = true
+	} else {
+		itemSetterName = ""
+	}
+
+	// Wrap some object literal in the fields of every property in
+	// `path`, e.g., `{spec+: {template+: <inner>}}`.
+	wrap := func(inner string) string {
+		for i := len(path) - 1; i >= 0; i-- {
+			fieldName := jsonnet.RewriteAsFieldKey(path[i].name)
+			inner = fmt.Sprintf("{%s+: %s}", fieldName, inner)
+		}
+		return inner
+	}
+
+	paramName := jsonnet.RewriteAsFuncParam(naming, k8sVersion, p.name)
+	fieldName := jsonnet.RewriteAsFieldKey(p.name)
+
+	var setterBody, mixinBody string
+	if p.schemaType != nil && *p.schemaType == "array" {
+		setterBody = fmt.Sprintf(
+			"if std.type(%s) == \"array\" then %s else %s",
+			paramName,
+			wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName)),
+			wrap(fmt.Sprintf("{%s: [%s]}", fieldName, paramName)))
+		mixinBody = p.arrayMixinBody(paramName, false, wrap)
+	} else if p.schemaType != nil && *p.schemaType == "object" {
+		setterBody = wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName))
+		mixinBody = wrap(fmt.Sprintf("{%s+: %s}", fieldName, paramName))
+	} else {
+		setterBody = wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName))
+	}
+
+	p.comments.emit(m)
+	m.method(
+		string(setterName), []string{p.paramWithDefault(paramName)},
+		fmt.Sprintf("%sself + %s", p.setterAssertions(paramName), setterBody))
+	if mixinBody != "" {
+		p.comments.emit(m)
+		p.emitPatchStrategyComment(m)
+		m.method(
+			string(mixinName), []string{string(paramName)},
+			fmt.Sprintf("%sself + %s", p.typeAssertions(paramName), mixinBody))
+	}
+	if p.schemaType != nil && *p.schemaType == "array" {
+		p.emitReplaceByKey(m, replaceName, paramName, wrap)
+	}
+	p.emitItemSetter(m, itemSetterName, wrap)
+}
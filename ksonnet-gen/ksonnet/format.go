@@ -0,0 +1,118 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-jsonnet/formatter"
+)
+
+// FormatStringStyles and FormatCommentStyles are the styles of string
+// literals and comments `NewFormatOptions` accepts, named as the
+// `--string-style` and `--comment-style` flags of `jsonnetfmt` name
+// them: `d` for double quotes, `s` for single quotes, `h` for `#`
+// comments, `s` for `//` comments, and `l` to leave them as they are.
+var (
+	FormatStringStyles  = []string{"d", "s", "l"}
+	FormatCommentStyles = []string{"h", "s", "l"}
+)
+
+var formatStringStyles = map[string]formatter.StringStyle{
+	"d": formatter.StringStyleDouble,
+	"s": formatter.StringStyleSingle,
+	"l": formatter.StringStyleLeave,
+}
+
+var formatCommentStyles = map[string]formatter.CommentStyle{
+	"h": formatter.CommentStyleHash,
+	"s": formatter.CommentStyleSlash,
+	"l": formatter.CommentStyleLeave,
+}
+
+// NewFormatOptions returns the options of `jsonnetfmt` (see
+// `formatter.DefaultOptions`), with the given indentation, maximum
+// number of consecutive blank lines, and styles of string literals and
+// comments (see `FormatStringStyles` and `FormatCommentStyles`), for
+// `Options.Format`.
+func NewFormatOptions(
+	indent, maxBlankLines int, stringStyle, commentStyle string,
+) (*formatter.Options, error) {
+	opts := formatter.DefaultOptions()
+	if indent < 0 {
+		return nil, fmt.Errorf("Indentation must not be negative, got %d", indent)
+	}
+	if maxBlankLines < 0 {
+		return nil, fmt.Errorf("Maximum number of blank lines must not be negative, got %d", maxBlankLines)
+	}
+	opts.Indent, opts.MaxBlankLines = indent, maxBlankLines
+
+	var ok bool
+	if opts.StringStyle, ok = formatStringStyles[stringStyle]; !ok {
+		return nil, fmt.Errorf(
+			"Unknown string style '%s'; must be one of: %s",
+			stringStyle, strings.Join(FormatStringStyles, ", "))
+	}
+	if opts.CommentStyle, ok = formatCommentStyles[commentStyle]; !ok {
+		return nil, fmt.Errorf(
+			"Unknown comment style '%s'; must be one of: %s",
+			commentStyle, strings.Join(FormatCommentStyles, ", "))
+	}
+	return &opts, nil
+}
+
+// `format` runs every Jsonnet file of `files` (i.e., every
+// `.libsonnet` and `.jsonnet` file) through the go-jsonnet formatter
+// with `opts`, in place, so that the style of the library depends on
+// `opts`, rather than on how the emitter indents the code it writes.
+// It returns an error naming the first file that does not parse.
+func format(files map[string][]byte, opts formatter.Options) error {
+	for _, name := range jsonnetFileNames(files) {
+		text, err := formatter.Format(name, string(files[name]), opts)
+		if err != nil {
+			return fmt.Errorf("Could not format '%s':\n%v", name, err)
+		}
+		files[name] = []byte(text)
+	}
+	return nil
+}
+
+// `strip` removes the comments (other than the header, see
+// `Options.Header`) and blank lines of every Jsonnet file of `files`
+// but the prototypes, whose metadata is in comments (see
+// `Options.Prototypes`),
+// in place, for `Options.NoComments`; if `minify`, it also removes all
+// other whitespace it can, for `Options.Minify`. It strips with the
+// go-jsonnet formatter, which knows what is a comment, and what is in
+// a string; the other formatting options are off, so that the code is
+// otherwise left as it was.
+func (root *root) strip(files map[string][]byte, minify bool) error {
+	opts := formatter.Options{
+		Indent:       2,
+		StringStyle:  formatter.StringStyleLeave,
+		CommentStyle: formatter.CommentStyleLeave,
+	}
+	if minify {
+		opts.StripEverything = true
+	} else {
+		opts.StripComments = true
+	}
+
+	m := newIndentWriter()
+	root.emitHeader(m)
+	header, err := m.bytes()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range jsonnetFileNames(files) {
+		if strings.HasPrefix(name, PrototypesDir+"/") {
+			continue
+		}
+		text, err := formatter.Format(name, string(files[name]), opts)
+		if err != nil {
+			return fmt.Errorf("Could not strip '%s':\n%v", name, err)
+		}
+		files[name] = append(header[:len(header):len(header)], text...)
+	}
+	return nil
+}
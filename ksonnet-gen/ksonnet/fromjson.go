@@ -0,0 +1,61 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `fromJSONName` is the name of the function `emitFromJSON` emits.
+const fromJSONName = "fromJson"
+
+// `emitFromJSON` emits, for a top-level API object, a function that
+// takes an existing manifest (e.g., one parsed from YAML) and returns
+// it as an object of the library, so that it can be changed with the
+// same setters and mixins as objects built with `new`, e.g.,
+//
+//	deployment.fromJson(std.parseJson(importstr "nginx.json")) +
+//	  deployment.mixin.spec.withReplicas(3)
+//
+// The manifest is merged into `apiVersion + kind` as is, after
+// checking that its `kind` and `apiVersion`, if it has them, are those
+// of the object.
+func (ao *apiObject) emitFromJSON(m *astWriter) {
+	if !ao.root().options.FromJSON || !ao.isTopLevel {
+		return
+	}
+	if _, ok := ao.properties[kubespec.PropertyName(fromJSONName)]; ok {
+		ao.root().report.errorf(
+			ao.parsedName.Unparse(),
+			"'%s' not emitted, because a property named '%s' already exists",
+			fromJSONName, fromJSONName)
+		return
+	}
+
+	help := fmt.Sprintf(
+		"Returns the manifest `obj` (e.g., parsed from YAML) as a `%s`, checking its `kind` and `apiVersion`.",
+		ao.name)
+	m.comment(help)
+	ao.root().emitDocsonnetFunction(m, fromJSONName, comments{help}, "d.arg(\"obj\", d.T.object)")
+	m.method(fromJSONName, []string{"obj"}, ao.fromJSONAssertions("obj")+"apiVersion + kind + obj")
+}
+
+// `fromJSONAssertions` returns the Jsonnet `assert` expressions
+// (including their trailing `;`s) that check that `param` is a
+// manifest of `ao`: an object whose `kind` and `apiVersion`, if set,
+// are those of `ao`.
+func (ao *apiObject) fromJSONAssertions(param string) string {
+	// NOTE: It is important to NOT capitalize `ao.name` here.
+	kind := string(ao.name)
+	apiVersion := ao.parent.apiVersion()
+	return fmt.Sprintf(
+		"assert std.isObject(%[1]s) : %[2]s + std.type(%[1]s); "+
+			"assert !std.objectHas(%[1]s, \"kind\") || %[1]s.kind == %[3]s : %[4]s %% [%[1]s.kind]; "+
+			"assert !std.objectHas(%[1]s, \"apiVersion\") || %[1]s.apiVersion == %[5]s : %[6]s %% [%[1]s.apiVersion]; ",
+		param,
+		jsonnetString(fmt.Sprintf("Expected a manifest of kind '%s', got ", kind)),
+		jsonnetString(kind),
+		jsonnetString(fmt.Sprintf("Expected a manifest of kind '%s', got '%%s'", kind)),
+		jsonnetString(apiVersion),
+		jsonnetString(fmt.Sprintf("Expected a manifest of apiVersion '%s', got '%%s'", apiVersion)))
+}
@@ -0,0 +1,110 @@
+package ksonnet
+
+import (
+	"bytes"
+	"fmt"
+	gofmt "go/format"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+)
+
+// GoFile is the name of the file `Options.GoPackage` causes to be
+// emitted.
+const GoFile = "libsonnet.go"
+
+// `goFileAccessors` is the API of the Go file that embeds the library
+// (see `emitGoFile`), which follows the `files` map it declares.
+const goFileAccessors = `
+// Names returns the names of the files of the library, sorted, e.g.,
+// k.libsonnet and k8s.libsonnet.
+func Names() []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// File returns the text of the file of the library named name, and
+// whether there is one.
+func File(name string) ([]byte, bool) {
+	text, ok := files[name]
+	return []byte(text), ok
+}
+
+// Files returns every file of the library, by name, e.g., to import
+// them from with a jsonnet.MemoryImporter.
+func Files() map[string][]byte {
+	copied := make(map[string][]byte, len(files))
+	for name, text := range files {
+		copied[name] = []byte(text)
+	}
+	return copied
+}
+`
+
+// `checkGoPackage` returns an error if `Options.GoPackage` is set, but
+// is not a valid Go package name, or the library is not emitted as
+// Jsonnet.
+func checkGoPackage(opts Options) error {
+	if opts.GoPackage == "" {
+		return nil
+	}
+	if opts.Backend != "" && opts.Backend != JsonnetBackend {
+		return fmt.Errorf("The Go file requires the '%s' backend", JsonnetBackend)
+	}
+	if !token.IsIdentifier(opts.GoPackage) || opts.GoPackage == "_" {
+		return fmt.Errorf("Go package name '%s' is not an identifier", opts.GoPackage)
+	}
+	return nil
+}
+
+// `emitGoFile` returns the text of a Go source file of the package
+// `pkg` that embeds every `.libsonnet` file of `files` as a string
+// literal, with `Names`, `File`, and `Files` to access them, so that
+// Go programs can vendor the library without shipping data files
+// alongside it. It must be called once `files` is final (i.e., after
+// formatting and stripping).
+func (root *root) emitGoFile(files map[string][]byte, pkg string) ([]byte, error) {
+	names := []string{}
+	for name := range files {
+		if path.Ext(name) == ".libsonnet" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	k8sVersion := root.spec.Info.Version
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by ksonnet-gen from the Kubernetes %s spec. DO NOT EDIT.\n\n", k8sVersion)
+	fmt.Fprintf(&b, "// Package %s embeds ksonnet-lib, as generated from the\n", pkg)
+	fmt.Fprintf(&b, "// Kubernetes %s spec.\n", k8sVersion)
+	fmt.Fprintf(&b, "package %s\n\nimport \"sort\"\n\n", pkg)
+	b.WriteString("// KubernetesVersion is the version of the Kubernetes spec the library\n")
+	b.WriteString("// was generated from.\n")
+	fmt.Fprintf(&b, "const KubernetesVersion = %q\n\n", k8sVersion)
+	b.WriteString("var files = map[string]string{\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "%q: %s,\n", name, goRawString(string(files[name])))
+	}
+	b.WriteString("}\n")
+	b.WriteString(goFileAccessors)
+
+	text, err := gofmt.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Generated invalid Go in '%s':\n%v", GoFile, err)
+	}
+	return text, nil
+}
+
+// `goRawString` returns a Go expression of the string `s`, as a raw
+// string literal, so that the embedded code stays readable (and its
+// diffs line up), with its backquotes and carriage returns, which raw
+// string literals can't contain, concatenated as interpreted ones.
+func goRawString(s string) string {
+	r := strings.NewReplacer("`", "` + \"`\" + `", "\r", "` + \"\\r\" + `")
+	return "`" + r.Replace(s) + "`"
+}
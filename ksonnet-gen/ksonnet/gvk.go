@@ -0,0 +1,130 @@
+package ksonnet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-jsonnet/ast"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `gvkNames` are the fields `emitGVKLookup` adds to the top level of
+// the library.
+var gvkNames = []string{"gvk", "byGVK", "byKind"}
+
+// `emitGVKLookup` emits, if `Options.GVKLookup` is set, a table that
+// maps the group, version, and kind of every top-level API object of
+// the library to the object, e.g.,
+//
+//	gvk:: {
+//	  "apps": {
+//	    v1beta1: {
+//	      Deployment: $.apps.v1beta1.deployment,
+//	      ...
+//
+// along with `byGVK(group, version, kind)`, which looks an object up
+// in it, and `byKind(kind)`, which looks up the preferred version of a
+// kind (see `preferredObjectOfKind`), so that code can build objects
+// from data that names their kind (e.g., `k.byGVK("apps", "v1beta1",
+// "Deployment").new(...)`). The core group is "". Since the table
+// refers to `$`, it also reaches the helpers `k.libsonnet` adds. If a
+// group is named after one of the fields, none of them is emitted.
+func (root *root) emitGVKLookup(m *astWriter) {
+	if !root.options.GVKLookup {
+		return
+	}
+	for _, name := range gvkNames {
+		if _, ok := root.groups[kubespec.GroupName(name)]; ok {
+			root.report.errorf(
+				"", "GVK lookup not emitted, because a group named '%s' already exists", name)
+			return
+		}
+	}
+
+	tables := make(map[string]map[kubespec.VersionString][]*apiObject)
+	groupNames := []string{}
+	preferred := make(map[kubespec.ObjectKind]*apiObject)
+	for _, group := range root.groups.toSortedSlice() {
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				if !ao.isTopLevel {
+					continue
+				}
+				name := ao.gvkGroup()
+				if _, ok := tables[name]; !ok {
+					tables[name] = make(map[kubespec.VersionString][]*apiObject)
+					groupNames = append(groupNames, name)
+				}
+				tables[name][versionedAPI.version] = append(tables[name][versionedAPI.version], ao)
+				if _, ok := preferred[ao.name]; !ok {
+					preferred[ao.name] = root.preferredObjectOfKind(ao.name)
+				}
+			}
+		}
+	}
+	sort.Strings(groupNames)
+
+	m.comment("Maps the group (\"\" for the core group), version, and kind of every object of the library to it.")
+	m.namespace("gvk", func(m *astWriter) {
+		for _, name := range groupNames {
+			// NOTE: Groups are quoted even if they are identifiers, since
+			// most are not (e.g., `rbac.authorization.k8s.io`).
+			m.field(astField(jsonnetString(name), ast.ObjectFieldInherit, false, m.nested(func(m *astWriter) {
+				versions := []kubespec.VersionString{}
+				for version := range tables[name] {
+					versions = append(versions, version)
+				}
+				sort.Slice(versions, func(i, j int) bool { return versions[i].HasPriorityOver(versions[j]) })
+				for _, version := range versions {
+					m.field(astField(string(version), ast.ObjectFieldInherit, false, m.nested(func(m *astWriter) {
+						for _, ao := range tables[name][version] {
+							m.visible(string(ao.name), ao.gvkPath())
+						}
+					})))
+				}
+			})))
+		}
+	})
+
+	m.comment("Returns the object of kind `kind` of version `version` of the API group `group` (\"\" for the core group), e.g., `byGVK(\"apps\", \"v1beta1\", \"Deployment\")`.")
+	m.method("byGVK", []string{"group", "version", "kind"}, strings.Join([]string{
+		"",
+		"  local versions = if std.objectHas(self.gvk, group) then self.gvk[group] else {};",
+		"  local kinds = if std.objectHas(versions, version) then versions[version] else {};",
+		"  if std.objectHas(kinds, kind) then kinds[kind]",
+		"  else error \"The library has no kind '%s' in version '%s' of group '%s'\" % [kind, version, group]",
+	}, "\n"))
+
+	kinds := []string{}
+	for kind := range preferred {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	body := []string{"", "  local preferred = {"}
+	for _, kind := range kinds {
+		ao := preferred[kubespec.ObjectKind(kind)]
+		body = append(body, fmt.Sprintf(
+			"    %s: [%s, %s],", kind, jsonnetString(ao.gvkGroup()), jsonnetString(string(ao.parent.version))))
+	}
+	body = append(body,
+		"  };",
+		"  if std.objectHas(preferred, kind) then self.byGVK(preferred[kind][0], preferred[kind][1], kind)",
+		"  else error \"The library has no kind '%s'\" % kind")
+	m.comment("Returns the object of kind `kind` of its preferred version, e.g., `byKind(\"Deployment\")`.")
+	m.method("byKind", []string{"kind"}, strings.Join(body, "\n"))
+}
+
+// `gvkGroup` returns the API group of `ao`, as in its `apiVersion`
+// (e.g., `rbac.authorization.k8s.io`), which is "" for the core group.
+func (ao *apiObject) gvkGroup() string {
+	if ao.parent.parent.qualifiedName == "core" {
+		return ""
+	}
+	return string(ao.parent.parent.qualifiedName)
+}
+
+// `gvkPath` returns the path of `ao` in the library, from `$`.
+func (ao *apiObject) gvkPath() string {
+	return fmt.Sprintf("$.%s.%s.%s", ao.parent.parent.identifier(), ao.parent.version, ao.jsonnetName)
+}
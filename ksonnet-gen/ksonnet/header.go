@@ -0,0 +1,114 @@
+package ksonnet
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultHeader is the template of the comment every generated file
+// starts with, if `Options.Header` is empty.
+const DefaultHeader = `AUTOGENERATED from the Kubernetes OpenAPI specification. DO NOT MODIFY.
+Kubernetes version: {{.KubernetesVersion}}
+{{if .KsonnetLibSHA}}SHA of ksonnet-lib HEAD: {{.KsonnetLibSHA}}
+{{end}}{{if .K8sSHA}}SHA of Kubernetes HEAD OpenAPI spec is generated from: {{.K8sSHA}}
+{{end}}`
+
+// HeaderData is what the template of the header of generated files
+// (see `Options.Header`) is executed with.
+type HeaderData struct {
+	KubernetesVersion string // e.g., `v1.7.0`.
+	KsonnetLibSHA     string // empty if unknown.
+	K8sSHA            string // empty if unknown.
+	Backend           string // e.g., `jsonnet`.
+}
+
+// `renderHeader` executes the template of the header (see
+// `Options.Header`), and returns its lines, without the comment
+// markers, which depend on the language of the file.
+func renderHeader(
+	opts Options, version string, ksonnetLibSHA, k8sSHA *string,
+) ([]string, error) {
+	text := opts.Header
+	if text == "" {
+		text = DefaultHeader
+	}
+	tmpl, err := template.New("header").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Could not parse header template:\n%v", err)
+	}
+
+	data := HeaderData{KubernetesVersion: version, Backend: opts.Backend}
+	if data.Backend == "" {
+		data.Backend = JsonnetBackend
+	}
+	if ksonnetLibSHA != nil {
+		data.KsonnetLibSHA = *ksonnetLibSHA
+	}
+	if k8sSHA != nil {
+		data.K8sSHA = *k8sSHA
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("Could not execute header template:\n%v", err)
+	}
+	return strings.Split(strings.TrimRight(b.String(), "\n"), "\n"), nil
+}
+
+// `emitCommentHeader` emits the header of a file as comments starting
+// with `marker` (e.g., `//`), followed by an empty line.
+func (root *root) emitCommentHeader(m *indentWriter, marker string) {
+	for _, line := range root.headerLines {
+		if line == "" {
+			// Don't create trailing space if the line is empty.
+			m.writeLine(marker)
+		} else {
+			m.writeLine(fmt.Sprintf("%s %s", marker, line))
+		}
+	}
+	m.writeLine("")
+}
+
+// `metadataName` is the hidden field of the top level of the library
+// that `emitMetadata` emits.
+const metadataName = "__ksonnetLib"
+
+// `emitMetadata` emits a hidden object that records what the library
+// was generated from, e.g.,
+//
+//	__ksonnetLib:: {
+//	  k8sVersion: "v1.7.0",
+//	  generatorVersion: "<SHA of ksonnet-lib>",
+//	  specSHA: "<SHA of the Kubernetes spec>",
+//	  generatedAt: "2017-08-01T12:00:00Z",
+//	},
+//
+// so that consumers and CI can check which spec and generator produced
+// a vendored library when it is evaluated, rather than by parsing its
+// header. Values that are unknown (e.g., the SHAs of a library
+// generated from a cluster, or `generatedAt` if
+// `Options.GeneratedAt` is zero) are null.
+func (root *root) emitMetadata(m *astWriter) {
+	orNull := func(s *string) string {
+		if s == nil || *s == "" {
+			return "null"
+		}
+		return jsonnetString(*s)
+	}
+	var generatedAt *string
+	if !root.options.GeneratedAt.IsZero() {
+		at := root.options.GeneratedAt.UTC().Format(time.RFC3339)
+		generatedAt = &at
+	}
+
+	m.comment("Describes the spec and the generator this library was generated from.")
+	m.namespace(metadataName, func(m *astWriter) {
+		m.visible("k8sVersion", jsonnetString(root.spec.Info.Version))
+		m.visible("generatorVersion", orNull(root.ksonnetLibSHA))
+		m.visible("specSHA", orNull(root.k8sSHA))
+		m.visible("generatedAt", orNull(generatedAt))
+	})
+}
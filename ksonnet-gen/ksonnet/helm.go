@@ -0,0 +1,316 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// HelmValuesFile is the name of the file `EmitHelmValues` generates.
+const HelmValuesFile = "values.libsonnet"
+
+// `helmSchema` is the subset of JSON Schema used by Helm charts to
+// describe their values in `values.schema.json`.
+type helmSchema struct {
+	Description string                 `json:"description"`
+	Type        interface{}            `json:"type"` // string, or list of strings.
+	Properties  map[string]*helmSchema `json:"properties"`
+	Enum        []interface{}          `json:"enum"`
+}
+
+// `schemaType` returns the type of the schema, or "" if it has none,
+// or several (e.g., `["string", "null"]`).
+func (s *helmSchema) schemaType() string {
+	if t, ok := s.Type.(string); ok {
+		return t
+	}
+	if len(s.Properties) > 0 {
+		return "object"
+	}
+	return ""
+}
+
+// EmitHelmValues takes the text of a Helm chart's `values.schema.json`,
+// and returns the text of a Jsonnet library for building the values
+// the chart accepts, e.g., to pass to `helm install --values`.
+//
+// The library follows the conventions of ksonnet-lib: every value has
+// a setter (e.g., `withReplicaCount`), arrays and free-form objects
+// also have mixins (e.g., `withTolerationsMixin`), and objects with
+// properties get a namespace in `mixin` (e.g.,
+// `mixin.image.withRepository`). Setters check the type (and, for
+// enums, the value) of their argument against the schema.
+func EmitHelmValues(schemaText []byte) ([]byte, *Report, error) {
+	schema := helmSchema{}
+	if err := json.Unmarshal(schemaText, &schema); err != nil {
+		return nil, nil, fmt.Errorf("Could not deserialize values schema:\n%v", err)
+	}
+	if schema.schemaType() != "object" {
+		return nil, nil, fmt.Errorf(
+			"Values schema must have type 'object', but has type '%v'", schema.Type)
+	}
+
+	report := newReport()
+	m := newIndentWriter()
+
+	m.writeLine("// AUTOGENERATED from the values schema of a Helm chart. DO NOT MODIFY.")
+	m.writeLine("")
+	m.writeLine("{")
+	m.indent()
+	emitHelmComments(m, &schema)
+	m.writeLine("new():: {},")
+	emitHelmProperties(m, report, "", &schema, nil)
+	m.dedent()
+	m.writeLine("}")
+
+	text, err := m.bytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	return text, report, nil
+}
+
+// `emitHelmProperties` emits the setters of every property of
+// `schema`, followed by a `mixin` namespace for the properties that
+// are themselves objects with properties. `path` is the dotted path
+// to `schema` (e.g., `image`), used in warnings and error messages.
+// If several properties have the same identifier (e.g.,
+// `service-account` and `serviceAccount`), only the first in sorted
+// order is emitted, since their functions would collide.
+func emitHelmProperties(
+	m *indentWriter, report *Report, path string, schema *helmSchema,
+	parentMixinName *string,
+) {
+	nested := []string{}
+	ids := make(map[string]string)
+	for _, name := range sortedHelmProperties(schema) {
+		prop := schema.Properties[name]
+		id := helmIdentifier(name)
+		if other, ok := ids[id]; ok {
+			report.errorf(
+				kubespec.DefinitionName(helmPath(path, name)),
+				"value not emitted, because value '%s' has the same identifier '%s'",
+				helmPath(path, other), id)
+			continue
+		}
+		ids[id] = name
+		if jsonnet.ShadowsBuiltin(kubespec.PropertyName(helmIdentifier(name))) {
+			report.warnf(
+				kubespec.DefinitionName(helmPath(path, name)),
+				"parameter renamed to '%s', because it would shadow a Jsonnet built-in",
+				helmFuncParam(name))
+		}
+		if prop.schemaType() == "object" && len(prop.Properties) > 0 {
+			nested = append(nested, name)
+			continue
+		}
+		emitHelmSetter(m, report, helmPath(path, name), name, prop, parentMixinName)
+	}
+
+	if len(nested) == 0 {
+		return
+	}
+
+	if parentMixinName == nil {
+		m.writeLine("mixin:: {")
+		m.indent()
+	}
+	for _, name := range nested {
+		emitHelmMixins(m, report, helmPath(path, name), name, schema.Properties[name], parentMixinName)
+	}
+	if parentMixinName == nil {
+		m.dedent()
+		m.writeLine("},")
+	}
+}
+
+// `emitHelmMixins` emits the namespace of mixins for a property that
+// is an object with properties, in the same form as `emitAsRefMixins`.
+func emitHelmMixins(
+	m *indentWriter, report *Report, path, name string, schema *helmSchema,
+	parentMixinName *string,
+) {
+	emitHelmComments(m, schema)
+
+	id := helmIdentifier(name)
+	paramName := helmFuncParam(name)
+	fieldName := helmFieldKey(name)
+	namespaceName := fmt.Sprintf("__%sNs", id)
+	mixinName := fmt.Sprintf("__%sMixin", id)
+	mixinRef := fmt.Sprintf("%s.%s", namespaceName, mixinName)
+
+	m.writeLine(fmt.Sprintf("%s:: {", jsonnet.RewriteAsFieldKey(kubespec.PropertyName(id))))
+	m.indent()
+	m.writeLine(fmt.Sprintf("local %s = self,", namespaceName))
+	if parentMixinName == nil {
+		m.writeLine(fmt.Sprintf(
+			"%s(%s):: {%s+: %s},", mixinName, paramName, fieldName, paramName))
+	} else {
+		m.writeLine(fmt.Sprintf(
+			"%s(%s):: %s({%s+: %s}),",
+			mixinName, paramName, *parentMixinName, fieldName, paramName))
+	}
+	m.writeLine(fmt.Sprintf("mixinInstance(%s):: %s(%s),", paramName, mixinRef, paramName))
+	emitHelmProperties(m, report, path, schema, &mixinRef)
+	m.dedent()
+	m.writeLine("},")
+}
+
+// `emitHelmSetter` emits the setter (and, for arrays and objects, the
+// mixin) for a single property.
+func emitHelmSetter(
+	m *indentWriter, report *Report, path, name string, schema *helmSchema,
+	parentMixinName *string,
+) {
+	id := jsonnet.Identifier(helmIdentifier(name))
+	paramName := helmFuncParam(name)
+	fieldName := helmFieldKey(name)
+
+	wrap := func(body string) string {
+		if parentMixinName == nil {
+			return body
+		}
+		return fmt.Sprintf("%s(%s)", *parentMixinName, body)
+	}
+
+	var setterBody, mixinBody string
+	switch schema.schemaType() {
+	case "array":
+		setterBody = fmt.Sprintf(
+			"if std.type(%s) == \"array\" then %s else %s",
+			paramName,
+			wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName)),
+			wrap(fmt.Sprintf("{%s: [%s]}", fieldName, paramName)))
+		mixinBody = fmt.Sprintf(
+			"if std.type(%s) == \"array\" then %s else %s",
+			paramName,
+			wrap(fmt.Sprintf("{%s+: %s}", fieldName, paramName)),
+			wrap(fmt.Sprintf("{%s+: [%s]}", fieldName, paramName)))
+	case "object":
+		setterBody = wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName))
+		mixinBody = wrap(fmt.Sprintf("{%s+: %s}", fieldName, paramName))
+	case "string", "integer", "number", "boolean", "null", "":
+		setterBody = wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName))
+	default:
+		report.warnf(
+			kubespec.DefinitionName(path),
+			"unrecognized type '%s', so the setter does not check its argument",
+			schema.schemaType())
+		setterBody = wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName))
+	}
+
+	assertion := helmAssertion(path, paramName, schema)
+	emitHelmComments(m, schema)
+	m.writeLine(fmt.Sprintf(
+		"%s(%s):: %sself + %s,", id.ToSetterID(jsonnet.CamelCase), paramName, assertion, setterBody))
+	if mixinBody != "" {
+		emitHelmComments(m, schema)
+		m.writeLine(fmt.Sprintf(
+			"%s(%s):: self + %s,", id.ToMixinID(jsonnet.CamelCase), paramName, mixinBody))
+	}
+}
+
+// `helmAssertion` returns a Jsonnet `assert` expression (including
+// the trailing `;`) checking that a setter's argument matches the
+// type and enum of `schema`, or "" if there's nothing to check.
+func helmAssertion(path, paramName string, schema *helmSchema) string {
+	if len(schema.Enum) > 0 {
+		values, err := json.Marshal(schema.Enum)
+		if err == nil {
+			message := fmt.Sprintf(
+				"%s must be one of %s", path, strings.Replace(string(values), "\"", "'", -1))
+			return fmt.Sprintf(
+				"assert std.count(%s, %s) > 0 : %s; ", values, paramName, jsonnetString(message))
+		}
+	}
+
+	var check string
+	switch schema.schemaType() {
+	case "string":
+		check = "std.isString(%s)"
+	case "integer":
+		check = "std.isNumber(%[1]s) && std.floor(%[1]s) == %[1]s"
+	case "number":
+		check = "std.isNumber(%s)"
+	case "boolean":
+		check = "std.isBoolean(%s)"
+	case "object":
+		check = "std.isObject(%s)"
+	default:
+		return ""
+	}
+	message := fmt.Sprintf("%s must be of type '%s'", path, schema.schemaType())
+	return fmt.Sprintf(
+		"assert %s : %s; ", fmt.Sprintf(check, paramName), jsonnetString(message))
+}
+
+func emitHelmComments(m *indentWriter, schema *helmSchema) {
+	if schema.Description == "" {
+		return
+	}
+	for _, line := range newComments(schema.Description).lines() {
+		m.writeLine(line)
+	}
+}
+
+func sortedHelmProperties(schema *helmSchema) []string {
+	names := []string{}
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func helmPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return fmt.Sprintf("%s.%s", path, name)
+}
+
+var helmSimpleIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// `helmIdentifier` converts the name of a value (e.g., `replicaCount`
+// or `service-account`) to a lowerCamelCase Jsonnet identifier (e.g.,
+// `replicaCount` or `serviceAccount`).
+func helmIdentifier(name string) string {
+	words := nonIdentifierChars.Split(name, -1)
+	id := ""
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		if id == "" {
+			id = strings.ToLower(word[:1]) + word[1:]
+		} else {
+			id += strings.Title(word)
+		}
+	}
+	if id == "" || !helmSimpleIdentifier.MatchString(id) {
+		id = "_" + id
+	}
+	return id
+}
+
+func helmFuncParam(name string) string {
+	id := helmIdentifier(name)
+	if jsonnet.RewriteAsFieldKey(kubespec.PropertyName(id)) != jsonnet.FieldKey(id) ||
+		jsonnet.ShadowsBuiltin(kubespec.PropertyName(id)) {
+		// `id` is a Jsonnet keyword, or would shadow `std`.
+		return id + "Param"
+	}
+	return id
+}
+
+func helmFieldKey(name string) string {
+	if !helmSimpleIdentifier.MatchString(name) {
+		return jsonnetString(name)
+	}
+	return string(jsonnet.RewriteAsFieldKey(kubespec.PropertyName(name)))
+}
@@ -0,0 +1,97 @@
+package ksonnet
+
+import (
+	"strings"
+	"testing"
+)
+
+var helmValuesSchema = `{
+  "type": "object",
+  "properties": {
+    "replicaCount": {"type": "integer"},
+    "image": {
+      "type": "object",
+      "properties": {
+        "pullPolicy": {"type": "string", "enum": ["Always", "Never"]}
+      }
+    },
+    "local": {"type": "boolean"}
+  }
+}`
+
+func TestEmitHelmValues(t *testing.T) {
+	text, report, err := EmitHelmValues([]byte(helmValuesSchema))
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %d", len(report.Warnings))
+	}
+
+	tests := map[string]string{
+		"replicaCount": "withReplicaCount(replicaCount):: assert std.isNumber(replicaCount)",
+		"keyword":      `withLocal(localParam):: assert std.isBoolean(localParam) : "local must be of type 'boolean'"; self + {"local": localParam},`,
+		"namespace":    "__imageMixin(image):: {image+: image},",
+		"enum":         `assert std.count(["Always","Never"], pullPolicy) > 0`,
+	}
+	for name, expected := range tests {
+		if !strings.Contains(string(text), expected) {
+			t.Errorf("[%s] Expected '%s' in emitted library", name, expected)
+		}
+	}
+}
+
+func TestEmitHelmValuesNotObject(t *testing.T) {
+	_, _, err := EmitHelmValues([]byte(`{"type": "string"}`))
+	if err == nil {
+		t.Errorf("Expected schema without type 'object' to fail")
+	}
+}
+
+var helmEscapingSchema = `{
+  "type": "object",
+  "properties": {
+    "say \"hi\"": {"type": "string"},
+    "back\\slash": {"type": "string", "enum": ["a\\b"]},
+    "service-account": {"type": "string"},
+    "serviceAccount": {"type": "string"}
+  }
+}`
+
+func TestEmitHelmValuesEscaping(t *testing.T) {
+	text, report, err := EmitHelmValues([]byte(helmEscapingSchema))
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	if len(report.Warnings) != 1 ||
+		report.Warnings[0].Path != "serviceAccount" ||
+		!strings.Contains(report.Warnings[0].Message, "'service-account'") {
+		t.Errorf("Expected the collision of 'serviceAccount' to be reported, got %v", report.Warnings)
+	}
+	if count := strings.Count(string(text), "withServiceAccount("); count != 1 {
+		t.Errorf("Expected 1 'withServiceAccount', got %d", count)
+	}
+
+	files := map[string][]byte{HelmValuesFile: text}
+	values := "local values = import \"values.libsonnet\"; "
+	outputs, errs := evaluate(files, map[string]string{
+		"valid":     values + "values.withSayHi(\"hello\") + values.withBackSlash(\"a\\\\b\")",
+		"wrongType": values + "values.withSayHi(1)",
+		"wrongEnum": values + "values.withBackSlash(\"b\")",
+	})
+	if err, ok := errs["valid"]; ok {
+		t.Fatalf("Failed to evaluate:\n%v", err)
+	}
+	if expected := `"say \"hi\"": "hello"`; !strings.Contains(outputs["valid"], expected) {
+		t.Errorf("Expected '%s' in output, got:\n%s", expected, outputs["valid"])
+	}
+	for name, expected := range map[string]string{
+		"wrongType": `say "hi" must be of type 'string'`,
+		"wrongEnum": `back\slash must be one of ['a\\b']`,
+	} {
+		if err, ok := errs[name]; !ok || !strings.Contains(err.Error(), expected) {
+			t.Errorf("[%s] Expected error '%s', got %v", name, expected, err)
+		}
+	}
+}
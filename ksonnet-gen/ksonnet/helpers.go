@@ -0,0 +1,203 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `helperSearchDepth` bounds how deep `synthesizeHelpers` looks for the
+// objects helpers act on. The deepest in practice is the `PodSpec` of
+// a CronJob, at `spec.jobTemplate.spec.template.spec`.
+const helperSearchDepth = 5
+
+// `synthesizedHelper` is a helper that `k.libsonnet` adds to every
+// top-level kind that embeds, at any depth, an object of kind `embeds`
+// with the property `property`, e.g., `mapContainers` for every kind
+// that embeds a `PodSpec`. Rather than being written for each kind,
+// the helper is implemented once, in `hidden`, as a function that
+// takes the path to the embedded object first, and each kind calls it
+// with its own path (e.g., `["spec", "template", "spec"]` for a
+// Deployment).
+type synthesizedHelper struct {
+	name     string                // e.g., `mapContainers`.
+	params   []string              // e.g., `f`, without the path.
+	embeds   kubespec.ObjectKind   // e.g., `PodSpec`.
+	property kubespec.PropertyName // e.g., `containers`.
+	body     []string              // lines of the body of the implementation in `hidden`.
+}
+
+// `synthesizedHelpers` are the helpers `k.libsonnet` adds to the kinds
+// that embed the objects they act on, in the order they are emitted.
+var synthesizedHelpers = []synthesizedHelper{
+	{
+		name:     "mapContainers",
+		params:   []string{"f"},
+		embeds:   "PodSpec",
+		property: "containers",
+		body: []string{
+			"{",
+			"  local patch(obj, i) =",
+			"    if i == std.length(path)",
+			"    // IMPORTANT: This overwrites the 'containers' field",
+			"    // of the pod spec.",
+			"    then {containers: std.map(f, obj.containers)}",
+			"    else {[path[i]]+: patch(obj[path[i]], i + 1)},",
+			"  [path[0]]+: patch(super[path[0]], 1),",
+			"}",
+		},
+	},
+	{
+		name:     "mapContainersWithName",
+		params:   []string{"names", "f"},
+		embeds:   "PodSpec",
+		property: "containers",
+		body: []string{
+			"",
+			"  local nameSet =",
+			"    if std.type(names) == \"array\"",
+			"    then std.set(names)",
+			"    else std.set([names]);",
+			"  local inNameSet(name) = std.length(std.setInter(nameSet, std.set([name]))) > 0;",
+			"  self.mapContainers(",
+			"    path,",
+			"    function(c)",
+			"      if std.objectHas(c, \"name\") && inNameSet(c.name)",
+			"      then f(c)",
+			"      else c",
+			"  )",
+		},
+	},
+	{
+		name:     "withSelectorLabels",
+		params:   []string{"labels"},
+		embeds:   labelSelectorKind,
+		property: "matchLabels",
+		body: []string{
+			"",
+			"  std.foldr(function(field, patch) {[field]+: patch}, path, {matchLabels: labels})",
+		},
+	},
+	{
+		name:     "withSelectorExpressions",
+		params:   []string{"expressions"},
+		embeds:   labelSelectorKind,
+		property: "matchExpressions",
+		body: []string{
+			"",
+			"  local matchExpressions = if std.type(expressions) == \"array\" then expressions else [expressions];",
+			"  std.foldr(function(field, patch) {[field]+: patch}, path, {matchExpressions: matchExpressions})",
+		},
+	},
+}
+
+// `helperInstance` is a `synthesizedHelper` of a specific kind, which
+// embeds the object the helper acts on at `path`.
+type helperInstance struct {
+	helper *synthesizedHelper
+	path   []*property
+}
+
+// `emit` adds the implementation of the helper to `hidden`, which
+// takes the path to the embedded object first.
+func (helper *synthesizedHelper) emit(hidden *astWriter) {
+	params := append([]string{"path"}, helper.params...)
+	hidden.method(helper.name, params, strings.Join(helper.body, "\n"))
+}
+
+// `emit` adds the helper as a member of the kind, which calls its
+// implementation in `hidden` with the path to the embedded object.
+func (hi helperInstance) emit(m *astWriter) {
+	path := []string{}
+	for _, pm := range hi.path {
+		path = append(path, jsonnetString(string(pm.name)))
+	}
+	params := strings.Join(hi.helper.params, ", ")
+	m.method(hi.helper.name, hi.helper.params, fmt.Sprintf(
+		"hidden.%s([%s], %s)", hi.helper.name, strings.Join(path, ", "), params))
+}
+
+// `synthesizeHelpers` returns the instances of `synthesizedHelpers`
+// that the top-level API object `ao` gets, locating the objects they
+// act on through the `$ref`s of its properties. Helpers whose name
+// collides with a property of `ao` are reported, and not emitted.
+func (ao *apiObject) synthesizeHelpers() []helperInstance {
+	if !ao.isTopLevel {
+		return nil
+	}
+
+	instances := []helperInstance{}
+	for i := range synthesizedHelpers {
+		helper := &synthesizedHelpers[i]
+		embedded := ao.root().apiObjectOfKind(helper.embeds)
+		if embedded == nil || embedded == ao {
+			continue
+		}
+		if _, ok := embedded.properties[helper.property]; !ok {
+			continue
+		}
+		path := ao.refPathTo(embedded, helperSearchDepth)
+		if path == nil {
+			continue
+		}
+		if _, ok := ao.properties[kubespec.PropertyName(helper.name)]; ok {
+			ao.root().report.errorf(
+				ao.parsedName.Unparse(),
+				"'%s' not emitted, because a property named '%s' already exists",
+				helper.name, helper.name)
+			continue
+		}
+		instances = append(instances, helperInstance{helper: helper, path: path})
+	}
+	return instances
+}
+
+// `apiObjectOfKind` returns the API object of kind `kind`, or nil if
+// the spec doesn't contain one. If there are several (e.g., in
+// different versions), that of the preferred version (see
+// `kubespec.VersionString.HasPriorityOver`) is used.
+func (root *root) apiObjectOfKind(kind kubespec.ObjectKind) *apiObject {
+	for _, groups := range []groupSet{root.hiddenGroups, root.groups} {
+		for _, group := range groups.toSortedSlice() {
+			for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+				if ao, ok := versionedAPI.apiObjects[kind]; ok {
+					return ao
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// `refPathTo` finds the shortest path of `$ref` properties, at most
+// `depth` long, from `ao` to `target` (e.g., `spec.template` from a
+// Deployment to `PodTemplateSpec`), or returns nil if there is none.
+func (ao *apiObject) refPathTo(target *apiObject, depth int) []*property {
+	type candidate struct {
+		object *apiObject
+		path   []*property
+	}
+	queue := []candidate{{object: ao, path: []*property{}}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if len(current.path) >= depth {
+			continue
+		}
+
+		for _, pm := range current.object.emittedProperties {
+			if pm.kind == typeAlias || !isMixinRef(pm.ref) {
+				continue
+			}
+
+			next := ao.root().getAPIObject(ao.root().parseRef(pm.ref))
+			path := append(current.path[:len(current.path):len(current.path)], pm)
+			if next == target {
+				return path
+			}
+			queue = append(queue, candidate{object: next, path: path})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/formatter"
+)
+
+// This file builds Jsonnet code as go-jsonnet ASTs, which are printed
+// by go-jsonnet's formatter (see `emitAST`), so that the backends
+// that emit Jsonnet (see `astWriter`, which the `jsonnet` backend
+// builds its files with, and `emitK8sLibsonnet`) emit valid Jsonnet by
+// construction, rather than by concatenating lines: nesting, commas,
+// and parentheses follow from the structure of the tree.
+
+// `astFormatOptions` are the options the trees are printed with: two
+// spaces of indentation, and the strings and comments as they were
+// built.
+var astFormatOptions = formatter.Options{
+	Indent:       2,
+	StringStyle:  formatter.StringStyleLeave,
+	CommentStyle: formatter.CommentStyleLeave,
+}
+
+// `emitAST` writes `node`, which was built with `b`, to `m`, and
+// returns its text, unless `b` failed to build it, or `m` failed
+// already, in which case it reports the error in `m`.
+func emitAST(m *indentWriter, b *astBuilder, node ast.Node) (string, bool) {
+	if m.err != nil {
+		return "", false
+	}
+	if b.err != nil {
+		m.err = b.err
+		return "", false
+	}
+	text, err := formatter.FormatNode(node, nil, astFormatOptions)
+	if err != nil {
+		m.err = fmt.Errorf("Could not print generated Jsonnet:\n%v", err)
+		return "", false
+	}
+	text = strings.TrimSuffix(text, "\n")
+	m.writeText(text)
+	return text, true
+}
+
+// astBuilder parses the Jsonnet code the emitter already has as text
+// (e.g., the assertions of `typeAssertions`, or a docsonnet
+// annotation) into nodes. The text is parsed (though, since it may
+// refer to variables bound around it, not analyzed) when the node is
+// built, so that it is as valid as the nodes around it. Rather than
+// failing on the first snippet that does not parse, it keeps the
+// error, which is reported when the tree is printed (see `emitAST`).
+type astBuilder struct {
+	err error
+}
+
+// `code` returns `text`, which must be a Jsonnet expression, as a
+// node. It may span several lines; a first line that is "" makes the
+// expression start on the line after the one it is added to (e.g., the
+// body of a method whose body declares locals).
+func (b *astBuilder) code(text string) ast.Node {
+	node, _, err := formatter.SnippetToRawAST("<generated>", text)
+	if err != nil {
+		if b.err == nil {
+			b.err = fmt.Errorf("Generated invalid Jsonnet '%s':\n%v", text, err)
+		}
+		return &ast.LiteralNull{}
+	}
+	return node
+}
+
+// `codeLines` returns the code `lines` (see `code`).
+func (b *astBuilder) codeLines(lines ...string) ast.Node {
+	return b.code(strings.Join(lines, "\n"))
+}
+
+// `function` returns the function of `params` that returns `body`. A
+// parameter may have a default value, e.g., `namespace=null`.
+func (b *astBuilder) function(params []string, body ast.Node) *ast.Function {
+	function := &ast.Function{Body: body}
+	for _, param := range params {
+		parameter := ast.Parameter{Name: ast.Identifier(param)}
+		if i := strings.Index(param, "="); i >= 0 {
+			parameter.Name = ast.Identifier(param[:i])
+			parameter.DefaultArg = b.code(param[i+1:])
+		}
+		function.Parameters = append(function.Parameters, parameter)
+	}
+	return function
+}
+
+// `asserted` returns `body`, preceded by `assertions`, which are
+// Jsonnet `assert` expressions, each followed by a `;` (e.g., those of
+// `typeAssertions`), if there are any.
+func (b *astBuilder) asserted(assertions string, body ast.Node) ast.Node {
+	if assertions == "" {
+		return body
+	}
+	node := b.code(assertions + "null")
+	assert, ok := node.(*ast.Assert)
+	for ok {
+		next, more := assert.Rest.(*ast.Assert)
+		if !more {
+			assert.Rest = body
+			return node
+		}
+		assert = next
+	}
+	if b.err == nil {
+		b.err = fmt.Errorf("Generated assertions that are not asserts: '%s'", assertions)
+	}
+	return body
+}
+
+// `astVar` returns the variable (or `self`, or `std`) `name`.
+func astVar(name string) ast.Node {
+	if name == "self" {
+		return &ast.Self{}
+	}
+	return &ast.Var{Id: ast.Identifier(name)}
+}
+
+// `astString` returns the string literal `s`.
+func astString(s string) *ast.LiteralString {
+	quoted := jsonnetString(s)
+	return &ast.LiteralString{Value: quoted[1 : len(quoted)-1], Kind: ast.StringDouble}
+}
+
+// `astIndex` returns `target` indexed by each of `ids` in turn, e.g.,
+// `self.metadata.withName`.
+func astIndex(target ast.Node, ids ...string) ast.Node {
+	for _, id := range ids {
+		identifier := ast.Identifier(id)
+		target = &ast.Index{Target: astOperand(target, astApplyPrecedence), Id: &identifier}
+	}
+	return target
+}
+
+// `astApply` returns the call of `target` with `args`.
+func astApply(target ast.Node, args ...ast.Node) ast.Node {
+	apply := &ast.Apply{Target: astOperand(target, astApplyPrecedence)}
+	for _, arg := range args {
+		apply.Arguments.Positional = append(
+			apply.Arguments.Positional, ast.CommaSeparatedExpr{Expr: arg})
+	}
+	return apply
+}
+
+// `astPlus` returns the sum of `operands`, left to right.
+func astPlus(operands ...ast.Node) ast.Node {
+	precedence := astBinaryPrecedence[ast.BopPlus]
+	sum := operands[0]
+	for _, operand := range operands[1:] {
+		sum = &ast.Binary{
+			Left:  astOperand(sum, precedence),
+			Op:    ast.BopPlus,
+			Right: astOperand(operand, precedence-1),
+		}
+	}
+	return sum
+}
+
+// `astImport` returns the import of `path`.
+func astImport(path string) ast.Node {
+	return &ast.Import{File: astString(path)}
+}
+
+// `astObject` returns an object with `fields`, which is written on one
+// line (see `astMultiline`).
+func astObject(fields ...ast.ObjectField) *ast.Object {
+	return &ast.Object{Fields: fields}
+}
+
+// `astMultiline` returns `object`, written one field per line, which
+// is how namespaces are written, unless it is empty.
+func astMultiline(object *ast.Object) *ast.Object {
+	if len(object.Fields) == 0 {
+		return object
+	}
+	for i := range object.Fields {
+		fodder := astFieldFodder(&object.Fields[i])
+		*fodder = append(ast.Fodder{{Kind: ast.FodderLineEnd}}, *fodder...)
+	}
+	object.CloseFodder = ast.Fodder{{Kind: ast.FodderLineEnd}}
+	object.TrailingComma = true
+	return object
+}
+
+// `astField` returns the field `name` of an object, whose value is
+// `body`. `name` is quoted if it is not an identifier (e.g., a field
+// key that `jsonnet.RewriteAsFieldKey` quoted, or `"#"`).
+func astField(name string, hide ast.ObjectFieldHide, superSugar bool, body ast.Node) ast.ObjectField {
+	field := ast.ObjectField{Hide: hide, SuperSugar: superSugar, Expr2: body}
+	if identifierPattern.MatchString(name) && !isJsonnetKeyword(name) {
+		id := ast.Identifier(name)
+		field.Kind, field.Id = ast.ObjectFieldID, &id
+	} else {
+		field.Kind, field.Expr1 = ast.ObjectFieldStr, astString(strings.Trim(name, "\""))
+	}
+	return field
+}
+
+// `astMethod` returns the hidden method `name` of an object, which is
+// `function` (see `astBuilder.function`).
+func astMethod(name string, function *ast.Function) ast.ObjectField {
+	field := astField(name, ast.ObjectFieldHidden, false, function.Body)
+	field.Method = function
+	return field
+}
+
+// `astLocal` returns the local `name` of an object, bound to `body`.
+func astLocal(name string, body ast.Node) ast.ObjectField {
+	id := ast.Identifier(name)
+	return ast.ObjectFieldLocalNoMethod(&id, body, ast.LocationRange{})
+}
+
+// `astLocalMethod` returns the local function `name` of an object.
+func astLocalMethod(name string, function *ast.Function) ast.ObjectField {
+	field := astLocal(name, function.Body)
+	field.Method = function
+	return field
+}
+
+// `astFieldFodder` returns the fodder that precedes `field`, i.e., its
+// comments, and the line break before it: that of its name, if it is
+// a string.
+func astFieldFodder(field *ast.ObjectField) *ast.Fodder {
+	if field.Kind == ast.ObjectFieldStr {
+		return field.Expr1.OpenFodder()
+	}
+	return &field.Fodder1
+}
+
+// `astComment` returns `field`, preceded by the comments `cs`.
+func astComment(field ast.ObjectField, cs comments) ast.ObjectField {
+	fodder := astFieldFodder(&field)
+	for _, line := range cs.lines() {
+		*fodder = append(*fodder, ast.FodderElement{
+			Kind: ast.FodderParagraph, Comment: []string{line},
+		})
+	}
+	return field
+}
+
+// `astCommented` returns `fields`, the first of which is preceded by
+// the comments `cs`.
+func astCommented(cs comments, fields []ast.ObjectField) []ast.ObjectField {
+	fields[0] = astComment(fields[0], cs)
+	return fields
+}
+
+// `isJsonnetKeyword` reports whether `name` is a keyword of Jsonnet,
+// which can't be a field name unless it is quoted.
+func isJsonnetKeyword(name string) bool {
+	switch name {
+	case "assert", "else", "error", "false", "for", "function", "if", "import",
+		"importstr", "importbin", "in", "local", "null", "tailstrict", "then",
+		"self", "super", "true":
+		return true
+	}
+	return false
+}
+
+const (
+	astApplyPrecedence = 2  // Calls and indexing.
+	astUnaryPrecedence = 4  // E.g., `!`.
+	astMaxPrecedence   = 16 // E.g., `if`, `local`, and `function`.
+)
+
+// `astBinaryPrecedence` are the precedences of the binary operators of
+// Jsonnet; lower numbers bind tighter.
+var astBinaryPrecedence = map[ast.BinaryOp]int{
+	ast.BopMult: 5, ast.BopDiv: 5, ast.BopPercent: 5,
+	ast.BopPlus: 6, ast.BopMinus: 6,
+	ast.BopShiftL: 7, ast.BopShiftR: 7,
+	ast.BopGreater: 8, ast.BopGreaterEq: 8, ast.BopLess: 8, ast.BopLessEq: 8, ast.BopIn: 8,
+	ast.BopManifestEqual: 9, ast.BopManifestUnequal: 9,
+	ast.BopBitwiseAnd: 10, ast.BopBitwiseXor: 11, ast.BopBitwiseOr: 12,
+	ast.BopAnd: 13, ast.BopOr: 14,
+}
+
+// `astPrecedence` returns how tightly `node` binds, to decide whether
+// it needs parentheses as the operand of an operator.
+func astPrecedence(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.Binary:
+		return astBinaryPrecedence[node.Op]
+	case *ast.Unary:
+		return astUnaryPrecedence
+	case *ast.Apply, *ast.Index:
+		return astApplyPrecedence
+	case *ast.Conditional, *ast.Local, *ast.Function, *ast.Assert, *ast.Import,
+		*ast.Error:
+		return astMaxPrecedence
+	}
+	return 0
+}
+
+// `astOperand` returns `node`, in parentheses if it binds more loosely
+// than `precedence` allows.
+func astOperand(node ast.Node, precedence int) ast.Node {
+	if astPrecedence(node) > precedence {
+		return &ast.Parens{Inner: node}
+	}
+	return node
+}
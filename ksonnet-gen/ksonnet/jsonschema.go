@@ -0,0 +1,241 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	jsonSchemaDir     = "schemas"
+	jsonSchemaVersion = "http://json-schema.org/draft-04/schema#"
+	definitionsPrefix = "#/definitions/"
+)
+
+// `jsonSchemaOverrides` replaces the schemas of definitions that the
+// OpenAPI spec describes differently from how they are serialized.
+// For example, `IntOrString` is declared to be a string, but may also
+// be an integer, and `RawExtension` is an arbitrary object, not one
+// with a `Raw` property.
+var jsonSchemaOverrides = map[string]map[string]interface{}{
+	"io.k8s.apimachinery.pkg.util.intstr.IntOrString": {
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{"type": "integer"},
+		},
+	},
+	"io.k8s.apimachinery.pkg.runtime.RawExtension": {
+		"type": "object",
+	},
+}
+
+// `emitJSONSchemas` returns a standalone JSON Schema for every
+// top-level API object, e.g., `schemas/apps/v1beta1/Deployment.json`,
+// so that manifests rendered with the library can be validated
+// against the same spec the library was generated from.
+//
+// Every `$ref` is resolved by inlining the definition it refers to,
+// except when this would recurse forever, in which case the definition
+// is included once under `definitions`, and referred to from there.
+// Each schema also requires `apiVersion` and `kind`, and restricts
+// them to the values for its kind.
+func (root *root) emitJSONSchemas() (map[string][]byte, error) {
+	definitions, err := root.rawDefinitions()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, group := range root.groups.toSortedSlice() {
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				if !ao.isTopLevel {
+					continue
+				}
+
+				schema, err := ao.jsonSchema(definitions)
+				if err != nil {
+					return nil, err
+				}
+
+				text, err := json.MarshalIndent(schema, "", "  ")
+				if err != nil {
+					return nil, err
+				}
+				fileName := path.Join(
+					jsonSchemaDir, string(group.name), string(versionedAPI.version),
+					fmt.Sprintf("%s.json", ao.name))
+				files[fileName] = append(text, '\n')
+			}
+		}
+	}
+	return files, nil
+}
+
+// `rawDefinitions` returns the definitions of the spec as plain JSON,
+// including the fields that `kubespec` ignores (e.g., `format`).
+func (root *root) rawDefinitions() (map[string]map[string]interface{}, error) {
+	raw := struct {
+		Definitions map[string]map[string]interface{} `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(root.spec.Text, &raw); err != nil {
+		return nil, fmt.Errorf("Could not deserialize schema:\n%v", err)
+	}
+	return raw.Definitions, nil
+}
+
+// `jsonSchema` returns the standalone JSON Schema for a top-level API
+// object.
+func (ao *apiObject) jsonSchema(
+	definitions map[string]map[string]interface{},
+) (map[string]interface{}, error) {
+	name := string(ao.parsedName.Unparse())
+	resolver := &jsonSchemaResolver{
+		definitions: definitions,
+		recursive:   make(map[string]bool),
+	}
+
+	schema, err := resolver.resolveDefinition(name, []string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	// Definitions that refer to themselves can't be inlined, so include
+	// each of them once, along with whatever they refer to in turn.
+	extra := make(map[string]interface{})
+	for len(extra) < len(resolver.recursive) {
+		for definition := range resolver.recursive {
+			if _, ok := extra[definition]; ok {
+				continue
+			}
+			resolved, err := resolver.resolveDefinition(definition, []string{definition})
+			if err != nil {
+				return nil, err
+			}
+			extra[definition] = resolved
+		}
+	}
+	if len(extra) > 0 {
+		schema["definitions"] = extra
+	}
+
+	schema["$schema"] = jsonSchemaVersion
+	properties, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		properties = make(map[string]interface{})
+		schema["properties"] = properties
+	}
+	properties["apiVersion"] = withEnum(properties["apiVersion"], ao.parent.apiVersion())
+	properties["kind"] = withEnum(properties["kind"], string(ao.name))
+
+	required := []interface{}{"apiVersion", "kind"}
+	if existing, ok := schema["required"].([]interface{}); ok {
+		for _, field := range existing {
+			if field != "apiVersion" && field != "kind" {
+				required = append(required, field)
+			}
+		}
+	}
+	schema["required"] = required
+
+	return schema, nil
+}
+
+// `withEnum` returns a copy of the schema of a string property,
+// restricted to a single value.
+func withEnum(schema interface{}, value string) map[string]interface{} {
+	result := map[string]interface{}{"type": "string"}
+	if existing, ok := schema.(map[string]interface{}); ok {
+		for k, v := range existing {
+			result[k] = v
+		}
+	}
+	result["enum"] = []interface{}{value}
+	return result
+}
+
+type jsonSchemaResolver struct {
+	definitions map[string]map[string]interface{}
+	recursive   map[string]bool // definitions that can't be inlined.
+}
+
+// `resolveDefinition` returns a copy of a definition, with every
+// `$ref` in it inlined. `stack` holds the definitions currently being
+// inlined, to detect recursion.
+func (r *jsonSchemaResolver) resolveDefinition(
+	name string, stack []string,
+) (map[string]interface{}, error) {
+	if override, ok := jsonSchemaOverrides[name]; ok {
+		resolved, err := r.resolve(override, stack)
+		if err != nil {
+			return nil, err
+		}
+		schema := resolved.(map[string]interface{})
+		if description, ok := r.definitions[name]["description"]; ok {
+			schema["description"] = description
+		}
+		return schema, nil
+	}
+
+	definition, ok := r.definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("Could not resolve reference to definition '%s'", name)
+	}
+	resolved, err := r.resolve(definition, stack)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+func (r *jsonSchemaResolver) resolve(
+	node interface{}, stack []string,
+) (interface{}, error) {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := node["$ref"].(string); ok && strings.HasPrefix(ref, definitionsPrefix) {
+			name := strings.TrimPrefix(ref, definitionsPrefix)
+			for _, ancestor := range stack {
+				if ancestor == name {
+					r.recursive[name] = true
+					return map[string]interface{}{"$ref": ref}, nil
+				}
+			}
+
+			resolved, err := r.resolveDefinition(name, append(stack[:len(stack):len(stack)], name))
+			if err != nil {
+				return nil, err
+			}
+			// Keep sibling keywords, e.g., the property's description.
+			for k, v := range node {
+				if k != "$ref" {
+					resolved[k] = v
+				}
+			}
+			return resolved, nil
+		}
+
+		result := make(map[string]interface{})
+		for k, v := range node {
+			resolved, err := r.resolve(v, stack)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = resolved
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(node))
+		for i, v := range node {
+			resolved, err := r.resolve(v, stack)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = resolved
+		}
+		return result, nil
+	default:
+		return node, nil
+	}
+}
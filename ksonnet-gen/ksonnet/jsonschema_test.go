@@ -0,0 +1,61 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var recursiveSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Tree": {
+      "required": ["root"],
+      "properties": {
+        "kind": {"type": "string"},
+        "root": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Node"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Tree"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Node": {
+      "properties": {
+        "children": {
+          "type": "array",
+          "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Node"}
+        }
+      }
+    }
+  }
+}`
+
+func TestEmitJSONSchemas(t *testing.T) {
+	files, _, err := EmitFiles(
+		parseSpec(t, recursiveSpec), nil, nil, Options{JSONSchemas: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+
+	text, ok := files["schemas/core/v1/Tree.json"]
+	if !ok {
+		t.Fatalf("Expected schema for 'Tree' to be emitted")
+	}
+	schema := struct {
+		Required    []string
+		Properties  map[string]map[string]interface{}
+		Definitions map[string]interface{}
+	}{}
+	if err := json.Unmarshal(text, &schema); err != nil {
+		t.Fatalf("Could not deserialize emitted schema:\n%v", err)
+	}
+
+	if len(schema.Required) != 3 || schema.Required[0] != "apiVersion" ||
+		schema.Required[1] != "kind" || schema.Required[2] != "root" {
+		t.Errorf("Unexpected required properties %v", schema.Required)
+	}
+	if _, ok := schema.Properties["root"]["properties"]; !ok {
+		t.Errorf("Expected reference to 'Node' to be inlined")
+	}
+	if _, ok := schema.Definitions["io.k8s.kubernetes.pkg.api.v1.Node"]; !ok {
+		t.Errorf("Expected recursive definition 'Node' in definitions")
+	}
+}
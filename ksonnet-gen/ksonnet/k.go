@@ -0,0 +1,206 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `emitKListHelper` adds the `list` object `k.libsonnet` adds to
+// `core.v1`, which builds a `List` of arbitrary objects.
+func emitKListHelper(m *astWriter) {
+	m.namespace("list", func(m *astWriter) {
+		m.method("new", []string{"items"}, strings.Join([]string{
+			"",
+			"  {apiVersion: \"v1\"} +",
+			"  {kind: \"List\"} +",
+			"  self.items(items)",
+		}, "\n"))
+		m.blank()
+		m.method("items", []string{"items"},
+			"if std.type(items) == \"array\" then {items+: items} else {items+: [items]}")
+	})
+}
+
+// `emitKAsListHelper` adds the `asList` function `k.libsonnet` adds,
+// which turns any collection of objects into a `List`, so that they
+// can be output as a single document.
+func emitKAsListHelper(m *astWriter) {
+	m.comment("Returns a `v1.List` of `objects`, which is an object, or any")
+	m.comment("nesting of arrays and of objects whose fields are objects (e.g.,")
+	m.comment("`{deployment: ..., service: ...}`), flattened. Objects are the")
+	m.comment("values that have a `kind`; `null`s are left out.")
+	m.method("asList", []string{"objects"}, strings.Join([]string{
+		"",
+		"  local flatten(value) =",
+		"    if value == null then []",
+		"    else if std.type(value) == \"array\" then std.flattenArrays([flatten(v) for v in value])",
+		"    else if std.type(value) == \"object\" && !std.objectHas(value, \"kind\") then",
+		"      std.flattenArrays([flatten(value[field]) for field in std.objectFields(value)])",
+		"    else [value];",
+		"  {apiVersion: \"v1\", kind: \"List\", items: flatten(objects)}",
+	}, "\n"))
+}
+
+// labelSelectorKind is the kind of the object that selects objects by
+// their labels, e.g., the pods of a Deployment.
+const labelSelectorKind kubespec.ObjectKind = "LabelSelector"
+
+// `emitKLabelSelectorHelper` adds the `labelSelector` object
+// `k.libsonnet` adds if the library has a `LabelSelector`, which builds
+// selectors and the requirements of their `matchExpressions`, e.g., for
+// the `withSelectorLabels` and `withSelectorExpressions` helpers of the
+// kinds that embed one (see `synthesizedHelpers`).
+func emitKLabelSelectorHelper(m *astWriter) {
+	m.comment("Builders of `LabelSelector`s, and of the requirements of their")
+	m.comment("`matchExpressions`, e.g., `labelSelector.keyIn(\"tier\", [\"web\"])`.")
+	m.namespace("labelSelector", func(m *astWriter) {
+		m.comment("The operators of the requirements of `matchExpressions`.")
+		m.hidden("operators",
+			"{In: \"In\", NotIn: \"NotIn\", Exists: \"Exists\", DoesNotExist: \"DoesNotExist\"}")
+		m.comment("Returns a selector of the objects that have all the labels of `labels`.")
+		m.method("fromLabels", []string{"labels"}, "{matchLabels: labels}")
+		m.comment("Returns a selector of the objects that meet all the requirements")
+		m.comment("`expressions` (a requirement, or an array of requirements).")
+		m.method("fromExpressions", []string{"expressions"}, strings.Join([]string{
+			"",
+			"  {matchExpressions: if std.type(expressions) == \"array\" then expressions else [expressions]}",
+		}, "\n"))
+		m.comment("Returns a requirement that `operator`, one of `operators`, applies")
+		m.comment("to the label `key` and `values`, which `In` and `NotIn` require,")
+		m.comment("and `Exists` and `DoesNotExist` don't take.")
+		m.method("requirement", []string{"key", "operator", "values=[]"}, strings.Join([]string{
+			"",
+			"  assert std.objectHas(self.operators, operator) : \"'%s' is not a label selector operator\" % operator;",
+			"  local message =",
+			"    if std.length(values) > 0",
+			"    then \"label selector operator '%s' takes no values\" % operator",
+			"    else \"label selector operator '%s' requires values\" % operator;",
+			"  assert (operator == \"In\" || operator == \"NotIn\") == (std.length(values) > 0) : message;",
+			"  {key: key, operator: operator} + if std.length(values) > 0 then {values: values} else {}",
+		}, "\n"))
+		m.method("keyIn", []string{"key", "values"}, "self.requirement(key, self.operators.In, values)")
+		m.method("keyNotIn", []string{"key", "values"}, "self.requirement(key, self.operators.NotIn, values)")
+		m.method("keyExists", []string{"key"}, "self.requirement(key, self.operators.Exists)")
+		m.method("keyDoesNotExist", []string{"key"}, "self.requirement(key, self.operators.DoesNotExist)")
+	})
+}
+
+// `kVersion` is a version of a group that `k.libsonnet` extends, with
+// the kinds it adds helpers to (see `synthesizeHelpers`).
+type kVersion struct {
+	versionedAPI *versionedAPI
+	objects      apiObjectSlice
+	list         bool
+}
+
+// `emitK` emits `k.libsonnet`, which extends `k8s.libsonnet` with
+// higher-level helpers (see `synthesizedHelpers`), a `core.v1.list`
+// constructor, `asList`, and `labelSelector`. It is derived from the spec, rather than
+// written by hand for each Kubernetes version, so that it only extends
+// the groups, versions, and kinds the library has (e.g., after
+// `Options.Include` filtered some out).
+func (root *root) emitK() ([]byte, error) {
+	groups := groupSlice{}
+	versions := make(map[*group][]kVersion)
+	helpers := make(map[*apiObject][]helperInstance)
+	used := make(map[*synthesizedHelper]bool)
+	for _, group := range root.groups.toSortedSlice() {
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			kv := kVersion{
+				versionedAPI: versionedAPI,
+				list:         group.qualifiedName == "core" && versionedAPI.version == "v1",
+			}
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				instances := ao.synthesizeHelpers()
+				if len(instances) == 0 {
+					continue
+				}
+				helpers[ao] = instances
+				kv.objects = append(kv.objects, ao)
+				for _, hi := range instances {
+					used[hi.helper] = true
+				}
+			}
+			if len(kv.objects) == 0 && !kv.list {
+				continue
+			}
+			if len(versions[group]) == 0 {
+				groups = append(groups, group)
+			}
+			versions[group] = append(versions[group], kv)
+		}
+	}
+
+	file := newASTWriter()
+	file.local("k8s", fmt.Sprintf("import \"%s\"", k8sFile))
+	for i, group := range groups {
+		if i == 0 {
+			file.blank()
+		}
+		file.local(string(group.identifier()), fmt.Sprintf("k8s.%s", group.identifier()))
+	}
+	if len(used) > 0 {
+		file.blank()
+		file.field(astLocal("hidden", file.nested(func(hidden *astWriter) {
+			first := true
+			for i := range synthesizedHelpers {
+				helper := &synthesizedHelpers[i]
+				if !used[helper] {
+					continue
+				}
+				if !first {
+					hidden.blank()
+				}
+				first = false
+				helper.emit(hidden)
+			}
+		})))
+	}
+
+	body := file.nested(func(m *astWriter) {
+		emitKAsListHelper(m)
+		if root.apiObjectOfKind(labelSelectorKind) != nil {
+			m.blank()
+			emitKLabelSelectorHelper(m)
+		}
+		for _, group := range groups {
+			m.blank()
+			groupID := string(group.identifier())
+			m.extend(groupID, astVar(groupID), func(m *astWriter) {
+				for j, kv := range versions[group] {
+					if j > 0 {
+						m.blank()
+					}
+					// NOTE: Do not need to call `jsonnet.RewriteAsIdentifier`.
+					version := string(kv.versionedAPI.version)
+					m.extend(version, astIndex(astVar(groupID), version), func(m *astWriter) {
+						if len(kv.objects) > 0 {
+							m.local(version, fmt.Sprintf("%s.%s", groupID, version))
+						}
+						for _, ao := range kv.objects {
+							m.blank()
+							m.extend(string(ao.jsonnetName), astIndex(astVar(version), string(ao.jsonnetName)), func(m *astWriter) {
+								for _, hi := range helpers[ao] {
+									hi.emit(m)
+								}
+							})
+						}
+						if kv.list {
+							if len(kv.objects) > 0 {
+								m.blank()
+							}
+							emitKListHelper(m)
+						}
+					})
+				}
+			})
+		}
+	})
+
+	m := newIndentWriter()
+	root.emitHeader(m)
+	emitFile(m, file, astPlus(astVar("k8s"), body))
+	return m.bytes()
+}
@@ -0,0 +1,306 @@
+package ksonnet
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/google/go-jsonnet/ast"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubeversion"
+)
+
+// K8sLibsonnetGenDir is the directory the `k8s-libsonnet` backend
+// emits the libraries of the API objects to.
+const K8sLibsonnetGenDir = "_gen"
+
+// `emitK8sLibsonnet` is a backend that emits a library laid out like
+// jsonnet-libs/k8s-libsonnet, so that it can be swapped in for it:
+//
+//	main.libsonnet                          imports `gen.libsonnet`
+//	gen.libsonnet                           imports each group
+//	_gen/apps/main.libsonnet                imports each version
+//	_gen/apps/v1beta1/main.libsonnet        imports each kind
+//	_gen/apps/v1beta1/deployment.libsonnet
+//
+// Unlike the `jsonnet` backend, setters and mixins return only the
+// fragment of the object they set, and the properties that refer to
+// other objects are namespaces of their own, rather than members of a
+// `mixin` namespace, e.g.:
+//
+//	deployment.new("nginx") + deployment.spec.withReplicas(3)
+//
+// Like those of the `jsonnet` backend, each file is built as a
+// go-jsonnet AST and printed (see `jsonnetast.go`).
+func emitK8sLibsonnet(root *root) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	// A group's objects may be split between `groups` and
+	// `hiddenGroups`, but must be imported from a single directory.
+	versions := make(map[string]map[string]apiObjectSlice)
+	groupIDs := make(map[string]jsonnet.Identifier)
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for groupName, group := range groups {
+			name := string(groupName)
+			if _, ok := versions[name]; !ok {
+				versions[name] = make(map[string]apiObjectSlice)
+				groupIDs[name] = group.identifier()
+			}
+			for version, versionedAPI := range group.versionedAPIs {
+				versions[name][string(version)] = append(
+					versions[name][string(version)], versionedAPI.apiObjects.toSortedSlice()...)
+			}
+		}
+	}
+
+	groupNames := []string{}
+	for groupName := range versions {
+		groupNames = append(groupNames, groupName)
+	}
+	sort.Strings(groupNames)
+
+	groupImports := []k8sLibsonnetImport{}
+	for _, groupName := range groupNames {
+		groupDir := path.Join(K8sLibsonnetGenDir, groupName)
+		groupImports = append(groupImports, k8sLibsonnetImport{
+			string(groupIDs[groupName]), path.Join(groupDir, "main.libsonnet"),
+		})
+
+		versionNames := []kubespec.VersionString{}
+		for version := range versions[groupName] {
+			versionNames = append(versionNames, kubespec.VersionString(version))
+		}
+		kubespec.SortVersions(versionNames)
+
+		versionImports := []k8sLibsonnetImport{}
+		for _, versionName := range versionNames {
+			version := string(versionName)
+			// NOTE: Do not need to call `jsonnet.RewriteAsIdentifier`.
+			versionImports = append(versionImports, k8sLibsonnetImport{
+				version, path.Join(version, "main.libsonnet"),
+			})
+
+			objects := versions[groupName][version]
+			sort.Slice(objects, func(i, j int) bool {
+				return objects[i].jsonnetName < objects[j].jsonnetName
+			})
+			kindImports := []k8sLibsonnetImport{}
+			for _, ao := range objects {
+				fileName := fmt.Sprintf("%s.libsonnet", ao.jsonnetName)
+				kindImports = append(kindImports, k8sLibsonnetImport{string(ao.jsonnetName), fileName})
+
+				text, err := ao.emitK8sLibsonnet()
+				if err != nil {
+					return nil, err
+				}
+				files[path.Join(groupDir, version, fileName)] = text
+			}
+
+			text, err := root.emitK8sLibsonnetIndex(
+				version, comments{fmt.Sprintf("Version `%s` of the `%s` API group.", version, groupName)},
+				kindImports)
+			if err != nil {
+				return nil, err
+			}
+			files[path.Join(groupDir, version, "main.libsonnet")] = text
+		}
+
+		text, err := root.emitK8sLibsonnetIndex(
+			string(groupIDs[groupName]), comments{fmt.Sprintf("The `%s` API group.", groupName)},
+			versionImports)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Join(groupDir, "main.libsonnet")] = text
+	}
+
+	text, err := root.emitK8sLibsonnetIndex("k8s", root.docsonnetHelp(), groupImports)
+	if err != nil {
+		return nil, err
+	}
+	files["gen.libsonnet"] = text
+
+	m := newIndentWriter()
+	root.emitHeader(m)
+	emitAST(m, &astBuilder{}, astImport("gen.libsonnet"))
+	text, err = m.bytes()
+	if err != nil {
+		return nil, err
+	}
+	files["main.libsonnet"] = text
+	return files, nil
+}
+
+// `k8sLibsonnetImport` is a field of an index of the `k8s-libsonnet`
+// backend, e.g., `v1beta1:: import "v1beta1/main.libsonnet"`.
+type k8sLibsonnetImport struct {
+	field string
+	path  string
+}
+
+// `emitK8sLibsonnetIndex` emits an object whose fields are `imports`,
+// and which is documented as the docsonnet package `name`.
+func (root *root) emitK8sLibsonnetIndex(
+	name string, help comments, imports []k8sLibsonnetImport,
+) ([]byte, error) {
+	b := &astBuilder{}
+	index := astObject(root.k8sLibsonnetPackage(b, name, help)...)
+	for _, i := range imports {
+		index.Fields = append(index.Fields, astField(i.field, ast.ObjectFieldHidden, false, astImport(i.path)))
+	}
+
+	m := newIndentWriter()
+	root.emitHeader(m)
+	emitAST(m, b, astMultiline(index))
+	return m.bytes()
+}
+
+// `k8sLibsonnetPackage` returns the fields that import docsonnet, and
+// document an object as the docsonnet package `name`, if
+// `Options.Docsonnet` is set.
+func (root *root) k8sLibsonnetPackage(b *astBuilder, name string, help comments) []ast.ObjectField {
+	if !root.options.Docsonnet {
+		return nil
+	}
+	return []ast.ObjectField{
+		astLocal("d", astImport(docsonnetImport)),
+		astField("#", ast.ObjectFieldHidden, false, b.code(docsonnetPackage(name, help))),
+	}
+}
+
+// `k8sLibsonnetDocs` returns the docsonnet annotation of the field
+// `name`, whose value is `annotation` (e.g., `d.fn(...)`), if
+// `Options.Docsonnet` is set.
+func (root *root) k8sLibsonnetDocs(b *astBuilder, name, annotation string) []ast.ObjectField {
+	if !root.options.Docsonnet {
+		return nil
+	}
+	return []ast.ObjectField{
+		astField("#"+name, ast.ObjectFieldHidden, false, b.code(annotation)),
+	}
+}
+
+// `emitK8sLibsonnet` emits the library of an API object for the
+// `k8s-libsonnet` backend: a `new` constructor, if it is a top-level
+// object, and a setter (and, for arrays and maps, a mixin) for every
+// property, at any depth.
+func (ao *apiObject) emitK8sLibsonnet() ([]byte, error) {
+	root := ao.root()
+	b := &astBuilder{}
+	library := astObject(root.k8sLibsonnetPackage(b, string(ao.jsonnetName), ao.comments)...)
+
+	if ao.isTopLevel {
+		// NOTE: It is important to NOT capitalize `ao.name` here.
+		body := astObject(
+			astField("apiVersion", ast.ObjectFieldInherit, false, astString(ao.parent.apiVersion())),
+			astField("kind", ast.ObjectFieldInherit, false, astString(string(ao.name))))
+		if ao.hasObjectMeta() {
+			namePath := "metadata.name"
+			library.Fields = append(library.Fields, root.k8sLibsonnetDocs(
+				b, constructorName, docsonnetFunction(ao.constructorHelp(), ao.docsonnetConstructorArgs(
+					[]kubeversion.CustomConstructorParam{{ID: "name", RelativePath: &namePath}})...))...)
+			library.Fields = append(library.Fields, astMethod(constructorName, b.function([]string{"name"}, astPlus(
+				body, astApply(astIndex(astVar("self"), "metadata", "withName"), astVar("name"))))))
+		} else {
+			library.Fields = append(library.Fields, root.k8sLibsonnetDocs(
+				b, constructorName, docsonnetFunction(ao.constructorHelp()))...)
+			library.Fields = append(library.Fields, astMethod(constructorName, b.function(nil, body)))
+		}
+		if root.options.FromJSON {
+			library.Fields = append(library.Fields, root.k8sLibsonnetDocs(
+				b, fromJSONName, docsonnetFunction(
+					comments{fmt.Sprintf("Returns the manifest `obj` as a `%s`.", ao.name)},
+					"d.arg(\"obj\", d.T.object)"))...)
+			library.Fields = append(library.Fields, astMethod(fromJSONName, b.function([]string{"obj"}, b.asserted(
+				ao.fromJSONAssertions("obj"), astPlus(body, astVar("obj"))))))
+		}
+	}
+	library.Fields = append(
+		library.Fields, ao.k8sLibsonnetProperties(b, nil, map[*apiObject]bool{ao: true})...)
+
+	m := newIndentWriter()
+	root.emitHeader(m)
+	for _, line := range ao.comments.lines() {
+		m.writeLine(line)
+	}
+	emitAST(m, b, astMultiline(library))
+	return m.bytes()
+}
+
+// `k8sLibsonnetProperties` returns the setters and mixins of every
+// property of `ao`, which is at `fieldPath` in the object the library
+// is for. `visiting` holds the objects being emitted above `ao`;
+// properties that refer to one of them are set as a whole, rather
+// than recursed into.
+func (ao *apiObject) k8sLibsonnetProperties(
+	b *astBuilder, fieldPath []jsonnet.FieldKey, visiting map[*apiObject]bool,
+) []ast.ObjectField {
+	root := ao.root()
+	k8sVersion := root.spec.Info.Version
+	naming := root.naming
+	wrap := func(inner ast.Node) ast.Node {
+		for i := len(fieldPath) - 1; i >= 0; i-- {
+			inner = astObject(astField(string(fieldPath[i]), ast.ObjectFieldInherit, true, inner))
+		}
+		return inner
+	}
+
+	fields := []ast.ObjectField{}
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == typeAlias || isSpecialProperty(pm.name) {
+			continue
+		}
+
+		id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name)
+		paramName := jsonnet.RewriteAsFuncParam(naming, k8sVersion, pm.name)
+		fieldName := jsonnet.RewriteAsFieldKey(pm.name)
+
+		if ref := pm.dhallRef(); ref != nil && pm.ref != nil && !visiting[ref] {
+			visiting[ref] = true
+			namespace := astMultiline(astObject(ref.k8sLibsonnetProperties(
+				b, append(fieldPath[:len(fieldPath):len(fieldPath)], fieldName), visiting)...))
+			delete(visiting, ref)
+			fields = append(fields, astCommented(pm.comments, append(
+				root.k8sLibsonnetDocs(b, string(id), docsonnetObject(pm.comments)),
+				astField(string(id), ast.ObjectFieldHidden, false, namespace)))...)
+			continue
+		}
+
+		param := astVar(string(paramName))
+		var setterValue ast.Node = param
+		mixin := false
+		switch {
+		case pm.schemaType != nil && *pm.schemaType == "array":
+			setterValue = &ast.Conditional{
+				Cond:        astApply(astIndex(astVar("std"), "isArray"), param),
+				BranchTrue:  param,
+				BranchFalse: &ast.Array{Elements: []ast.CommaSeparatedExpr{{Expr: param}}},
+			}
+			mixin = true
+		case pm.schemaType != nil && *pm.schemaType == "object", pm.dhallRef() != nil:
+			mixin = true
+		}
+
+		assertions := pm.typeAssertions(paramName)
+		setter := func(name jsonnet.Identifier, superSugar bool) []ast.ObjectField {
+			value := astObject(astField(string(fieldName), ast.ObjectFieldInherit, superSugar, setterValue))
+			return astCommented(pm.comments, append(
+				root.k8sLibsonnetDocs(b, string(name), docsonnetFunction(pm.comments, pm.docsonnetArg(paramName))),
+				astMethod(string(name), b.function([]string{string(paramName)}, b.asserted(assertions, wrap(value))))))
+		}
+		fields = append(fields, setter(id.ToSetterID(naming), false)...)
+		if mixin {
+			fields = append(fields, setter(id.ToMixinID(naming), true)...)
+		}
+		if name := pm.addFunctionName(); name != "" {
+			fields = append(fields, astCommented(pm.comments, append(
+				root.k8sLibsonnetDocs(b, string(name), docsonnetFunction(
+					pm.comments, fmt.Sprintf("d.arg(%s, d.T.object)", jsonnetString(string(addFunctionParam))))),
+				astMethod(string(name), b.function(
+					[]string{string(addFunctionParam)},
+					b.asserted(pm.typeAssertions(addFunctionParam), wrap(b.code(pm.addBody())))))))...)
+		}
+	}
+	return fields
+}
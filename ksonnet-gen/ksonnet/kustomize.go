@@ -0,0 +1,53 @@
+package ksonnet
+
+import (
+	"fmt"
+)
+
+// `emitKustomizeHelpers` emits, for a top-level API object with
+// `metadata`, a `kustomize` namespace of functions that wrap fragments
+// built with ksonnet-lib into the patch documents kustomize expects,
+// so that libraries can be used to write patches for
+// kustomize-based pipelines. For example,
+//
+//	deployment.kustomize.strategicMergePatch(
+//	  "nginx", deployment.mixin.spec.withReplicas(3))
+//
+// returns a `patchesStrategicMerge` entry, with the `apiVersion`,
+// `kind`, and `metadata.name` kustomize uses to find the object to
+// patch.
+func (ao *apiObject) emitKustomizeHelpers(m *astWriter) {
+	if !ao.root().options.KustomizeHelpers || !ao.hasObjectMeta() {
+		return
+	}
+	if _, ok := ao.properties["kustomize"]; ok {
+		ao.root().report.errorf(
+			ao.parsedName.Unparse(),
+			"kustomize helpers not emitted, because a property named 'kustomize' already exists")
+		return
+	}
+
+	var group string
+	if gn := ao.parent.parent.qualifiedName; gn != "core" {
+		group = fmt.Sprintf("group: \"%s\", ", gn)
+	}
+
+	m.comment("Helpers for writing kustomize patches for objects of this kind.")
+	m.namespace("kustomize", func(m *astWriter) {
+		m.local("__kustomizeNs", "self")
+		m.comment("A `patchesStrategicMerge` entry that merges `fragment` into the object named `name`.")
+		m.method(
+			"strategicMergePatch", []string{"name", "fragment={}"},
+			"apiVersion + kind + {metadata+: {name: name}} + fragment")
+		m.comment("A `patchesJson6902` target selecting the object named `name`.")
+		m.method(
+			"json6902Target", []string{"name", "namespace=null"},
+			fmt.Sprintf(
+				"{%sversion: \"%s\", kind: \"%s\", name: name} + if namespace == null then {} else {namespace: namespace}",
+				group, ao.parent.version, ao.name))
+		m.comment("A `patches` entry that applies the JSON 6902 `operations` to the object named `name`.")
+		m.method(
+			"json6902Patch", []string{"name", "operations", "namespace=null"},
+			"{target: __kustomizeNs.json6902Target(name, namespace), patch: std.manifestJsonEx(operations, \"  \")}")
+	})
+}
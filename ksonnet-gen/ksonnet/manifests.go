@@ -0,0 +1,85 @@
+package ksonnet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ManifestKind is the API version and kind of an object of a
+// Kubernetes manifest, e.g., `apps/v1beta1` and `Deployment`.
+type ManifestKind struct {
+	APIVersion string
+	Kind       string
+}
+
+func (k ManifestKind) String() string {
+	return fmt.Sprintf("%s/%s", k.APIVersion, k.Kind)
+}
+
+// ManifestKinds reads Kubernetes manifests, in YAML or JSON, with any
+// number of documents each, and returns the kinds of the objects in
+// them, sorted, and without duplicates. The items of lists (e.g., of
+// `kubectl get -o yaml`) count as objects too. Setting
+// `Options.Kinds` to them generates a library of only what the
+// manifests use.
+func ManifestKinds(manifests ...[]byte) ([]ManifestKind, error) {
+	found := make(map[ManifestKind]bool)
+	var add func(object map[interface{}]interface{}, location string) error
+	add = func(object map[interface{}]interface{}, location string) error {
+		apiVersion, _ := object["apiVersion"].(string)
+		kind, _ := object["kind"].(string)
+		if apiVersion == "" || kind == "" {
+			return fmt.Errorf("Object of %s has no `apiVersion` or `kind`", location)
+		}
+
+		items, isList := object["items"].([]interface{})
+		if !isList || !strings.HasSuffix(kind, "List") {
+			found[ManifestKind{APIVersion: apiVersion, Kind: kind}] = true
+			return nil
+		}
+		for i, item := range items {
+			itemObject, ok := item.(map[interface{}]interface{})
+			if !ok {
+				return fmt.Errorf("Item %d of the list of %s is not an object", i+1, location)
+			}
+			if err := add(itemObject, fmt.Sprintf("%s, item %d", location, i+1)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, manifest := range manifests {
+		decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+		for document := 1; ; document++ {
+			location := fmt.Sprintf("manifest %d, document %d", i+1, document)
+			var object map[interface{}]interface{}
+			err := decoder.Decode(&object)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Could not parse %s:\n%v", location, err)
+			}
+			if object == nil {
+				// Empty documents, e.g., after a trailing `---`.
+				continue
+			}
+			if err := add(object, location); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	kinds := []ManifestKind{}
+	for kind := range found {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].String() < kinds[j].String() })
+	return kinds, nil
+}
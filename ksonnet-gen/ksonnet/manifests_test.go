@@ -0,0 +1,56 @@
+package ksonnet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifestKinds(t *testing.T) {
+	deployment := `apiVersion: apps/v1beta1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+`
+	list := `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "v1", "kind": "ConfigMap"},
+    {"apiVersion": "apps/v1beta1", "kind": "Deployment"}
+  ]
+}`
+	kinds, err := ManifestKinds([]byte(deployment), []byte(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ManifestKind{
+		{APIVersion: "apps/v1beta1", Kind: "Deployment"},
+		{APIVersion: "v1", Kind: "ConfigMap"},
+		{APIVersion: "v1", Kind: "Service"},
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Errorf("Expected kinds %v, got %v", expected, kinds)
+	}
+
+	tests := map[string]struct {
+		manifest string
+		err      string
+	}{
+		"no kind":         {"apiVersion: v1\n---\nkind: Service\n", "manifest 1, document 1"},
+		"malformed":       {"kind: [Service\n", "Could not parse manifest 1, document 1"},
+		"list of strings": {"apiVersion: v1\nkind: List\nitems: [foo]\n", "Item 1 of the list"},
+	}
+	for name, test := range tests {
+		_, err := ManifestKinds([]byte(test.manifest))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("[%s] Expected error containing '%s', got %v", name, test.err, err)
+		}
+	}
+}
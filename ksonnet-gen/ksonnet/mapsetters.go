@@ -0,0 +1,91 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `isMap` reports whether `p` is an object property whose
+// `additionalProperties` make it a map (e.g., `labels`, or the `data`
+// of a `ConfigMap`).
+func (p *property) isMap() bool {
+	return p.valueSchema != nil && p.schemaType != nil && *p.schemaType == "object"
+}
+
+// `itemSetterName` returns the name of the setter that sets one entry
+// of a map property (e.g., `withLabel` for `labels`), or "" if `p` is
+// not a map, or if another property of the same object already has a
+// function with that name, in which case the setter is not emitted.
+func (p *property) itemSetterName() jsonnet.Identifier {
+	if !p.isMap() {
+		return ""
+	}
+
+	k8sVersion := p.root().spec.Info.Version
+	naming := p.root().naming
+	name := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.name).ToItemSetterID(naming)
+	for _, sibling := range p.parent.emittedProperties {
+		id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, sibling.name)
+		if id.ToSetterID(naming) == name || id.ToMixinID(naming) == name {
+			return ""
+		}
+	}
+	return name
+}
+
+// `emitItemSetter` emits `name`, a setter with two parameters, `key`
+// and `value`, that sets one entry of a map property and keeps the
+// others, so that users don't have to wrap it in an object, as they
+// would for the mixin. `wrap` places the object literal that sets the
+// property where the property is, e.g., in a call to the mixin of its
+// parent.
+func (p *property) emitItemSetter(
+	m *astWriter, name jsonnet.Identifier, wrap func(string) string,
+) {
+	if name == "" {
+		return
+	}
+	fieldName := jsonnet.RewriteAsFieldKey(p.name)
+	p.comments.emit(m)
+	m.comment(fmt.Sprintf(
+		"Sets the entry `key` of `%s` to `value`, which is %s, and keeps the other entries.",
+		p.name, describeSchema(p.valueSchema)))
+	m.method(
+		string(name), []string{"key", "value"},
+		"self + "+wrap(fmt.Sprintf("{%s+: {[key]: value}}", fieldName)))
+}
+
+// `describeSchema` describes the values `schema` accepts, for
+// comments, e.g., "a string", "a `Quantity`", or "an array of
+// strings".
+func describeSchema(schema *kubespec.Property) string {
+	switch {
+	case schema.Ref != nil:
+		return fmt.Sprintf("a `%s`", refKind(*schema.Ref))
+	case schema.Type == nil:
+		return "any value"
+	case *schema.Type == "array":
+		switch {
+		case schema.Items.Ref != nil:
+			return fmt.Sprintf("an array of `%s`s", refKind(*schema.Items.Ref))
+		case schema.Items.Type != nil:
+			return fmt.Sprintf("an array of %ss", *schema.Items.Type)
+		default:
+			return "an array"
+		}
+	case *schema.Type == "integer" || *schema.Type == "object":
+		return fmt.Sprintf("an %s", *schema.Type)
+	default:
+		return fmt.Sprintf("a %s", *schema.Type)
+	}
+}
+
+// `refKind` returns the last segment of the name of the definition
+// `ref` refers to, e.g., `Quantity`.
+func refKind(ref kubespec.ObjectRef) string {
+	name := string(ref)
+	return name[strings.LastIndex(name, ".")+1:]
+}
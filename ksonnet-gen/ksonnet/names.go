@@ -0,0 +1,219 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubeversion"
+)
+
+// NamesFile is the name of the file `Options.NameMap` causes to be
+// emitted.
+const NamesFile = "names.json"
+
+// The roles a `Name` can have in the library.
+const (
+	NameRoleObject      = "object"      // e.g., `apps.v1beta1.deployment`.
+	NameRoleConstructor = "constructor" // e.g., `apps.v1beta1.deployment.new`.
+	NameRoleSetter      = "setter"      // e.g., `...deployment.mixin.spec.withReplicas`.
+	NameRoleMixin       = "mixin"       // e.g., `...deployment.mixin.spec.withSelectorMixin`.
+	NameRoleNamespace   = "namespace"   // e.g., `...deployment.mixin.spec`.
+)
+
+// NameMap records what every function and namespace of a generated
+// library stands for in the spec, e.g., that
+// `apps.v1beta1.deployment.mixin.spec.withReplicas` is the setter of
+// `spec.replicas` of `apps/v1beta1` `Deployment`. Two name maps, from
+// two versions of the library, together describe how to migrate code
+// written against one to the other (see the `migrate` package).
+type NameMap struct {
+	KubernetesVersion string `json:"kubernetesVersion"`
+	Names             []Name `json:"names"`
+}
+
+// Name is a single function or namespace of a generated library.
+type Name struct {
+	Path    string `json:"path"`    // e.g., `apps.v1beta1.deployment.mixin.spec.withReplicas`.
+	Group   string `json:"group"`   // e.g., `apps`.
+	Version string `json:"version"` // e.g., `v1beta1`.
+	Kind    string `json:"kind"`    // e.g., `Deployment`.
+	// Field is the dotted path to the property a setter, mixin, or
+	// namespace is for (e.g., `spec.replicas`), or the name of a
+	// constructor.
+	Field string `json:"field,omitempty"`
+	Role  string `json:"role"`
+	// Params are the parameters of a function, with their default
+	// values, if any (e.g., `podLabels={app: name}`).
+	Params []string `json:"params,omitempty"`
+}
+
+// Key identifies what `n` stands for, regardless of where it is in the
+// library, or what it is called.
+func (n Name) Key() string {
+	return fmt.Sprintf("%s/%s/%s:%s:%s", n.Group, n.Version, n.Kind, n.Field, n.Role)
+}
+
+// ParseNameMap deserializes a name map written with `Options.NameMap`.
+func ParseNameMap(text []byte) (*NameMap, error) {
+	names := NameMap{}
+	if err := json.Unmarshal(text, &names); err != nil {
+		return nil, fmt.Errorf("Could not deserialize name map:\n%v", err)
+	}
+	return &names, nil
+}
+
+// `emitNameMap` returns the text of the name map of the library.
+func (root *root) emitNameMap() ([]byte, error) {
+	return json.MarshalIndent(root.nameMap(), "", "  ")
+}
+
+// `nameMap` lists the names of every top-level object, its
+// constructors, and its setters and mixins at every depth, sorted by
+// path. Namespaces of objects that refer to themselves (e.g.,
+// `JSONSchemaProps`) are listed only once per path.
+func (root *root) nameMap() *NameMap {
+	names := NameMap{
+		KubernetesVersion: root.spec.Info.Version,
+		Names:             []Name{},
+	}
+	k8sVersion := root.spec.Info.Version
+	naming := root.naming
+	for _, group := range root.groups.toSortedSlice() {
+		groupID := jsonnet.RewriteAsIdentifier(naming, k8sVersion, group.name)
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				if !ao.isTopLevel {
+					continue
+				}
+
+				object := Name{
+					Path:    fmt.Sprintf("%s.%s.%s", groupID, versionedAPI.version, ao.jsonnetName),
+					Group:   string(group.name),
+					Version: string(versionedAPI.version),
+					Kind:    string(ao.name),
+					Role:    NameRoleObject,
+				}
+				names.Names = append(names.Names, object)
+				names.Names = append(names.Names, ao.constructorNames(object)...)
+				names.Names = append(
+					names.Names, ao.propertyNames(object, object.Path, "", map[*apiObject]bool{ao: true})...)
+				names.Names = append(names.Names, ao.podTemplateNames(object)...)
+			}
+		}
+	}
+
+	sort.Slice(names.Names, func(i, j int) bool {
+		return names.Names[i].Path < names.Names[j].Path
+	})
+	return &names
+}
+
+func (ao *apiObject) constructorNames(object Name) []Name {
+	named, specs := ao.constructors()
+	if named != "" {
+		specs = append(specs, kubeversion.CustomConstructorSpec{
+			ID: named, Params: namedConstructorParams(),
+		})
+	} else if specs == nil {
+		specs = []kubeversion.CustomConstructorSpec{{ID: constructorName}}
+	}
+
+	names := []Name{}
+	for _, spec := range specs {
+		name := object
+		name.Path = fmt.Sprintf("%s.%s", object.Path, spec.ID)
+		name.Field = spec.ID
+		name.Role = NameRoleConstructor
+		for _, param := range spec.Params {
+			if param.DefaultValue != nil {
+				name.Params = append(name.Params, fmt.Sprintf("%s=%s", param.ID, *param.DefaultValue))
+			} else {
+				name.Params = append(name.Params, param.ID)
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// `podTemplateNames` returns the names in the shared pod template
+// namespace of a workload kind (see `emitPodTemplateRef`).
+func (ao *apiObject) podTemplateNames(object Name) []Name {
+	if ao.podTemplatePath() == nil {
+		return nil
+	}
+	if _, ok := ao.properties["podTemplate"]; ok {
+		return nil
+	}
+
+	pts := ao.root().podTemplateSpec()
+	namespace := object
+	namespace.Path = fmt.Sprintf("%s.mixin.podTemplate", object.Path)
+	namespace.Field = "podTemplate"
+	namespace.Role = NameRoleNamespace
+	return append(
+		[]Name{namespace},
+		pts.propertyNames(object, namespace.Path, namespace.Field, map[*apiObject]bool{ao: true, pts: true})...)
+}
+
+// `propertyNames` returns the names of the setters, mixins, and
+// namespaces `ao` has in the namespace at `path`, i.e., either the
+// object itself, or one of its `mixin` namespaces. `field` is the
+// dotted path to the property the namespace is for, and `visiting`
+// holds the objects whose namespaces enclose it.
+func (ao *apiObject) propertyNames(
+	object Name, path, field string, visiting map[*apiObject]bool,
+) []Name {
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	names := []Name{}
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == typeAlias || isSpecialProperty(pm.name) {
+			continue
+		}
+
+		id := jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name)
+		params := []string{string(jsonnet.RewriteAsFuncParam(naming, k8sVersion, pm.name))}
+		name := object
+		name.Field = strings.TrimPrefix(fmt.Sprintf("%s.%s", field, pm.name), ".")
+
+		if isMixinRef(pm.ref) {
+			ref := ao.root().getAPIObject(ao.root().parseRef(pm.ref))
+			if visiting[ref] {
+				continue
+			}
+
+			namespacePath := fmt.Sprintf("%s.%s", path, id)
+			if field == "" {
+				namespacePath = fmt.Sprintf("%s.mixin.%s", path, id)
+			}
+			name.Path = namespacePath
+			name.Role = NameRoleNamespace
+			names = append(names, name)
+
+			visiting[ref] = true
+			names = append(names, ref.propertyNames(object, namespacePath, name.Field, visiting)...)
+			delete(visiting, ref)
+			continue
+		}
+
+		setter := name
+		setter.Path = fmt.Sprintf("%s.%s", path, id.ToSetterID(naming))
+		setter.Role = NameRoleSetter
+		setter.Params = params
+		names = append(names, setter)
+
+		if pm.ref == nil && pm.schemaType != nil &&
+			(*pm.schemaType == "array" || *pm.schemaType == "object") {
+			mixin := name
+			mixin.Path = fmt.Sprintf("%s.%s", path, id.ToMixinID(naming))
+			mixin.Role = NameRoleMixin
+			mixin.Params = params
+			names = append(names, mixin)
+		}
+	}
+	return names
+}
@@ -0,0 +1,339 @@
+package ksonnet
+
+import (
+	"time"
+
+	"github.com/google/go-jsonnet/formatter"
+)
+
+// Options controls optional features of the generated library. The
+// zero value of `Options` generates the default ksonnet-lib.
+type Options struct {
+	// Backend is the name of the language to generate code for (e.g.,
+	// `JsonnetBackend`). See `Backends` for the full list. Defaults to
+	// `JsonnetBackend`.
+	Backend string
+
+	// ConstructorsTakeName causes every top-level API object that has a
+	// `metadata` property to get a constructor `new(name,
+	// namespace=null)`, which sets `metadata.name` (and
+	// `metadata.namespace`, if given) alongside `apiVersion` and
+	// `kind`, rather than the zero-argument `new()`. A custom
+	// constructor specified in `kubeversion` that only takes the name is
+	// replaced by it; if another custom constructor is named `new`
+	// (e.g., that of `Deployment`), it is kept, and the constructor is
+	// named `newNamed` instead.
+	ConstructorsTakeName bool
+
+	// RequiredConstructors causes API objects with no custom
+	// constructor specified in `kubeversion` to get a constructor that
+	// takes the properties listed in the `required` of their definition
+	// as parameters, e.g., `new(name, image)` for `Container`, rather
+	// than the zero-argument `new()`, followed by optional parameters
+	// for the properties that have a `default`, which default to it.
+	// `ConstructorsTakeName` takes precedence for the objects it
+	// applies to.
+	RequiredConstructors bool
+
+	// FlattenDepth, if 2 or greater, causes top-level API objects to get
+	// setters that reach through nested properties in a single call,
+	// e.g., `deployment.withSpecTemplateSpecContainers(containers)`,
+	// for every property up to `FlattenDepth` properties deep.
+	FlattenDepth int
+
+	// PatchStrategyMixins causes the `withXMixin` functions of array
+	// properties to follow the property's
+	// `x-kubernetes-patch-strategy`, like a strategic merge patch
+	// would, rather than always appending to the existing elements:
+	// the mixins of arrays Kubernetes treats as atomic (e.g., `args`)
+	// replace them instead, and those of arrays with a patch merge key
+	// (e.g., `containers`) merge their elements by it, alongside a
+	// `replaceXByKey` function (e.g., `replaceContainersByName`). An
+	// `x-kubernetes-list-type` takes precedence over the patch
+	// strategy, like it does for server-side apply.
+	PatchStrategyMixins bool
+
+	// KustomizeHelpers causes top-level API objects that have a
+	// `metadata` property to get a `kustomize` namespace, with functions
+	// that wrap library-built fragments into kustomize patch documents.
+	KustomizeHelpers bool
+
+	// PodTemplateHelpers causes workload kinds (i.e., those that embed a
+	// `PodTemplateSpec`, like Deployment or CronJob) to get a
+	// `mixin.podTemplate` namespace, which sets the properties of the
+	// pod template wherever the kind embeds it, e.g.,
+	// `cronJob.mixin.podTemplate.spec.withContainers(containers)`.
+	PodTemplateHelpers bool
+
+	// JSONSchemas causes a standalone JSON Schema to be generated for
+	// every top-level API object, alongside the library, e.g.,
+	// `schemas/apps/v1beta1/Deployment.json`.
+	JSONSchemas bool
+
+	// Docs causes Markdown reference docs for the library to be
+	// generated alongside it, in the `docs` directory: a page per
+	// top-level object (e.g., `docs/apps/v1beta1/Deployment.md`), and
+	// an index, `docs/README.md`.
+	Docs bool
+
+	// FunctionIndex causes a `functions.json` file to also be emitted,
+	// which lists every function of the library with its path,
+	// parameters, and documentation (see `FunctionIndex`), for editor
+	// plugins and language servers.
+	FunctionIndex bool
+
+	// Snippets causes a `k8s.code-snippets` file of VS Code snippets to
+	// also be emitted, for the constructors of the common kinds (e.g.,
+	// `k.deployment.new`) and of the objects code builds with them (e.g.,
+	// `k.container.new`), with the paths and parameters they have in the
+	// library. This requires the Jsonnet backend.
+	Snippets bool
+
+	// Prototypes causes a ksonnet package of prototypes of well-known
+	// patterns (`deployed-service`, `configmap`, and
+	// `single-port-service`) to also be emitted, in the `parts`
+	// directory, so that `ks generate` builds them with the library
+	// they were generated with. This requires the Jsonnet backend.
+	Prototypes bool
+
+	// Site causes a static HTML reference site for the library to be
+	// generated alongside it, in the `site` directory, from the same
+	// documentation as `Docs`: a searchable index of the top-level
+	// objects by group and version, `site/index.html`, and a page per
+	// object (e.g., `site/apps/v1beta1/Deployment.html`), with an anchor
+	// per function.
+	Site bool
+
+	// TestSuite causes a test suite for the library, `k8s_test.jsonnet`,
+	// to be generated alongside it. This only applies to the Jsonnet
+	// backend.
+	TestSuite bool
+
+	// NameMap causes a `names.json` file to also be emitted, which
+	// records what every function and namespace of the library stands
+	// for in the spec (see `NameMap`), for use by `ksonnet-gen migrate`.
+	NameMap bool
+
+	// DiffAnchors causes every API object of the library to be
+	// preceded by a comment that marks where it starts, e.g.,
+	// `// --- apps.v1beta1.deployment ---`, so that diffs between
+	// libraries generated from different specs line up by object.
+	DiffAnchors bool
+
+	// SkippedReport causes a `skipped.json` file to also be emitted,
+	// which lists every definition and property of the spec the library
+	// leaves out, and why (see `SkippedEntry`), e.g., properties that
+	// are blacklisted for the Kubernetes version, so that what the
+	// library omits can be audited.
+	SkippedReport bool
+
+	// NoComments causes the comments (other than the header, see
+	// `Header`) and blank lines of the Jsonnet files of the library to
+	// be left out, which cuts their size by more than half. Minify
+	// also leaves out all other whitespace that can be, which makes the
+	// library smaller still, but unreadable.
+	NoComments bool
+	Minify     bool
+
+	// SourceMap causes a `sourcemap.json` file to also be emitted, which
+	// maps the ranges of lines of the library generated from each API
+	// object and property back to its definition and property name (see
+	// `SourceMap`), for tools, and for tracing a problem in the library
+	// back to the spec. This requires the Jsonnet backend, and can't be
+	// combined with `Format`, `NoComments`, or `Minify`.
+	SourceMap bool
+
+	// PreviousNameMap is the name map of a previous version of the
+	// library. If set, a `CHANGELOG.md` file is also emitted, which
+	// lists what was added, removed, and changed since, and suggests a
+	// semantic version bump for the new library (see `Changelog`).
+	PreviousNameMap *NameMap
+
+	// CompatShim causes a `compat.libsonnet` file to also be emitted,
+	// which extends `k.libsonnet` with an alias for every function and
+	// namespace of the library of `PreviousNameMap` that was renamed
+	// since (e.g., `scaleIO`, which became `scaleIo`), so that code
+	// written against the previous library keeps working. This
+	// requires `PreviousNameMap`, and the Jsonnet backend.
+	CompatShim bool
+
+	// RenderHelpers causes every top-level object to also get
+	// `renderJson(obj)` and `renderYaml(obj)` functions, which render an
+	// object of that kind as a manifest, and the library to get a
+	// `renderYamlList(objects)` function, which renders several objects
+	// as a multi-document YAML stream.
+	RenderHelpers bool
+
+	// FromJSON causes every top-level object to also get a
+	// `fromJson(obj)` function, which takes an existing manifest (e.g.,
+	// parsed from YAML), checks its `kind` and `apiVersion`, and returns
+	// it as an object of that kind, to be changed with the library.
+	FromJSON bool
+
+	// Docsonnet causes the library to document its groups, versions,
+	// objects, namespaces, constructors, setters, and mixins with
+	// docsonnet annotations (e.g., `"#withReplicas":: d.fn(...)`), so
+	// that docsonnet can render reference docs for it. The library then
+	// imports `doc-util/main.libsonnet`, which must be installed (e.g.,
+	// with jsonnet-bundler).
+	Docsonnet bool
+
+	// Header is a `text/template` of the comment every generated file
+	// starts with (e.g., to add a copyright notice or an SPDX license
+	// identifier), executed with a `HeaderData`. Each line of the
+	// output becomes a comment in the language of the file. Defaults
+	// to `DefaultHeader`.
+	Header string
+
+	// GeneratedAt is the time the library is generated at, which it
+	// records as the `generatedAt` of its `__ksonnetLib` metadata
+	// object, along with the version of Kubernetes and the SHAs of the
+	// generator and of the spec. If it is zero, `generatedAt` is null,
+	// so that the library is the same every time it is generated.
+	GeneratedAt time.Time
+
+	// Verify causes the generated library to be evaluated, and the
+	// objects its constructors and setters produce to be validated
+	// against the spec, failing generation if they don't match. This
+	// requires a backend that emits Jsonnet.
+	Verify bool
+
+	// Format, if set, causes every Jsonnet file of the library to be
+	// run through the go-jsonnet formatter (i.e., what `jsonnetfmt`
+	// does) with these options before it is returned (see
+	// `NewFormatOptions`), so that the style of the output doesn't
+	// depend on how the emitter indents the code it writes.
+	Format *formatter.Options
+
+	// ReflectionIndex causes every versioned API to get a hidden
+	// `__index` object, which lists its top-level API objects, and the
+	// functions and mixins of each, for generic code to introspect the
+	// library with.
+	ReflectionIndex bool
+
+	// ExposeTypes causes the library to get a `types` namespace, which
+	// exposes the API objects that aren't top-level (e.g.,
+	// `types.core.v1.podSpec`), which are otherwise hidden, so that
+	// they can be built directly, rather than through the mixins of the
+	// objects that embed them. This only applies to the Jsonnet
+	// backend.
+	ExposeTypes bool
+
+	// ShortNames causes the library to get an alias for every common
+	// kind, named after its `kubectl` short name, for the preferred
+	// version of the kind, e.g., `deploy` for `apps.v1beta1.deployment`.
+	// This only applies to the Jsonnet backend.
+	ShortNames bool
+
+	// GVKLookup causes the library to get a `gvk` table, which maps the
+	// group, version, and kind of every top-level object to the object
+	// (e.g., `gvk.apps.v1beta1.Deployment`), along with
+	// `byGVK(group, version, kind)`, which looks one up, and
+	// `byKind(kind)`, which looks up the preferred version of a kind, so
+	// that code can resolve kinds it only knows as data. This only
+	// applies to the Jsonnet backend.
+	GVKLookup bool
+
+	// UtilHelpers causes the library to get a `util` namespace of
+	// generic helpers (e.g., `mergePatch` and `pruneNulls`), which
+	// don't depend on the spec.
+	UtilHelpers bool
+
+	// QuantityHelpers causes `ResourceRequirements`, and every namespace
+	// of a property that refers to it (e.g.,
+	// `container.mixin.resources`), to get helpers that check resource
+	// quantities (`cpu("500m")` and `memory("1Gi")`), parse them into
+	// numbers (`parseQuantity`), and build the maps of `requests` and
+	// `limits` (`resourceList(cpu, memory)`).
+	QuantityHelpers bool
+
+	// EnvHelpers causes objects with an array of `EnvVar`s or of
+	// `EnvFromSource`s (i.e., containers) to get setters that take a
+	// map of environment variables to their values (e.g.,
+	// `withEnvMap({PORT: 8080})`), and builders of the elements of
+	// `envFrom` (e.g., `envFromConfigMapRef(name)` and
+	// `envFromSecretRef(name)`).
+	EnvHelpers bool
+
+	// Strict causes setters to check their arguments against the
+	// validation keywords of their property (`format`, `pattern`,
+	// `minimum`, `maximum`, `exclusiveMinimum`, `exclusiveMaximum`,
+	// `minLength`, `maxLength`, `minItems`, `maxItems`, `uniqueItems`,
+	// and `enum`), so that invalid values fail when the library is
+	// evaluated. Of the formats, `int32`, `int64`, `byte`, and
+	// `date-time` are checked.
+	Strict bool
+
+	// TypeAssertions causes setters and mixins to check that their
+	// arguments have the type of their property (e.g., that the
+	// argument of `withReplicas` is an integer), so that values of the
+	// wrong type fail when the library is evaluated, with an error
+	// naming the property, rather than being emitted as broken
+	// manifests.
+	TypeAssertions bool
+
+	// UnknownTypes is what to do with properties whose schema type the
+	// generator doesn't recognize, or that have neither a type nor a
+	// `$ref`: with `UnknownTypesLenient` (or ""), they get a plain
+	// setter that accepts any value, and a warning is reported; with
+	// `UnknownTypesStrict`, generation fails with an error listing
+	// every such property.
+	UnknownTypes string
+
+	// Include and Exclude filter the top-level API objects of the
+	// library by glob patterns (see `path.Match`) of their group,
+	// version, and kind, e.g., `apps/v1beta1/Deployment`, or `rbac/*/*`
+	// for every kind of `rbac`. The group of core objects is `core`,
+	// and patterns that leave out the version or kind match any (e.g.,
+	// `apps` is `apps/*/*`). If `Include` is set, only the objects that
+	// match it are kept, and objects that match `Exclude` never are.
+	// Objects that only objects that were filtered out refer to are
+	// left out too.
+	Include []string
+	Exclude []string
+
+	// Kinds, if set, also filters the top-level API objects of the
+	// library, by API version and kind (e.g., those `ManifestKinds`
+	// found in a set of manifests): objects of these kinds are kept,
+	// along with what they refer to, as if they matched `Include`.
+	// Kinds the spec doesn't define are reported as warnings.
+	Kinds []ManifestKind
+
+	// Split, if set, causes the library to be emitted as several files,
+	// laid out as one of `SplitLayouts`, rather than as a single
+	// `k8s.libsonnet`: with `SplitGroup`, one file per group (e.g.,
+	// `apps.libsonnet`), and with `SplitVersion`, one file per version
+	// of a group (e.g., `apps/v1beta1.libsonnet`), which the file of the
+	// group imports. `k8s.libsonnet` is then an index that imports the
+	// groups, so code that imports it keeps working, while code that
+	// only needs some groups or versions can import just their files.
+	// This only applies to the Jsonnet backend.
+	Split string
+
+	// NamingConvention is the casing convention of the functions and
+	// fields of the library, one of `NamingConventionNames`:
+	// `camelCase` (or ""), e.g., `persistentVolumeClaim.withApiVersion`,
+	// or `snake_case`, e.g., `persistent_volume_claim.with_api_version`.
+	// It applies to the names derived from the spec (groups, kinds, and
+	// properties, and their setters, mixins, and type aliases), while
+	// the names of the generator itself (e.g., `mixinInstance`) are
+	// left alone, and so are the fields of the objects the library
+	// builds, which are those of the Kubernetes API. Conventions other
+	// than camelCase require the Jsonnet backend.
+	NamingConvention string
+
+	// GoPackage, if set, causes a `libsonnet.go` file to also be
+	// emitted: the source of a Go package of that name, which embeds
+	// the `.libsonnet` files of the library as they are written (i.e.,
+	// after formatting and stripping), with `Names`, `File`, and
+	// `Files` to access them, so that Go programs can vendor the
+	// library without shipping data files. This requires the Jsonnet
+	// backend.
+	GoPackage string
+
+	// Overlay is the text of an overlay of partial definitions, which
+	// are merged over the spec's before the library is built (see
+	// `kubespec.Overlay`). Every value of the spec the overlay replaces
+	// or removes is reported as a warning.
+	Overlay []byte
+}
@@ -0,0 +1,29 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `applyOverlay` returns a copy of `spec` with the definitions of the
+// overlay `overlayText` merged over its own, along with every conflict
+// between them. `spec` itself is left untouched.
+func applyOverlay(
+	spec *kubespec.APISpec, overlayText []byte,
+) (*kubespec.APISpec, []kubespec.OverlayConflict, error) {
+	text, conflicts, err := kubespec.Overlay(spec.Text, overlayText)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	overlaid := kubespec.APISpec{}
+	if err := json.Unmarshal(text, &overlaid); err != nil {
+		return nil, nil, fmt.Errorf("Could not deserialize overlaid schema:\n%v", err)
+	}
+	overlaid.Text = text
+	overlaid.FilePath = spec.FilePath
+	overlaid.MergeConflicts = spec.MergeConflicts
+	return &overlaid, conflicts, nil
+}
@@ -0,0 +1,252 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// `mergeByKeyFunction` is the local function that the mixins of arrays
+// whose elements are merged by a patch merge key call to combine their
+// argument with the existing elements.
+const mergeByKeyFunction = "__mergeByKey"
+
+// `mergeSetFunction` is the local function that the mixins of `set`
+// lists call to add the elements of their argument that are not
+// already in the list.
+const mergeSetFunction = "__mergeSet"
+
+// List types of `x-kubernetes-list-type`.
+const (
+	listTypeAtomic = "atomic"
+	listTypeSet    = "set"
+	listTypeMap    = "map"
+)
+
+// `hasPatchStrategy` reports whether `strategy` is one of the strategies
+// listed in the `x-kubernetes-patch-strategy` of a property.
+func (p *property) hasPatchStrategy(strategy string) bool {
+	for _, s := range strings.Split(p.patchStrategy, ",") {
+		if strings.TrimSpace(s) == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// `arrayMixinOperator` returns the field operator the mixin of an array
+// property uses to combine its argument with the existing value: `+:`
+// (append), unless `Options.PatchStrategyMixins` is set, in which case
+// it follows the way strategic merge patches treat the property: `+:`
+// if its patch strategy is `merge`, and `:` (replace) otherwise, since
+// Kubernetes then treats the array as atomic. An
+// `x-kubernetes-list-type` of `atomic` always replaces.
+func (p *property) arrayMixinOperator() string {
+	if !p.root().options.PatchStrategyMixins {
+		return "+:"
+	}
+	if p.listType != listTypeAtomic && p.hasPatchStrategy("merge") {
+		return "+:"
+	}
+	return ":"
+}
+
+// `mergeKeys` returns the keys by which the elements of an array
+// property are merged: its `x-kubernetes-list-map-keys` if its list
+// type is `map` (e.g., `containerPort` and `protocol` for `ports`), or
+// else its patch merge key if its patch strategy is `merge` (e.g.,
+// `name` for `containers`), or nil if its elements are not merged by
+// key. The list type takes precedence over the patch strategy, so the
+// elements of `atomic` and `set` lists never are. Elements are only
+// merged by key if `Options.PatchStrategyMixins` is set.
+func (p *property) mergeKeys() []string {
+	if !p.root().options.PatchStrategyMixins || p.schemaType == nil || *p.schemaType != "array" {
+		return nil
+	}
+	switch {
+	case p.listType == listTypeMap:
+		return p.listMapKeys
+	case p.listType != "":
+		return nil
+	case p.hasPatchStrategy("merge") && p.patchMergeKey != "":
+		return []string{p.patchMergeKey}
+	}
+	return nil
+}
+
+// `mergeKey` names the merge keys of an array property (see
+// `mergeKeys`), e.g., in the name of its `replace...By...` function:
+// the key itself if there's one (e.g., `name`), or the keys joined by
+// `And` (e.g., `containerPortAndProtocol`), or "" if there are none.
+func (p *property) mergeKey() string {
+	keys := append([]string{}, p.mergeKeys()...)
+	for i := 1; i < len(keys); i++ {
+		keys[i] = "And" + strings.Title(keys[i])
+	}
+	return strings.Join(keys, "")
+}
+
+// `mergeKeysText` lists the merge keys of an array property for
+// comments, e.g., "`containerPort` and `protocol`".
+func (p *property) mergeKeysText() string {
+	keys := []string{}
+	for _, key := range p.mergeKeys() {
+		keys = append(keys, fmt.Sprintf("`%s`", key))
+	}
+	if len(keys) < 2 {
+		return strings.Join(keys, "")
+	}
+	return strings.Join(keys[:len(keys)-1], ", ") + " and " + keys[len(keys)-1]
+}
+
+// `arrayMixinBody` returns the body of the mixin of an array property,
+// which accepts either an array or a single element as `paramName`.
+// `wrap` places the object literal that sets the property where the
+// property is, e.g., in a call to the mixin of its parent.
+//
+// If the property has merge keys, the argument is merged into the
+// existing elements by key, and if `replace` is set, elements with the
+// same keys are replaced rather than merged. If it is a `set` list (and
+// `Options.PatchStrategyMixins` is set), only the elements of the
+// argument that are not in it yet are added.
+func (p *property) arrayMixinBody(
+	paramName jsonnet.FuncParam, replace bool, wrap func(string) string,
+) string {
+	fieldName := jsonnet.RewriteAsFieldKey(p.name)
+	name := jsonnetString(string(p.name))
+	existing := fmt.Sprintf("if %s in super then super[%s] else null", name, name)
+	field := func(value string) string {
+		keys := p.mergeKeys()
+		switch {
+		case len(keys) == 1:
+			p.root().usesMergeByKey = true
+			return fmt.Sprintf(
+				"{%s: %s(%s, %s, %s, %t)}",
+				fieldName, mergeByKeyFunction, existing, value, jsonnetString(keys[0]), replace)
+		case len(keys) > 1:
+			p.root().usesMergeByKey = true
+			return fmt.Sprintf(
+				"{%s: %s(%s, %s, %s, %t)}",
+				fieldName, mergeByKeyFunction, existing, value, jsonnetValue(keys), replace)
+		case p.listType == listTypeSet && p.root().options.PatchStrategyMixins:
+			p.root().usesMergeSet = true
+			return fmt.Sprintf("{%s: %s(%s, %s)}", fieldName, mergeSetFunction, existing, value)
+		}
+		return fmt.Sprintf("{%s%s %s}", fieldName, p.arrayMixinOperator(), value)
+	}
+	return fmt.Sprintf(
+		"if std.type(%s) == \"array\" then %s else %s",
+		paramName, wrap(field(string(paramName))), wrap(field(fmt.Sprintf("[%s]", paramName))))
+}
+
+// `emitReplaceByKey` emits `name`, a function that replaces the
+// elements of an array property that have the same merge key as its
+// argument, if the property has a merge key.
+func (p *property) emitReplaceByKey(
+	m *astWriter, name jsonnet.Identifier, paramName jsonnet.FuncParam,
+	wrap func(string) string,
+) {
+	key := p.mergeKey()
+	if key == "" {
+		return
+	}
+	match := "matches that"
+	if len(p.mergeKeys()) > 1 {
+		match = "match those"
+	}
+	p.comments.emit(m)
+	m.comment(fmt.Sprintf(
+		"Replaces the existing elements whose %s %s of an element of `%s`, and appends the others.",
+		p.mergeKeysText(), match, paramName))
+	m.method(
+		string(name), []string{string(paramName)}, "self + "+p.arrayMixinBody(paramName, true, wrap))
+}
+
+// `emitMergeByKeyFunction` emits the function that the mixins of
+// arrays with a merge key call, if any of them was emitted. This must
+// be called after every group has been emitted.
+//
+// Like a strategic merge patch, each element is merged into the
+// existing element with the same key, if any, and appended otherwise.
+// Unlike one, elements are merged with `+`, i.e., nested arrays are
+// replaced rather than merged. `key` is either a single key, or an
+// array of keys (of a `map` list), all of which have to match; keys
+// that neither element has match.
+func (root *root) emitMergeByKeyFunction(m *astWriter) {
+	if !root.usesMergeByKey {
+		return
+	}
+	m.field(astLocalMethod(
+		mergeByKeyFunction, m.function([]string{"existing", "elements", "key", "replace"}, m.code(strings.Join([]string{
+			"",
+			"  local keys = if std.isArray(key) then key else [key];",
+			"  local values(x) = [if std.objectHas(x, k) then x[k] else null for k in keys];",
+			"  local matches(a, b) = std.isObject(a) && std.isObject(b) && std.length([k for k in keys if std.objectHas(a, k)]) > 0 && values(a) == values(b);",
+			"  std.foldl(",
+			"    function(acc, element)",
+			"      if std.length([x for x in acc if matches(x, element)]) == 0 then acc + [element]",
+			"      else [if matches(x, element) then (if replace then element else x + element) else x for x in acc],",
+			"    elements,",
+			"    if existing == null then [] else existing",
+			"  )",
+		}, "\n")))))
+}
+
+// `emitMergeSetFunction` emits the function that the mixins of `set`
+// lists call, if any of them was emitted. This must be called after
+// every group has been emitted.
+func (root *root) emitMergeSetFunction(m *astWriter) {
+	if !root.usesMergeSet {
+		return
+	}
+	m.field(astLocalMethod(
+		mergeSetFunction, m.function([]string{"existing", "elements"}, m.code(strings.Join([]string{
+			"",
+			"  std.foldl(",
+			"    function(acc, element) if std.count(acc, element) == 0 then acc + [element] else acc,",
+			"    elements,",
+			"    if existing == null then [] else existing",
+			"  )",
+		}, "\n")))))
+}
+
+// `emitPatchStrategyComment` documents, in the comments of the mixin
+// of an array property, how its list type or patch strategy decided
+// what the mixin does, if `Options.PatchStrategyMixins` is set.
+func (p *property) emitPatchStrategyComment(m *astWriter) {
+	if !p.root().options.PatchStrategyMixins || p.schemaType == nil || *p.schemaType != "array" {
+		return
+	}
+
+	switch {
+	case p.listType == listTypeMap && p.mergeKey() != "":
+		m.comment(fmt.Sprintf(
+			"Merges into the existing elements by %s, because `%s` is a list of type `map` with the keys %s: elements whose keys match an existing element are merged into it, and the others are appended.",
+			p.mergeKeysText(), p.name, p.mergeKeysText()))
+	case p.listType == listTypeSet:
+		m.comment(fmt.Sprintf(
+			"Appends the elements that are not in `%s` yet, because it is a list of type `set`.",
+			p.name))
+	case p.listType == listTypeAtomic:
+		m.comment(fmt.Sprintf(
+			"Replaces the existing elements, because `%s` is a list of type `atomic`.",
+			p.name))
+	case p.mergeKey() != "":
+		m.comment(fmt.Sprintf(
+			"Merges into the existing elements by `%s`, because the patch strategy of `%s` is `%s` with the merge key `%s`: elements whose `%s` matches an existing element are merged into it, and the others are appended.",
+			p.mergeKey(), p.name, p.patchStrategy, p.mergeKey(), p.mergeKey()))
+	case p.hasPatchStrategy("merge"):
+		m.comment(fmt.Sprintf(
+			"Appends to the existing elements, because the patch strategy of `%s` is `%s`.",
+			p.name, p.patchStrategy))
+	case p.patchStrategy != "":
+		m.comment(fmt.Sprintf(
+			"Replaces the existing elements, because the patch strategy of `%s` is `%s`.",
+			p.name, p.patchStrategy))
+	default:
+		m.comment(fmt.Sprintf(
+			"Replaces the existing elements, because `%s` has no patch strategy, so Kubernetes treats it as atomic.",
+			p.name))
+	}
+}
@@ -0,0 +1,104 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// podTemplateKind is the kind of the object that workload kinds (e.g.,
+// Deployment, StatefulSet, DaemonSet, Job, CronJob) embed to describe
+// the pods they create.
+const podTemplateKind kubespec.ObjectKind = "PodTemplateSpec"
+
+// podTemplateSearchDepth bounds how deep we look for an embedded
+// `PodTemplateSpec`. The deepest in practice is CronJob, at
+// `spec.jobTemplate.spec.template`.
+const podTemplateSearchDepth = 4
+
+// `podTemplateSpec` returns the API object for `PodTemplateSpec`, or
+// nil if the spec doesn't contain one, or `Options.PodTemplateHelpers`
+// is not set. If there are several (e.g., in different versions), the
+// first in sorted order is used.
+func (root *root) podTemplateSpec() *apiObject {
+	if !root.options.PodTemplateHelpers {
+		return nil
+	}
+	return root.apiObjectOfKind(podTemplateKind)
+}
+
+// `emitPodTemplateMixins` emits the mixin namespace for
+// `PodTemplateSpec` that all workload kinds share, as the `podTemplate`
+// member of `hidden`.
+//
+// The namespace is emitted exactly like the ref mixins of any other
+// property, except that its root mixin,
+// `__podTemplateMixin(podTemplate)`, returns its argument unchanged.
+// Each workload kind then overrides `__podTemplateMixin` to nest the
+// template at the right place (see `emitPodTemplateRef`), so that,
+// e.g., `deployment.mixin.podTemplate.spec.withContainers(c)` sets
+// `spec.template.spec.containers`. This lets users write pod-level
+// configuration once, as a function of the `podTemplate` namespace,
+// and apply it to any workload kind.
+func (root *root) emitPodTemplateMixins(m *astWriter) {
+	pts := root.podTemplateSpec()
+	if pts == nil {
+		return
+	}
+	if _, ok := root.hiddenGroups["podTemplate"]; ok {
+		root.report.errorf(
+			pts.parsedName.Unparse(),
+			"shared pod template mixins not emitted, because a group named 'podTemplate' already exists")
+		return
+	}
+
+	m.namespace("podTemplate", func(m *astWriter) {
+		m.local("__podTemplateNs", "self")
+		m.method("__podTemplateMixin", []string{"podTemplate"}, "podTemplate")
+		m.method("mixinInstance", []string{"podTemplate"}, "__podTemplateNs.__podTemplateMixin(podTemplate)")
+
+		for _, pm := range pts.emittedProperties {
+			if isSpecialProperty(pm.name) {
+				continue
+			}
+			pm.emitAsRefMixin(m, "__podTemplateNs.__podTemplateMixin")
+		}
+	})
+}
+
+// `podTemplatePath` finds the shortest path of `$ref` properties from
+// a top-level API object to the `PodTemplateSpec` it embeds (e.g.,
+// `spec.template` for a Deployment), or returns nil if there is none.
+func (ao *apiObject) podTemplatePath() []*property {
+	pts := ao.root().podTemplateSpec()
+	if !ao.isTopLevel || pts == nil || ao == pts {
+		return nil
+	}
+	return ao.refPathTo(pts, podTemplateSearchDepth)
+}
+
+// `emitPodTemplateRef` emits, in the `mixin` namespace of a workload
+// kind, a `podTemplate` namespace that reuses the shared pod template
+// mixins, nesting them at the place the workload embeds its
+// `PodTemplateSpec`.
+func (ao *apiObject) emitPodTemplateRef(m *astWriter) {
+	path := ao.podTemplatePath()
+	if path == nil {
+		return
+	}
+	if _, ok := ao.properties["podTemplate"]; ok {
+		ao.root().report.errorf(
+			ao.parsedName.Unparse(),
+			"shared pod template mixins not emitted, because a property named 'podTemplate' already exists")
+		return
+	}
+
+	body := "podTemplate"
+	for i := len(path) - 1; i >= 0; i-- {
+		body = fmt.Sprintf("{%s+: %s}", jsonnet.RewriteAsFieldKey(path[i].name), body)
+	}
+
+	m.comment("Mixins for the pod template of this object, shared by all kinds that embed a pod template.")
+	m.hidden("podTemplate", fmt.Sprintf("hidden.podTemplate + {__podTemplateMixin(podTemplate):: %s}", body))
+}
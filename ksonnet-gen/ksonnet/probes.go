@@ -0,0 +1,91 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// `probe` is a Jsonnet expression that exercises the generated library
+// (e.g., calls a setter), evaluating to (part of) an instance of the
+// top-level API object `object`.
+type probe struct {
+	name       string
+	expression string
+	object     *apiObject
+}
+
+// `text` returns the probe as a complete Jsonnet program, to be
+// evaluated next to `k8s.libsonnet`.
+func (p probe) text() string {
+	return fmt.Sprintf("local k8s = import \"k8s.libsonnet\"; %s", p.expression)
+}
+
+// `probes` returns the expressions that `Options.Verify` and the
+// differential tests evaluate against the library: the cases of the
+// generated test suite (see `emitTestSuite`), and a call to every
+// setter of every top-level object, and of its `mixin` namespaces,
+// that can be tested generically. They are sorted by name.
+func (root *root) probes() []probe {
+	probes := []probe{}
+	k8sVersion := root.spec.Info.Version
+	naming := root.naming
+	for _, group := range root.groups.toSortedSlice() {
+		groupID := jsonnet.RewriteAsIdentifier(naming, k8sVersion, group.name)
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				if !ao.isTopLevel {
+					continue
+				}
+
+				for _, test := range ao.testCases() {
+					// Constructor tests index into the object the
+					// constructor builds, so the probe is the call itself.
+					expression := test.actual
+					if test.call != "" {
+						expression = test.call
+					}
+					probes = append(probes, probe{
+						name: test.name, expression: expression, object: ao,
+					})
+				}
+
+				object := fmt.Sprintf(
+					"k8s.%s.%s.%s", groupID, versionedAPI.version, ao.jsonnetName)
+				addSetter := func(setter, value string) {
+					probes = append(probes, probe{
+						name:       setter,
+						expression: fmt.Sprintf("%s(%s)", setter, value),
+						object:     ao,
+					})
+				}
+
+				for _, pm := range ao.emittedProperties {
+					if isSpecialProperty(pm.name) {
+						continue
+					}
+					if value, _, ok := pm.testValue(); ok {
+						addSetter(fmt.Sprintf(
+							"%s.%s", object, jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name).ToSetterID(naming)),
+							value)
+					}
+					if pm.kind == typeAlias || !isMixinRef(pm.ref) {
+						continue
+					}
+
+					ref := root.getAPIObject(root.parseRef(pm.ref))
+					for _, child := range ref.emittedProperties {
+						if value, _, ok := child.testValue(); ok && !isSpecialProperty(child.name) {
+							addSetter(fmt.Sprintf(
+								"%s.mixin.%s.%s", object,
+								jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name),
+								jsonnet.RewriteAsIdentifier(naming, k8sVersion, child.name).ToSetterID(naming)),
+								value)
+						}
+					}
+				}
+			}
+		}
+	}
+	return probes
+}
@@ -0,0 +1,339 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// PrototypesDir is the directory `Options.Prototypes` causes a ksonnet
+// package of prototypes (also known as parts) to be emitted to: its
+// `parts.yaml`, and a `prototypes/<name>.jsonnet` file per prototype.
+const PrototypesDir = "parts"
+
+// `prototypesPackage` is the name of the package of prototypes, which
+// also prefixes the names of the prototypes (e.g.,
+// `io.ksonnet.pkg.k8s-deployed-service`), so that they don't clash
+// with the prototypes ksonnet comes with.
+const prototypesPackage = "k8s"
+
+// `prototype` is a ksonnet prototype of a well-known pattern, which
+// `ks generate` turns into a component. It is written against the
+// generated library, so that it follows its paths and constructors.
+type prototype struct {
+	name             string
+	shortDescription string
+	description      string
+	params           []prototypeParam
+	// `locals` are bound after the parameters, e.g., `labels = {app:
+	// name}`.
+	locals []string
+	// `objects` are built in order, each bound to its `local`; the
+	// top-level ones are the components of the prototype.
+	objects []prototypeObject
+}
+
+// `prototypeParam` is a parameter of a `prototype`. It is required if
+// it has no default.
+type prototypeParam struct {
+	name, typ, defaultValue, help string
+}
+
+// `prototypeObject` is an object a `prototype` builds, with the
+// constructor `new` of the object of kind `kind` of the library,
+// followed by the setters of the properties the constructor does not
+// set.
+type prototypeObject struct {
+	local  string
+	kind   kubespec.ObjectKind
+	values []prototypeValue
+}
+
+// `prototypeValue` is the Jsonnet expression a `prototypeObject` sets
+// the property at the dotted path `field` to. `optional` values are
+// left out of objects that don't have the property (e.g.,
+// `spec.selector.matchLabels`, which later versions of `Deployment`
+// require).
+type prototypeValue struct {
+	field, value string
+	optional     bool
+}
+
+var prototypes = []prototype{
+	{
+		name:             "configmap",
+		shortDescription: "A simple config map with optional user-specified data.",
+		description:      "A simple config map with optional user-specified data.",
+		params: []prototypeParam{
+			{"name", "string", "", "Name to give the config map."},
+			{"data", "object", "{}", "Data for the config map."},
+		},
+		objects: []prototypeObject{
+			{"configMap", "ConfigMap", []prototypeValue{
+				{"metadata.name", "name", false},
+				{"data", "data", false},
+			}},
+		},
+	},
+	{
+		name:             "deployed-service",
+		shortDescription: "A deployment exposed with a service.",
+		description: "A service that exposes `containerPort` of the pods of a " +
+			"deployment running `image`, on `servicePort`.",
+		params: []prototypeParam{
+			{"name", "string", "", "Name to give to each of the components."},
+			{"image", "string", "", "Container image to deploy."},
+			{"servicePort", "number", "80", "Port for the service to expose."},
+			{"containerPort", "number", "80", "Container port for the service to target."},
+			{"replicas", "number", "1", "Number of replicas."},
+			{"type", "string", "ClusterIP", "Type of service to expose."},
+		},
+		locals: []string{"labels = {app: name}"},
+		objects: []prototypeObject{
+			{"appServicePort", "ServicePort", []prototypeValue{
+				{"port", "servicePort", false},
+				{"targetPort", "containerPort", false},
+			}},
+			{"service", "Service", []prototypeValue{
+				{"metadata.name", "name", false},
+				{"spec.ports", "[appServicePort]", false},
+				{"spec.selector", "labels", false},
+				{"spec.type", "type", false},
+			}},
+			{"appContainerPort", "ContainerPort", []prototypeValue{
+				{"containerPort", "containerPort", false},
+			}},
+			{"appContainer", "Container", []prototypeValue{
+				{"name", "name", false},
+				{"image", "image", false},
+				{"ports", "[appContainerPort]", false},
+			}},
+			{"deployment", "Deployment", []prototypeValue{
+				{"metadata.name", "name", false},
+				{"spec.replicas", "replicas", false},
+				{"spec.selector.matchLabels", "labels", true},
+				{"spec.template.metadata.labels", "labels", false},
+				{"spec.template.spec.containers", "[appContainer]", false},
+			}},
+		},
+	},
+	{
+		name:             "single-port-service",
+		shortDescription: "A service that exposes a single port of the pods it selects.",
+		description: "A service that exposes `targetPort` of the pods " +
+			"`targetLabelSelector` selects, on `port`.",
+		params: []prototypeParam{
+			{"name", "string", "", "Name of the service."},
+			{"targetLabelSelector", "object", "", "Labels of the pods to expose, e.g., {app: 'nginx'}."},
+			{"port", "number", "80", "Port for the service to expose."},
+			{"targetPort", "number", "80", "Port of the pods for the service to target."},
+			{"protocol", "string", "TCP", "Protocol of the port."},
+			{"type", "string", "ClusterIP", "Type of service to expose."},
+		},
+		objects: []prototypeObject{
+			{"appServicePort", "ServicePort", []prototypeValue{
+				{"port", "port", false},
+				{"targetPort", "targetPort", false},
+				{"protocol", "protocol", false},
+			}},
+			{"service", "Service", []prototypeValue{
+				{"metadata.name", "name", false},
+				{"spec.ports", "[appServicePort]", false},
+				{"spec.selector", "targetLabelSelector", false},
+				{"spec.type", "type", false},
+			}},
+		},
+	},
+}
+
+// `partsFile` is the `parts.yaml` of the package of prototypes. JSON
+// is YAML, so it is written as JSON.
+type partsFile struct {
+	Name        string   `json:"name"`
+	APIVersion  string   `json:"apiVersion"`
+	Kind        string   `json:"kind"`
+	Description string   `json:"description"`
+	Keywords    []string `json:"keywords"`
+}
+
+// `emitPrototypes` returns the files of the package of prototypes of
+// the library, by path. A prototype that needs a kind, a constructor,
+// or a property the library doesn't have is left out, with an error
+// in the report.
+func (root *root) emitPrototypes() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, p := range prototypes {
+		text, err := root.emitPrototype(p)
+		if err != nil {
+			root.report.errorf("", "prototype '%s' not emitted: %v", p.name, err)
+			continue
+		}
+		files[path.Join(PrototypesDir, "prototypes", p.name+".jsonnet")] = text
+	}
+
+	parts, err := json.MarshalIndent(partsFile{
+		Name:       prototypesPackage,
+		APIVersion: "0.0.1",
+		Kind:       "ksonnet.io/parts",
+		Description: fmt.Sprintf(
+			"Prototypes of common patterns, built with the library generated from the Kubernetes %s spec.",
+			root.spec.Info.Version),
+		Keywords: []string{"kubernetes", "prototypes"},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files[path.Join(PrototypesDir, "parts.yaml")] = parts
+	return files, nil
+}
+
+// `emitPrototype` returns the text of the prototype `p`, e.g.:
+//
+//	// @apiVersion 0.0.1
+//	// @name io.ksonnet.pkg.k8s-configmap
+//	...
+//	// @param name string Name to give the config map.
+//	// @optionalParam data object {} Data for the config map.
+//
+//	local k = import "k.libsonnet";
+//	local name = import "param://name";
+//	local data = import "param://data";
+//
+//	local configMap =
+//	  k.core.v1.configMap.new(name=name, data=data);
+//
+//	k.asList([configMap])
+func (root *root) emitPrototype(p prototype) ([]byte, error) {
+	topLevel := []*apiObject{}
+	objects := make(map[kubespec.ObjectKind]*apiObject)
+	for _, object := range p.objects {
+		if ao := root.preferredObjectOfKind(object.kind); ao != nil {
+			topLevel = append(topLevel, ao)
+			objects[object.kind] = ao
+		}
+	}
+	paths := root.typeAliasPaths(topLevel)
+	for _, ao := range topLevel {
+		paths[ao] = fmt.Sprintf("%s.%s.%s", ao.parent.parent.identifier(), ao.parent.version, ao.jsonnetName)
+	}
+	aliased := []*apiObject{}
+	for ao := range paths {
+		if !ao.isTopLevel {
+			aliased = append(aliased, ao)
+		}
+	}
+	sort.Slice(aliased, func(i, j int) bool { return paths[aliased[i]] < paths[aliased[j]] })
+	for _, ao := range aliased {
+		if _, ok := objects[ao.name]; !ok {
+			objects[ao.name] = ao
+		}
+	}
+
+	m := newIndentWriter()
+	m.writeLine("// @apiVersion 0.0.1")
+	m.writeLine(fmt.Sprintf("// @name io.ksonnet.pkg.%s-%s", prototypesPackage, p.name))
+	m.writeLine("// @description " + p.description)
+	m.writeLine("// @shortDescription " + p.shortDescription)
+	for _, param := range p.params {
+		if param.defaultValue == "" {
+			m.writeLine(fmt.Sprintf("// @param %s %s %s", param.name, param.typ, param.help))
+		} else {
+			m.writeLine(fmt.Sprintf(
+				"// @optionalParam %s %s %s %s", param.name, param.typ, param.defaultValue, param.help))
+		}
+	}
+	m.writeLine("")
+	root.emitHeader(m)
+	m.writeLine(`local k = import "k.libsonnet";`)
+	for _, param := range p.params {
+		m.writeLine(fmt.Sprintf(`local %s = import "param://%s";`, param.name, param.name))
+	}
+	for _, local := range p.locals {
+		m.writeLine(fmt.Sprintf("local %s;", local))
+	}
+	m.writeLine("")
+
+	items := []string{}
+	for _, object := range p.objects {
+		ao, ok := objects[object.kind]
+		if !ok {
+			return nil, fmt.Errorf("the library has no '%s'", object.kind)
+		}
+		terms, err := ao.prototypeTerms(fmt.Sprintf("k.%s", paths[ao]), object.values)
+		if err != nil {
+			return nil, fmt.Errorf("could not build '%s': %v", object.kind, err)
+		}
+		m.writeLine(fmt.Sprintf("local %s =", object.local))
+		m.indent()
+		for i, term := range terms {
+			if i < len(terms)-1 {
+				m.writeLine(term + " +")
+			} else {
+				m.writeLine(term + ";")
+			}
+		}
+		m.dedent()
+		if ao.isTopLevel {
+			items = append(items, object.local)
+		}
+	}
+	m.writeLine("")
+	m.writeLine(fmt.Sprintf("k.asList([%s])", strings.Join(items, ", ")))
+	return m.bytes()
+}
+
+// `prototypeTerms` returns the terms of the expression that builds
+// `ao`, whose path in the library is `path`, with the properties of
+// `values` set: a call to its constructor `new`, with the parameters
+// that set one of the properties passed by name, and then a call to
+// the setter of each of the other properties (see `setterPath`).
+func (ao *apiObject) prototypeTerms(path string, values []prototypeValue) ([]string, error) {
+	hasConstructor, args := false, []string{}
+	set := make(map[string]bool)
+	for _, spec := range ao.constructorSpecs() {
+		if spec.ID != constructorName {
+			continue
+		}
+		hasConstructor = true
+		for _, param := range spec.Params {
+			field := param.ID
+			if param.RelativePath != nil {
+				field = *param.RelativePath
+			}
+			found := false
+			for _, v := range values {
+				if v.field == field {
+					args = append(args, fmt.Sprintf("%s=%s", param.ID, v.value))
+					set[field], found = true, true
+				}
+			}
+			if !found && param.DefaultValue == nil {
+				return nil, fmt.Errorf("its constructor takes '%s', which the prototype doesn't set", param.ID)
+			}
+		}
+	}
+	if !hasConstructor {
+		return nil, fmt.Errorf("it has no constructor '%s'", constructorName)
+	}
+
+	terms := []string{
+		fmt.Sprintf("%s.%s(%s)", path, constructorName, strings.Join(args, ", ")),
+	}
+	for _, v := range values {
+		if set[v.field] {
+			continue
+		}
+		if ao.propertyAt(v.field) == nil {
+			if v.optional {
+				continue
+			}
+			return nil, fmt.Errorf("it has no property '%s'", v.field)
+		}
+		terms = append(terms, fmt.Sprintf("%s.%s(%s)", path, ao.setterPath(v.field), v.value))
+	}
+	return terms, nil
+}
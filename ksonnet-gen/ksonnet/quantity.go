@@ -0,0 +1,150 @@
+package ksonnet
+
+import (
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// resourceRequirementsKind is the kind of the object that holds the
+// compute resources of a container, whose `requests` and `limits` map
+// resource names to quantities.
+const resourceRequirementsKind kubespec.ObjectKind = "ResourceRequirements"
+
+// `quantityNamespace` is the member of `hidden` that implements the
+// quantity helpers (see `emitQuantityHelpers`).
+const quantityNamespace = "quantity"
+
+// `quantityHelpers` are the functions `emitQuantityHelpers` adds to
+// `ResourceRequirements`, and to every namespace of a property that
+// refers to it, with their doc comments. They call the implementation
+// in `hidden` (see `emitQuantityFunctions`).
+var quantityHelpers = []struct {
+	doc    string
+	name   string
+	params []string
+	body   string
+}{
+	{
+		"Checks that `quantity` is a valid CPU quantity (e.g., `\"500m\"`, or `0.5`), and returns it.",
+		"cpu", []string{"quantity"}, "hidden.quantity.check(quantity, \"CPU\")",
+	},
+	{
+		"Checks that `quantity` is a valid memory quantity (e.g., `\"1Gi\"`), and returns it.",
+		"memory", []string{"quantity"}, "hidden.quantity.check(quantity, \"memory\")",
+	},
+	{
+		"Returns the value of `quantity` as a number (e.g., `0.5` for `\"500m\"`, and `1073741824` for `\"1Gi\"`), or null if it is not a valid quantity.",
+		"parseQuantity", []string{"quantity"}, "hidden.quantity.parse(quantity)",
+	},
+	{
+		"Returns a map of resources to quantities, for `withRequests` and `withLimits`, with the quantities of CPU and memory that are not null, after checking them.",
+		"resourceList", []string{"cpu=null", "memory=null"}, "hidden.quantity.resourceList(cpu, memory)",
+	},
+}
+
+// `hasQuantityHelpers` reports whether `Options.QuantityHelpers` is set,
+// the library has a `ResourceRequirements` to add them to, and no
+// hidden group is named after `quantityNamespace`, whose place in
+// `hidden` the implementation of the helpers takes.
+func (root *root) hasQuantityHelpers() bool {
+	if !root.options.QuantityHelpers || root.apiObjectOfKind(resourceRequirementsKind) == nil {
+		return false
+	}
+	if _, ok := root.hiddenGroups[quantityNamespace]; ok {
+		return false
+	}
+	return true
+}
+
+// `emitQuantityHelpers` emits the quantity helpers of `ao`, if it is
+// `ResourceRequirements` (or a namespace of a property that refers to
+// it), e.g.,
+//
+//	container.mixin.resources.withRequests(
+//	  container.mixin.resources.resourceList(cpu="500m", memory="1Gi"))
+func (ao *apiObject) emitQuantityHelpers(m *astWriter) {
+	if ao.name != resourceRequirementsKind || !ao.root().hasQuantityHelpers() {
+		return
+	}
+	for _, helper := range quantityHelpers {
+		m.comment(helper.doc)
+		m.method(helper.name, helper.params, helper.body)
+	}
+}
+
+// `emitQuantityFunctions` emits the implementation of the quantity
+// helpers, as the `quantity` member of `hidden`. Quantities are parsed
+// as Kubernetes parses them: a signed decimal number, followed by a
+// binary suffix (e.g., `Ki`), a decimal suffix (e.g., `m` or `k`), or
+// a decimal exponent (e.g., `e3`). Numbers are quantities too.
+func (root *root) emitQuantityFunctions(m *astWriter) {
+	if !root.hasQuantityHelpers() {
+		if _, ok := root.hiddenGroups[quantityNamespace]; ok && root.options.QuantityHelpers {
+			root.report.errorf(
+				"", "quantity helpers not emitted, because a group named '%s' already exists", quantityNamespace)
+		}
+		return
+	}
+
+	m.namespace(quantityNamespace, func(m *astWriter) {
+		m.local("quantity", "self")
+		m.field(astLocal("suffixes", m.codeLines(
+			"{",
+			"  n: 1e-9,",
+			"  u: 1e-6,",
+			"  m: 1e-3,",
+			"  \"\": 1,",
+			"  k: 1e3,",
+			"  M: 1e6,",
+			"  G: 1e9,",
+			"  T: 1e12,",
+			"  P: 1e15,",
+			"  E: 1e18,",
+			"  Ki: std.pow(2, 10),",
+			"  Mi: std.pow(2, 20),",
+			"  Gi: std.pow(2, 30),",
+			"  Ti: std.pow(2, 40),",
+			"  Pi: std.pow(2, 50),",
+			"  Ei: std.pow(2, 60),",
+			"}")))
+		m.field(astLocalMethod("isDigits", m.function([]string{"s"}, m.codeLines(
+			"s != \"\" && std.length(std.filter(function(c) c < \"0\" || c > \"9\", std.stringChars(s))) == 0"))))
+		m.field(astLocalMethod("parseDigits", m.function([]string{"s"}, m.codeLines(
+			"if s == \"\" then 0 else std.parseInt(s)"))))
+		m.field(astLocalMethod("numberEnd", m.function([]string{"s", "i"}, m.codeLines(
+			"if i < std.length(s) && (s[i] == \".\" || isDigits(s[i])) then numberEnd(s, i + 1) else i"))))
+		m.field(astLocalMethod("signOf", m.function([]string{"s"}, m.codeLines(
+			"if std.length(s) > 0 && s[0] == \"-\" then -1 else 1"))))
+		m.field(astLocalMethod("unsigned", m.function([]string{"s"}, m.codeLines(
+			"if std.length(s) > 0 && (s[0] == \"-\" || s[0] == \"+\") then std.substr(s, 1, std.length(s) - 1) else s"))))
+		m.field(astLocalMethod("multiplier", m.function([]string{"suffix"}, m.codeLines(
+			"",
+			"  local exponent = unsigned(std.substr(suffix, 1, std.length(suffix) - 1));",
+			"  if std.objectHas(suffixes, suffix) then suffixes[suffix]",
+			"  else if std.length(suffix) > 1 && (suffix[0] == \"e\" || suffix[0] == \"E\") && isDigits(exponent)",
+			"  then std.pow(10, signOf(std.substr(suffix, 1, 1)) * std.parseInt(exponent))",
+			"  else null"))))
+		m.field(astMethod("parse", m.function([]string{"q"}, m.codeLines(
+			"",
+			"  if std.isNumber(q) then q",
+			"  else if !std.isString(q) then null",
+			"  else",
+			"    local number = unsigned(q);",
+			"    local end = numberEnd(number, 0);",
+			"    local parts = std.split(std.substr(number, 0, end), \".\");",
+			"    local fraction = if std.length(parts) == 2 then parts[1] else \"\";",
+			"    local factor = multiplier(std.substr(number, end, std.length(number) - end));",
+			"    if std.length(parts) > 2 || parts[0] + fraction == \"\" || factor == null then null",
+			"    else signOf(q) * (parseDigits(parts[0]) + parseDigits(fraction) / std.pow(10, std.length(fraction))) * factor"))))
+		m.field(astMethod("check", m.function([]string{"q", "resource"}, m.codeLines(
+			"",
+			"  local value = quantity.parse(q);",
+			"  if value == null then error \"'%s' is not a valid %s quantity\" % [std.toString(q), resource]",
+			"  else if value < 0 then error \"%s quantity '%s' must not be negative\" % [resource, std.toString(q)]",
+			"  else q"))))
+		m.field(astMethod("resourceList", m.function([]string{"cpu", "memory"}, m.codeLines(
+			"{",
+			"  [if cpu != null then \"cpu\"]: quantity.check(cpu, \"CPU\"),",
+			"  [if memory != null then \"memory\"]: quantity.check(memory, \"memory\"),",
+			"}"))))
+	})
+}
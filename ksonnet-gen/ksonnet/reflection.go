@@ -0,0 +1,72 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// `reflectionIndexName` is the name of the hidden field
+// `emitReflectionIndex` adds to every versioned API.
+const reflectionIndexName = "__index"
+
+// `emitReflectionIndex` emits, for a versioned API, a hidden object
+// that describes its top-level API objects, e.g.,
+//
+//	apps.v1beta1.__index.deployment ==
+//	  {kind: "Deployment", apiVersion: "apps/v1beta1", functions: ["new", ...], mixins: ["metadata", "spec"]}
+//
+// so that generic code can introspect the library when it is
+// evaluated (e.g., to find every kind that has a `mixin.metadata`
+// namespace). The functions are listed by the evaluator itself, so
+// they are always those the library actually has, including optional
+// helpers; the mixins are listed here, since the evaluator can't tell
+// a `mixin` namespace from a type alias.
+func (va *versionedAPI) emitReflectionIndex(m *astWriter) {
+	if !va.root().options.ReflectionIndex {
+		return
+	}
+
+	objects := []*apiObject{}
+	for _, ao := range va.apiObjects.toSortedSlice() {
+		if ao.isTopLevel {
+			objects = append(objects, ao)
+		}
+	}
+	if len(objects) == 0 {
+		return
+	}
+
+	m.local("__api", "self")
+	m.field(astLocalMethod("__kindIndex", m.function([]string{"kind", "object", "mixins"}, m.code(
+		"apiVersion + {kind: kind, "+
+			"functions: std.filter(function(f) std.isFunction(object[f]), std.objectFieldsAll(object)), "+
+			"mixins: mixins}"))))
+	m.comment("Describes the kinds of this API version, and the functions and mixins of each.")
+	m.namespace(reflectionIndexName, func(m *astWriter) {
+		for _, ao := range objects {
+			m.visible(string(ao.jsonnetName), fmt.Sprintf(
+				"__kindIndex(\"%s\", __api.%s, [%s])",
+				ao.name, ao.jsonnetName, strings.Join(ao.mixinNamespaces(), ", ")))
+		}
+	})
+}
+
+// `mixinNamespaces` returns the quoted names of the namespaces in the
+// `mixin` namespace of `ao`, in the order they are emitted.
+func (ao *apiObject) mixinNamespaces() []string {
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	names := []string{}
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == typeAlias || !isMixinRef(pm.ref) {
+			continue
+		}
+		names = append(names, fmt.Sprintf("%q", jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name)))
+	}
+	if _, ok := ao.properties["podTemplate"]; !ok && ao.podTemplatePath() != nil {
+		names = append(names, `"podTemplate"`)
+	}
+	return names
+}
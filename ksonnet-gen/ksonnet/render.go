@@ -0,0 +1,55 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `renderHelpers` are the names of the functions
+// `emitRenderHelpers` adds to every top-level API object.
+var renderHelpers = []string{"renderJson", "renderYaml"}
+
+// `emitRenderHelpers` emits, for a top-level API object, functions
+// that render an object of that kind as a manifest, e.g.,
+//
+//	deployment.renderYaml(deployment.new() + deployment.mixin.spec.withReplicas(3))
+//
+// They check the `kind` of their argument, so that, e.g., a
+// `configMap` is not accidentally rendered where a `deployment` was
+// expected.
+func (ao *apiObject) emitRenderHelpers(m *astWriter) {
+	if !ao.root().options.RenderHelpers {
+		return
+	}
+	for _, name := range renderHelpers {
+		if _, ok := ao.properties[kubespec.PropertyName(name)]; ok {
+			ao.root().report.errorf(
+				ao.parsedName.Unparse(),
+				"render helpers not emitted, because a property named '%s' already exists", name)
+			return
+		}
+	}
+
+	assertion := fmt.Sprintf(
+		"assert std.objectHas(obj, \"kind\") && obj.kind == \"%s\" : \"expected an object of kind '%s'\";",
+		ao.name, ao.name)
+	m.comment("Renders `obj`, an object of this kind, as a JSON manifest.")
+	m.method("renderJson", []string{"obj"}, fmt.Sprintf("%s std.manifestJsonEx(obj, \"  \")", assertion))
+	m.comment("Renders `obj`, an object of this kind, as a YAML manifest.")
+	m.method("renderYaml", []string{"obj"}, fmt.Sprintf("%s std.manifestYamlDoc(obj)", assertion))
+}
+
+// `emitRenderListHelper` emits a function that renders several
+// objects, of any kind, as a multi-document YAML stream, e.g., to pipe
+// to `kubectl apply -f -`. It accepts either an array of objects, or
+// a `List` object.
+func (root *root) emitRenderListHelper(m *astWriter) {
+	if !root.options.RenderHelpers {
+		return
+	}
+	m.comment("Renders `objects`, an array of objects or a `List`, as a multi-document YAML stream.")
+	m.method(
+		"renderYamlList", []string{"objects"},
+		"std.manifestYamlStream(if std.isArray(objects) then objects else objects.items)")
+}
@@ -0,0 +1,183 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// Report collects the decisions `ksonnet-gen` makes while building
+// and emitting ksonnet-lib that do not prevent the library from being
+// emitted, but which a user may want to know about. For example, if a
+// type alias had to be renamed because its name collided with a
+// property of the same API object, `Report` will contain a warning
+// saying so. It also summarizes the size of the generated library.
+//
+// A `Report` serializes to JSON (see `JSON`), so that tools can act on
+// it.
+type Report struct {
+	Warnings []Warning     `json:"warnings"`
+	Stats    Stats         `json:"stats"`
+	Timings  []PhaseTiming `json:"timings"`
+	// Changelog is set if `Options.PreviousNameMap` is.
+	Changelog *Changelog `json:"changelog,omitempty"`
+}
+
+// PhaseTiming records how long one phase of generation (e.g., model
+// construction, or emission) took.
+type PhaseTiming struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration"` // In nanoseconds.
+}
+
+// Stats summarizes the size of the library that was generated, e.g.,
+// how many API objects and property methods it contains. Hidden
+// objects (i.e., those that are not top-level API objects) are counted
+// separately from top-level objects.
+type Stats struct {
+	Groups          int `json:"groups"`
+	VersionedAPIs   int `json:"versionedAPIs"`
+	TopLevelObjects int `json:"topLevelObjects"`
+	HiddenObjects   int `json:"hiddenObjects"`
+	Properties      int `json:"properties"`
+	TypeAliases     int `json:"typeAliases"`
+}
+
+// Warning describes a single non-fatal decision made while generating
+// ksonnet-lib, along with the definition it pertains to (e.g.,
+// `io.k8s.kubernetes.pkg.api.v1.Container`), if any. Warnings about
+// the spec itself, found by `kubespec.Validate` before generation, also
+// record the `Check` that found them.
+type Warning struct {
+	Path     kubespec.DefinitionName `json:"path"`
+	Message  string                  `json:"message"`
+	Severity Severity                `json:"severity"`
+	Check    kubespec.Check          `json:"check,omitempty"`
+}
+
+// Severity is how much a `Warning` matters: `SeverityWarning` if the
+// library still has everything the spec describes, if only renamed
+// or coerced (e.g., a type alias renamed to avoid a collision), and
+// `SeverityError` if something was left out of it.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// The levels of `Report.Fails`.
+const (
+	FailOnNever   = "never"
+	FailOnWarning = "warning"
+	FailOnError   = "error"
+)
+
+// FailOnLevels lists the levels `Report.Fails` accepts.
+var FailOnLevels = []string{FailOnNever, FailOnWarning, FailOnError}
+
+func (w Warning) String() string {
+	if w.Path == "" {
+		return w.Message
+	}
+	return fmt.Sprintf("%s: %s", w.Path, w.Message)
+}
+
+func newReport() *Report {
+	return &Report{
+		Warnings: []Warning{},
+	}
+}
+
+func (r *Report) warnf(
+	path kubespec.DefinitionName, format string, args ...interface{},
+) {
+	r.Warnings = append(r.Warnings, Warning{
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+		Severity: SeverityWarning,
+	})
+}
+
+func (r *Report) errorf(
+	path kubespec.DefinitionName, format string, args ...interface{},
+) {
+	r.Warnings = append(r.Warnings, Warning{
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+		Severity: SeverityError,
+	})
+}
+
+// CheckFailOn returns an error if `failOn` is not one of
+// `FailOnLevels`.
+func CheckFailOn(failOn string) error {
+	for _, level := range FailOnLevels {
+		if failOn == level {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"Unrecognized fail-on level '%s'; expected one of: %v", failOn, FailOnLevels)
+}
+
+// Fails reports whether, under the policy `failOn`, the report should
+// fail the run: never for `FailOnNever`, if it has any warnings for
+// `FailOnWarning`, and if it has any warnings with `SeverityError` for
+// `FailOnError`.
+func (r *Report) Fails(failOn string) (bool, error) {
+	if err := CheckFailOn(failOn); err != nil {
+		return false, err
+	}
+	for _, w := range r.Warnings {
+		switch {
+		case failOn == FailOnWarning:
+			return true, nil
+		case failOn == FailOnError && w.Severity == SeverityError:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// JSON serializes the report, e.g., for the `--report` flag of
+// `ksonnet-gen`.
+func (r *Report) JSON() ([]byte, error) {
+	text, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(text, '\n'), nil
+}
+
+// `validate` runs `kubespec.Validate` over `spec`, and records the
+// problems it finds: definitions that were left out of the library
+// (i.e., those that have no version) as warnings, and those that keep
+// the library from being generated at all as errors.
+func (r *Report) validate(spec *kubespec.APISpec) (ok bool) {
+	ok = true
+	for _, problem := range kubespec.Validate(spec) {
+		severity := SeverityError
+		if problem.Check == kubespec.CheckMissingVersion {
+			severity = SeverityWarning
+		} else {
+			ok = false
+		}
+		r.Warnings = append(r.Warnings, Warning{
+			Path:     problem.Definition,
+			Message:  problem.Message,
+			Severity: severity,
+			Check:    problem.Check,
+		})
+	}
+	return ok
+}
+
+func (r *Report) addTiming(phase string, start time.Time) {
+	r.Timings = append(r.Timings, PhaseTiming{
+		Phase:    phase,
+		Duration: time.Since(start),
+	})
+}
@@ -0,0 +1,69 @@
+package ksonnet
+
+import (
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubeversion"
+)
+
+// `requiredParams` returns the parameters of a constructor that takes
+// the properties listed in the `required` of the object's definition,
+// in the order they are listed. Each is set with its setter, or, if it
+// `$ref`s another API object, with the `mixinInstance` of its `mixin`
+// namespace, e.g., for `Event`:
+//
+//	new(metadata, involvedObject):: apiVersion + kind + self.mixin.metadata.mixinInstance(metadata) + ...
+//
+// `apiVersion` and `kind` are set by every constructor anyway, and
+// required properties that are not in the library (e.g., because they
+// are blacklisted) are left out, and reported if `reportMissing` is
+// set.
+//
+// Required properties that have a `default` come last, followed by
+// the other properties that have one (see `defaultParams`), so that
+// the parameters without a default can be passed positionally.
+func (ao *apiObject) requiredParams(reportMissing bool) []kubeversion.CustomConstructorParam {
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	params := []kubeversion.CustomConstructorParam{}
+	defaulted := []kubeversion.CustomConstructorParam{}
+	included := make(map[kubespec.PropertyName]bool)
+	for _, name := range ao.required {
+		if isSpecialProperty(name) {
+			continue
+		}
+
+		var pm *property
+		for _, emitted := range ao.emittedProperties {
+			if emitted.name == name && emitted.kind == method {
+				pm = emitted
+			}
+		}
+		if pm == nil {
+			if !reportMissing {
+				continue
+			}
+			ao.root().report.warnf(
+				ao.parsedName.Unparse(),
+				"required property '%s' is not a parameter of the constructor, because it is not in the library",
+				name)
+			continue
+		}
+
+		relativePath := string(name)
+		param := kubeversion.CustomConstructorParam{
+			ID:           string(jsonnet.RewriteAsFuncParam(naming, k8sVersion, name)),
+			RelativePath: &relativePath,
+		}
+		included[name] = true
+		if pm.defaultValue == nil {
+			params = append(params, param)
+			continue
+		}
+		defaultValue := jsonnetValue(pm.defaultValue)
+		param.DefaultValue = &defaultValue
+		defaulted = append(defaulted, param)
+	}
+	params = append(params, defaulted...)
+	return append(params, ao.defaultParams(included)...)
+}
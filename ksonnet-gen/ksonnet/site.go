@@ -0,0 +1,155 @@
+package ksonnet
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SiteDir is the directory `Options.Site` causes the reference site
+// to be emitted to.
+const SiteDir = "site"
+
+// `siteStyle` is the stylesheet every page of the site links to.
+const siteStyle = `body { font-family: sans-serif; max-width: 60em; margin: 0 auto; padding: 1em; }
+code { background: #f4f4f4; padding: 0 0.2em; }
+input { width: 100%; padding: 0.4em; margin: 1em 0; font-size: 1em; }
+section { border-top: 1px solid #ddd; padding: 0.5em 0; }
+h3 a { color: inherit; text-decoration: none; }
+.type { color: #555; }
+`
+
+// `siteSearch` filters the elements of a page that have a
+// `data-search` attribute, hiding those whose value doesn't contain
+// the text of the search box.
+const siteSearch = `<script>
+document.getElementById("search").addEventListener("input", function(e) {
+  var query = e.target.value.toLowerCase();
+  document.querySelectorAll("[data-search]").forEach(function(el) {
+    el.hidden = el.getAttribute("data-search").indexOf(query) < 0;
+  });
+});
+</script>
+`
+
+var codeSpan = regexp.MustCompile("`([^`]+)`")
+
+// `emitSite` returns a static HTML reference site for the library,
+// built from the same documentation as `emitDocs`: a page per
+// top-level object (e.g., `site/apps/v1beta1/Deployment.html`), with
+// an anchor per function, and `site/index.html`, which lists the pages
+// by group and version. Both can be searched, by kind and by function
+// respectively.
+func (root *root) emitSite() map[string][]byte {
+	files := map[string][]byte{
+		path.Join(SiteDir, "style.css"): []byte(siteStyle),
+	}
+	title := fmt.Sprintf("Kubernetes %s", root.spec.Info.Version)
+
+	var index bytes.Buffer
+	writeSiteHeader(&index, title, "", "Search kinds")
+	group, version := "", ""
+	for _, kind := range root.docs() {
+		if kind.object.Group != group {
+			if group != "" {
+				index.WriteString("</ul>\n")
+			}
+			group, version = kind.object.Group, ""
+			fmt.Fprintf(&index, "<h2 id=\"%s\">%s</h2>\n", html.EscapeString(group), html.EscapeString(group))
+		}
+		if kind.object.Version != version {
+			if version != "" {
+				index.WriteString("</ul>\n")
+			}
+			version = kind.object.Version
+			groupVersion := fmt.Sprintf("%s/%s", group, version)
+			fmt.Fprintf(&index, "<h3 id=\"%s\">%s</h3>\n<ul>\n",
+				html.EscapeString(groupVersion), html.EscapeString(groupVersion))
+		}
+
+		page := path.Join(group, version, fmt.Sprintf("%s.html", kind.object.Kind))
+		fmt.Fprintf(&index, "<li data-search=\"%s\"><a href=\"%s\">%s</a></li>\n",
+			html.EscapeString(strings.ToLower(kind.object.Path)),
+			html.EscapeString(page), html.EscapeString(kind.object.Kind))
+		files[path.Join(SiteDir, page)] = kind.html(title)
+	}
+	if group != "" {
+		index.WriteString("</ul>\n")
+	}
+	writeSiteFooter(&index)
+	files[path.Join(SiteDir, "index.html")] = index.Bytes()
+	return files
+}
+
+// `html` returns the page of `kind` on the site (see `emitSite`),
+// whose title is `title`.
+func (kind *kindDoc) html(title string) []byte {
+	var b bytes.Buffer
+	writeSiteHeader(&b, fmt.Sprintf("%s - %s", kind.object.Kind, title), "../../", "Search functions")
+	fmt.Fprintf(&b, "<p><a href=\"../../index.html\">%s</a></p>\n", html.EscapeString(title))
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(kind.object.Kind))
+	fmt.Fprintf(&b, "<p><code>%s</code> (<code>%s/%s</code>)</p>\n",
+		html.EscapeString(kind.object.Path),
+		html.EscapeString(kind.object.Group), html.EscapeString(kind.object.Version))
+	kind.description.emitHTML(&b)
+
+	sections := []struct {
+		title     string
+		functions []functionDoc
+	}{
+		{"Constructors", kind.constructors},
+		{"Setters", kind.setters},
+		{"Mixins", kind.mixins},
+	}
+	for _, section := range sections {
+		if len(section.functions) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", section.title)
+		for _, fn := range section.functions {
+			anchor := strings.TrimPrefix(fn.name.Path, kind.object.Path+".")
+			signature := strings.TrimPrefix(fn.name.signature(), kind.object.Path+".")
+			fmt.Fprintf(&b, "<section id=\"%s\" data-search=\"%s\">\n",
+				html.EscapeString(anchor), html.EscapeString(strings.ToLower(anchor)))
+			fmt.Fprintf(&b, "<h3><a href=\"#%s\"><code>%s</code></a></h3>\n",
+				html.EscapeString(anchor), html.EscapeString(signature))
+			if fn.typeName != "" {
+				fmt.Fprintf(&b, "<p class=\"type\">Property: <code>%s</code>, of type <code>%s</code>.</p>\n",
+					html.EscapeString(fn.name.Field), html.EscapeString(fn.typeName))
+			}
+			fn.description.emitHTML(&b)
+			b.WriteString("</section>\n")
+		}
+	}
+	writeSiteFooter(&b)
+	return b.Bytes()
+}
+
+// `writeSiteHeader` writes the start of a page of the site, up to a
+// search box labeled `search`. `rootPath` is the path from the page to
+// the root of the site.
+func writeSiteHeader(b *bytes.Buffer, title, rootPath, search string) {
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(b, "<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(b, "<link rel=\"stylesheet\" href=\"%sstyle.css\">\n", rootPath)
+	b.WriteString("</head>\n<body>\n")
+	fmt.Fprintf(b, "<input id=\"search\" type=\"search\" placeholder=\"%s\" autofocus>\n", search)
+}
+
+// `writeSiteFooter` writes the end of a page of the site.
+func writeSiteFooter(b *bytes.Buffer) {
+	b.WriteString(siteSearch)
+	b.WriteString("</body>\n</html>\n")
+}
+
+// `emitHTML` writes `cs` as an HTML paragraph, if there are any, with
+// the text between backticks as code.
+func (cs comments) emitHTML(b *bytes.Buffer) {
+	text := strings.TrimSpace(strings.Join(cs, "\n"))
+	if text != "" {
+		fmt.Fprintf(b, "<p>%s</p>\n", codeSpan.ReplaceAllString(html.EscapeString(text), "<code>$1</code>"))
+	}
+}
@@ -0,0 +1,81 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// SkippedFile is the name of the file `Options.SkippedReport` causes
+// to be emitted.
+const SkippedFile = "skipped.json"
+
+// SkipReason is why a definition or property of the spec is not (or
+// not fully) in the library.
+type SkipReason string
+
+const (
+	// SkipBlacklisted is the reason of properties blacklisted for the
+	// Kubernetes version in `kubeversion` (e.g., `status`).
+	SkipBlacklisted SkipReason = "blacklisted"
+	// SkipNoVersion is the reason of definitions whose name has no
+	// version (e.g., `io.k8s.apimachinery.pkg.runtime.RawExtension`),
+	// which have no place in the library, and of the properties that
+	// refer to them.
+	SkipNoVersion SkipReason = "noVersion"
+	// SkipUnsupportedType is the reason of properties whose type isn't
+	// supported. They still get a setter, but it accepts any value,
+	// and they get no mixin.
+	SkipUnsupportedType SkipReason = "unsupportedType"
+)
+
+// Skipped lists what the library generated from a spec leaves out of
+// it, so that it can be audited.
+type Skipped struct {
+	KubernetesVersion string         `json:"kubernetesVersion"`
+	Entries           []SkippedEntry `json:"entries"`
+}
+
+// SkippedEntry is a definition or property of the spec that was left
+// out of the library.
+type SkippedEntry struct {
+	Definition kubespec.DefinitionName `json:"definition"`
+	// Property is the property of `Definition` that was left out, or ""
+	// if the whole definition was.
+	Property kubespec.PropertyName `json:"property,omitempty"`
+	Reason   SkipReason            `json:"reason"`
+	Detail   string                `json:"detail"`
+}
+
+// `skip` records that the property `prop` of the definition `def` (or
+// the whole definition, if `prop` is "") was left out of the library,
+// and why.
+func (root *root) skip(
+	def kubespec.DefinitionName, prop kubespec.PropertyName, reason SkipReason,
+	format string, args ...interface{},
+) {
+	root.skipped = append(root.skipped, SkippedEntry{
+		Definition: def,
+		Property:   prop,
+		Reason:     reason,
+		Detail:     fmt.Sprintf(format, args...),
+	})
+}
+
+// `emitSkipped` returns the text of the report of what the library
+// leaves out, sorted by definition and property.
+func (root *root) emitSkipped() ([]byte, error) {
+	entries := append([]SkippedEntry{}, root.skipped...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Definition != entries[j].Definition {
+			return entries[i].Definition < entries[j].Definition
+		}
+		return entries[i].Property < entries[j].Property
+	})
+	return json.MarshalIndent(Skipped{
+		KubernetesVersion: root.spec.Info.Version,
+		Entries:           entries,
+	}, "", "  ")
+}
@@ -0,0 +1,100 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubeversion"
+)
+
+// SnippetsFile is the name of the file `Options.Snippets` causes to be
+// emitted, which VS Code loads as snippets if it is copied to the
+// `.vscode` directory of a project.
+const SnippetsFile = "k8s.code-snippets"
+
+// `snippetTypes` are the kinds that are not top-level, but that code
+// builds often enough to get snippets, through the type alias that
+// reaches them from a common kind (see `typeAliasPaths`).
+var snippetTypes = []kubespec.ObjectKind{
+	"Container", "ContainerPort", "EnvVar", "ServicePort", "Volume", "VolumeMount",
+}
+
+// Snippet is a VS Code snippet, as in a `.code-snippets` file.
+type Snippet struct {
+	Scope       string   `json:"scope"`
+	Prefix      string   `json:"prefix"`
+	Body        []string `json:"body"`
+	Description string   `json:"description"`
+}
+
+// `emitSnippets` returns the text of the snippets of the library:
+// one for every constructor of the preferred version of the common
+// kinds (those of `shortNames`, see `preferredObjectOfKind`), e.g.,
+// `k.deployment.new`, and of the kinds of `snippetTypes` they reach,
+// e.g., `k.container.new`, which expands to
+// `k.core.v1.pod.mixin.spec.containersType.new(name, image)`, with a
+// tab stop for each parameter without a default.
+func (root *root) emitSnippets() ([]byte, error) {
+	kinds := []string{}
+	for _, kind := range shortNames {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+
+	objects := []*apiObject{}
+	paths := make(map[*apiObject]string)
+	for _, kind := range kinds {
+		if ao := root.preferredObjectOfKind(kubespec.ObjectKind(kind)); ao != nil {
+			objects = append(objects, ao)
+			paths[ao] = fmt.Sprintf("%s.%s.%s", ao.parent.parent.identifier(), ao.parent.version, ao.jsonnetName)
+		}
+	}
+	snippetObjects := append([]*apiObject{}, objects...)
+	for ao, path := range root.typeAliasPaths(objects) {
+		for _, kind := range snippetTypes {
+			if ao.name == kind {
+				snippetObjects = append(snippetObjects, ao)
+				paths[ao] = path
+			}
+		}
+	}
+
+	snippets := make(map[string]Snippet)
+	for _, ao := range snippetObjects {
+		for _, spec := range ao.constructorSpecs() {
+			args := []string{}
+			for _, param := range spec.Params {
+				if param.DefaultValue == nil {
+					args = append(args, fmt.Sprintf("${%d:%s}", len(args)+1, param.ID))
+				}
+			}
+			path := fmt.Sprintf("%s.%s", paths[ao], spec.ID)
+			snippets[path] = Snippet{
+				Scope:  "jsonnet",
+				Prefix: fmt.Sprintf("k.%s.%s", ao.jsonnetName, spec.ID),
+				Body:   []string{fmt.Sprintf("k.%s(%s)", path, strings.Join(args, ", "))},
+				Description: fmt.Sprintf(
+					"%s (`%s`).", strings.TrimSuffix(ao.constructorHelp()[0], "."), ao.parent.apiVersion()),
+			}
+		}
+	}
+	return json.MarshalIndent(snippets, "", "  ")
+}
+
+// `constructorSpecs` returns the constructors `emitConstructors`
+// emits for `ao`.
+func (ao *apiObject) constructorSpecs() []kubeversion.CustomConstructorSpec {
+	named, specs := ao.constructors()
+	switch {
+	case named != "":
+		return append(specs, kubeversion.CustomConstructorSpec{ID: named, Params: namedConstructorParams()})
+	case specs != nil:
+		return specs
+	case ao.root().options.RequiredConstructors:
+		return []kubeversion.CustomConstructorSpec{{ID: constructorName, Params: ao.requiredParams(false)}}
+	}
+	return []kubeversion.CustomConstructorSpec{{ID: constructorName}}
+}
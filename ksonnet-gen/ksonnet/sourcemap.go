@@ -0,0 +1,101 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// SourceMapFile is the name of the file `Options.SourceMap` causes to
+// be emitted.
+const SourceMapFile = "sourcemap.json"
+
+// SourceMap maps the lines of the files of a generated library back to
+// the definitions and properties of the spec they were generated from.
+type SourceMap struct {
+	KubernetesVersion string           `json:"kubernetesVersion"`
+	Entries           []SourceMapEntry `json:"entries"`
+}
+
+// SourceMapEntry is a range of lines of a file that were generated
+// from an API object, or from one of its properties. Ranges nest: the
+// range of a property is inside that of its object, so the innermost
+// range that contains a line is the most specific.
+type SourceMapEntry struct {
+	File      string `json:"file"`      // e.g., `k8s.libsonnet`.
+	StartLine int    `json:"startLine"` // First line, counting from 1.
+	EndLine   int    `json:"endLine"`   // Last line, inclusive.
+	// Path is the path of the generated function or namespace in the
+	// object `File` evaluates to, e.g.,
+	// `apps.v1beta1.deployment.mixin.spec.withReplicas` in
+	// `k8s.libsonnet`, or `deployment.mixin.spec.withReplicas` in
+	// `apps/v1beta1.libsonnet` of a split library.
+	Path string `json:"path"`
+	// Definition is the definition the lines were generated from,
+	// e.g., `io.k8s.kubernetes.pkg.apis.apps.v1beta1.DeploymentSpec`.
+	Definition string `json:"definition"`
+	// Property is the property of `Definition` the lines were
+	// generated from (e.g., `replicas`), or "" for the object itself.
+	Property string `json:"property,omitempty"`
+}
+
+// `sourceRange` is a range of lines an `indentWriter` wrote for a
+// definition, or one of its properties (see `indentWriter.source`).
+type sourceRange struct {
+	startLine, endLine int
+	definition         kubespec.DefinitionName
+	property           kubespec.PropertyName
+}
+
+// `addSources` adds the ranges of lines `m` wrote for definitions to
+// the source map, as those of the file `name`, if `Options.SourceMap`
+// is set. It must be called with the text of the file, once it is
+// complete.
+func (root *root) addSources(name string, m *indentWriter, text []byte) {
+	if !root.options.SourceMap {
+		return
+	}
+
+	lines := strings.Split(string(text), "\n")
+	for _, r := range m.sources {
+		if r.endLine < r.startLine {
+			continue
+		}
+		root.sourceMap = append(root.sourceMap, SourceMapEntry{
+			File:       name,
+			StartLine:  r.startLine,
+			EndLine:    r.endLine,
+			Path:       strings.Join(sourcePath(lines, r), "."),
+			Definition: string(r.definition),
+			Property:   string(r.property),
+		})
+	}
+}
+
+// `sourcePath` returns the path of the function or namespace that the
+// range `r` of `lines` defines: that of the fields enclosing the first
+// line of the range that opens a field, other than a docsonnet
+// annotation (e.g., `"#withReplicas"`), or, if there is none (e.g., a
+// range of only comments), that of its first line.
+func sourcePath(lines []string, r sourceRange) []string {
+	for n := r.startLine; n <= r.endLine; n++ {
+		match := fieldOpenPattern.FindStringSubmatch(strings.TrimSpace(lines[n-1]))
+		if match != nil && !strings.HasPrefix(strings.Trim(match[1], "\""), "#") {
+			return enclosingFields(lines, n)
+		}
+	}
+	return enclosingFields(lines, r.startLine)
+}
+
+// `emitSourceMap` returns the text of the source map of the library.
+func (root *root) emitSourceMap() ([]byte, error) {
+	sourceMap := SourceMap{
+		KubernetesVersion: root.spec.Info.Version,
+		Entries:           root.sourceMap,
+	}
+	if sourceMap.Entries == nil {
+		sourceMap.Entries = []SourceMapEntry{}
+	}
+	return json.MarshalIndent(sourceMap, "", "  ")
+}
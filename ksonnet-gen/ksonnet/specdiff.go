@@ -0,0 +1,228 @@
+package ksonnet
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// SpecDiff lists the API objects and properties that were added to,
+// removed from, or changed in a spec since a previous version of it
+// (e.g., between two Kubernetes releases), so that maintainers can
+// review what regenerating ksonnet-lib from it will change. Unlike
+// `Changelog`, which compares the functions of two generated
+// libraries, it compares the specs themselves.
+//
+// Objects are identified by group, version, and kind (e.g.,
+// `apps.v1beta1.Deployment`), and properties by their object and name
+// (e.g., `apps.v1beta1.Deployment.spec`).
+type SpecDiff struct {
+	From              string // Kubernetes version of the previous spec.
+	To                string // Kubernetes version of the current spec.
+	AddedObjects      []string
+	RemovedObjects    []string
+	ChangedObjects    []SpecChange
+	AddedProperties   []string
+	RemovedProperties []string
+	ChangedProperties []SpecChange
+}
+
+// SpecChange is an object or property that is in both specs, but
+// differs between them, e.g., a property whose type changed.
+type SpecChange struct {
+	Path     string
+	Previous string
+	Current  string
+}
+
+// `specObject` summarizes an API object for `DiffSpecs`.
+type specObject struct {
+	description string
+	properties  map[string]string // property name -> type description.
+}
+
+// DiffSpecs compares two specs, building the same model of each that
+// the library is generated from. It fails if either spec fails
+// validation (see `kubespec.Validate`).
+func DiffSpecs(previous, current *kubespec.APISpec) (*SpecDiff, error) {
+	previousObjects, err := specObjects(previous)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read previous spec:\n%v", err)
+	}
+	currentObjects, err := specObjects(current)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read current spec:\n%v", err)
+	}
+
+	diff := SpecDiff{
+		From:              previous.Info.Version,
+		To:                current.Info.Version,
+		AddedObjects:      []string{},
+		RemovedObjects:    []string{},
+		ChangedObjects:    []SpecChange{},
+		AddedProperties:   []string{},
+		RemovedProperties: []string{},
+		ChangedProperties: []SpecChange{},
+	}
+	for _, path := range sortedObjectPaths(currentObjects) {
+		object := currentObjects[path]
+		old, ok := previousObjects[path]
+		if !ok {
+			diff.AddedObjects = append(diff.AddedObjects, path)
+			continue
+		}
+		if old.description != object.description {
+			diff.ChangedObjects = append(diff.ChangedObjects, SpecChange{
+				Path: path, Previous: old.description, Current: object.description,
+			})
+		}
+
+		for _, name := range sortedKeys(object.properties) {
+			propPath := fmt.Sprintf("%s.%s", path, name)
+			oldType, ok := old.properties[name]
+			switch {
+			case !ok:
+				diff.AddedProperties = append(diff.AddedProperties, propPath)
+			case oldType != object.properties[name]:
+				diff.ChangedProperties = append(diff.ChangedProperties, SpecChange{
+					Path: propPath, Previous: oldType, Current: object.properties[name],
+				})
+			}
+		}
+		for _, name := range sortedKeys(old.properties) {
+			if _, ok := object.properties[name]; !ok {
+				diff.RemovedProperties = append(diff.RemovedProperties, fmt.Sprintf("%s.%s", path, name))
+			}
+		}
+	}
+	for _, path := range sortedObjectPaths(previousObjects) {
+		if _, ok := currentObjects[path]; !ok {
+			diff.RemovedObjects = append(diff.RemovedObjects, path)
+		}
+	}
+	return &diff, nil
+}
+
+// `specObjects` builds the model of `spec`, and summarizes every API
+// object in it, keyed by group, version, and kind.
+func specObjects(spec *kubespec.APISpec) (map[string]specObject, error) {
+	kubespec.Normalize(spec)
+	validation := newReport()
+	if !validation.validate(spec) {
+		problems := []string{}
+		for _, w := range validation.Warnings {
+			if w.Severity == SeverityError {
+				problems = append(problems, w.String())
+			}
+		}
+		return nil, fmt.Errorf("Spec failed validation:\n%s", strings.Join(problems, "\n"))
+	}
+
+	root, err := newRoot(spec, nil, nil, Options{})
+	if err != nil {
+		return nil, err
+	}
+	objects := make(map[string]specObject)
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for groupName, group := range groups {
+			for version, versionedAPI := range group.versionedAPIs {
+				for kind, ao := range versionedAPI.apiObjects {
+					object := specObject{
+						description: "a type",
+						properties:  make(map[string]string),
+					}
+					if ao.isTopLevel {
+						object.description = "a top-level object"
+					}
+					if len(ao.required) > 0 {
+						required := []string{}
+						for _, name := range ao.required {
+							required = append(required, string(name))
+						}
+						sort.Strings(required)
+						object.description += fmt.Sprintf(
+							" requiring `%s`", strings.Join(required, "`, `"))
+					}
+					for name, p := range ao.properties {
+						if p.kind == typeAlias {
+							continue
+						}
+						object.properties[string(name)] = describeSchema(&kubespec.Property{
+							Type:  p.schemaType,
+							Ref:   p.ref,
+							Items: p.itemTypes,
+						})
+					}
+					objects[fmt.Sprintf("%s.%s.%s", groupName, version, kind)] = object
+				}
+			}
+		}
+	}
+	return objects, nil
+}
+
+func sortedObjectPaths(objects map[string]specObject) []string {
+	paths := []string{}
+	for path := range objects {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Empty reports whether the specs have the same objects and
+// properties.
+func (d *SpecDiff) Empty() bool {
+	return len(d.AddedObjects) == 0 && len(d.RemovedObjects) == 0 &&
+		len(d.ChangedObjects) == 0 && len(d.AddedProperties) == 0 &&
+		len(d.RemovedProperties) == 0 && len(d.ChangedProperties) == 0
+}
+
+// Markdown renders the diff as a Markdown document.
+func (d *SpecDiff) Markdown() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Spec changes from Kubernetes %s to %s\n", d.From, d.To)
+	if d.Empty() {
+		fmt.Fprintf(&b, "\nNo objects or properties changed.\n")
+		return b.Bytes()
+	}
+
+	writePaths := func(title string, paths []string) {
+		if len(paths) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", title, len(paths))
+		for _, path := range paths {
+			fmt.Fprintf(&b, "* `%s`\n", path)
+		}
+	}
+	writeChanges := func(title string, changes []SpecChange) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", title, len(changes))
+		for _, change := range changes {
+			fmt.Fprintf(&b, "* `%s` was %s, and is now %s\n", change.Path, change.Previous, change.Current)
+		}
+	}
+
+	writePaths("Removed objects", d.RemovedObjects)
+	writePaths("Removed properties", d.RemovedProperties)
+	writeChanges("Changed objects", d.ChangedObjects)
+	writeChanges("Changed properties", d.ChangedProperties)
+	writePaths("Added objects", d.AddedObjects)
+	writePaths("Added properties", d.AddedProperties)
+	return b.Bytes()
+}
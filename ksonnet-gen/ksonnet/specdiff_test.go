@@ -0,0 +1,76 @@
+package ksonnet
+
+import (
+	"strings"
+	"testing"
+)
+
+var previousDiffSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Deployment": {
+      "properties": {
+        "paused": {"type": "boolean"},
+        "replicas": {"type": "integer"},
+        "selector": {"type": "string"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Deployment"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Scale": {
+      "properties": {"replicas": {"type": "integer"}}
+    }
+  }
+}`
+
+var currentDiffSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Deployment": {
+      "required": ["selector"],
+      "properties": {
+        "minReadySeconds": {"type": "integer"},
+        "replicas": {"type": "integer"},
+        "selector": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.LabelSelector"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Deployment"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.LabelSelector": {
+      "properties": {"matchLabels": {"type": "object", "additionalProperties": {"type": "string"}}}
+    }
+  }
+}`
+
+func TestDiffSpecs(t *testing.T) {
+	diff, err := DiffSpecs(parseSpec(t, previousDiffSpec), parseSpec(t, currentDiffSpec))
+	if err != nil {
+		t.Fatalf("Failed to diff:\n%v", err)
+	}
+
+	markdown := string(diff.Markdown())
+	for _, expected := range []string{
+		"## Removed objects (1)\n\n* `apps.v1beta1.Scale`\n",
+		"## Removed properties (1)\n\n* `apps.v1beta1.Deployment.paused`\n",
+		"* `apps.v1beta1.Deployment` was a top-level object, and is now a top-level object requiring `selector`\n",
+		"* `apps.v1beta1.Deployment.selector` was a string, and is now a `LabelSelector`\n",
+		"## Added objects (1)\n\n* `apps.v1beta1.LabelSelector`\n",
+		"## Added properties (1)\n\n* `apps.v1beta1.Deployment.minReadySeconds`\n",
+	} {
+		if !strings.Contains(markdown, expected) {
+			t.Errorf("Expected '%s' in changelog:\n%s", expected, markdown)
+		}
+	}
+
+	same, err := DiffSpecs(parseSpec(t, currentDiffSpec), parseSpec(t, currentDiffSpec))
+	if err != nil {
+		t.Fatalf("Failed to diff:\n%v", err)
+	}
+	if !same.Empty() {
+		t.Errorf("Expected no changes between identical specs, got:\n%s", same.Markdown())
+	}
+
+	if _, err := DiffSpecs(parseSpec(t, previousDiffSpec), parseSpec(t, danglingRefSpec)); err == nil {
+		t.Error("Expected a spec that fails validation to fail the diff")
+	}
+}
@@ -0,0 +1,170 @@
+package ksonnet
+
+import (
+	"fmt"
+	"path"
+)
+
+// The layouts of `Options.Split`.
+const (
+	SplitGroup   = "group"
+	SplitVersion = "version"
+)
+
+// SplitLayouts lists the layouts `Options.Split` accepts.
+var SplitLayouts = []string{SplitGroup, SplitVersion}
+
+// `hiddenFile` is the file the hidden groups of a split library (see
+// `Options.Split`) are emitted to, which the file of every group
+// imports.
+const hiddenFile = "hidden.libsonnet"
+
+// CheckSplit returns an error if `layout` is not one of
+// `SplitLayouts`, or "".
+func CheckSplit(layout string) error {
+	if layout == "" {
+		return nil
+	}
+	for _, l := range SplitLayouts {
+		if layout == l {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unrecognized split layout '%s'; expected one of: %v", layout, SplitLayouts)
+}
+
+// `emitSplit` emits the library as one file per group (e.g.,
+// `apps.libsonnet`), and `hiddenFile`, rather than as a single
+// `k8s.libsonnet`, which becomes an index that imports them, so that
+// it can still be used as before:
+//
+//	{
+//	  apps:: import "apps.libsonnet",
+//	  ...
+//	}
+//
+// With `SplitVersion`, the file of each group is in turn an index
+// that imports a file per version, in a directory named after the
+// group:
+//
+//	{
+//	  v1beta1:: import "apps/v1beta1.libsonnet",
+//	}
+//
+// In a single file, the groups refer to the types they share through
+// `hidden`, a local of the library, and to the functions their mixins
+// call (e.g., `__mergeByKey`) through locals next to it. Split up,
+// every file imports `hidden` instead, and has its own copy of the
+// functions it calls.
+func (root *root) emitSplit() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	owners := map[string]string{
+		kFile:      "the helpers of the library",
+		k8sFile:    "the index",
+		hiddenFile: "the hidden types",
+	}
+	addFile := func(name, owner string, m *indentWriter) error {
+		if existing, ok := owners[name]; ok && existing != owner {
+			return fmt.Errorf(
+				"Could not split the library: %s would be written to '%s', which is %s",
+				owner, name, existing)
+		}
+		text, err := m.bytes()
+		if err != nil {
+			return err
+		}
+		root.addSources(name, m, text)
+		owners[name] = owner
+		files[name] = text
+		return nil
+	}
+
+	index := newASTWriter()
+	root.emitDocsonnetPackage(index, "k8s", root.docsonnetHelp())
+	for _, group := range root.groups.toSortedSlice() {
+		name := fmt.Sprintf("%s.libsonnet", group.identifier())
+		index.hidden(string(group.identifier()), fmt.Sprintf("import \"%s\"", name))
+
+		m := newIndentWriter()
+		if root.options.Split == SplitGroup {
+			root.emitSplitFile(m, hiddenFile, group.emitVersionedAPIs)
+			if err := addFile(name, fmt.Sprintf("group '%s'", group.name), m); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		versions := newASTWriter()
+		group.emitDocsonnetPackage(versions)
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			versionName := path.Join(
+				string(group.identifier()), fmt.Sprintf("%s.libsonnet", versionedAPI.version))
+			versions.hidden(string(versionedAPI.version), fmt.Sprintf("import \"%s\"", versionName))
+
+			vm := newIndentWriter()
+			root.emitSplitFile(vm, path.Join("..", hiddenFile), versionedAPI.emitObjects)
+			owner := fmt.Sprintf("version '%s' of group '%s'", versionedAPI.version, group.name)
+			if err := addFile(versionName, owner, vm); err != nil {
+				return nil, err
+			}
+		}
+		root.emitHeader(m)
+		emitObject(m, versions)
+		if err := addFile(name, fmt.Sprintf("group '%s'", group.name), m); err != nil {
+			return nil, err
+		}
+	}
+	root.emitRenderListHelper(index)
+	root.emitUtilHelpers(index)
+	root.emitTypes(index, fmt.Sprintf("(import \"%s\")", hiddenFile))
+	root.emitShortNames(index)
+	root.emitGVKLookup(index)
+	root.emitMetadata(index)
+	im := newIndentWriter()
+	root.emitHeader(im)
+	emitObject(im, index)
+	if err := addFile(k8sFile, "the index", im); err != nil {
+		return nil, err
+	}
+
+	hidden := newASTWriter()
+	hidden.local("hidden", "self")
+	root.emitDocsonnetImport(hidden)
+	root.emitSplitBody(hidden, func(m *astWriter) {
+		for _, hiddenGroup := range root.hiddenGroups.toSortedSlice() {
+			hiddenGroup.emit(m)
+		}
+		root.emitPodTemplateMixins(m)
+		root.emitQuantityFunctions(m)
+	})
+	hm := newIndentWriter()
+	root.emitHeader(hm)
+	emitObject(hm, hidden)
+	if err := addFile(hiddenFile, "the hidden types", hm); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// `emitSplitFile` emits the file of a group or version of a split
+// library, whose members `emitMembers` adds, and which imports
+// `hiddenFile` from `hiddenPath`, relative to itself.
+func (root *root) emitSplitFile(m *indentWriter, hiddenPath string, emitMembers func(m *astWriter)) {
+	file := newASTWriter()
+	file.local("hidden", fmt.Sprintf("import \"%s\"", hiddenPath))
+	body := file.nested(func(ns *astWriter) {
+		root.emitSplitBody(ns, emitMembers)
+	})
+	root.emitHeader(m)
+	emitFile(m, file, body)
+}
+
+// `emitSplitBody` calls `emitMembers`, which adds the members of an
+// object to `m`, and then adds the functions they call, if any.
+func (root *root) emitSplitBody(m *astWriter, emitMembers func(m *astWriter)) {
+	root.usesMergeByKey, root.usesMergeSet = false, false
+	emitMembers(m)
+	root.emitMergeByKeyFunction(m)
+	root.emitMergeSetFunction(m)
+}
@@ -0,0 +1,162 @@
+package ksonnet
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+const starlarkFile = "k8s.star"
+
+// `emitStarlark` is a backend that emits a Starlark module, `k8s.star`,
+// for use with Bazel or other Starlark-based configuration tools.
+//
+// The module contains one builder function per API object, named for
+// its group, version, and kind (e.g., `apps_v1beta1_deployment`), which
+// takes every property of the object as an optional keyword argument
+// and returns the object as a dict. Top-level objects have their
+// `apiVersion` and `kind` filled in automatically. For example:
+//
+//	apps_v1beta1_deployment(
+//	  metadata = meta_v1_object_meta(name = "nginx"),
+//	  spec = apps_v1beta1_deployment_spec(replicas = 2))
+func emitStarlark(root *root) (map[string][]byte, error) {
+	m := newIndentWriter()
+
+	root.emitCommentHeader(m, "#")
+
+	m.writeLine("def _build(fields):")
+	m.indent()
+	m.writeLine("\"\"\"Returns `fields` without the entries whose value is `None`.\"\"\"")
+	m.writeLine("return {k: v for k, v in fields.items() if v != None}")
+	m.dedent()
+
+	for _, object := range root.sortedAPIObjects() {
+		m.writeLine("")
+		object.emitStarlark(m)
+	}
+
+	k8sBytes, err := m.bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{starlarkFile: k8sBytes}, nil
+}
+
+// `sortedAPIObjects` returns every API object in `root`, with the
+// top-level objects first, each sorted by group, version, and kind.
+func (root *root) sortedAPIObjects() apiObjectSlice {
+	objects := apiObjectSlice{}
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for _, group := range groups.toSortedSlice() {
+			for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+				objects = append(objects, versionedAPI.apiObjects.toSortedSlice()...)
+			}
+		}
+	}
+	return objects
+}
+
+func (ao *apiObject) emitStarlark(m *indentWriter) {
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	groupName := jsonnet.RewriteAsIdentifier(naming, k8sVersion, ao.parent.parent.name)
+	functionName := fmt.Sprintf(
+		"%s_%s_%s",
+		toSnakeCase(string(groupName)), ao.parent.version, toSnakeCase(string(ao.jsonnetName)))
+
+	params := []string{}
+	fields := []string{}
+	docs := []string{}
+	if ao.isTopLevel {
+		fields = append(
+			fields,
+			fmt.Sprintf("\"apiVersion\": %s,", starlarkString(ao.parent.apiVersion())),
+			fmt.Sprintf("\"kind\": %s,", starlarkString(string(ao.name))))
+	}
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == typeAlias || (ao.isTopLevel && isSpecialProperty(pm.name)) {
+			continue
+		}
+		param := starlarkParam(string(pm.name))
+		params = append(params, fmt.Sprintf("%s = None", param))
+		fields = append(
+			fields, fmt.Sprintf("%s: %s,", starlarkString(string(pm.name)), param))
+		docs = append(
+			docs, strings.TrimSpace(fmt.Sprintf("%s: %s", param, strings.Join(pm.comments, " "))))
+	}
+
+	m.writeLine(fmt.Sprintf("def %s(%s):", functionName, strings.Join(params, ", ")))
+	m.indent()
+
+	m.writeLine("\"\"\"" + starlarkDocString(fmt.Sprintf("Builds an object of kind `%s`.", ao.name)))
+	if len(ao.comments) > 0 && ao.comments[0] != "" {
+		m.writeLine("")
+		for _, comment := range ao.comments {
+			m.writeLine(starlarkDocString(comment))
+		}
+	}
+	if len(docs) > 0 {
+		m.writeLine("")
+		m.writeLine("Args:")
+		m.indent()
+		for _, doc := range docs {
+			m.writeLine(starlarkDocString(doc))
+		}
+		m.dedent()
+	}
+	m.writeLine("\"\"\"")
+
+	m.writeLine("return _build({")
+	m.indent()
+	for _, field := range fields {
+		m.writeLine(field)
+	}
+	m.dedent()
+	m.writeLine("})")
+
+	m.dedent()
+}
+
+var starlarkKeywords = map[string]bool{
+	"and": true, "as": true, "assert": true, "async": true, "await": true,
+	"break": true, "class": true, "continue": true, "def": true,
+	"del": true, "elif": true, "else": true, "except": true,
+	"finally": true, "for": true, "from": true, "global": true,
+	"if": true, "import": true, "in": true, "is": true, "lambda": true,
+	"load": true, "nonlocal": true, "not": true, "or": true, "pass": true,
+	"raise": true, "return": true, "try": true, "while": true,
+	"with": true, "yield": true,
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// `starlarkParam` converts a property name to a valid Starlark
+// parameter, e.g., `from` -> `from_`, and `$ref` -> `_ref`.
+func starlarkParam(name string) string {
+	param := nonIdentifierChars.ReplaceAllString(name, "_")
+	if starlarkKeywords[param] {
+		param += "_"
+	}
+	return param
+}
+
+func starlarkString(text string) string {
+	return fmt.Sprintf("%q", text)
+}
+
+func starlarkDocString(text string) string {
+	text = strings.Replace(text, "\\", "\\\\", -1)
+	return strings.Replace(text, "\"\"\"", "\\\"\\\"\\\"", -1)
+}
+
+var upperCaseRun = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+// `toSnakeCase` converts a lowerCamelCase identifier to snake_case,
+// e.g., `objectMeta` -> `object_meta`.
+func toSnakeCase(id string) string {
+	return strings.ToLower(upperCaseRun.ReplaceAllString(id, "${1}_${2}"))
+}
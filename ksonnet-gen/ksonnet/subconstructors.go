@@ -0,0 +1,64 @@
+package ksonnet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// `emitElementConstructor` emits, next to the type alias `typeName` of
+// an array property whose elements are hidden API objects (e.g.,
+// `containersType`), a constructor of one element (e.g.,
+// `newContainer(name, image)`), which takes the properties listed in the
+// `required` of the element's definition (see `requiredParams`), so
+// that users don't have to look up the path of the element's type to
+// create one, e.g.,
+//
+//	local spec = deployment.mixin.spec.template.spec;
+//	spec.withContainers(spec.newContainer("nginx", "nginx:1.13"))
+//
+// It is not emitted if the element has no required properties, or if
+// its name collides with a function of another property of the same
+// object.
+func (p *property) emitElementConstructor(m *astWriter, typeName jsonnet.Identifier) {
+	if p.itemTypes.Ref == nil || !isMixinRef(p.itemTypes.Ref) {
+		return
+	}
+	element := p.root().getAPIObject(p.root().parseRef(p.itemTypes.Ref))
+	if len(element.required) == 0 {
+		return
+	}
+	params := element.requiredParams(false)
+	if len(params) == 0 {
+		return
+	}
+
+	k8sVersion := p.root().spec.Info.Version
+	naming := p.root().naming
+	name := jsonnet.RewriteAsIdentifier(naming, k8sVersion, p.aliasOf).ToNewID(naming)
+	for _, sibling := range p.parent.emittedProperties {
+		if jsonnet.RewriteAsIdentifier(naming, k8sVersion, sibling.name) == name {
+			return
+		}
+	}
+
+	paramLiterals := []string{}
+	setters := []string{"{}"}
+	for _, param := range params {
+		if param.DefaultValue != nil {
+			paramLiterals = append(
+				paramLiterals, fmt.Sprintf("%s=%s", param.ID, *param.DefaultValue))
+		} else {
+			paramLiterals = append(paramLiterals, param.ID)
+		}
+		setters = append(setters, fmt.Sprintf(
+			"self.%s.%s(%s)", typeName, element.setterPath(*param.RelativePath), param.ID))
+	}
+
+	m.comment(fmt.Sprintf(
+		"Creates a new `%s`, an element of `%s`, from its required properties.",
+		element.name, p.aliasOf))
+	element.emitDocsonnetConstructor(m, string(name), params)
+	m.method(string(name), paramLiterals, strings.Join(setters, " + "))
+}
@@ -0,0 +1,333 @@
+package ksonnet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubeversion"
+)
+
+const (
+	testSuiteFile = "k8s_test.jsonnet"
+
+	// testSuiteSetters is the number of setters tested per object.
+	testSuiteSetters = 3
+)
+
+// `testCase` is a single test of the generated library, in the style
+// of jsonnetunit and testonnet: evaluating `actual` must produce
+// `expect`.
+type testCase struct {
+	name   string
+	actual string
+	expect string
+	// `call` is the call of the constructor a constructor test tests.
+	call string
+}
+
+// `emitTestSuite` emits a Jsonnet program, `k8s_test.jsonnet`, which
+// tests the constructors, a sample of the setters, and a mixin of
+// every top-level API object in the library, and the custom
+// constructors of the objects that are not top-level. Evaluating it produces
+// the number of tests that passed, or fails with the names of the
+// tests that didn't, so that users vendoring the library can check it
+// in their own pipelines.
+func (root *root) emitTestSuite() ([]byte, error) {
+	m := newIndentWriter()
+
+	m.writeLine("// AUTOGENERATED tests for k8s.libsonnet. DO NOT MODIFY.")
+	m.writeLine("//")
+	m.writeLine("// Run with `jsonnet k8s_test.jsonnet`.")
+	m.writeLine("")
+	m.writeLine("local k8s = import \"k8s.libsonnet\";")
+	m.writeLine("")
+	m.writeLine("local tests = {")
+	m.indent()
+	objects := []*apiObject{}
+	for _, group := range root.groups.toSortedSlice() {
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				objects = append(objects, ao)
+				emitTestCases(m, ao.testCases())
+			}
+		}
+	}
+
+	// Objects that are not top-level are tested through a type alias
+	// that reaches them, if they have custom constructors.
+	paths := root.typeAliasPaths(objects)
+	hidden := []string{}
+	byPath := make(map[string]*apiObject)
+	for ao, path := range paths {
+		if _, ok := kubeversion.ConstructorSpec(root.spec.Info.Version, ao.parsedName.Unparse()); ok {
+			hidden = append(hidden, path)
+			byPath[path] = ao
+		}
+	}
+	sort.Strings(hidden)
+	for _, path := range hidden {
+		ao := byPath[path]
+		for _, spec := range ao.constructorSpecs() {
+			if test, ok := ao.constructorTest(path, spec); ok {
+				emitTestCases(m, []testCase{test})
+			}
+		}
+	}
+	m.dedent()
+	m.writeLine("};")
+	m.writeLine("")
+	m.writeLine("local failures = [")
+	m.indent()
+	m.writeLine("name")
+	m.writeLine("for name in std.objectFields(tests)")
+	m.writeLine("if tests[name].actual != tests[name].expect")
+	m.dedent()
+	m.writeLine("];")
+	m.writeLine("")
+	m.writeLine("if std.length(failures) == 0 then")
+	m.writeLine("  {passed: std.length(std.objectFields(tests))}")
+	m.writeLine("else")
+	m.writeLine("  error \"FAILED: \" + std.join(\", \", failures)")
+
+	return m.bytes()
+}
+
+// `emitTestCases` emits `tests` as fields of the object of tests.
+func emitTestCases(m *indentWriter, tests []testCase) {
+	for _, test := range tests {
+		m.writeLine(fmt.Sprintf("\"%s\": {", test.name))
+		m.indent()
+		m.writeLine(fmt.Sprintf("actual: %s,", test.actual))
+		m.writeLine(fmt.Sprintf("expect: %s,", test.expect))
+		m.dedent()
+		m.writeLine("},")
+	}
+}
+
+// `testCases` returns the tests of a top-level API object: one per
+// constructor (see `constructorTest`), and, from the first constructor
+// that can be tested, a sample of its setters, and a setter of a
+// `mixin` namespace. It returns none if the object isn't top-level.
+func (ao *apiObject) testCases() []testCase {
+	k8sVersion := ao.root().spec.Info.Version
+	naming := ao.root().naming
+	if !ao.isTopLevel {
+		return nil
+	}
+
+	groupID := jsonnet.RewriteAsIdentifier(naming, k8sVersion, ao.parent.parent.name)
+	name := fmt.Sprintf("%s.%s.%s", groupID, ao.parent.version, ao.jsonnetName)
+	object := fmt.Sprintf("k8s.%s", name)
+
+	// `constructor` is the object the setters are tested on, and
+	// `base` is what it evaluates to.
+	var constructor, base string
+	tests := []testCase{}
+	named, _ := ao.constructors()
+	for _, spec := range ao.constructorSpecs() {
+		switch {
+		case spec.ID == named:
+			call := fmt.Sprintf("%s.%s(\"test\")", object, named)
+			literal := fmt.Sprintf(
+				"{apiVersion: \"%s\", kind: \"%s\", metadata: {name: \"test\"}}",
+				ao.parent.apiVersion(), ao.name)
+			tests = append(tests, testCase{
+				name:   fmt.Sprintf("%s.%s", name, named),
+				actual: call,
+				expect: literal,
+			}, testCase{
+				name:   fmt.Sprintf("%s.%s.namespace", name, named),
+				actual: fmt.Sprintf("%s.%s(\"test\", \"test\")", object, named),
+				expect: fmt.Sprintf(
+					"{apiVersion: \"%s\", kind: \"%s\", metadata: {name: \"test\", namespace: \"test\"}}",
+					ao.parent.apiVersion(), ao.name),
+			})
+			constructor, base = call, literal
+		case len(spec.Params) == 0:
+			call := fmt.Sprintf("%s.%s()", object, spec.ID)
+			literal := fmt.Sprintf(
+				"{apiVersion: \"%s\", kind: \"%s\"}", ao.parent.apiVersion(), ao.name)
+			tests = append(tests, testCase{
+				name:   fmt.Sprintf("%s.%s", name, spec.ID),
+				actual: call,
+				expect: literal,
+			})
+			if constructor == "" {
+				constructor, base = call, literal
+			}
+		default:
+			test, ok := ao.constructorTest(name, spec)
+			if !ok {
+				continue
+			}
+			tests = append(tests, test)
+			if constructor == "" {
+				// Whatever else the constructor sets, a setter must only
+				// add its property to it.
+				constructor, base = test.call, test.call
+			}
+		}
+	}
+	if constructor == "" {
+		return tests
+	}
+
+	setters := 0
+	for _, pm := range ao.emittedProperties {
+		if setters >= testSuiteSetters {
+			break
+		}
+		value, expected, ok := pm.testValue()
+		if !ok || isSpecialProperty(pm.name) {
+			continue
+		}
+
+		setter := jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name).ToSetterID(naming)
+		tests = append(tests, testCase{
+			name:   fmt.Sprintf("%s.%s", name, setter),
+			actual: fmt.Sprintf("%s + %s.%s(%s)", constructor, object, setter, value),
+			expect: fmt.Sprintf(
+				"%s + {%s: %s}", base, jsonnet.RewriteAsFieldKey(pm.name), expected),
+		})
+		setters++
+	}
+
+	// Test a setter in the first `mixin` namespace that has one.
+	for _, pm := range ao.emittedProperties {
+		if pm.kind == typeAlias || !isMixinRef(pm.ref) {
+			continue
+		}
+
+		ref := ao.root().getAPIObject(ao.root().parseRef(pm.ref))
+		for _, child := range ref.emittedProperties {
+			value, expected, ok := child.testValue()
+			if !ok || isSpecialProperty(child.name) {
+				continue
+			}
+
+			mixin := fmt.Sprintf(
+				"mixin.%s.%s",
+				jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name),
+				jsonnet.RewriteAsIdentifier(naming, k8sVersion, child.name).ToSetterID(naming))
+			tests = append(tests, testCase{
+				name:   fmt.Sprintf("%s.%s", name, mixin),
+				actual: fmt.Sprintf("%s + %s.%s(%s)", constructor, object, mixin, value),
+				expect: fmt.Sprintf(
+					"%s + {%s+: {%s: %s}}",
+					base, jsonnet.RewriteAsFieldKey(pm.name),
+					jsonnet.RewriteAsFieldKey(child.name), expected),
+			})
+			return tests
+		}
+	}
+
+	return tests
+}
+
+// `constructorTest` returns a test of the constructor `spec` of `ao`,
+// at the path `path` of the library, which passes it a value for each
+// parameter that has no default (see `constructorTestValue`), and
+// checks that each is set at the path of its property, along with
+// `apiVersion` and `kind`, for a top-level object. It returns false if
+// it can't make up a value for some parameter.
+func (ao *apiObject) constructorTest(
+	path string, spec kubeversion.CustomConstructorSpec,
+) (testCase, bool) {
+	args, actual, expect := []string{}, []string{}, []string{}
+	if ao.isTopLevel {
+		actual = append(actual, "o.apiVersion", "o.kind")
+		expect = append(expect, fmt.Sprintf("%q", ao.parent.apiVersion()), fmt.Sprintf("%q", ao.name))
+	}
+	for _, param := range spec.Params {
+		if param.DefaultValue != nil {
+			continue
+		}
+		field := param.ID
+		if param.RelativePath != nil {
+			field = *param.RelativePath
+		}
+		p := ao.propertyAt(field)
+		if p == nil {
+			return testCase{}, false
+		}
+		value, expected, ok := p.constructorTestValue()
+		if !ok {
+			return testCase{}, false
+		}
+
+		index := "o"
+		for _, segment := range strings.Split(field, ".") {
+			index += fmt.Sprintf("[%q]", segment)
+		}
+		args = append(args, value)
+		actual = append(actual, index)
+		expect = append(expect, expected)
+	}
+
+	call := fmt.Sprintf("k8s.%s.%s(%s)", path, spec.ID, strings.Join(args, ", "))
+	return testCase{
+		name:   fmt.Sprintf("%s.%s", path, spec.ID),
+		actual: fmt.Sprintf("local o = %s; [%s]", call, strings.Join(actual, ", ")),
+		expect: fmt.Sprintf("[%s]", strings.Join(expect, ", ")),
+		call:   call,
+	}, true
+}
+
+// `constructorTestValue` is `testValue`, for the parameter of a
+// constructor that sets `p`, which can also be an object (or an array
+// of objects), or an `IntOrString`.
+func (p *property) constructorTestValue() (string, string, bool) {
+	isObject := func(t *kubespec.SchemaType) bool { return t != nil && *t == "object" }
+	switch {
+	case p.intOrString:
+		return "\"test\"", "\"test\"", true
+	case isMixinRef(p.ref), p.ref == nil && isObject(p.schemaType):
+		return "{}", "{}", true
+	case p.ref == nil && p.schemaType != nil && *p.schemaType == "array" && isObject(p.itemTypes.Type):
+		return "{}", "[{}]", true
+	}
+	return p.testValue()
+}
+
+// `testValue` returns an argument to pass to the setter of a property
+// in a test, and the value the setter should give the property, or
+// false if the property's setter isn't one we can test generically.
+func (p *property) testValue() (string, string, bool) {
+	if p.kind == typeAlias || p.ref != nil || p.schemaType == nil {
+		return "", "", false
+	}
+
+	if *p.schemaType == "array" {
+		// Setters for arrays wrap single elements in an array.
+		var element string
+		if isMixinRef(p.itemTypes.Ref) {
+			element = "{}"
+		} else if p.itemTypes.Type != nil {
+			element = testValueOfType(*p.itemTypes.Type)
+		} else {
+			element = testValueOfType("string")
+		}
+		if element == "" {
+			return "", "", false
+		}
+		return element, fmt.Sprintf("[%s]", element), true
+	}
+
+	value := testValueOfType(*p.schemaType)
+	return value, value, value != ""
+}
+
+func testValueOfType(schemaType kubespec.SchemaType) string {
+	switch schemaType {
+	case "string":
+		return "\"test\""
+	case "integer", "number":
+		return "1"
+	case "boolean":
+		return "true"
+	}
+	return ""
+}
@@ -0,0 +1,117 @@
+package ksonnet
+
+import (
+	"fmt"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `typesNamespace` is the namespace `Options.ExposeTypes` exposes the
+// hidden groups in.
+const typesNamespace = "types"
+
+// `emitTypes` emits the `types` namespace, which exposes the hidden
+// groups (i.e., the API objects that aren't top-level, like `PodSpec`)
+// of `hidden`, which is an expression that evaluates to them, so that
+// users can build these objects directly, e.g.,
+// `k.types.core.v1.podSpec.new()`, rather than through the mixins of
+// the objects that embed them. It is not emitted if a group named
+// `types` already exists.
+func (root *root) emitTypes(m *astWriter, hidden string) {
+	if !root.options.ExposeTypes || len(root.hiddenGroups) == 0 {
+		return
+	}
+	if group, ok := root.groups[kubespec.GroupName(typesNamespace)]; ok {
+		path := kubespec.DefinitionName("")
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				path = ao.parsedName.Unparse()
+				break
+			}
+		}
+		root.report.errorf(
+			path, "hidden types not exposed, because a group named 'types' already exists")
+		return
+	}
+
+	help := comments{"The API objects that aren't top-level, e.g., `core.v1.podSpec`."}
+	help.emit(m)
+	root.emitDocsonnetObject(m, typesNamespace, help)
+	m.namespace(typesNamespace, func(m *astWriter) {
+		for _, group := range root.hiddenGroups.toSortedSlice() {
+			m.hidden(string(group.identifier()), fmt.Sprintf("%s.%s", hidden, group.identifier()))
+		}
+	})
+}
+
+// `typeAliasPaths` returns, for every object that is not top-level
+// and that the top-level `objects` reach, the path of a type alias of
+// it, e.g., `core.v1.pod.mixin.spec.containersType` for `Container`.
+// The path goes through the fewest `mixin` namespaces and type aliases
+// of array elements (e.g., `containersType.portsType` for
+// `ContainerPort`), from the first of `objects` that has such a path.
+func (root *root) typeAliasPaths(objects []*apiObject) map[*apiObject]string {
+	type namespace struct {
+		ao   *apiObject
+		path string
+		top  bool // Whether `path` is the object itself, rather than a `mixin` namespace.
+	}
+
+	k8sVersion := root.spec.Info.Version
+	naming := root.naming
+	paths := make(map[*apiObject]string)
+	visited := make(map[*apiObject]bool)
+	queue := []namespace{}
+	for _, ao := range objects {
+		visited[ao] = true
+		queue = append(queue, namespace{
+			ao:   ao,
+			path: fmt.Sprintf("%s.%s.%s", ao.parent.parent.identifier(), ao.parent.version, ao.jsonnetName),
+			top:  true,
+		})
+	}
+	for len(queue) > 0 {
+		ns := queue[0]
+		queue = queue[1:]
+		for _, pm := range ns.ao.emittedProperties {
+			if pm.kind != typeAlias {
+				continue
+			}
+			ref := pm.ref
+			if !isMixinRef(ref) {
+				ref = pm.itemTypes.Ref
+			}
+			if !isMixinRef(ref) || root.parseRef(ref).Version == nil {
+				continue
+			}
+			ao := root.getAPIObject(root.parseRef(ref))
+			if visited[ao] {
+				continue
+			}
+			visited[ao] = true
+
+			alias := jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.name)
+			path := fmt.Sprintf("%s.%s", ns.path, alias)
+			if isMixinRef(pm.ref) {
+				// The type alias is next to the `mixin` namespace of its
+				// property, which is where code goes on from.
+				prefix := ns.path
+				if ns.top {
+					prefix += ".mixin"
+				}
+				path = fmt.Sprintf("%s.%s", prefix, alias)
+				queue = append(queue, namespace{
+					ao:   ao,
+					path: fmt.Sprintf("%s.%s", prefix, jsonnet.RewriteAsIdentifier(naming, k8sVersion, pm.aliasOf)),
+				})
+			} else {
+				queue = append(queue, namespace{ao: ao, path: path, top: true})
+			}
+			if !ao.isTopLevel {
+				paths[ao] = path
+			}
+		}
+	}
+	return paths
+}
@@ -0,0 +1,83 @@
+package ksonnet
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/jsonnet"
+)
+
+// The policies of `Options.UnknownTypes`.
+const (
+	UnknownTypesLenient = "lenient"
+	UnknownTypesStrict  = "strict"
+)
+
+// UnknownTypesPolicies lists the policies `Options.UnknownTypes`
+// accepts.
+var UnknownTypesPolicies = []string{UnknownTypesLenient, UnknownTypesStrict}
+
+// CheckUnknownTypes returns an error if `policy` is not one of
+// `UnknownTypesPolicies`, or "".
+func CheckUnknownTypes(policy string) error {
+	if policy == "" {
+		return nil
+	}
+	for _, p := range UnknownTypesPolicies {
+		if policy == p {
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"Unrecognized unknown-types policy '%s'; expected one of: %v", policy, UnknownTypesPolicies)
+}
+
+// `emitAsUnknownType` emits a plain setter for a property whose type
+// isn't supported, which sets it to whatever value it is passed, and
+// records why, so that it is either reported as a warning or, with
+// `UnknownTypesStrict`, fails generation once everything else has been
+// emitted.
+func (p *property) emitAsUnknownType(
+	m *astWriter, setterName jsonnet.Identifier,
+	fieldName jsonnet.FieldKey, paramName jsonnet.FuncParam, wrap func(string) string, reason string,
+) {
+	m.method(
+		string(setterName), []string{p.paramWithDefault(paramName)},
+		"self + "+wrap(fmt.Sprintf("{%s: %s}", fieldName, paramName)))
+
+	root := p.root()
+	key := fmt.Sprintf("%s.%s", p.path, p.name)
+	if _, ok := root.unknownTypes[key]; ok {
+		return
+	}
+	root.unknownTypes[key] = reason
+	root.skip(p.path, p.name, SkipUnsupportedType, "property %s, so its setter accepts any value", reason)
+	if root.options.UnknownTypes != UnknownTypesStrict {
+		root.report.warnf(
+			p.path, "property '%s' %s, so its setter accepts any value", p.name, reason)
+	}
+}
+
+// `unknownTypesError` returns an error listing every property that was
+// emitted with `emitAsUnknownType`, if `Options.UnknownTypes` is
+// `UnknownTypesStrict`.
+func (root *root) unknownTypesError() error {
+	if root.options.UnknownTypes != UnknownTypesStrict || len(root.unknownTypes) == 0 {
+		return nil
+	}
+
+	keys := []string{}
+	for key := range root.unknownTypes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("  %s: %s", key, root.unknownTypes[key]))
+	}
+	return fmt.Errorf(
+		"Spec has %d properties of unsupported types:\n%s",
+		len(keys), strings.Join(lines, "\n"))
+}
@@ -6,6 +6,11 @@ import (
 
 const constructorName = "new"
 
+// namedConstructorName is the name of the constructor that
+// `Options.ConstructorsTakeName` emits for objects whose custom
+// constructor already takes `constructorName`.
+const namedConstructorName = "newNamed"
+
 // isMixinRef will check whether a `ObjectRef` refers to an API object
 // that can be turned into a mixin. This should be true of the vast
 // majority of non-nil `ObjectRef`s. The most common exception is
@@ -13,9 +18,22 @@ const constructorName = "new"
 // instead by transformed into a property method that behaves
 // identically to one taking an int or a ref as argument.
 func isMixinRef(or *kubespec.ObjectRef) bool {
-	return or != nil && *or != "#/definitions/io.k8s.apimachinery.pkg.util.intstr.IntOrString"
+	return or != nil && !isIntOrStringRef(or)
+}
+
+// isIntOrStringRef will check whether a `ObjectRef` refers to
+// `IntOrString`, whose values are either an integer or a string (e.g.,
+// a port number or a port name), rather than an object.
+func isIntOrStringRef(or *kubespec.ObjectRef) bool {
+	return or != nil && *or == intOrStringRef
 }
 
+const intOrStringRef = "#/definitions/io.k8s.apimachinery.pkg.util.intstr.IntOrString"
+
+// intOrStringComment is added to the comments of properties that
+// `$ref` `IntOrString`, since the spec declares it to be a string.
+const intOrStringComment = "Accepts either an integer (e.g., `8080`) or a string (e.g., `\"http\"` or `\"25%\"`), which is set as is."
+
 var specialProperties = map[kubespec.PropertyName]kubespec.PropertyName{
 	"apiVersion": "apiVersion",
 	"kind":       "kind",
@@ -0,0 +1,100 @@
+package ksonnet
+
+import (
+	"strings"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `utilNamespace` is the name of the namespace `emitUtilHelpers` adds
+// to the library.
+const utilNamespace = "util"
+
+// `utilHelpers` are the functions of the `util` namespace, with their
+// doc comments: generic helpers for manipulating objects built with
+// the library, which don't depend on the spec. They use only the parts
+// of the standard library that every Jsonnet release the library
+// supports has. A body that starts on the line after the function's
+// name starts with an empty line.
+var utilHelpers = []struct {
+	doc    comments
+	name   string
+	params []string
+	body   []string
+}{
+	{
+		comments{
+			"Applies the JSON merge patch (RFC 7386) `patch` to `target`: objects",
+			"are merged recursively, any other value replaces the target's, and",
+			"null removes it.",
+		},
+		"mergePatch", []string{"target", "patch"},
+		[]string{
+			"",
+			"  if std.isObject(patch) then",
+			"    local t = if std.isObject(target) then target else {};",
+			"    {[k]: t[k] for k in std.objectFields(t) if !std.objectHas(patch, k)} +",
+			"    {[k]: util.mergePatch(if std.objectHas(t, k) then t[k] else null, patch[k]) for k in std.objectFields(patch) if patch[k] != null}",
+			"  else patch",
+		},
+	},
+	{
+		comments{
+			"Removes the field at `path` (e.g., \"spec.replicas\", or an array of",
+			"field names) from `obj`, if it exists.",
+		},
+		"removeField", []string{"obj", "path"},
+		[]string{
+			"",
+			"  local fields = if std.isArray(path) then path else std.split(path, \".\");",
+			"  local rest = std.makeArray(std.length(fields) - 1, function(i) fields[i + 1]);",
+			"  if !std.isObject(obj) || !std.objectHas(obj, fields[0]) then obj",
+			"  else if std.length(rest) == 0 then {[k]: obj[k] for k in std.objectFields(obj) if k != fields[0]}",
+			"  else obj + {[fields[0]]: util.removeField(obj[fields[0]], rest)}",
+		},
+	},
+	{
+		comments{"Applies `f` to the value of every field of `obj`."},
+		"mapValues", []string{"f", "obj"},
+		[]string{"{[k]: f(obj[k]) for k in std.objectFields(obj)}"},
+	},
+	{
+		comments{"Removes every null field and array element from `value`, recursively."},
+		"pruneNulls", []string{"value"},
+		[]string{
+			"",
+			"  if std.isObject(value) then {[k]: util.pruneNulls(value[k]) for k in std.objectFields(value) if value[k] != null}",
+			"  else if std.isArray(value) then [util.pruneNulls(v) for v in value if v != null]",
+			"  else value",
+		},
+	},
+}
+
+// `emitUtilHelpers` emits the `util` namespace (see `utilHelpers`) at
+// the root of the library.
+func (root *root) emitUtilHelpers(m *astWriter) {
+	if !root.options.UtilHelpers {
+		return
+	}
+	if group, ok := root.groups[kubespec.GroupName(utilNamespace)]; ok {
+		path := kubespec.DefinitionName("")
+		for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+			for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+				path = ao.parsedName.Unparse()
+				break
+			}
+		}
+		root.report.errorf(
+			path, "util helpers not emitted, because a group named 'util' already exists")
+		return
+	}
+
+	m.comment("Generic helpers for manipulating objects of any kind.")
+	m.namespace(utilNamespace, func(m *astWriter) {
+		m.local("util", "self")
+		for _, helper := range utilHelpers {
+			helper.doc.emit(m)
+			m.method(helper.name, helper.params, strings.Join(helper.body, "\n"))
+		}
+	})
+}
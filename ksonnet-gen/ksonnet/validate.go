@@ -0,0 +1,132 @@
+package ksonnet
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	gojsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+)
+
+// `isJsonnetFile` reports whether the emitted file `name` is Jsonnet
+// code, i.e., a `.libsonnet` or `.jsonnet` file.
+func isJsonnetFile(name string) bool {
+	ext := path.Ext(name)
+	return ext == ".libsonnet" || ext == ".jsonnet"
+}
+
+// `jsonnetFileNames` returns the names of the Jsonnet files of `files`
+// (see `isJsonnetFile`), sorted.
+func jsonnetFileNames(files map[string][]byte) []string {
+	names := []string{}
+	for name := range files {
+		if isJsonnetFile(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// `validateJsonnet` parses every Jsonnet file of `files` with
+// go-jsonnet, so that a library that a bad rewrite rule (or a bug of
+// the emitter) broke is never written, rather than being discovered
+// when it is used. It returns an error naming the file and line of the
+// first problem, and the definition whose code contains the line (see
+// `definitionAt`).
+func (root *root) validateJsonnet(files map[string][]byte) error {
+	for _, name := range jsonnetFileNames(files) {
+		text := string(files[name])
+		_, err := gojsonnet.SnippetToAST(name, text)
+		if err == nil {
+			continue
+		}
+
+		located, ok := err.(interface{ Loc() ast.LocationRange })
+		if !ok {
+			return fmt.Errorf("Generated invalid Jsonnet in '%s':\n%v", name, err)
+		}
+		lines := strings.Split(text, "\n")
+		line := located.Loc().Begin.Line
+		if line < 1 || line > len(lines) {
+			return fmt.Errorf("Generated invalid Jsonnet in '%s':\n%v", name, err)
+		}
+
+		message := fmt.Sprintf("Generated invalid Jsonnet in '%s' at line %d", name, line)
+		// The files of a split library (e.g., `_gen/apps/v1beta1/deployment.libsonnet`)
+		// are named after the fields they are the values of.
+		fields := strings.Split(strings.TrimSuffix(name, path.Ext(name)), "/")
+		fields = append(fields, enclosingFields(lines, line)...)
+		if definition := root.definitionAt(fields); definition != "" {
+			message += fmt.Sprintf(", in the code of '%s'", definition)
+		}
+		return fmt.Errorf(
+			"%s:\n  %s\n%v", message, strings.TrimSpace(lines[line-1]), err)
+	}
+	return nil
+}
+
+// `fieldOpenPattern` matches a line that opens the value of a field
+// (or method) of an object, e.g., `deployment:: {` or
+// `withReplicas(replicas)::`, and captures the name of the field.
+var fieldOpenPattern = regexp.MustCompile(
+	`^([A-Za-z_][A-Za-z0-9_]*|"[^"]*")(\([^)]*\))?\s*\+?:{1,3}`)
+
+// `enclosingFields` returns the names of the fields whose values
+// contain line `n` (counting from 1) of `lines`, outermost first,
+// judging by the indentation of the lines before it, which is how the
+// emitter nests objects.
+func enclosingFields(lines []string, n int) []string {
+	fields := []string{}
+	indent := indentation(lines[n-1])
+	if match := fieldOpenPattern.FindStringSubmatch(strings.TrimSpace(lines[n-1])); match != nil {
+		fields = append(fields, strings.Trim(match[1], "\""))
+	}
+	for i := n - 2; i >= 0 && indent > 0; i-- {
+		if strings.TrimSpace(lines[i]) == "" || indentation(lines[i]) >= indent {
+			continue
+		}
+		indent = indentation(lines[i])
+		if match := fieldOpenPattern.FindStringSubmatch(strings.TrimSpace(lines[i])); match != nil {
+			fields = append([]string{strings.Trim(match[1], "\"")}, fields...)
+		}
+	}
+	return fields
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// `definitionAt` returns the name of the definition (e.g.,
+// `io.k8s.api.apps.v1beta1.Deployment`) of the API object whose code
+// is nested in `fields` (see `enclosingFields`), i.e., whose version
+// and name appear in `fields` one after the other, preferably after its
+// group, or "" if there is none.
+func (root *root) definitionAt(fields []string) string {
+	best, bestScore := "", 0
+	for _, groups := range []groupSet{root.groups, root.hiddenGroups} {
+		for _, group := range groups.toSortedSlice() {
+			for _, versionedAPI := range group.versionedAPIs.toSortedSlice() {
+				for _, ao := range versionedAPI.apiObjects.toSortedSlice() {
+					for i := 1; i < len(fields); i++ {
+						if fields[i-1] != string(versionedAPI.version) || fields[i] != string(ao.jsonnetName) {
+							continue
+						}
+						score := 1
+						if i >= 2 && fields[i-2] == string(group.identifier()) {
+							score++
+						}
+						if score > bestScore {
+							best, bestScore = string(ao.parsedName.Unparse()), score
+						}
+					}
+				}
+			}
+		}
+	}
+	return best
+}
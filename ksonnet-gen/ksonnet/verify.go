@@ -0,0 +1,263 @@
+package ksonnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	gojsonnet "github.com/google/go-jsonnet"
+)
+
+// `verify` checks that the library in `files` produces objects that
+// match the spec it was generated from. It evaluates every probe (see
+// `probes`) with go-jsonnet, and validates the resulting object
+// against the JSON Schema of its kind (see `jsonSchema`), so that,
+// e.g., a setter that writes a misspelled field, or a string to an
+// integer field, is caught before the library is written. It returns
+// an error listing every probe that failed.
+func (root *root) verify(files map[string][]byte) error {
+	if _, ok := files[k8sFile]; !ok {
+		return fmt.Errorf(
+			"Verification requires a backend that emits '%s'", k8sFile)
+	}
+
+	definitions, err := root.rawDefinitions()
+	if err != nil {
+		return err
+	}
+
+	probes := root.probes()
+	programs := make(map[string]string)
+	for _, probe := range probes {
+		programs[probe.name] = probe.text()
+	}
+	outputs, errs := evaluate(files, programs)
+
+	schemas := make(map[*apiObject]map[string]interface{})
+	failures := []string{}
+	for _, probe := range probes {
+		if err, ok := errs[probe.name]; ok {
+			failures = append(failures, fmt.Sprintf(
+				"%s: failed to evaluate: %s", probe.name, firstLine(err.Error())))
+			continue
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(outputs[probe.name]), &value); err != nil {
+			return err
+		}
+
+		schema, ok := schemas[probe.object]
+		if !ok {
+			schema, err = probe.object.jsonSchema(definitions)
+			if err != nil {
+				return err
+			}
+			schemas[probe.object] = schema
+		}
+
+		v := jsonValidator{}
+		if extra, ok := schema["definitions"].(map[string]interface{}); ok {
+			v.definitions = extra
+		}
+		for _, problem := range v.validate("", value, schema) {
+			failures = append(failures, fmt.Sprintf("%s: %s", probe.name, problem))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf(
+			"Verification failed; the library produces objects that don't match the spec:\n  %s",
+			strings.Join(failures, "\n  "))
+	}
+	return nil
+}
+
+// `evaluate` evaluates Jsonnet programs next to the emitted files, and
+// returns the output of each program that succeeded, and the error of
+// each that failed, keyed by the name of the program.
+func evaluate(
+	files map[string][]byte, programs map[string]string,
+) (map[string]string, map[string]error) {
+	data := make(map[string]gojsonnet.Contents)
+	for name, text := range files {
+		data[name] = gojsonnet.MakeContentsRaw(text)
+	}
+
+	// Reuse the VM, so that the library is parsed only once.
+	vm := gojsonnet.MakeVM()
+	vm.Importer(&memoryImporter{data: data})
+
+	outputs := make(map[string]string)
+	errs := make(map[string]error)
+	for name, program := range programs {
+		output, err := vm.EvaluateAnonymousSnippet(name, program)
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		outputs[name] = output
+	}
+	return outputs, errs
+}
+
+// `memoryImporter` imports the emitted files, resolving the paths of
+// imports relative to the file that imports them, like the `jsonnet`
+// command does, so that the files of a library split into directories
+// (see `Options.Split`) find each other.
+type memoryImporter struct {
+	data map[string]gojsonnet.Contents
+}
+
+func (importer *memoryImporter) Import(
+	importedFrom, importedPath string,
+) (gojsonnet.Contents, string, error) {
+	foundAt := path.Join(path.Dir(importedFrom), importedPath)
+	if contents, ok := importer.data[foundAt]; ok {
+		return contents, foundAt, nil
+	}
+	return gojsonnet.Contents{}, "", fmt.Errorf("Could not import '%s'", importedPath)
+}
+
+func firstLine(text string) string {
+	return strings.SplitN(text, "\n", 2)[0]
+}
+
+// `jsonValidator` validates JSON values against the subset of JSON
+// Schema used in the Kubernetes OpenAPI spec (and by `jsonSchema`).
+type jsonValidator struct {
+	// Definitions that `$ref`s refer to, i.e., the recursive definitions
+	// `jsonSchema` could not inline.
+	definitions map[string]interface{}
+}
+
+// `validate` returns a description of every way `value` doesn't match
+// `schema`. `path` is the path to `value` in the object being
+// validated, e.g., `.spec.replicas`. Required properties are not
+// checked, since probes build partial objects.
+func (v *jsonValidator) validate(
+	path string, value interface{}, schema map[string]interface{},
+) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		definition, ok := v.definitions[strings.TrimPrefix(ref, definitionsPrefix)].(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: unresolved reference '%s'", displayPath(path), ref)}
+		}
+		return v.validate(path, value, definition)
+	}
+
+	if alternatives, ok := schema["oneOf"].([]interface{}); ok {
+		for _, alternative := range alternatives {
+			if s, ok := alternative.(map[string]interface{}); ok && len(v.validate(path, value, s)) == 0 {
+				return nil
+			}
+		}
+		return []string{fmt.Sprintf("%s: %v matches none of the allowed schemas", displayPath(path), value)}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			found = found || reflect.DeepEqual(allowed, value)
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: %v is not one of %v", displayPath(path), value, enum)}
+		}
+	}
+
+	properties, hasProperties := schema["properties"].(map[string]interface{})
+	schemaType, _ := schema["type"].(string)
+	if schemaType == "" && hasProperties {
+		schemaType = "object"
+	}
+
+	mismatch := []string{fmt.Sprintf(
+		"%s: expected %s, got %s", displayPath(path), schemaType, jsonTypeOf(value))}
+	switch schemaType {
+	case "string":
+		if _, ok := value.(string); !ok {
+			return mismatch
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return mismatch
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return mismatch
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return mismatch
+		}
+	case "array":
+		elements, ok := value.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		problems := []string{}
+		for i, element := range elements {
+			problems = append(problems, v.validate(fmt.Sprintf("%s[%d]", path, i), element, items)...)
+		}
+		return problems
+	case "object":
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		additional, hasAdditional := schema["additionalProperties"].(map[string]interface{})
+
+		names := []string{}
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		problems := []string{}
+		for _, name := range names {
+			fieldPath := fmt.Sprintf("%s.%s", path, name)
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				problems = append(problems, v.validate(fieldPath, fields[name], property)...)
+			} else if hasAdditional {
+				problems = append(problems, v.validate(fieldPath, fields[name], additional)...)
+			} else if hasProperties {
+				problems = append(problems, fmt.Sprintf("%s: unknown property", fieldPath))
+			}
+		}
+		return problems
+	}
+	return nil
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
@@ -0,0 +1,55 @@
+package ksonnet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	spec := parseSpec(t, differentialSpec)
+	if _, _, err := EmitFiles(spec, nil, nil, Options{Verify: true}); err != nil {
+		t.Errorf("Expected library to pass verification:\n%v", err)
+	}
+	// Constructors that take arguments are verified too.
+	if _, _, err := EmitFiles(
+		parseSpec(t, customConstructorSpec), nil, nil, Options{Verify: true}); err != nil {
+		t.Errorf("Expected library with custom constructors to pass verification:\n%v", err)
+	}
+
+	// Each replacement breaks the `withReplicas` setter in a way that
+	// verification must catch.
+	tests := map[string]string{
+		"{replicas: std.toString(replicas)}": ".spec.replicas: expected integer, got string",
+		"{replica: replicas}":                ".spec.replica: unknown property",
+		"{replicas: error 'broken'}":         "failed to evaluate: RUNTIME ERROR: broken",
+	}
+	for replacement, expected := range tests {
+		backends["jsonnet-broken"] = func(root *root) (map[string][]byte, error) {
+			files, err := emitJsonnet(root)
+			if err != nil {
+				return nil, err
+			}
+			files[k8sFile] = []byte(strings.Replace(
+				string(files[k8sFile]), "{replicas: replicas}", replacement, -1))
+			return files, nil
+		}
+
+		_, _, err := EmitFiles(
+			spec, nil, nil, Options{Backend: "jsonnet-broken", Verify: true})
+		if err == nil {
+			t.Errorf("Expected verification to fail with '%s'", replacement)
+		} else if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected verification error to contain '%s', got:\n%v", expected, err)
+		}
+	}
+	delete(backends, "jsonnet-broken")
+}
+
+func TestVerifyRequiresJsonnet(t *testing.T) {
+	_, _, err := EmitFiles(
+		parseSpec(t, differentialSpec), nil, nil,
+		Options{Backend: StarlarkBackend, Verify: true})
+	if err == nil || !strings.Contains(err.Error(), k8sFile) {
+		t.Errorf("Expected verification of the Starlark backend to fail, got: %v", err)
+	}
+}
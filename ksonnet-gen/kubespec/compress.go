@@ -0,0 +1,47 @@
+package kubespec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+)
+
+//-----------------------------------------------------------------------------
+// Compressed specs.
+//-----------------------------------------------------------------------------
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Decompress returns the text of a spec that may be compressed (e.g.,
+// `swagger.json.gz`, which is a fraction of the size of the spec, so
+// it can be kept in a repository). Compression is detected from the
+// first bytes of `text`, rather than a file extension or HTTP header,
+// so that it works the same for files, downloads, and stdin; text that
+// is not compressed is returned as is.
+//
+// Only gzip is decompressed. zstd-compressed text is detected, but is
+// an error, since there is no zstd decoder in the standard library.
+func Decompress(text []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(text, gzipMagic):
+		reader, err := gzip.NewReader(bytes.NewReader(text))
+		if err != nil {
+			return nil, fmt.Errorf("Could not decompress gzip-compressed spec:\n%v", err)
+		}
+		defer reader.Close()
+		decompressed, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decompress gzip-compressed spec:\n%v", err)
+		}
+		return decompressed, nil
+	case bytes.HasPrefix(text, zstdMagic):
+		return nil, fmt.Errorf(
+			"Spec is zstd-compressed, which is not supported; decompress it with `zstd -d`, or recompress it with gzip")
+	default:
+		return text, nil
+	}
+}
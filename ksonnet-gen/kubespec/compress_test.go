@@ -0,0 +1,46 @@
+package kubespec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	spec := []byte(`{"swagger": "2.0", "definitions": {}}`)
+
+	compressed := bytes.Buffer{}
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]byte{
+		"plain": spec,
+		"gzip":  compressed.Bytes(),
+	}
+	for name, text := range tests {
+		decompressed, err := Decompress(text)
+		if err != nil {
+			t.Errorf("[%s] Failed to decompress:\n%v", name, err)
+			continue
+		}
+		if !bytes.Equal(decompressed, spec) {
+			t.Errorf("[%s] Expected '%s', got '%s'", name, spec, decompressed)
+		}
+	}
+
+	_, err := Decompress(append([]byte{0x28, 0xb5, 0x2f, 0xfd}, spec...))
+	if err == nil || !strings.Contains(err.Error(), "zstd") {
+		t.Errorf("Expected zstd-compressed specs to be rejected, got: %v", err)
+	}
+
+	_, err = Decompress(compressed.Bytes()[:len(compressed.Bytes())/2])
+	if err == nil {
+		t.Error("Expected truncated gzip-compressed specs to be rejected")
+	}
+}
@@ -0,0 +1,188 @@
+package kubespec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// Dependency graph of definitions.
+//-----------------------------------------------------------------------------
+
+// Reference is an edge of a `Graph`: the property `Property` of the
+// definition `From` refers to the definition `To`, either directly
+// with a `$ref`, or through its array items or map values.
+type Reference struct {
+	From     DefinitionName
+	To       DefinitionName
+	Property PropertyName
+}
+
+// Graph is the dependency graph of the definitions of a spec, i.e.,
+// which definitions refer to which. `$ref`s to definitions the spec
+// lacks are left out (see `Validate`).
+type Graph struct {
+	definitions  []DefinitionName
+	references   map[DefinitionName][]Reference
+	referencedBy map[DefinitionName][]Reference
+}
+
+// DependencyGraph builds the dependency graph of the definitions of
+// `spec`.
+func DependencyGraph(spec *APISpec) *Graph {
+	g := Graph{
+		definitions:  []DefinitionName{},
+		references:   make(map[DefinitionName][]Reference),
+		referencedBy: make(map[DefinitionName][]Reference),
+	}
+	for name := range spec.Definitions {
+		g.definitions = append(g.definitions, name)
+	}
+	sortDefinitionNames(g.definitions)
+
+	for _, name := range g.definitions {
+		def := spec.Definitions[name]
+		for _, propName := range sortedPropertyNames(def.Properties) {
+			for _, ref := range propertyRefs(def.Properties[propName]) {
+				if !refersToDefinition(spec, ref) {
+					continue
+				}
+				reference := Reference{From: name, To: *ref.Name(), Property: propName}
+				g.references[name] = append(g.references[name], reference)
+				g.referencedBy[reference.To] = append(g.referencedBy[reference.To], reference)
+			}
+		}
+	}
+	return &g
+}
+
+// Definitions returns every definition of the graph, sorted by name.
+func (g *Graph) Definitions() []DefinitionName {
+	return append([]DefinitionName{}, g.definitions...)
+}
+
+// References returns the references of the properties of `name` to
+// other definitions, ordered by property.
+func (g *Graph) References(name DefinitionName) []Reference {
+	return append([]Reference{}, g.references[name]...)
+}
+
+// ReferencedBy returns the references of other definitions to `name`,
+// ordered by the definition they are in.
+func (g *Graph) ReferencedBy(name DefinitionName) []Reference {
+	return append([]Reference{}, g.referencedBy[name]...)
+}
+
+// Dependencies returns the definitions `name` refers to, directly or
+// indirectly, sorted by name, not including `name` itself unless it
+// is part of a reference cycle.
+func (g *Graph) Dependencies(name DefinitionName) []DefinitionName {
+	return g.walk(name, g.references, func(r Reference) DefinitionName { return r.To })
+}
+
+// Dependents returns the definitions that refer to `name`, directly or
+// indirectly, sorted by name, not including `name` itself unless it is
+// part of a reference cycle.
+func (g *Graph) Dependents(name DefinitionName) []DefinitionName {
+	return g.walk(name, g.referencedBy, func(r Reference) DefinitionName { return r.From })
+}
+
+func (g *Graph) walk(
+	name DefinitionName, edges map[DefinitionName][]Reference,
+	next func(Reference) DefinitionName,
+) []DefinitionName {
+	seen := make(map[DefinitionName]bool)
+	queue := []DefinitionName{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, r := range edges[current] {
+			if n := next(r); !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	reached := []DefinitionName{}
+	for n := range seen {
+		reached = append(reached, n)
+	}
+	sortDefinitionNames(reached)
+	return reached
+}
+
+// CycleError is the error `TopologicalSort` returns if definitions
+// refer to each other in a cycle. `Cycle` lists the definitions of one
+// such cycle, each referring to the next, and the last to the first.
+type CycleError struct {
+	Cycle []DefinitionName
+}
+
+func (e *CycleError) Error() string {
+	names := []string{}
+	for _, name := range e.Cycle {
+		names = append(names, string(name))
+	}
+	return fmt.Sprintf(
+		"Definitions refer to each other in a cycle: %s -> %s",
+		strings.Join(names, " -> "), names[0])
+}
+
+// TopologicalSort returns every definition of the graph, ordered so
+// that each one comes after the definitions it refers to, e.g., to
+// process dependencies first. The order is deterministic: definitions
+// are visited by name, and their references by property. Definitions
+// that refer to themselves are allowed, but if definitions refer to
+// each other in a cycle, it returns a `*CycleError`.
+func (g *Graph) TopologicalSort() ([]DefinitionName, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[DefinitionName]int)
+	sorted := []DefinitionName{}
+	path := []DefinitionName{}
+
+	var visit func(name DefinitionName) error
+	visit = func(name DefinitionName) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return &CycleError{Cycle: append([]DefinitionName{}, path[i:]...)}
+				}
+			}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, r := range g.references[name] {
+			if r.To == name {
+				continue
+			}
+			if err := visit(r.To); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		sorted = append(sorted, name)
+		return nil
+	}
+
+	for _, name := range g.definitions {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+func sortDefinitionNames(names []DefinitionName) {
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+}
@@ -0,0 +1,108 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var graphSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Pod": {
+      "properties": {
+        "metadata": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodSpec"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.PodSpec": {
+      "properties": {
+        "containers": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Container"}},
+        "overhead": {"type": "object", "additionalProperties": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.api.resource.Quantity"}}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.Container": {
+      "properties": {"name": {"type": "string"}}
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "properties": {"ownerReferences": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"}}}
+    },
+    "io.k8s.apimachinery.pkg.api.resource.Quantity": {"type": "string"}
+  }
+}`
+
+func parseGraphSpec(t *testing.T, text string) *APISpec {
+	spec := APISpec{}
+	if err := json.Unmarshal([]byte(text), &spec); err != nil {
+		t.Fatal(err)
+	}
+	return &spec
+}
+
+func TestDependencyGraph(t *testing.T) {
+	g := DependencyGraph(parseGraphSpec(t, graphSpec))
+
+	const (
+		pod       = DefinitionName("io.k8s.kubernetes.pkg.api.v1.Pod")
+		podSpec   = DefinitionName("io.k8s.kubernetes.pkg.api.v1.PodSpec")
+		container = DefinitionName("io.k8s.kubernetes.pkg.api.v1.Container")
+		meta      = DefinitionName("io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta")
+		quantity  = DefinitionName("io.k8s.apimachinery.pkg.api.resource.Quantity")
+	)
+
+	expectedRefs := []Reference{
+		{From: podSpec, To: container, Property: "containers"},
+		{From: podSpec, To: quantity, Property: "overhead"},
+	}
+	if refs := g.References(podSpec); !reflect.DeepEqual(refs, expectedRefs) {
+		t.Errorf("Expected references %v, got %v", expectedRefs, refs)
+	}
+	expectedRefs = []Reference{{From: podSpec, To: container, Property: "containers"}}
+	if refs := g.ReferencedBy(container); !reflect.DeepEqual(refs, expectedRefs) {
+		t.Errorf("Expected to be referenced by %v, got %v", expectedRefs, refs)
+	}
+
+	expected := []DefinitionName{quantity, meta, container, podSpec}
+	if deps := g.Dependencies(pod); !reflect.DeepEqual(deps, expected) {
+		t.Errorf("Expected dependencies %v, got %v", expected, deps)
+	}
+	expected = []DefinitionName{pod, podSpec}
+	if deps := g.Dependents(container); !reflect.DeepEqual(deps, expected) {
+		t.Errorf("Expected dependents %v, got %v", expected, deps)
+	}
+	expected = []DefinitionName{meta}
+	if deps := g.Dependencies(meta); !reflect.DeepEqual(deps, expected) {
+		t.Errorf("Expected a self-referential definition to depend on itself, got %v", deps)
+	}
+
+	sorted, err := g.TopologicalSort()
+	if err != nil {
+		t.Fatalf("Failed to sort:\n%v", err)
+	}
+	expected = []DefinitionName{quantity, meta, container, podSpec, pod}
+	if !reflect.DeepEqual(sorted, expected) {
+		t.Errorf("Expected order %v, got %v", expected, sorted)
+	}
+}
+
+func TestTopologicalSortCycle(t *testing.T) {
+	spec := parseGraphSpec(t, graphSpec)
+	spec.Definitions["io.k8s.kubernetes.pkg.api.v1.Container"].Properties["pod"] = &Property{
+		Ref: DefinitionName("io.k8s.kubernetes.pkg.api.v1.Pod").AsObjectRef(),
+	}
+
+	_, err := DependencyGraph(spec).TopologicalSort()
+	cycle, ok := err.(*CycleError)
+	if !ok {
+		t.Fatalf("Expected a cycle error, got: %v", err)
+	}
+	expected := []DefinitionName{
+		"io.k8s.kubernetes.pkg.api.v1.Container",
+		"io.k8s.kubernetes.pkg.api.v1.Pod",
+		"io.k8s.kubernetes.pkg.api.v1.PodSpec",
+	}
+	if !reflect.DeepEqual(cycle.Cycle, expected) {
+		t.Errorf("Expected cycle %v, got %v", expected, cycle.Cycle)
+	}
+}
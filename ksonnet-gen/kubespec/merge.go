@@ -0,0 +1,91 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+//-----------------------------------------------------------------------------
+// Merging specs.
+//-----------------------------------------------------------------------------
+
+// MergeConflict records a definition that several merged specs define
+// differently. The definition of the first spec that has it is kept.
+type MergeConflict struct {
+	Definition DefinitionName
+	// Kept and Dropped are the indices, among the merged specs, of the
+	// spec whose definition was kept, and of one whose definition
+	// was dropped.
+	Kept    int
+	Dropped int
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf(
+		"%s: definition in spec %d differs from the one in spec %d, which was kept",
+		c.Definition, c.Dropped+1, c.Kept+1)
+}
+
+// Merge combines several specs (e.g., those of the aggregated API
+// servers of a cluster) into one, which has the definitions of all of
+// them. Specs earlier in `specs` take precedence: the merged spec has
+// the `info` of the first, and if several specs define the same
+// definition differently, the first one's definition is kept, and the
+// conflict recorded in `MergeConflicts`.
+//
+// The specs' `Text` must be set, since it's merged too.
+func Merge(specs ...*APISpec) (*APISpec, error) {
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("No specs to merge")
+	}
+
+	merged := make(map[string]interface{})
+	definitions := make(map[string]interface{})
+	owners := make(map[string]int)
+	conflicts := []MergeConflict{}
+	for i, spec := range specs {
+		raw := struct {
+			Definitions map[string]interface{} `json:"definitions"`
+		}{}
+		if err := json.Unmarshal(spec.Text, &raw); err != nil {
+			return nil, fmt.Errorf("Could not deserialize schema %d:\n%v", i+1, err)
+		}
+		if i == 0 {
+			if err := json.Unmarshal(spec.Text, &merged); err != nil {
+				return nil, fmt.Errorf("Could not deserialize schema %d:\n%v", i+1, err)
+			}
+		}
+
+		for _, name := range sortedKeys(raw.Definitions) {
+			definition := raw.Definitions[name]
+			existing, ok := definitions[name]
+			if !ok {
+				definitions[name] = definition
+				owners[name] = i
+				continue
+			}
+			if !reflect.DeepEqual(existing, definition) {
+				conflicts = append(conflicts, MergeConflict{
+					Definition: DefinitionName(name),
+					Kept:       owners[name],
+					Dropped:    i,
+				})
+			}
+		}
+	}
+	merged["definitions"] = definitions
+
+	text, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	spec := APISpec{}
+	if err := json.Unmarshal(text, &spec); err != nil {
+		return nil, fmt.Errorf("Could not deserialize merged schema:\n%v", err)
+	}
+	spec.Text = text
+	spec.FilePath = specs[0].FilePath
+	spec.MergeConflicts = conflicts
+	return &spec, nil
+}
@@ -0,0 +1,83 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var mergeSpecs = []string{`{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Pod": {"properties": {"spec": {"type": "object"}}},
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {"properties": {"name": {"type": "string"}}}
+  }
+}`, `{
+  "swagger": "2.0",
+  "info": {"title": "metrics", "version": "v0.1.0"},
+  "definitions": {
+    "io.k8s.metrics.pkg.apis.metrics.v1beta1.NodeMetrics": {"properties": {"window": {"type": "string"}}},
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {"properties": {"name": {"type": "string"}}}
+  }
+}`, `{
+  "swagger": "2.0",
+  "info": {"title": "custom", "version": "v0.2.0"},
+  "definitions": {
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {"properties": {"uid": {"type": "string"}}}
+  }
+}`}
+
+func TestMerge(t *testing.T) {
+	specs := []*APISpec{}
+	for _, text := range mergeSpecs {
+		spec := APISpec{}
+		if err := json.Unmarshal([]byte(text), &spec); err != nil {
+			t.Fatalf("Could not deserialize spec:\n%v", err)
+		}
+		spec.Text = []byte(text)
+		specs = append(specs, &spec)
+	}
+
+	merged, err := Merge(specs...)
+	if err != nil {
+		t.Fatalf("Failed to merge:\n%v", err)
+	}
+	if merged.Info.Version != "v1.7.0" {
+		t.Errorf("Expected the info of the first spec, got version '%s'", merged.Info.Version)
+	}
+	for _, name := range []DefinitionName{
+		"io.k8s.kubernetes.pkg.api.v1.Pod",
+		"io.k8s.metrics.pkg.apis.metrics.v1beta1.NodeMetrics",
+	} {
+		if _, ok := merged.Definitions[name]; !ok {
+			t.Errorf("Expected definition '%s' to be merged", name)
+		}
+	}
+
+	// Identical definitions don't conflict; different ones do, and the
+	// first one is kept.
+	objectMeta := merged.Definitions["io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"]
+	if _, ok := objectMeta.Properties["name"]; !ok {
+		t.Errorf("Expected the first definition of 'ObjectMeta' to be kept")
+	}
+	if len(merged.MergeConflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %v", merged.MergeConflicts)
+	}
+	conflict := merged.MergeConflicts[0]
+	if conflict.Definition != "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta" ||
+		conflict.Kept != 0 || conflict.Dropped != 2 {
+		t.Errorf("Unexpected conflict '%s'", conflict)
+	}
+
+	// The merged text has every definition too.
+	raw := struct {
+		Definitions map[string]interface{} `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(merged.Text, &raw); err != nil || len(raw.Definitions) != 3 {
+		t.Errorf("Expected the merged text to have 3 definitions")
+	}
+
+	if _, err := Merge(); err == nil {
+		t.Errorf("Expected merging no specs to fail")
+	}
+}
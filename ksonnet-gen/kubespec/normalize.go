@@ -0,0 +1,328 @@
+package kubespec
+
+//-----------------------------------------------------------------------------
+// Normalizing specs.
+//-----------------------------------------------------------------------------
+
+// Normalize rewrites `spec`, in place, into the canonical shape the
+// generator builds its model from, so that the model never has to deal
+// with the other ways a schema can be written:
+//
+//   - Trivial wrappers, i.e., definitions that are nothing but a `$ref`
+//     to another definition (directly, or as the only schema of an
+//     `allOf`), are inlined: `$ref`s to them are rewritten to refer to
+//     the definition they wrap, and they are removed. Properties that
+//     referred to a wrapper, and have no description of their own,
+//     inherit the wrapper's.
+//   - `allOf`s of definitions and properties are flattened: each of
+//     the schemas they list contributes whatever the definition or
+//     property does not already set, e.g., its type, description, or
+//     properties, and a definition also gets their required
+//     properties.
+//
+// Top-level definitions are never treated as wrappers. Normalizing a
+// spec that is already normalized leaves it unchanged.
+func Normalize(spec *APISpec) {
+	n := normalizer{spec: spec, wrappers: make(map[DefinitionName]wrapper)}
+	n.findWrappers()
+
+	names := definitionNames(spec)
+	for _, name := range names {
+		def := spec.Definitions[name]
+		n.rewriteDefinition(def)
+	}
+	for name := range n.wrappers {
+		delete(spec.Definitions, name)
+	}
+
+	for _, name := range names {
+		if def, ok := spec.Definitions[name]; ok {
+			n.flattenDefinition(def)
+		}
+	}
+	for _, name := range definitionNames(spec) {
+		for _, prop := range spec.Definitions[name].Properties {
+			flattenProperty(prop)
+		}
+	}
+}
+
+// `wrapper` is a trivial wrapper `Normalize` inlines: the definition it
+// ultimately wraps (following wrappers of wrappers), and the first
+// description along the way.
+type wrapper struct {
+	target      DefinitionName
+	description string
+}
+
+type normalizer struct {
+	spec     *APISpec
+	wrappers map[DefinitionName]wrapper
+}
+
+func (n *normalizer) findWrappers() {
+	for _, name := range definitionNames(n.spec) {
+		w := wrapper{}
+		seen := map[DefinitionName]bool{name: true}
+		current := name
+		for {
+			target, ok := n.wrappedDefinition(current)
+			if !ok {
+				break
+			}
+			if seen[target] {
+				// Wrappers that wrap each other in a cycle wrap nothing.
+				current = name
+				break
+			}
+			seen[target] = true
+			if w.description == "" {
+				w.description = n.spec.Definitions[current].Description
+			}
+			current = target
+		}
+		if current != name {
+			w.target = current
+			n.wrappers[name] = w
+		}
+	}
+}
+
+// `wrappedDefinition` returns the definition `name` is a trivial
+// wrapper of, if it is one.
+func (n *normalizer) wrappedDefinition(name DefinitionName) (DefinitionName, bool) {
+	def := n.spec.Definitions[name]
+	if len(def.TopLevelSpecs) > 0 || len(def.Properties) > 0 || len(def.Required) > 0 {
+		return "", false
+	}
+
+	ref := def.Ref
+	switch {
+	case ref != nil && len(def.AllOf) == 0:
+	case ref == nil && len(def.AllOf) == 1:
+		member := def.AllOf[0]
+		if len(member.Properties) > 0 || len(member.Required) > 0 || len(member.AllOf) > 0 {
+			return "", false
+		}
+		ref = member.Ref
+	default:
+		return "", false
+	}
+	if ref == nil || !refersToDefinition(n.spec, *ref) {
+		return "", false
+	}
+	return *ref.Name(), true
+}
+
+// `unwrap` returns the definition `ref` refers to once wrappers are
+// inlined, and the wrapper it referred to, if any.
+func (n *normalizer) unwrap(ref *ObjectRef) (*ObjectRef, *wrapper) {
+	if ref == nil || !refersToDefinition(n.spec, *ref) {
+		return ref, nil
+	}
+	w, ok := n.wrappers[*ref.Name()]
+	if !ok {
+		return ref, nil
+	}
+	return w.target.AsObjectRef(), &w
+}
+
+func (n *normalizer) rewriteDefinition(def *SchemaDefinition) {
+	def.Ref, _ = n.unwrap(def.Ref)
+	for _, member := range def.AllOf {
+		n.rewriteDefinition(member)
+	}
+	for _, prop := range def.Properties {
+		n.rewriteProperty(prop)
+	}
+}
+
+func (n *normalizer) rewriteProperty(prop *Property) {
+	if prop == nil {
+		return
+	}
+	var w *wrapper
+	prop.Ref, w = n.unwrap(prop.Ref)
+	if w != nil && prop.Description == "" {
+		prop.Description = w.description
+	}
+	prop.Items.Ref, _ = n.unwrap(prop.Items.Ref)
+	for _, member := range prop.AllOf {
+		n.rewriteProperty(member)
+	}
+	if prop.AdditionalProperties != nil {
+		n.rewriteProperty(prop.AdditionalProperties.Schema)
+	}
+}
+
+// `flattenDefinition` folds the `$ref` and `allOf` of `def` into it.
+// Schemas that `$ref` a definition the spec lacks are kept in its
+// `allOf`, since there's nothing to fold in.
+func (n *normalizer) flattenDefinition(def *SchemaDefinition) {
+	members := def.AllOf
+	if def.Ref != nil {
+		members = append([]*SchemaDefinition{{Ref: def.Ref}}, members...)
+	}
+	// Cleared before flattening the members, so that definitions whose
+	// `allOf`s refer to each other are each flattened only once.
+	def.Ref, def.AllOf = nil, nil
+
+	for _, member := range members {
+		if member.Ref != nil {
+			if !refersToDefinition(n.spec, *member.Ref) {
+				def.AllOf = append(def.AllOf, member)
+				continue
+			}
+			member = n.spec.Definitions[*member.Ref.Name()]
+		}
+		n.flattenDefinition(member)
+		mergeDefinition(def, member)
+	}
+}
+
+// `mergeDefinition` copies into `def` whatever `from` sets, and `def`
+// does not.
+func mergeDefinition(def, from *SchemaDefinition) {
+	if def.Type == nil {
+		def.Type = from.Type
+	}
+	if def.Description == "" {
+		def.Description = from.Description
+	}
+	def.Extensions = mergeExtensions(def.Extensions, from.Extensions)
+
+	required := make(map[string]bool)
+	for _, name := range def.Required {
+		required[name] = true
+	}
+	for _, name := range from.Required {
+		if !required[name] {
+			required[name] = true
+			def.Required = append(def.Required, name)
+		}
+	}
+
+	for _, name := range sortedPropertyNames(from.Properties) {
+		if _, ok := def.Properties[name]; ok {
+			continue
+		}
+		if def.Properties == nil {
+			def.Properties = make(Properties)
+		}
+		// Copied, so that flattening the `allOf`s of properties doesn't
+		// change the definitions they came from.
+		prop := *from.Properties[name]
+		def.Properties[name] = &prop
+	}
+}
+
+// `flattenProperty` folds the `allOf` of `prop`, and of its map values,
+// into it.
+func flattenProperty(prop *Property) {
+	if prop == nil {
+		return
+	}
+	members := prop.AllOf
+	prop.AllOf = nil
+	for _, member := range members {
+		flattenProperty(member)
+		mergeProperty(prop, member)
+	}
+	if prop.AdditionalProperties != nil {
+		flattenProperty(prop.AdditionalProperties.Schema)
+	}
+}
+
+// `mergeProperty` copies into `prop` whatever `from` sets, and `prop`
+// does not.
+func mergeProperty(prop, from *Property) {
+	if prop.Description == "" {
+		prop.Description = from.Description
+	}
+	if prop.Type == nil {
+		prop.Type = from.Type
+	}
+	if prop.Ref == nil {
+		prop.Ref = from.Ref
+	}
+	if prop.Items.Ref == nil && prop.Items.Type == nil {
+		prop.Items = from.Items
+	}
+	if prop.PatchStrategy == "" {
+		prop.PatchStrategy = from.PatchStrategy
+	}
+	if prop.PatchMergeKey == "" {
+		prop.PatchMergeKey = from.PatchMergeKey
+	}
+	if prop.ListType == "" {
+		prop.ListType = from.ListType
+	}
+	if prop.ListMapKeys == nil {
+		prop.ListMapKeys = from.ListMapKeys
+	}
+	if prop.AdditionalProperties == nil {
+		prop.AdditionalProperties = from.AdditionalProperties
+	}
+	if prop.Enum == nil {
+		prop.Enum = from.Enum
+	}
+	if prop.Default == nil {
+		prop.Default = from.Default
+	}
+	prop.Extensions = mergeExtensions(prop.Extensions, from.Extensions)
+	prop.PreserveUnknownFields = prop.PreserveUnknownFields || from.PreserveUnknownFields
+	prop.IntOrString = prop.IntOrString || from.IntOrString
+
+	c, fc := &prop.Constraints, &from.Constraints
+	if c.Format == "" {
+		c.Format = fc.Format
+	}
+	if c.Pattern == "" {
+		c.Pattern = fc.Pattern
+	}
+	if c.Minimum == nil {
+		c.Minimum, c.ExclusiveMinimum = fc.Minimum, fc.ExclusiveMinimum
+	}
+	if c.Maximum == nil {
+		c.Maximum, c.ExclusiveMaximum = fc.Maximum, fc.ExclusiveMaximum
+	}
+	if c.MinLength == nil {
+		c.MinLength = fc.MinLength
+	}
+	if c.MaxLength == nil {
+		c.MaxLength = fc.MaxLength
+	}
+	if c.MinItems == nil {
+		c.MinItems = fc.MinItems
+	}
+	if c.MaxItems == nil {
+		c.MaxItems = fc.MaxItems
+	}
+	c.UniqueItems = c.UniqueItems || fc.UniqueItems
+}
+
+// `mergeExtensions` returns `exts`, with the extensions of `from` it
+// lacks added. `exts` is copied rather than changed, since it may be
+// shared with the schema it was copied from.
+func mergeExtensions(exts, from map[string]interface{}) map[string]interface{} {
+	if len(from) == 0 {
+		return exts
+	}
+	merged := make(map[string]interface{})
+	for key, value := range from {
+		merged[key] = value
+	}
+	for key, value := range exts {
+		merged[key] = value
+	}
+	return merged
+}
+
+func definitionNames(spec *APISpec) []DefinitionName {
+	names := []DefinitionName{}
+	for name := range spec.Definitions {
+		names = append(names, name)
+	}
+	sortDefinitionNames(names)
+	return names
+}
@@ -0,0 +1,111 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var normalizeSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "com.example.operator.v1.Widget": {
+      "properties": {
+        "spec": {"description": "Spec of the widget.", "allOf": [{"$ref": "#/definitions/com.example.operator.v1.WidgetSpecAlias"}]},
+        "owner": {"$ref": "#/definitions/com.example.operator.v1.Owner"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "operator.example.com", "Version": "v1", "Kind": "Widget"}]
+    },
+    "com.example.operator.v1.WidgetSpecAlias": {"$ref": "#/definitions/com.example.operator.v1.WidgetSpec"},
+    "com.example.operator.v1.Owner": {
+      "description": "Owner of the widget.",
+      "allOf": [{"$ref": "#/definitions/com.example.operator.v1.ObjectReference"}]
+    },
+    "com.example.operator.v1.ObjectReference": {
+      "properties": {"name": {"type": "string"}}
+    },
+    "com.example.operator.v1.WidgetSpec": {
+      "properties": {"name": {"type": "string"}},
+      "allOf": [
+        {"$ref": "#/definitions/com.example.operator.v1.Sized"},
+        {"properties": {"color": {"type": "string"}}, "required": ["color"]}
+      ]
+    },
+    "com.example.operator.v1.Sized": {
+      "description": "Sized has a size.",
+      "properties": {"size": {"type": "integer", "minimum": 0}},
+      "required": ["size"]
+    },
+    "com.example.operator.v1.Ping": {"$ref": "#/definitions/com.example.operator.v1.Pong"},
+    "com.example.operator.v1.Pong": {"$ref": "#/definitions/com.example.operator.v1.Ping"}
+  }
+}`
+
+func TestNormalize(t *testing.T) {
+	spec := APISpec{}
+	if err := json.Unmarshal([]byte(normalizeSpec), &spec); err != nil {
+		t.Fatal(err)
+	}
+	Normalize(&spec)
+
+	for _, name := range []DefinitionName{
+		"com.example.operator.v1.WidgetSpecAlias", "com.example.operator.v1.Owner",
+	} {
+		if _, ok := spec.Definitions[name]; ok {
+			t.Errorf("[%s] Expected trivial wrapper to be removed", name)
+		}
+	}
+	for _, name := range []DefinitionName{
+		"com.example.operator.v1.Ping", "com.example.operator.v1.Pong",
+	} {
+		if _, ok := spec.Definitions[name]; !ok {
+			t.Errorf("[%s] Expected wrappers that wrap each other to be kept", name)
+		}
+	}
+
+	widget := spec.Definitions["com.example.operator.v1.Widget"]
+	tests := map[PropertyName]struct {
+		ref         DefinitionName
+		description string
+	}{
+		"spec":  {"com.example.operator.v1.WidgetSpec", "Spec of the widget."},
+		"owner": {"com.example.operator.v1.ObjectReference", "Owner of the widget."},
+	}
+	for name, expected := range tests {
+		prop := widget.Properties[name]
+		if prop.Ref == nil || *prop.Ref.Name() != expected.ref || prop.AllOf != nil {
+			t.Errorf("[%s] Expected a `$ref` to '%s', got %+v", name, expected.ref, prop)
+		}
+		if prop.Description != expected.description {
+			t.Errorf("[%s] Expected description '%s', got '%s'", name, expected.description, prop.Description)
+		}
+	}
+
+	widgetSpec := spec.Definitions["com.example.operator.v1.WidgetSpec"]
+	if widgetSpec.Ref != nil || widgetSpec.AllOf != nil {
+		t.Errorf("Expected `allOf` to be flattened, got %+v", widgetSpec)
+	}
+	if widgetSpec.Description != "Sized has a size." {
+		t.Errorf("Expected description to be inherited, got '%s'", widgetSpec.Description)
+	}
+	if len(widgetSpec.Properties) != 3 || widgetSpec.Properties["size"].Minimum == nil {
+		t.Errorf("Expected properties 'name', 'size', and 'color', got %+v", widgetSpec.Properties)
+	}
+	if !reflect.DeepEqual(widgetSpec.Required, []string{"size", "color"}) {
+		t.Errorf("Expected required properties 'size' and 'color', got %v", widgetSpec.Required)
+	}
+
+	normalized, err := json.Marshal(spec.Definitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Normalize(&spec)
+	renormalized, err := json.Marshal(spec.Definitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(normalized) != string(renormalized) {
+		t.Errorf("Expected normalizing twice to change nothing, got:\n%s", renormalized)
+	}
+}
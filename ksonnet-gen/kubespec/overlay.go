@@ -0,0 +1,135 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// Overlays of partial definitions.
+//-----------------------------------------------------------------------------
+
+// OverlayConflict records a value of the spec that an overlay replaced
+// or removed, as opposed to one it added. `Field` is the dot-separated
+// path of the value within the definition (e.g.,
+// `properties.replicas.type`).
+type OverlayConflict struct {
+	Definition DefinitionName
+	Field      string
+	Message    string
+}
+
+func (c OverlayConflict) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.Definition, c.Field, c.Message)
+}
+
+// Overlay merges the definitions of the overlay `overlayText` over
+// those of the spec `specText`, and returns the text of the resulting
+// spec, along with every conflict between them.
+//
+// The overlay has the same shape as a spec (i.e., a `definitions`
+// object), but its definitions can be partial: definitions the spec
+// lacks are added, and those it has are merged like a JSON merge
+// patch, i.e., objects are merged recursively, any other value
+// replaces the spec's, and `null` removes it.
+func Overlay(specText, overlayText []byte) ([]byte, []OverlayConflict, error) {
+	spec := make(map[string]interface{})
+	if err := json.Unmarshal(specText, &spec); err != nil {
+		return nil, nil, fmt.Errorf("Could not deserialize schema:\n%v", err)
+	}
+	overlay := struct {
+		Definitions map[string]interface{} `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(overlayText, &overlay); err != nil {
+		return nil, nil, fmt.Errorf("Could not deserialize overlay:\n%v", err)
+	}
+
+	definitions, ok := spec["definitions"].(map[string]interface{})
+	if !ok {
+		definitions = make(map[string]interface{})
+		spec["definitions"] = definitions
+	}
+
+	conflicts := []OverlayConflict{}
+	for _, name := range sortedKeys(overlay.Definitions) {
+		fragment, ok := overlay.Definitions[name].(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf(
+				"Overlay for definition '%s' must be an object", name)
+		}
+
+		definition, ok := definitions[name].(map[string]interface{})
+		if !ok {
+			definitions[name] = fragment
+			continue
+		}
+		merge(definition, fragment, nil, func(field []string, message string) {
+			conflicts = append(conflicts, OverlayConflict{
+				Definition: DefinitionName(name),
+				Field:      strings.Join(field, "."),
+				Message:    message,
+			})
+		})
+	}
+
+	text, err := json.Marshal(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return text, conflicts, nil
+}
+
+// `merge` merges `patch` into `target` in place, calling `conflict`
+// for every value of `target` that is replaced or removed.
+func merge(
+	target, patch map[string]interface{}, field []string,
+	conflict func(field []string, message string),
+) {
+	for _, key := range sortedKeys(patch) {
+		value := patch[key]
+		keyField := append(append([]string{}, field...), key)
+
+		existing, exists := target[key]
+		switch {
+		case value == nil:
+			if exists {
+				delete(target, key)
+				conflict(keyField, "removed by overlay")
+			}
+		case !exists:
+			target[key] = value
+		default:
+			existingObject, existingIsObject := existing.(map[string]interface{})
+			valueObject, valueIsObject := value.(map[string]interface{})
+			if existingIsObject && valueIsObject {
+				merge(existingObject, valueObject, keyField, conflict)
+				continue
+			}
+			if !reflect.DeepEqual(existing, value) {
+				conflict(keyField, fmt.Sprintf(
+					"overlay replaced %s with %s", compactJSON(existing), compactJSON(value)))
+			}
+			target[key] = value
+		}
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func compactJSON(value interface{}) string {
+	text, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(text)
+}
@@ -0,0 +1,100 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var overlaySpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec": {
+      "description": "WidgetSpec is a test object.",
+      "properties": {
+        "replicas": {"type": "string"},
+        "paused": {"type": "boolean"},
+        "legacy": {"type": "string"}
+      }
+    }
+  }
+}`
+
+var overlayFragments = `{
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec": {
+      "properties": {
+        "replicas": {"type": "integer"},
+        "paused": {"type": "boolean"},
+        "selector": {"type": "object"},
+        "legacy": null
+      }
+    },
+    "io.example.apis.widgets.v1.Gadget": {
+      "properties": {"size": {"type": "integer"}},
+      "x-kubernetes-group-version-kind": [{"Group": "widgets", "Version": "v1", "Kind": "Gadget"}]
+    }
+  }
+}`
+
+func TestOverlay(t *testing.T) {
+	text, conflicts, err := Overlay([]byte(overlaySpec), []byte(overlayFragments))
+	if err != nil {
+		t.Fatalf("Failed to overlay:\n%v", err)
+	}
+
+	spec := APISpec{}
+	if err := json.Unmarshal(text, &spec); err != nil {
+		t.Fatalf("Could not deserialize overlaid spec:\n%v", err)
+	}
+	if spec.Info == nil || spec.Info.Version != "v1.7.0" {
+		t.Errorf("Expected spec info to be preserved")
+	}
+
+	widgetSpec := spec.Definitions["io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec"]
+	if widgetSpec.Description != "WidgetSpec is a test object." {
+		t.Errorf("Expected description to be preserved, got '%s'", widgetSpec.Description)
+	}
+	tests := map[PropertyName]string{
+		"replicas": "integer",
+		"paused":   "boolean",
+		"selector": "object",
+	}
+	for name, expected := range tests {
+		property, ok := widgetSpec.Properties[name]
+		if !ok || property.Type == nil || string(*property.Type) != expected {
+			t.Errorf("Expected property '%s' to have type '%s'", name, expected)
+		}
+	}
+	if _, ok := widgetSpec.Properties["legacy"]; ok {
+		t.Errorf("Expected property 'legacy' to be removed")
+	}
+	if _, ok := spec.Definitions["io.example.apis.widgets.v1.Gadget"]; !ok {
+		t.Errorf("Expected definition 'Gadget' to be added")
+	}
+
+	if len(conflicts) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %v", conflicts)
+	}
+	if conflicts[0].Field != "properties.legacy" ||
+		!strings.Contains(conflicts[0].Message, "removed") {
+		t.Errorf("Unexpected conflict '%s'", conflicts[0])
+	}
+	if conflicts[1].Field != "properties.replicas.type" ||
+		!strings.Contains(conflicts[1].Message, `replaced "string" with "integer"`) {
+		t.Errorf("Unexpected conflict '%s'", conflicts[1])
+	}
+}
+
+func TestOverlayRejectsMalformedFragments(t *testing.T) {
+	tests := map[string]string{
+		"not JSON":           `{`,
+		"non-object overlay": `{"definitions": {"io.k8s.kubernetes.pkg.api.v1.Foo": 1}}`,
+	}
+	for name, overlay := range tests {
+		if _, _, err := Overlay([]byte(overlaySpec), []byte(overlay)); err == nil {
+			t.Errorf("[%s] Expected overlay to be rejected", name)
+		}
+	}
+}
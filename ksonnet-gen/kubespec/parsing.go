@@ -3,6 +3,7 @@ package kubespec
 import (
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
 )
 
@@ -11,13 +12,22 @@ import (
 //-----------------------------------------------------------------------------
 
 // Parse will parse a `DefinitionName` into a structured
-// `ParsedDefinitionName`.
+// `ParsedDefinitionName`, exiting if the name is malformed. Use
+// `ParseE` for names that have not been checked with `Validate`.
 func (dn *DefinitionName) Parse() *ParsedDefinitionName {
+	parsed, err := dn.ParseE()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return parsed
+}
+
+// ParseE is `Parse`, except that it returns an error naming the
+// definition rather than exiting if the name is malformed.
+func (dn *DefinitionName) ParseE() (*ParsedDefinitionName, error) {
 	split := strings.Split(string(*dn), ".")
-	if len(split) < 6 {
-		log.Fatalf("Failed to parse definition name '%s'", string(*dn))
-	} else if split[0] != "io" || split[1] != "k8s" || split[3] != "pkg" {
-		log.Fatalf("Failed to parse definition name '%s'", string(*dn))
+	if len(split) < 6 || split[0] != "io" || split[1] != "k8s" || split[3] != "pkg" {
+		return dn.parseDomain(split)
 	}
 
 	codebase := split[2]
@@ -25,7 +35,7 @@ func (dn *DefinitionName) Parse() *ParsedDefinitionName {
 	if split[4] == "api" {
 		// Name is something like: `io.k8s.kubernetes.pkg.api.v1.LimitRangeSpec`.
 		if len(split) < 7 {
-			log.Fatalf(
+			return nil, fmt.Errorf(
 				"Expected >= 7 path components for package 'api' in path: '%s'",
 				string(*dn))
 		}
@@ -36,11 +46,11 @@ func (dn *DefinitionName) Parse() *ParsedDefinitionName {
 			Group:       nil,
 			Version:     &versionString,
 			Kind:        ObjectKind(split[6]),
-		}
+		}, nil
 	} else if split[4] == "apis" {
 		// Name is something like: `io.k8s.kubernetes.pkg.apis.batch.v1.JobList`.
 		if len(split) < 8 {
-			log.Fatalf(
+			return nil, fmt.Errorf(
 				"Expected >= 8 path components for package 'apis' in path: '%s'",
 				string(*dn))
 		}
@@ -52,10 +62,10 @@ func (dn *DefinitionName) Parse() *ParsedDefinitionName {
 			Group:       &groupName,
 			Version:     &versionString,
 			Kind:        ObjectKind(split[7]),
-		}
+		}, nil
 	} else if split[4] == "util" {
 		if len(split) < 7 {
-			log.Fatalf(
+			return nil, fmt.Errorf(
 				"Expected >= 7 path components for package 'api' in path: '%s'",
 				string(*dn))
 		}
@@ -66,7 +76,7 @@ func (dn *DefinitionName) Parse() *ParsedDefinitionName {
 			Group:       nil,
 			Version:     &versionString,
 			Kind:        ObjectKind(split[6]),
-		}
+		}, nil
 	} else if split[4] == "runtime" {
 		// Name is something like: `io.k8s.apimachinery.pkg.runtime.RawExtension`.
 		return &ParsedDefinitionName{
@@ -75,7 +85,7 @@ func (dn *DefinitionName) Parse() *ParsedDefinitionName {
 			Group:       nil,
 			Version:     nil,
 			Kind:        ObjectKind(split[5]),
-		}
+		}, nil
 	} else if split[4] == "version" {
 		// Name is something like: `io.k8s.apimachinery.pkg.version.Info`.
 		return &ParsedDefinitionName{
@@ -84,11 +94,188 @@ func (dn *DefinitionName) Parse() *ParsedDefinitionName {
 			Group:       nil,
 			Version:     nil,
 			Kind:        ObjectKind(split[5]),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown package name '%s' in path: '%s'", split[4], string(*dn))
+}
+
+// `versionPattern` matches the segment of a definition name that is
+// its version, e.g., `v1` or `v2beta1`.
+var versionPattern = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// `dockerImagePattern` matches the packages of OpenShift's image API
+// that describe Docker's own image formats (e.g., `docker10` of
+// `com.github.openshift.api.image.docker10.DockerImage`), which take
+// the place of a version in the name, but are not API versions.
+var dockerImagePattern = regexp.MustCompile(`^docker(pre)?[0-9]+$`)
+
+// `parseDomain` parses a definition name that is not under
+// `io.k8s.*.pkg`, e.g., of a custom API server, or of Kubernetes' own
+// newer definitions, as a reverse domain, followed by a group, a
+// version, and a kind, e.g., `com.example.operator.v1.Widget` (group
+// `operator`) or `io.k8s.api.apps.v1.Deployment` (group `apps`).
+//
+// Names whose version is one of OpenShift's Docker image formats parse
+// without a version, so that they are left out of the library like
+// those of the `Runtime` package.
+func (dn *DefinitionName) parseDomain(split []string) (*ParsedDefinitionName, error) {
+	n := len(split)
+	if n >= 3 && dockerImagePattern.MatchString(split[n-2]) {
+		groupName := domainGroupName(split[:n-3], split[n-3])
+		return &ParsedDefinitionName{
+			PackageType: Domain,
+			Codebase:    strings.Join(split[:n-3], "."),
+			Group:       &groupName,
+			Version:     nil,
+			Kind:        ObjectKind(split[n-1]),
+			Definition:  *dn,
+		}, nil
+	}
+	if n < 3 || !versionPattern.MatchString(split[n-2]) {
+		return nil, fmt.Errorf("Failed to parse definition name '%s'", string(*dn))
+	}
+	groupName := domainGroupName(split[:n-3], split[n-3])
+	versionString := VersionString(split[n-2])
+	return &ParsedDefinitionName{
+		PackageType: Domain,
+		Codebase:    strings.Join(split[:n-3], "."),
+		Group:       &groupName,
+		Version:     &versionString,
+		Kind:        ObjectKind(split[n-1]),
+		Definition:  *dn,
+	}, nil
+}
+
+// `groupLabel` turns a label of a group's domain (e.g., `cert-manager`
+// of `cert-manager.io`) into the name of the group in the library,
+// which has to be a Jsonnet identifier (e.g., `certManager`).
+func groupLabel(label string) GroupName {
+	words := strings.Split(label, "-")
+	for i := 1; i < len(words); i++ {
+		if words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
 		}
 	}
+	return GroupName(strings.Join(words, ""))
+}
 
-	log.Fatalf("Unknown package name '%s' in path: '%s'", split[4], string(*dn))
-	return nil
+// `kubernetesGroups` are the first labels of Kubernetes' own API
+// groups (e.g., `rbac` of `rbac.authorization.k8s.io`).
+var kubernetesGroups = map[string]bool{
+	"admissionregistration": true, "apiextensions": true, "apiregistration": true,
+	"apps": true, "authentication": true, "authorization": true,
+	"autoscaling": true, "batch": true, "certificates": true,
+	"coordination": true, "discovery": true, "events": true,
+	"extensions": true, "flowcontrol": true, "networking": true,
+	"node": true, "policy": true, "rbac": true, "scheduling": true,
+	"settings": true, "storage": true,
+}
+
+// `declaredGroupName` is the name in the library of a group declared
+// in an `x-kubernetes-group-version-kind` extension. That's the label
+// of its first label (see `groupLabel`), unless the group is not
+// Kubernetes' own, but shares its first label with one of Kubernetes'
+// groups (e.g., OpenShift's `authorization.openshift.io`, which serves
+// some of the same kinds as `authorization.k8s.io`), in which case its
+// second label is prepended to keep the two apart (e.g.,
+// `openshiftAuthorization`).
+func declaredGroupName(group GroupName) GroupName {
+	labels := strings.Split(string(group), ".")
+	if len(labels) < 2 || !kubernetesGroups[labels[0]] ||
+		strings.HasSuffix(string(group), ".k8s.io") {
+		return groupLabel(labels[0])
+	}
+	return groupLabel(labels[1]) + GroupName(strings.Title(string(groupLabel(labels[0]))))
+}
+
+// `domainGroupName` is the name in the library of the group `label` of
+// a definition name parsed by `parseDomain`, whose reverse domain is
+// `codebase`. Like `declaredGroupName`, it keeps OpenShift's groups
+// apart from Kubernetes' groups of the same first label (e.g.,
+// `com.github.openshift.api.authorization.v1.PolicyRule` is in the
+// group `openshiftAuthorization`), since the names of definitions that
+// are not top-level do not say which group they belong to.
+func domainGroupName(codebase []string, label string) GroupName {
+	group := groupLabel(label)
+	if len(codebase) >= 3 && codebase[0] == "com" && codebase[1] == "github" &&
+		codebase[2] == "openshift" && kubernetesGroups[label] {
+		return "openshift" + GroupName(strings.Title(string(group)))
+	}
+	return group
+}
+
+// ParseGroupVersionKind parses the `DefinitionName` of a top-level
+// definition like `Parse`, except that its group, version, and kind are
+// those `def` declares in its `x-kubernetes-group-version-kind`
+// extension, rather than those its name suggests. The group is named
+// after the first label of the declared group (e.g., `rbac` for
+// `rbac.authorization.k8s.io`, or `certManager` for `cert-manager.io`;
+// see `declaredGroupName`), or nil for the core group. If `def`
+// declares none, it is the same as `Parse`.
+func (dn *DefinitionName) ParseGroupVersionKind(def *SchemaDefinition) *ParsedDefinitionName {
+	parsed, err := dn.ParseGroupVersionKindE(def)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return parsed
+}
+
+// ParseGroupVersionKindE is `ParseGroupVersionKind`, except that it
+// returns an error rather than exiting if the name is malformed, like
+// `ParseE`.
+func (dn *DefinitionName) ParseGroupVersionKindE(def *SchemaDefinition) (*ParsedDefinitionName, error) {
+	parsed, err := dn.ParseE()
+	if err != nil {
+		return nil, err
+	}
+	gvk := def.GroupVersionKind(*dn)
+	if gvk == nil {
+		return parsed, nil
+	}
+
+	parsed.Group = nil
+	if gvk.Group != "" {
+		group := declaredGroupName(gvk.Group)
+		parsed.Group = &group
+	}
+	version := gvk.Version
+	parsed.Version = &version
+	parsed.Kind = gvk.Kind
+	parsed.Definition = *dn
+	return parsed, nil
+}
+
+// GroupVersionKind returns the group, version, and kind `def`
+// declares in its `x-kubernetes-group-version-kind` extension, or nil
+// if it declares none, i.e., it is not a top-level API object. If it
+// declares several (e.g., a kind that was renamed, and is served under
+// both names), the one whose kind matches the definition name `dn` is
+// preferred. Among those, one of a named group is preferred over the
+// core group, since OpenShift declares its kinds under both their own
+// group (e.g., `route.openshift.io`) and the legacy core group it
+// served them under before it had groups.
+func (def *SchemaDefinition) GroupVersionKind(dn DefinitionName) *TopLevelSpec {
+	if len(def.TopLevelSpecs) == 0 {
+		return nil
+	}
+	split := strings.Split(string(dn), ".")
+	var match *TopLevelSpec
+	for _, gvk := range def.TopLevelSpecs {
+		if string(gvk.Kind) != split[len(split)-1] {
+			continue
+		}
+		if gvk.Group != "" {
+			return gvk
+		}
+		if match == nil {
+			match = gvk
+		}
+	}
+	if match != nil {
+		return match
+	}
+	return def.TopLevelSpecs[0]
 }
 
 // Name parses a `DefinitionName` from an `ObjectRef`. `ObjectRef`s
@@ -142,6 +329,13 @@ const (
 	// Version is a package that supplies version information collected
 	// at build time.
 	Version
+
+	// Domain is a group of a custom API server (e.g.,
+	// `com.example.operator.v1.Widget`), or of Kubernetes itself under
+	// its newer naming scheme (e.g., `io.k8s.api.apps.v1.Deployment`),
+	// whose name is a reverse domain, followed by the group, version,
+	// and kind.
+	Domain
 )
 
 // ParsedDefinitionName is a parsed version of a fully-qualified
@@ -154,6 +348,12 @@ type ParsedDefinitionName struct {
 	Group       *GroupName     // Pointer because it's optional.
 	Version     *VersionString // Pointer because it's optional.
 	Kind        ObjectKind
+
+	// Definition is the name this was parsed from, if its group,
+	// version, and kind came from somewhere other than the name
+	// itself (see `ParseGroupVersionKind`), so that `Unparse` can
+	// return it.
+	Definition DefinitionName
 }
 
 // GroupName represetents a Kubernetes group name (e.g., apps,
@@ -184,6 +384,10 @@ func (vs VersionString) String() string {
 // corresponding string, e.g.,
 // `io.k8s.kubernetes.pkg.api.v1.Container`.
 func (p *ParsedDefinitionName) Unparse() DefinitionName {
+	if p.Definition != "" {
+		return p.Definition
+	}
+
 	switch p.PackageType {
 	case Core:
 		{
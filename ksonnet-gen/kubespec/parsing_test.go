@@ -349,3 +349,66 @@ func TestNamespaceParser(t *testing.T) {
 		}
 	}
 }
+
+func TestDomainParser(t *testing.T) {
+	tests := map[DefinitionName]struct {
+		codebase string
+		group    GroupName
+		version  VersionString
+		kind     ObjectKind
+	}{
+		"com.example.operator.v1.Widget":                    {"com.example", "operator", "v1", "Widget"},
+		"io.k8s.api.apps.v1.Deployment":                     {"io.k8s.api", "apps", "v1", "Deployment"},
+		"io.cert-manager.cert-manager.v1alpha2.Certificate": {"io.cert-manager", "certManager", "v1alpha2", "Certificate"},
+		"operator.v2beta1.Widget":                           {"", "operator", "v2beta1", "Widget"},
+		"com.github.openshift.api.route.v1.Route":           {"com.github.openshift.api", "route", "v1", "Route"},
+		"com.github.openshift.api.authorization.v1.PolicyRule": {
+			"com.github.openshift.api", "openshiftAuthorization", "v1", "PolicyRule"},
+	}
+	for dn, expected := range tests {
+		parsed, err := dn.ParseE()
+		if err != nil {
+			t.Errorf("[%s] Failed to parse:\n%v", dn, err)
+			continue
+		}
+		if parsed.PackageType != Domain || parsed.Codebase != expected.codebase ||
+			*parsed.Group != expected.group || *parsed.Version != expected.version ||
+			parsed.Kind != expected.kind {
+			t.Errorf("[%s] Expected %v, got %+v", dn, expected, parsed)
+		}
+		if parsed.Unparse() != dn {
+			t.Errorf("[%s] Expected to unparse to itself, got '%s'", dn, parsed.Unparse())
+		}
+	}
+
+	for _, dn := range []DefinitionName{"Quantity", "com.example.Widget", "io.k8s.foo.pkg.v1"} {
+		if _, err := dn.ParseE(); err == nil {
+			t.Errorf("[%s] Expected name to fail to parse", dn)
+		}
+	}
+
+	dn := DefinitionName("com.github.openshift.api.image.docker10.DockerImage")
+	parsed, err := dn.ParseE()
+	if err != nil {
+		t.Fatalf("[%s] Failed to parse:\n%v", dn, err)
+	}
+	if parsed.Version != nil || parsed.Kind != "DockerImage" {
+		t.Errorf("[%s] Expected kind 'DockerImage' without a version, got %+v", dn, parsed)
+	}
+}
+
+func TestDeclaredGroupName(t *testing.T) {
+	tests := map[GroupName]GroupName{
+		"apps":                       "apps",
+		"rbac.authorization.k8s.io":  "rbac",
+		"cert-manager.io":            "certManager",
+		"route.openshift.io":         "route",
+		"authorization.openshift.io": "openshiftAuthorization",
+		"apps.openshift.io":          "openshiftApps",
+	}
+	for group, expected := range tests {
+		if name := declaredGroupName(group); name != expected {
+			t.Errorf("[%s] Expected group name '%s', got '%s'", group, expected, name)
+		}
+	}
+}
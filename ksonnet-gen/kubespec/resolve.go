@@ -0,0 +1,294 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// External references.
+//-----------------------------------------------------------------------------
+
+// ResolveOptions configures `ResolveExternalRefs`.
+type ResolveOptions struct {
+	// LocalOnly rejects `$ref`s to URLs, so that the spec only depends
+	// on files next to it, for reproducible builds.
+	LocalOnly bool
+	// Fetch downloads the document at a URL. If it is nil, `$ref`s to
+	// URLs are rejected.
+	Fetch func(url string) ([]byte, error)
+}
+
+// ResolveExternalRefs stitches the definitions that the `$ref`s of the
+// spec `specText` refer to in other documents (e.g.,
+// `common.json#/definitions/Foo`, or a URL) into its `definitions`,
+// and rewrites those `$ref`s to refer to them there (e.g.,
+// `#/definitions/Foo`), so that the spec can be built like any other.
+// `location` is the path or URL of the spec, which relative `$ref`s
+// are relative to. References of the stitched definitions are resolved
+// the same way, relative to the document they are in.
+//
+// A stitched definition is named after the last segment of the JSON
+// pointer of its `$ref`, or, if there is none, after the document
+// (e.g., `Foo` for `foo.json`). It is an error for it to differ from a
+// definition of the same name that is already in the spec.
+//
+// If the spec has no external `$ref`s, `specText` is returned as is.
+func ResolveExternalRefs(
+	specText []byte, location string, opts ResolveOptions,
+) ([]byte, error) {
+	spec := make(map[string]interface{})
+	if err := json.Unmarshal(specText, &spec); err != nil {
+		return nil, fmt.Errorf("Could not deserialize schema:\n%v", err)
+	}
+	definitions, ok := spec["definitions"].(map[string]interface{})
+	if !ok || !hasExternalRef(definitions) {
+		return specText, nil
+	}
+
+	r := resolver{
+		opts:        opts,
+		root:        location,
+		definitions: definitions,
+		documents:   make(map[string]map[string]interface{}),
+		resolved:    make(map[string]string),
+	}
+	for _, name := range sortedKeys(definitions) {
+		if err := r.rewrite(definitions[name], location); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(spec)
+}
+
+type resolver struct {
+	opts ResolveOptions
+	// `root` is the location of the spec, whose own `#` references
+	// are left as they are.
+	root        string
+	definitions map[string]interface{}
+	// `documents` caches external documents by location.
+	documents map[string]map[string]interface{}
+	// `resolved` maps every resolved reference (the location of its
+	// document, `#`, and its JSON pointer) to the name of the
+	// definition it was stitched into the spec as.
+	resolved map[string]string
+}
+
+// `rewrite` rewrites every `$ref` in `value`, which is in the document
+// at `location`, to refer to a definition of the spec.
+func (r *resolver) rewrite(value interface{}, location string) error {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			ref, isRef := value[key].(string)
+			if key != "$ref" || !isRef {
+				if err := r.rewrite(value[key], location); err != nil {
+					return err
+				}
+				continue
+			}
+			if location == r.root && strings.HasPrefix(ref, "#") {
+				continue
+			}
+			name, err := r.resolve(location, ref)
+			if err != nil {
+				return err
+			}
+			value[key] = "#/definitions/" + name
+		}
+	case []interface{}:
+		for _, element := range value {
+			if err := r.rewrite(element, location); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// `resolve` stitches the definition `ref`, a reference in the document
+// at `location`, into the spec, and returns its name there.
+func (r *resolver) resolve(location, ref string) (string, error) {
+	documentRef, pointer := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		documentRef, pointer = ref[:i], ref[i+1:]
+	}
+	target := location
+	if documentRef != "" {
+		var err error
+		if target, err = joinLocation(location, documentRef); err != nil {
+			return "", err
+		}
+	}
+
+	key := target + "#" + pointer
+	if name, ok := r.resolved[key]; ok {
+		return name, nil
+	}
+
+	document, err := r.load(target)
+	if err != nil {
+		return "", err
+	}
+	definition, name, err := lookupPointer(document, pointer)
+	if err != nil {
+		return "", fmt.Errorf("Could not resolve reference '%s' in '%s':\n%v", ref, location, err)
+	}
+	if name == "" {
+		name = documentName(target)
+	}
+
+	// Record the name first, so that references back to this
+	// definition, e.g., of a recursive type, resolve to it.
+	r.resolved[key] = name
+	definition = deepCopy(definition)
+	if err := r.rewrite(definition, target); err != nil {
+		return "", err
+	}
+	if existing, ok := r.definitions[name]; ok && !reflect.DeepEqual(existing, definition) {
+		return "", fmt.Errorf(
+			"Definition '%s' that '%s' in '%s' refers to differs from the spec's definition of the same name",
+			name, ref, location)
+	}
+	r.definitions[name] = definition
+	return name, nil
+}
+
+// `load` reads and deserializes the document at `location`.
+func (r *resolver) load(location string) (map[string]interface{}, error) {
+	if document, ok := r.documents[location]; ok {
+		return document, nil
+	}
+
+	var text []byte
+	var err error
+	switch {
+	case !isURL(location):
+		text, err = ioutil.ReadFile(location)
+	case r.opts.LocalOnly:
+		return nil, fmt.Errorf(
+			"Could not load '%s': references to URLs are not allowed, only to local files", location)
+	case r.opts.Fetch == nil:
+		return nil, fmt.Errorf("Could not load '%s': references to URLs are not supported", location)
+	default:
+		text, err = r.opts.Fetch(location)
+	}
+	if err == nil {
+		text, err = Decompress(text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not load '%s':\n%v", location, err)
+	}
+
+	document := make(map[string]interface{})
+	if err := json.Unmarshal(text, &document); err != nil {
+		return nil, fmt.Errorf("Could not deserialize '%s':\n%v", location, err)
+	}
+	r.documents[location] = document
+	return document, nil
+}
+
+// `hasExternalRef` reports whether any `$ref` in `value` refers to
+// another document.
+func hasExternalRef(value interface{}) bool {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, v := range value {
+			if ref, ok := v.(string); ok && key == "$ref" && !strings.HasPrefix(ref, "#") {
+				return true
+			}
+			if hasExternalRef(v) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, element := range value {
+			if hasExternalRef(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// `lookupPointer` returns the value at the JSON pointer `pointer` in
+// `document`, and the last segment of the pointer, if any.
+func lookupPointer(document map[string]interface{}, pointer string) (interface{}, string, error) {
+	var value interface{} = document
+	name := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		segment = strings.Replace(strings.Replace(segment, "~1", "/", -1), "~0", "~", -1)
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("'%s' is not an object", name)
+		}
+		if value, ok = object[segment]; !ok {
+			return nil, "", fmt.Errorf("No value at '%s'", pointer)
+		}
+		name = segment
+	}
+	if _, ok := value.(map[string]interface{}); !ok {
+		return nil, "", fmt.Errorf("Value at '%s' is not a schema", pointer)
+	}
+	return value, name, nil
+}
+
+// `joinLocation` resolves `ref`, the path or URL of a document, which
+// is relative to `base`, the location of the document it is in.
+func joinLocation(base, ref string) (string, error) {
+	if isURL(base) {
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		refURL, err := url.Parse(ref)
+		if err != nil {
+			return "", err
+		}
+		return baseURL.ResolveReference(refURL).String(), nil
+	}
+	if isURL(ref) || filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	return filepath.Join(filepath.Dir(base), filepath.FromSlash(ref)), nil
+}
+
+// `documentName` names a definition that is a whole document after the
+// document, e.g., `Foo` for `schemas/Foo.json`.
+func documentName(location string) string {
+	name := path.Base(filepath.ToSlash(location))
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, v := range value {
+			copied[key] = deepCopy(v)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, v := range value {
+			copied[i] = deepCopy(v)
+		}
+		return copied
+	default:
+		return value
+	}
+}
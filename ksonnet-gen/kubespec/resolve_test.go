@@ -0,0 +1,165 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var splitSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Widget": {
+      "properties": {
+        "metadata": {"$ref": "common.json#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"},
+        "size": {"$ref": "types/Size.json"},
+        "status": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetStatus"},
+        "owner": {"$ref": "https://example.com/schemas/owner.json#/definitions/Owner"}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v1.WidgetStatus": {
+      "properties": {"ready": {"type": "boolean"}}
+    }
+  }
+}`
+
+var splitFiles = map[string]string{
+	"common.json": `{
+  "definitions": {
+    "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta": {
+      "properties": {
+        "name": {"type": "string"},
+        "initializers": {"$ref": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.Initializers"}
+      }
+    },
+    "io.k8s.apimachinery.pkg.apis.meta.v1.Initializers": {
+      "properties": {"pending": {"type": "array", "items": {"type": "string"}}}
+    }
+  }
+}`,
+	"types/Size.json": `{"type": "integer"}`,
+}
+
+func writeSplitSpec(t *testing.T, spec string) string {
+	dir, err := ioutil.TempDir("", "resolve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"spec.json": spec}
+	for name, text := range splitFiles {
+		files[name] = text
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResolveExternalRefs(t *testing.T) {
+	dir := writeSplitSpec(t, splitSpec)
+	defer os.RemoveAll(dir)
+
+	fetched := []string{}
+	opts := ResolveOptions{
+		Fetch: func(url string) ([]byte, error) {
+			fetched = append(fetched, url)
+			return []byte(`{"definitions": {"Owner": {"properties": {"name": {"type": "string"}}}}}`), nil
+		},
+	}
+	text, err := ResolveExternalRefs([]byte(splitSpec), filepath.Join(dir, "spec.json"), opts)
+	if err != nil {
+		t.Fatalf("Failed to resolve references:\n%v", err)
+	}
+	if len(fetched) != 1 || fetched[0] != "https://example.com/schemas/owner.json" {
+		t.Errorf("Expected owner.json to be fetched once, got %v", fetched)
+	}
+
+	spec := APISpec{}
+	if err := json.Unmarshal(text, &spec); err != nil {
+		t.Fatalf("Could not deserialize resolved spec:\n%v", err)
+	}
+	widget := spec.Definitions["io.k8s.kubernetes.pkg.api.v1.Widget"]
+	tests := map[PropertyName]ObjectRef{
+		"metadata": "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta",
+		"size":     "#/definitions/Size",
+		"status":   "#/definitions/io.k8s.kubernetes.pkg.api.v1.WidgetStatus",
+		"owner":    "#/definitions/Owner",
+	}
+	for name, expected := range tests {
+		if ref := widget.Properties[name].Ref; ref == nil || *ref != expected {
+			t.Errorf("Expected '%s' to refer to '%s', got %v", name, expected, ref)
+		}
+	}
+	for _, name := range []DefinitionName{
+		"io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta",
+		"io.k8s.apimachinery.pkg.apis.meta.v1.Initializers",
+		"Size",
+		"Owner",
+	} {
+		if _, ok := spec.Definitions[name]; !ok {
+			t.Errorf("Expected definition '%s' to be stitched into the spec", name)
+		}
+	}
+	meta := spec.Definitions["io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"]
+	if ref := meta.Properties["initializers"].Ref; ref == nil ||
+		*ref != "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.Initializers" {
+		t.Errorf("Expected reference in 'common.json' to be rewritten, got %v", ref)
+	}
+}
+
+func TestResolveExternalRefsErrors(t *testing.T) {
+	dir := writeSplitSpec(t, splitSpec)
+	defer os.RemoveAll(dir)
+	location := filepath.Join(dir, "spec.json")
+
+	tests := map[string]struct {
+		spec     string
+		opts     ResolveOptions
+		expected string
+	}{
+		"localOnly": {
+			spec:     splitSpec,
+			opts:     ResolveOptions{LocalOnly: true},
+			expected: "references to URLs are not allowed",
+		},
+		"noFetch": {
+			spec:     splitSpec,
+			expected: "references to URLs are not supported",
+		},
+		"missing": {
+			spec:     strings.Replace(splitSpec, "types/Size.json", "types/Missing.json", 1),
+			expected: "Could not load",
+		},
+		"conflict": {
+			spec: strings.Replace(
+				splitSpec, `"io.k8s.kubernetes.pkg.api.v1.WidgetStatus": {`,
+				`"Size": {"type": "string"}, "io.k8s.kubernetes.pkg.api.v1.WidgetStatus": {`, 1),
+			opts: ResolveOptions{Fetch: func(string) ([]byte, error) {
+				return []byte(`{"definitions": {"Owner": {}}}`), nil
+			}},
+			expected: "Definition 'Size'",
+		},
+	}
+	for name, test := range tests {
+		_, err := ResolveExternalRefs([]byte(test.spec), location, test.opts)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("[%s] Expected error '%s', got %v", name, test.expected, err)
+		}
+	}
+
+	unchanged := `{"definitions": {"A": {"properties": {"b": {"$ref": "#/definitions/B"}}}}}`
+	text, err := ResolveExternalRefs([]byte(unchanged), location, ResolveOptions{})
+	if err != nil || string(text) != unchanged {
+		t.Errorf("Expected spec without external references to be unchanged, got %s, %v", text, err)
+	}
+}
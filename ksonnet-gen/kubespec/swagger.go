@@ -1,5 +1,10 @@
 package kubespec
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 // APISpec represents an OpenAPI specification of an API.
 type APISpec struct {
 	SwaggerVersion string            `json:"swagger"`
@@ -14,6 +19,8 @@ type APISpec struct {
 	// Not part of the OpenAPI spec. Filled in later.
 	FilePath string
 	Text     []byte
+	// MergeConflicts is set by `Merge`.
+	MergeConflicts []MergeConflict
 }
 
 // SchemaInfo contains information about the the API represented with
@@ -34,6 +41,26 @@ type SchemaDefinition struct {
 	Required      []string      `json:"required"`    // nullable.
 	Properties    Properties    `json:"properties"`  // nullable.
 	TopLevelSpecs TopLevelSpecs `json:"x-kubernetes-group-version-kind"`
+	// Ref and AllOf are set for definitions that are (partly) made of
+	// other schemas. `Normalize` folds them into the definition, or
+	// into the definitions that refer to it.
+	Ref   *ObjectRef          `json:"$ref"`
+	AllOf []*SchemaDefinition `json:"allOf"`
+	// Extensions holds the vendor extensions of the definition, i.e.,
+	// the values of all of its `x-` keys (see `extensions`).
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON deserializes a definition, along with its
+// `Extensions`.
+func (sd *SchemaDefinition) UnmarshalJSON(text []byte) error {
+	type plain SchemaDefinition
+	if err := json.Unmarshal(text, (*plain)(sd)); err != nil {
+		return err
+	}
+	exts, err := extensions(text)
+	sd.Extensions = exts
+	return err
 }
 
 // TopLevelSpec is a property that exists on `SchemaDefinition`s for
@@ -58,6 +85,122 @@ type Property struct {
 	Type        *SchemaType `json:"type"`
 	Ref         *ObjectRef  `json:"$ref"`
 	Items       Items       `json:"items"` // nil unless Type == "array".
+	// PatchStrategy is how strategic merge patches combine values of
+	// this property, e.g., `merge` or `replace`. Several strategies may
+	// be listed, separated by commas (e.g., `merge,retainKeys`).
+	PatchStrategy string `json:"x-kubernetes-patch-strategy"`
+	// PatchMergeKey is, for arrays of objects whose patch strategy is
+	// `merge`, the field that identifies an element (e.g., `name` for
+	// `containers`), so that patches merge elements with the same key
+	// rather than appending them.
+	PatchMergeKey string `json:"x-kubernetes-patch-merge-key"`
+	// ListType is, for arrays, how server-side apply combines their
+	// elements: `atomic` (the array is replaced as a whole), `set`
+	// (elements are unique scalars), or `map` (elements are objects
+	// identified by the fields listed in `ListMapKeys`).
+	ListType    string   `json:"x-kubernetes-list-type"`
+	ListMapKeys []string `json:"x-kubernetes-list-map-keys"`
+	// AdditionalProperties is set for object properties that are maps
+	// (e.g., `labels`), and holds the schema of their values.
+	AdditionalProperties *AdditionalProperties `json:"additionalProperties"`
+	// Enum lists the values the property can have (e.g., `Always`,
+	// `IfNotPresent`, and `Never` for `imagePullPolicy`), if they are
+	// restricted.
+	Enum []interface{} `json:"enum"`
+	// Default is the value the API server gives the property if it is
+	// not set, or nil.
+	Default interface{} `json:"default"`
+	// PreserveUnknownFields is set for objects whose fields are not
+	// described by the spec (e.g., the arbitrary configuration of a
+	// CRD), which the API server keeps as is.
+	PreserveUnknownFields bool `json:"x-kubernetes-preserve-unknown-fields"`
+	// IntOrString is set for properties whose values are either an
+	// integer or a string, like those that `$ref` `IntOrString`.
+	IntOrString bool `json:"x-kubernetes-int-or-string"`
+	// AllOf lists schemas the property's values must all match, e.g.,
+	// a `$ref` along with a description. `Normalize` folds them into
+	// the property.
+	AllOf []*Property `json:"allOf"`
+	// Extensions holds the vendor extensions of the property, i.e., the
+	// values of all of its `x-` keys (see `extensions`).
+	Extensions map[string]interface{} `json:"-"`
+	Constraints
+}
+
+// UnmarshalJSON deserializes a property, along with its `Extensions`.
+func (p *Property) UnmarshalJSON(text []byte) error {
+	type plain Property
+	if err := json.Unmarshal(text, (*plain)(p)); err != nil {
+		return err
+	}
+	exts, err := extensions(text)
+	p.Extensions = exts
+	return err
+}
+
+// `extensions` returns the values of the `x-` keys of the JSON object
+// `text`, or nil if it has none. Extensions the generator understands
+// (e.g., `x-kubernetes-patch-strategy`) are included too, so that
+// tools that act on extensions see all of them in one place.
+func extensions(text []byte) (map[string]interface{}, error) {
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(text, &raw); err != nil {
+		return nil, err
+	}
+	var exts map[string]interface{}
+	for key, value := range raw {
+		if !strings.HasPrefix(key, "x-") {
+			continue
+		}
+		var ext interface{}
+		if err := json.Unmarshal(value, &ext); err != nil {
+			return nil, err
+		}
+		if exts == nil {
+			exts = make(map[string]interface{})
+		}
+		exts[key] = ext
+	}
+	return exts, nil
+}
+
+// AdditionalProperties is the `additionalProperties` of a `Property`,
+// i.e., the schema of the values of a map. JSON schema also allows a
+// boolean here, which leaves the values unconstrained, so `Schema` is
+// nil.
+type AdditionalProperties struct {
+	Schema *Property
+}
+
+// UnmarshalJSON accepts either a schema or a boolean.
+func (ap *AdditionalProperties) UnmarshalJSON(text []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(text, &allowed); err == nil {
+		return nil
+	}
+	ap.Schema = &Property{}
+	return json.Unmarshal(text, ap.Schema)
+}
+
+// Constraints are the validation keywords of a `Property`, which
+// restrict the values it can have beyond its type, e.g., that a string
+// matches some `pattern`, or has some `format` (e.g., `int32`, or
+// `date-time`). Bounds that are not declared are nil.
+type Constraints struct {
+	Format    string   `json:"format"`
+	Pattern   string   `json:"pattern"`
+	Minimum   *float64 `json:"minimum"`
+	Maximum   *float64 `json:"maximum"`
+	MinLength *int     `json:"minLength"`
+	MaxLength *int     `json:"maxLength"`
+	MinItems  *int     `json:"minItems"`
+	MaxItems  *int     `json:"maxItems"`
+	// ExclusiveMinimum and ExclusiveMaximum are set if `Minimum` and
+	// `Maximum` themselves are out of bounds.
+	ExclusiveMinimum bool `json:"exclusiveMinimum"`
+	ExclusiveMaximum bool `json:"exclusiveMaximum"`
+	// UniqueItems is set for arrays whose items must all be different.
+	UniqueItems bool `json:"uniqueItems"`
 }
 
 // Properties is a named collection of `Properties`s, represented as a
@@ -68,10 +211,13 @@ type Properties map[PropertyName]*Property
 // is used to fully specify a `Property` object whose `type` field is
 // `"array"`.
 type Items struct {
-	Ref *ObjectRef `json:"$ref"`
+	Ref  *ObjectRef  `json:"$ref"`
+	Type *SchemaType `json:"type"`
+	// Enum lists the values the elements can have (e.g., `ReadWriteOnce`
+	// for `accessModes`), if they are restricted.
+	Enum []interface{} `json:"enum"`
 
 	// Ignored fields:
-	// - Type *SchemaType `json:"type"`
 	// - Format *string `json:"format"`
 }
 
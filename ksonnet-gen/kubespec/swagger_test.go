@@ -0,0 +1,64 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var extensionsSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "com.example.operator.v1.Widget": {
+      "properties": {
+        "size": {
+          "type": "integer",
+          "minimum": 0,
+          "x-example-unit": "replicas",
+          "x-example-scale": {"min": 1, "max": 10}
+        },
+        "labels": {
+          "type": "object",
+          "additionalProperties": {"type": "string", "x-example-label": true}
+        },
+        "color": {"type": "string"}
+      },
+      "x-example-owner": "widgets-team",
+      "x-kubernetes-group-version-kind": [{"Group": "operator.example.com", "Version": "v1", "Kind": "Widget"}]
+    }
+  }
+}`
+
+func TestExtensions(t *testing.T) {
+	spec := APISpec{}
+	if err := json.Unmarshal([]byte(extensionsSpec), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	widget := spec.Definitions["com.example.operator.v1.Widget"]
+	if widget.Extensions["x-example-owner"] != "widgets-team" {
+		t.Errorf("Expected definition extension 'x-example-owner', got %v", widget.Extensions)
+	}
+	if _, ok := widget.Extensions["x-kubernetes-group-version-kind"]; !ok || len(widget.TopLevelSpecs) != 1 {
+		t.Errorf("Expected extensions with fields of their own to be kept in both, got %v", widget.Extensions)
+	}
+
+	size := widget.Properties["size"]
+	expected := map[string]interface{}{
+		"x-example-unit":  "replicas",
+		"x-example-scale": map[string]interface{}{"min": 1.0, "max": 10.0},
+	}
+	if !reflect.DeepEqual(size.Extensions, expected) {
+		t.Errorf("Expected property extensions %v, got %v", expected, size.Extensions)
+	}
+	if size.Minimum == nil || *size.Minimum != 0 {
+		t.Errorf("Expected constraints to still be deserialized, got %+v", size.Constraints)
+	}
+	if labels := widget.Properties["labels"]; labels.AdditionalProperties.Schema.Extensions["x-example-label"] != true {
+		t.Errorf("Expected extensions of map values, got %+v", labels.AdditionalProperties.Schema)
+	}
+	if color := widget.Properties["color"]; color.Extensions != nil {
+		t.Errorf("Expected no extensions, got %v", color.Extensions)
+	}
+}
@@ -0,0 +1,152 @@
+package kubespec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// Validation of specs.
+//-----------------------------------------------------------------------------
+
+// Check names a kind of problem `Validate` looks for.
+type Check string
+
+const (
+	// CheckUnparseableName is a definition whose name is not of a form
+	// the generator understands (e.g., `io.k8s.kubernetes.pkg.api.v1.Pod`).
+	CheckUnparseableName Check = "unparseable-name"
+	// CheckMissingVersion is a definition that has no API version,
+	// either in its name or in its `x-kubernetes-group-version-kind`,
+	// and so is left out of the library.
+	CheckMissingVersion Check = "missing-version"
+	// CheckDanglingRef is a `$ref` to a definition the spec lacks.
+	CheckDanglingRef Check = "dangling-ref"
+	// CheckDuplicateKind is a definition of the same group, version,
+	// and kind as another one.
+	CheckDuplicateKind Check = "duplicate-kind"
+)
+
+// Problem is something `Validate` found wrong with one definition of a
+// spec.
+type Problem struct {
+	Check      Check
+	Definition DefinitionName
+	Message    string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Definition, p.Check, p.Message)
+}
+
+// Validate checks `spec` for problems that would keep the generator
+// from building a library out of it, or would leave parts of it out of
+// the library: definitions with unparseable names or no version,
+// `$ref`s to definitions that do not exist, and definitions of the
+// same kind. Problems are returned sorted by definition.
+func Validate(spec *APISpec) []Problem {
+	problems := []Problem{}
+	problemf := func(check Check, name DefinitionName, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Check:      check,
+			Definition: name,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	names := []string{}
+	for name := range spec.Definitions {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	kinds := make(map[string]DefinitionName)
+	for _, n := range names {
+		name := DefinitionName(n)
+		def := spec.Definitions[name]
+
+		for _, propName := range sortedPropertyNames(def.Properties) {
+			for _, ref := range propertyRefs(def.Properties[propName]) {
+				if !refersToDefinition(spec, ref) {
+					problemf(CheckDanglingRef, name,
+						"property '%s' refers to '%s', which is not a definition of the spec", propName, ref)
+				}
+			}
+		}
+
+		parsed, err := name.ParseE()
+		if err != nil {
+			problemf(CheckUnparseableName, name, "%v", err)
+			continue
+		}
+		version := parsed.Version
+		if gvk := def.GroupVersionKind(name); gvk != nil {
+			version = &gvk.Version
+			if gvk.Version == "" {
+				version = nil
+			}
+		}
+		if version == nil {
+			problemf(CheckMissingVersion, name, "definition has no version, so it is left out of the library")
+			continue
+		}
+
+		parsed = name.ParseGroupVersionKind(def)
+		group := "core"
+		if parsed.Group != nil {
+			group = string(*parsed.Group)
+		}
+		key := fmt.Sprintf("%t/%s/%s/%s", len(def.TopLevelSpecs) > 0, group, *parsed.Version, parsed.Kind)
+		if other, ok := kinds[key]; ok {
+			problemf(CheckDuplicateKind, name,
+				"kind '%s' of group '%s', version '%s' is also defined by '%s'",
+				parsed.Kind, group, *parsed.Version, other)
+			continue
+		}
+		kinds[key] = name
+	}
+	return problems
+}
+
+// `propertyRefs` returns every `$ref` of `prop`, including those of
+// its array items and map values.
+func propertyRefs(prop *Property) []ObjectRef {
+	refs := []ObjectRef{}
+	for prop != nil {
+		if prop.Ref != nil {
+			refs = append(refs, *prop.Ref)
+		}
+		if prop.Items.Ref != nil {
+			refs = append(refs, *prop.Items.Ref)
+		}
+		if prop.AdditionalProperties == nil {
+			break
+		}
+		prop = prop.AdditionalProperties.Schema
+	}
+	return refs
+}
+
+func refersToDefinition(spec *APISpec, ref ObjectRef) bool {
+	const prefix = "#/definitions/"
+	if !strings.HasPrefix(string(ref), prefix) {
+		return false
+	}
+	_, ok := spec.Definitions[DefinitionName(strings.TrimPrefix(string(ref), prefix))]
+	return ok
+}
+
+func sortedPropertyNames(props Properties) []PropertyName {
+	names := []string{}
+	for name := range props {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	sorted := make([]PropertyName, len(names))
+	for i, name := range names {
+		sorted[i] = PropertyName(name)
+	}
+	return sorted
+}
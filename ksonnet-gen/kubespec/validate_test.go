@@ -0,0 +1,70 @@
+package kubespec
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var invalidSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.api.v1.Pod": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodSpec"},
+        "status": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.PodStatus"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Pod"}]
+    },
+    "io.k8s.kubernetes.pkg.api.v1.PodSpec": {
+      "properties": {
+        "volumes": {"type": "array", "items": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.api.v1.Volume"}},
+        "overhead": {"type": "object", "additionalProperties": {"$ref": "#/definitions/Quantity"}}
+      }
+    },
+    "io.k8s.kubernetes.pkg.api.v2.Pod": {
+      "x-kubernetes-group-version-kind": [{"Group": "", "Version": "v1", "Kind": "Pod"}]
+    },
+    "io.k8s.apimachinery.pkg.runtime.RawExtension": {},
+    "Quantity": {"type": "string"}
+  }
+}`
+
+func TestValidate(t *testing.T) {
+	spec := APISpec{}
+	if err := json.Unmarshal([]byte(invalidSpec), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Problem{
+		{CheckUnparseableName, "Quantity", ""},
+		{CheckMissingVersion, "io.k8s.apimachinery.pkg.runtime.RawExtension", ""},
+		{CheckDanglingRef, "io.k8s.kubernetes.pkg.api.v1.Pod", ""},
+		{CheckDanglingRef, "io.k8s.kubernetes.pkg.api.v1.PodSpec", ""},
+		{CheckDuplicateKind, "io.k8s.kubernetes.pkg.api.v2.Pod", ""},
+	}
+	problems := Validate(&spec)
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got:\n%v", len(expected), problems)
+	}
+	for i, problem := range problems {
+		if problem.Check != expected[i].Check || problem.Definition != expected[i].Definition {
+			t.Errorf("Expected problem %d to be %s of '%s', got:\n%v",
+				i, expected[i].Check, expected[i].Definition, problem)
+		}
+	}
+}
+
+func TestValidateCleanSpec(t *testing.T) {
+	spec := APISpec{}
+	if err := json.Unmarshal([]byte(splitSpec), &spec); err != nil {
+		t.Fatal(err)
+	}
+	delete(spec.Definitions["io.k8s.kubernetes.pkg.api.v1.Widget"].Properties, "metadata")
+	delete(spec.Definitions["io.k8s.kubernetes.pkg.api.v1.Widget"].Properties, "size")
+	delete(spec.Definitions["io.k8s.kubernetes.pkg.api.v1.Widget"].Properties, "owner")
+
+	if problems := Validate(&spec); len(problems) != 0 {
+		t.Errorf("Expected no problems, got:\n%v", problems)
+	}
+}
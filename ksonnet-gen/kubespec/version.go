@@ -0,0 +1,56 @@
+package kubespec
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// kubeVersionPattern matches the API versions Kubernetes knows how to
+// order, e.g., `v1`, `v2beta1`, and `v1alpha3`.
+var kubeVersionPattern = regexp.MustCompile(`^v([1-9][0-9]*)(?:(alpha|beta)([1-9][0-9]*))?$`)
+
+// versionStability ranks the stability levels of API versions, from
+// the highest priority.
+var versionStability = map[string]int{"": 0, "beta": 1, "alpha": 2}
+
+// HasPriorityOver reports whether `vs` comes before `other` in
+// Kubernetes' priority of API versions (see
+// `CompareKubeAwareVersionStrings` in k8s.io/apimachinery): GA
+// versions come first, then beta, then alpha versions, each from the
+// highest version number down (e.g., `v2`, `v1`, `v1beta2`,
+// `v1beta1`, `v1alpha1`), so that `v10` comes before `v2`, rather than
+// after `v1`. Versions that don't look like Kubernetes versions (e.g.,
+// `foo1`) come last, in lexical order.
+func (vs VersionString) HasPriorityOver(other VersionString) bool {
+	a := kubeVersionPattern.FindStringSubmatch(string(vs))
+	b := kubeVersionPattern.FindStringSubmatch(string(other))
+	switch {
+	case a == nil && b == nil:
+		return vs < other
+	case a == nil || b == nil:
+		return a != nil
+	}
+
+	if versionStability[a[2]] != versionStability[b[2]] {
+		return versionStability[a[2]] < versionStability[b[2]]
+	}
+	if major, otherMajor := atoi(a[1]), atoi(b[1]); major != otherMajor {
+		return major > otherMajor
+	}
+	return atoi(a[3]) > atoi(b[3])
+}
+
+// SortVersions sorts `versions` by priority (see `HasPriorityOver`),
+// so that the preferred version comes first.
+func SortVersions(versions []VersionString) {
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].HasPriorityOver(versions[j])
+	})
+}
+
+func atoi(s string) int {
+	// The pattern only matches numbers, or "", which is 0.
+	n, _ := strconv.Atoi(s)
+	return n
+}
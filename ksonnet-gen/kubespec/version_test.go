@@ -0,0 +1,21 @@
+package kubespec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortVersions(t *testing.T) {
+	versions := []VersionString{
+		"v1alpha1", "foo10", "v1beta1", "v10", "v2", "v1", "v11alpha2",
+		"v12alpha1", "v10beta3", "v1beta2", "foo1", "v3beta1",
+	}
+	SortVersions(versions)
+	expected := []VersionString{
+		"v10", "v2", "v1", "v10beta3", "v3beta1", "v1beta2", "v1beta1",
+		"v12alpha1", "v11alpha2", "v1alpha1", "foo1", "foo10",
+	}
+	if !reflect.DeepEqual(versions, expected) {
+		t.Errorf("Expected %v, got %v", expected, versions)
+	}
+}
@@ -100,12 +100,12 @@ var versions = map[string]versionData{
 			"io.k8s.kubernetes.pkg.apis.authentication.v1.TokenReview": []CustomConstructorSpec{
 				newConstructor(
 					"new",
-					newParamNestedRef("token", "mixin.spec.token")),
+					newParamNestedRef("token", "spec.token")),
 			},
 			"io.k8s.kubernetes.pkg.apis.authentication.v1beta1.TokenReview": []CustomConstructorSpec{
 				newConstructor(
 					"new",
-					newParamNestedRef("token", "mixin.spec.token")),
+					newParamNestedRef("token", "spec.token")),
 			},
 
 			//
@@ -136,7 +136,7 @@ var versions = map[string]versionData{
 			"io.k8s.kubernetes.pkg.api.v1.ConfigMap": []CustomConstructorSpec{
 				newConstructor(
 					"new",
-					newParamNestedRef("name", "mixin.metadata.name"),
+					newParamNestedRef("name", "metadata.name"),
 					newParam("data")),
 			},
 			"io.k8s.kubernetes.pkg.api.v1.ConfigMapList": objectList,
@@ -153,12 +153,12 @@ var versions = map[string]versionData{
 				newConstructor(
 					"fromSecretRef",
 					newParam("name"),
-					newParamNestedRef("secretRefName", "mixin.valueFrom.secretKeyRef.withName"),
-					newParamNestedRef("secretRefKey", "mixin.valueFrom.secretKeyRef.withKey")),
+					newParamNestedRef("secretRefName", "valueFrom.secretKeyRef.name"),
+					newParamNestedRef("secretRefKey", "valueFrom.secretKeyRef.key")),
 				newConstructor(
 					"fromFieldPath",
 					newParam("name"),
-					newParamNestedRef("fieldPath", "mixin.valueFrom.fieldRef.withFieldPath")),
+					newParamNestedRef("fieldPath", "valueFrom.fieldRef.fieldPath")),
 			},
 			"io.k8s.kubernetes.pkg.api.v1.EventList": objectList,
 			"io.k8s.kubernetes.pkg.api.v1.KeyToPath": []CustomConstructorSpec{
@@ -168,7 +168,7 @@ var versions = map[string]versionData{
 			"io.k8s.kubernetes.pkg.api.v1.Namespace": []CustomConstructorSpec{
 				newConstructor(
 					"new",
-					newParamNestedRef("name", "mixin.metadata.name")),
+					newParamNestedRef("name", "metadata.name")),
 			},
 			"io.k8s.kubernetes.pkg.api.v1.NamespaceList":             objectList,
 			"io.k8s.kubernetes.pkg.api.v1.NodeList":                  objectList,
@@ -181,12 +181,12 @@ var versions = map[string]versionData{
 			"io.k8s.kubernetes.pkg.api.v1.Secret": []CustomConstructorSpec{
 				newConstructor(
 					"new",
-					newParamNestedRef("name", "mixin.metadata.name"),
+					newParamNestedRef("name", "metadata.name"),
 					newParam("data"),
 					newParamWithDefault("type", "\"Opaque\"")),
 				newConstructor(
 					"fromString",
-					newParamNestedRef("name", "mixin.metadata.name"),
+					newParamNestedRef("name", "metadata.name"),
 					newParam("stringData"),
 					newParamWithDefault("type", "\"Opaque\"")),
 			},
@@ -194,14 +194,14 @@ var versions = map[string]versionData{
 			"io.k8s.kubernetes.pkg.api.v1.Service": []CustomConstructorSpec{
 				newConstructor(
 					"new",
-					newParamNestedRef("name", "mixin.metadata.withName"),
-					newParamNestedRef("selector", "mixin.spec.withSelector"),
-					newParamNestedRef("ports", "mixin.spec.withPorts")),
+					newParamNestedRef("name", "metadata.name"),
+					newParamNestedRef("selector", "spec.selector"),
+					newParamNestedRef("ports", "spec.ports")),
 			},
 			"io.k8s.kubernetes.pkg.api.v1.ServiceAccount": []CustomConstructorSpec{
 				newConstructor(
 					"new",
-					newParamNestedRef("name", "mixin.metadata.name")),
+					newParamNestedRef("name", "metadata.name")),
 			},
 			"io.k8s.kubernetes.pkg.api.v1.ServiceAccountList": objectList,
 			"io.k8s.kubernetes.pkg.api.v1.ServiceList":        objectList,
@@ -213,24 +213,24 @@ var versions = map[string]versionData{
 				newConstructor(
 					"fromConfigMap",
 					newParam("name"),
-					newParamNestedRef("configMapName", "mixin.configMap.withName"),
-					newParamNestedRef("configMapItems", "mixin.configMap.withItems")),
+					newParamNestedRef("configMapName", "configMap.name"),
+					newParamNestedRef("configMapItems", "configMap.items")),
 				newConstructor(
 					"fromEmptyDir",
 					newParam("name"),
-					newParamNestedRefDefault("emptyDir", "mixin.emptyDir.mixinInstance", "{}")),
+					newParamNestedRefDefault("emptyDir", "emptyDir", "{}")),
 				newConstructor(
 					"fromPersistentVolumeClaim",
 					newParam("name"),
-					newParamNestedRef("claimName", "mixin.persistentVolumeClaim.withClaimName")),
+					newParamNestedRef("claimName", "persistentVolumeClaim.claimName")),
 				newConstructor(
 					"fromHostPath",
 					newParam("name"),
-					newParamNestedRef("hostPath", "mixin.hostPath.withPath")),
+					newParamNestedRef("hostPath", "hostPath.path")),
 				newConstructor(
 					"fromSecret",
 					newParam("name"),
-					newParamNestedRef("secretName", "mixin.secret.secretName")),
+					newParamNestedRef("secretName", "secret.secretName")),
 			},
 			"io.k8s.kubernetes.pkg.api.v1.VolumeMount": []CustomConstructorSpec{
 				newConstructor("new", newParam("name"), newParam("mountPath"), newParamWithDefault("readOnly", "false")),
@@ -346,87 +346,14 @@ var versions = map[string]versionData{
 			// Misc.
 			"io.k8s.kubernetes.pkg.apis.extensions.v1beta1.DaemonSetSpec": newPropertySet("templateGeneration"),
 		},
-		kSource: `local k8s = import "k8s.libsonnet";
 
-local apps = k8s.apps;
-local core = k8s.core;
-local extensions = k8s.extensions;
+		// Type alias names that override the default `<property>Type`.
+		// Keyed by definition name, then by property name.
+		typeAliasNames: map[string]map[string]string{},
 
-local hidden = {
-  mapContainers(f):: {
-    local podContainers = super.spec.template.spec.containers,
-    spec+: {
-      template+: {
-        spec+: {
-          // IMPORTANT: This overwrites the 'containers' field
-          // for this deployment.
-          containers: std.map(f, podContainers),
-        },
-      },
-    },
-  },
-
-  mapContainersWithName(names, f) ::
-    local nameSet =
-      if std.type(names) == "array"
-      then std.set(names)
-      else std.set([names]);
-    local inNameSet(name) = std.length(std.setInter(nameSet, std.set([name]))) > 0;
-    self.mapContainers(
-      function(c)
-        if std.objectHas(c, "name") && inNameSet(c.name)
-        then f(c)
-        else c
-    ),
-};
-
-k8s + {
-  apps:: apps + {
-    v1beta1:: apps.v1beta1 + {
-      local v1beta1 = apps.v1beta1,
-
-      daemonSet:: v1beta1.daemonSet + {
-        mapContainers(f):: hidden.mapContainers(f),
-        mapContainersWithName(names, f):: hidden.mapContainersWithName(names, f),
-      },
-
-      deployment:: v1beta1.deployment + {
-        mapContainers(f):: hidden.mapContainers(f),
-        mapContainersWithName(names, f):: hidden.mapContainersWithName(names, f),
-      },
-    },
-  },
-
-  core:: core + {
-    v1:: core.v1 + {
-      list:: {
-        new(items)::
-          {apiVersion: "v1"} +
-          {kind: "List"} +
-          self.items(items),
-
-        items(items):: if std.type(items) == "array" then {items+: items} else {items+: [items]},
-      },
-    },
-  },
-
-  extensions:: extensions + {
-    v1beta1:: extensions.v1beta1 + {
-      local v1beta1 = extensions.v1beta1,
-
-      daemonSet:: v1beta1.daemonSet + {
-        mapContainers(f):: hidden.mapContainers(f),
-        mapContainersWithName(names, f):: hidden.mapContainersWithName(names, f),
-      },
-
-      deployment:: v1beta1.deployment + {
-        mapContainers(f):: hidden.mapContainers(f),
-        mapContainersWithName(names, f):: hidden.mapContainersWithName(names, f),
-      },
-    },
-  },
-}
-`,
+		// Jsonnet identifiers that override the default, lower-cased
+		// object kind. Keyed by definition name.
+		objectNames: map[string]string{},
 	},
 }
 
@@ -442,12 +369,12 @@ var objectList = []CustomConstructorSpec{
 var v1beta1Deployment = []CustomConstructorSpec{
 	newConstructor(
 		"new",
-		newParamNestedRef("name", "mixin.metadata.name"),
-		newParamNestedRef("replicas", "mixin.spec.replicas"),
-		newParamNestedRef("containers", "mixin.spec.template.spec.containers"),
+		newParamNestedRef("name", "metadata.name"),
+		newParamNestedRef("replicas", "spec.replicas"),
+		newParamNestedRef("containers", "spec.template.spec.containers"),
 		newParamNestedRefDefault(
 			"podLabels",
-			"mixin.spec.template.metadata.labels",
+			"spec.template.metadata.labels",
 			"{app: name}")),
 }
 var v1beta1DeploymentRollback = []CustomConstructorSpec{
@@ -458,17 +385,17 @@ var v1beta1DeploymentRollback = []CustomConstructorSpec{
 var v1beta1Scale = []CustomConstructorSpec{
 	newConstructor(
 		"new",
-		newParamNestedRef("replicas", "mixin.spec.replicas")),
+		newParamNestedRef("replicas", "spec.replicas")),
 }
 var v1beta1StatefulSet = []CustomConstructorSpec{
 	newConstructor(
 		"new",
-		newParamNestedRef("name", "mixin.metadata.name"),
-		newParamNestedRef("replicas", "mixin.spec.replicas"),
-		newParamNestedRef("containers", "mixin.spec.template.spec.containers"),
-		newParamNestedRef("volumeClaims", "mixin.spec.volumeClaimTemplates"),
+		newParamNestedRef("name", "metadata.name"),
+		newParamNestedRef("replicas", "spec.replicas"),
+		newParamNestedRef("containers", "spec.template.spec.containers"),
+		newParamNestedRef("volumeClaims", "spec.volumeClaimTemplates"),
 		newParamNestedRefDefault(
 			"podLabels",
-			"mixin.spec.template.metadata.labels",
+			"spec.template.metadata.labels",
 			"{app: name}")),
 }
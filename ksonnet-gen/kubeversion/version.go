@@ -15,17 +15,6 @@ import (
 	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
 )
 
-// KSource returns the source of `k.libsonnet` for a specific version
-// of Kubernetes.
-func KSource(k8sVersion string) string {
-	verData, ok := versions[k8sVersion]
-	if !ok {
-		log.Fatalf("Unrecognized Kubernetes version '%s'", k8sVersion)
-	}
-
-	return verData.kSource
-}
-
 // MapIdentifier takes a text identifier and maps it to a
 // Jsonnet-appropriate identifier, for some version of Kubernetes. For
 // example, in Kubernetes v1.7.0, we might map `clusterIP` ->
@@ -66,6 +55,49 @@ func IsBlacklistedProperty(
 	return ok
 }
 
+// TypeAliasName takes a definition name (e.g.,
+// `io.k8s.kubernetes.pkg.apis.apps.v1beta1.Deployment`) and a property
+// name (e.g., `spec`), and reports the name of the type alias to emit
+// for that property, if one has been specified for some Kubernetes
+// version. By default the type alias for `spec` would be `specType`;
+// this override is useful when that name is already taken by another
+// property of the same object.
+func TypeAliasName(
+	k8sVersion string, path kubespec.DefinitionName,
+	propertyName kubespec.PropertyName,
+) (kubespec.PropertyName, bool) {
+	verData, ok := versions[k8sVersion]
+	if !ok {
+		return "", false
+	}
+
+	aliases, ok := verData.typeAliasNames[string(path)]
+	if !ok {
+		return "", false
+	}
+
+	alias, ok := aliases[string(propertyName)]
+	return kubespec.PropertyName(alias), ok
+}
+
+// ObjectName takes a definition name (e.g.,
+// `io.k8s.kubernetes.pkg.api.v1.Binding`), and reports the Jsonnet
+// identifier to emit for that API object, if one has been specified
+// for some Kubernetes version. By default `Binding` would be emitted as
+// `binding`; this override is useful when that name collides with
+// another object in the same versioned API.
+func ObjectName(
+	k8sVersion string, path kubespec.DefinitionName,
+) (string, bool) {
+	verData, ok := versions[k8sVersion]
+	if !ok {
+		return "", false
+	}
+
+	name, ok := verData.objectNames[string(path)]
+	return name, ok
+}
+
 func ConstructorSpec(
 	k8sVersion string, path kubespec.DefinitionName,
 ) ([]CustomConstructorSpec, bool) {
@@ -86,7 +118,8 @@ type versionData struct {
 	idAliases         map[string]string
 	constructorSpecs  map[string][]CustomConstructorSpec
 	propertyBlacklist map[string]propertySet
-	kSource           string
+	typeAliasNames    map[string]map[string]string
+	objectNames       map[string]string
 }
 
 type propertySet map[string]bool
@@ -144,19 +177,21 @@ func newConstructor(
 // `CustomConstructorSpec`. This class allows users to specify
 // constructors of various forms, including:
 //
-// * The "normal" form, e.g., `foo(bar):: self.bar(bar)`,
+// * The "normal" form, e.g., `foo(bar):: self.withBar(bar)`,
 // * Parameters with default values, e.g., `foo(bar="baz")::
-//   self.bar(bar)`, and
+//   self.withBar(bar)`, and
 // * Parameters that are nested inside the object, e.g., `foo(bar)::
-//   self.baz.bat.bar(bar)`
+//   self.mixin.baz.bat.withBar(bar)`
 //
 // DESIGN NOTES:
 //
-// * For constructors that use nested paths, we do not currently check
-//   that the path is valid. So for example, `self.baz.bat.bar` in the
-//   example above may not correspond to a real property. We make this
-//   decision because it complicates the code, and it doesn't seem
-//   worth it since this feature is used relatively rarely.
+// * `RelativePath` is the dotted path of the nested property (e.g.,
+//   `baz.bat.bar`), rather than of the setter that sets it, so that
+//   the name of the setter follows the naming scheme of the library.
+//   A path to a property that refers to another object (e.g.,
+//   `emptyDir`) sets it with the `mixinInstance` of its namespace.
+//   Paths are not checked, since objects of the same kind in other
+//   specs may lack the property.
 type CustomConstructorParam struct {
 	ID           string
 	DefaultValue *string
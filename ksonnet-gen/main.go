@@ -2,84 +2,875 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/cluster"
 	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/ksonnet"
 	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/remote"
 )
 
-var usage = "Usage: ksonnet-gen [path to k8s OpenAPI swagger.json] [output dir]"
+var usage = `Usage: ksonnet-gen [flags] [path or URL of k8s OpenAPI swagger.json, or - for stdin] [output dir]
+       ksonnet-gen --spec=[path or URL] [--spec=[path or URL] ...] [flags] [output dir]
+       ksonnet-gen --k8s-version=[version, e.g., 1.9.3] [flags] [output dir]
+       ksonnet-gen --from-cluster [--kubeconfig=[path]] [--context=[name]] [flags] [output dir]
+       ksonnet-gen --helm-values-schema=[path to values.schema.json] [output dir]
+       ksonnet-gen diff [flags] [previous swagger.json] [current swagger.json]
+       ksonnet-gen migrate --from=[old names.json] --to=[new names.json] [Jsonnet files]`
+
+// repeatedFlag holds every value of a flag that may be repeated, e.g.,
+// `--spec`.
+type repeatedFlag []string
+
+func (s *repeatedFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *repeatedFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+var specs, includeKinds, excludeKinds, manifestPaths repeatedFlag
+
+func init() {
+	flag.Var(
+		&specs, "spec",
+		"Path or URL of a spec to generate the library from, or - to read it from stdin; may be repeated, to merge several specs (e.g., of aggregated API servers), earlier ones taking precedence")
+	flag.Var(
+		&includeKinds, "include",
+		"Only emit the top-level objects whose group/version/kind matches this glob (e.g., apps/*/*, or just apps); may be repeated")
+	flag.Var(
+		&excludeKinds, "exclude",
+		"Don't emit the top-level objects whose group/version/kind matches this glob (e.g., extensions/v1beta1/*); may be repeated")
+	flag.Var(
+		&manifestPaths, "from-manifests",
+		"Only emit the top-level objects of the kinds the manifests (YAML or JSON) in this file or directory use, and what they refer to; may be repeated")
+}
+
+// stdinSource is the source that stands for the spec being piped in on
+// stdin, e.g., from `kubectl get --raw /openapi/v2`.
+const stdinSource = "-"
+
+var (
+	dryRun = flag.Bool(
+		"dry-run", false,
+		"Validate the spec, and build, emit, and parse the library, and print a summary of it, but don't write any files")
+	cpuProfile = flag.String(
+		"cpuprofile", "", "Write a pprof CPU profile of the generation run to this file")
+	memProfile = flag.String(
+		"memprofile", "", "Write a pprof heap profile to this file after the generation run")
+	traceTiming = flag.Bool(
+		"trace-timing", false, "Report the time spent in each phase of generation")
+	namedConstructors = flag.Bool(
+		"named-constructors", false,
+		"Emit `new(name)` constructors that set `metadata.name` for top-level objects")
+	requiredConstructors = flag.Bool(
+		"required-constructors", false,
+		"Emit constructors that take the properties the spec lists as `required` as parameters")
+	flattenDepth = flag.Int(
+		"flatten-depth", 0,
+		"Emit flattened setters (e.g., `withSpecReplicas`) for properties up to this many levels deep")
+	patchStrategyMixins = flag.Bool(
+		"patch-strategy-mixins", false,
+		"Make the mixins of arrays follow their x-kubernetes-patch-strategy, e.g., replace the elements of atomic arrays, rather than always append")
+	backend = flag.String(
+		"backend", ksonnet.JsonnetBackend,
+		fmt.Sprintf("Language to emit the library in; one of: %s", strings.Join(ksonnet.Backends(), ", ")))
+	kustomizeHelpers = flag.Bool(
+		"kustomize-helpers", false,
+		"Emit helpers that wrap fragments into kustomize strategic merge and JSON 6902 patches")
+	podTemplateHelpers = flag.Bool(
+		"pod-template-helpers", false,
+		"Emit a mixin.podTemplate namespace, shared by all kinds that embed a pod template")
+	jsonSchemas = flag.Bool(
+		"json-schemas", false,
+		"Also write a standalone JSON Schema for each top-level kind to the `schemas` directory")
+	emitDocs = flag.Bool(
+		"emit-docs", false,
+		"Also write Markdown reference docs, a page per top-level kind, to the `docs` directory")
+	functionIndex = flag.Bool(
+		"function-index", false,
+		"Also write `functions.json`, which lists every function of the library with its parameters and doc string")
+	snippets = flag.Bool(
+		"snippets", false,
+		"Also write `k8s.code-snippets`, VS Code snippets for the constructors of common kinds")
+	prototypes = flag.Bool(
+		"prototypes", false,
+		"Also write a ksonnet package of prototypes of common patterns, built with the library, to the `parts` directory")
+	emitSite = flag.Bool(
+		"emit-site", false,
+		"Also write a searchable static HTML reference site, a page per top-level kind, to the `site` directory")
+	testSuite = flag.Bool(
+		"test-suite", false,
+		"Also write `k8s_test.jsonnet`, which tests the constructors, setters, and mixins of the library")
+	nameMap = flag.Bool(
+		"name-map", false,
+		"Also write `names.json`, which `ksonnet-gen migrate` uses to migrate code between libraries")
+	sourceMap = flag.Bool(
+		"source-map", false,
+		"Also write sourcemap.json, which maps the lines of the library to the definitions and properties they were generated from")
+	skippedReport = flag.Bool(
+		"skipped-report", false,
+		"Also write skipped.json, which lists every definition and property of the spec the library leaves out, and why")
+	previousNameMap = flag.String(
+		"previous-name-map", "",
+		"Also write `CHANGELOG.md`, comparing the library to the one this `names.json` was written for")
+	compatShim = flag.Bool(
+		"compat-shim", false,
+		"With --previous-name-map, also write `compat.libsonnet`, which aliases the renamed names of the previous library to their new ones")
+	renderHelpers = flag.Bool(
+		"render-helpers", false,
+		"Emit renderJson/renderYaml helpers for every top-level object, and renderYamlList for lists of objects")
+	fromJSON = flag.Bool(
+		"from-json", false,
+		"Emit a fromJson(obj) function for every top-level object, which imports an existing manifest into the library")
+	docsonnet = flag.Bool(
+		"docsonnet", false,
+		"Annotate the library with docsonnet documentation (requires doc-util/main.libsonnet to be importable)")
+	reflectionIndex = flag.Bool(
+		"reflection-index", false,
+		"Emit a hidden `__index` object in every API version, listing its kinds and their functions and mixins")
+	utilHelpers = flag.Bool(
+		"util-helpers", false,
+		"Emit a `util` namespace of generic helpers: mergePatch, removeField, mapValues, and pruneNulls")
+	quantityHelpers = flag.Bool(
+		"quantity-helpers", false,
+		"Emit helpers that check and parse resource quantities, and build requests and limits, wherever ResourceRequirements is referenced")
+	envHelpers = flag.Bool(
+		"env-helpers", false,
+		"Emit setters of env that take a map of variables to values, and builders of the configMapRef and secretRef elements of envFrom")
+	exposeTypes = flag.Bool(
+		"expose-types", false,
+		"Emit a `types` namespace that exposes the API objects that aren't top-level, e.g., types.core.v1.podSpec")
+	gvkLookup = flag.Bool(
+		"gvk-lookup", false,
+		"Emit a `gvk` table of the objects of the library by group, version, and kind, and the byGVK(group, version, kind) and byKind(kind) functions that look them up")
+	shortNames = flag.Bool(
+		"short-names", false,
+		"Emit aliases of the preferred version of common kinds, named after their kubectl short names, e.g., deploy for apps.v1beta1.deployment")
+	strict = flag.Bool(
+		"strict", false,
+		"Emit setters that assert that their arguments satisfy the spec's format, pattern, minimum/maximum, length, item, and enum constraints")
+	typeAssertions = flag.Bool(
+		"type-assertions", false,
+		"Emit setters and mixins that assert that their arguments have the type of their property")
+	unknownTypes = flag.String(
+		"unknown-types", ksonnet.UnknownTypesLenient,
+		fmt.Sprintf("What to do with properties of types the generator doesn't support: %s gives them a setter that accepts any value, and warns; %s fails, listing all of them", ksonnet.UnknownTypesLenient, ksonnet.UnknownTypesStrict))
+	split = flag.String(
+		"split", "",
+		fmt.Sprintf("Split the library into several files, with k8s.libsonnet importing them; one of: %s", strings.Join(ksonnet.SplitLayouts, ", ")))
+	namingConvention = flag.String(
+		"naming-convention", "camelCase",
+		fmt.Sprintf("Casing convention of the functions and fields of the library; one of: %s", strings.Join(ksonnet.NamingConventionNames(), ", ")))
+	goPackage = flag.String(
+		"go-package", "",
+		"Also write `libsonnet.go`, which embeds the library in a Go package of the given name")
+	verify = flag.Bool(
+		"verify", false,
+		"Evaluate the library's constructors and setters, and fail if the objects they produce don't match the spec")
+	jsonnetFmt = flag.Bool(
+		"fmt", false,
+		"Run the generated Jsonnet through the go-jsonnet formatter, like jsonnetfmt, before writing it")
+	fmtIndent = flag.Int(
+		"fmt-indent", 2,
+		"With --fmt, the number of spaces to indent by")
+	fmtMaxBlankLines = flag.Int(
+		"fmt-max-blank-lines", 2,
+		"With --fmt, the maximum number of consecutive blank lines")
+	fmtStringStyle = flag.String(
+		"fmt-string-style", "s",
+		fmt.Sprintf("With --fmt, the quotes of string literals, as for jsonnetfmt; one of: %s", strings.Join(ksonnet.FormatStringStyles, ", ")))
+	fmtCommentStyle = flag.String(
+		"fmt-comment-style", "s",
+		fmt.Sprintf("With --fmt, the style of comments, as for jsonnetfmt; one of: %s", strings.Join(ksonnet.FormatCommentStyles, ", ")))
+	diffAnchors = flag.Bool(
+		"diff-anchors", false,
+		"Precede every object of the generated Jsonnet with a comment marking its path, so that diffs between libraries line up by object")
+	noComments = flag.Bool(
+		"no-comments", false,
+		"Leave the comments (other than the header) and blank lines out of the generated Jsonnet")
+	minify = flag.Bool(
+		"minify", false,
+		"Leave all the whitespace that can be out of the generated Jsonnet, as well as the comments")
+	header = flag.String(
+		"header", "",
+		"Start every generated file with the comment this Go template renders, instead of the default header")
+	generatedAt = flag.String(
+		"generated-at", "",
+		"Record this RFC 3339 time (e.g., 2017-08-01T12:00:00Z) as the time the library was generated at; defaults to SOURCE_DATE_EPOCH, if it is set, and otherwise to none")
+	overlay = flag.String(
+		"overlay", "",
+		"Merge the partial definitions in this file over the spec's before building the library")
+	failOn = flag.String(
+		"fail-on", ksonnet.FailOnNever,
+		fmt.Sprintf("Fail without writing anything if generation reports problems of this severity; one of: %s", strings.Join(ksonnet.FailOnLevels, ", ")))
+	reportPath = flag.String(
+		"report", "",
+		"Write a JSON report of the problems validation found in the spec, and of the warnings of generation, to this file")
+	fromCluster = flag.Bool(
+		"from-cluster", false,
+		"Instead of reading the spec from a file, fetch it from the API server of a cluster in a kubeconfig")
+	kubeconfig = flag.String(
+		"kubeconfig", "",
+		"With --from-cluster, the kubeconfig file to use; defaults to $KUBECONFIG, or ~/.kube/config")
+	kubeContext = flag.String(
+		"context", "",
+		"With --from-cluster, the kubeconfig context to use; defaults to the current context")
+	requestTimeout = flag.Duration(
+		"request-timeout", time.Minute,
+		"With --from-cluster or a spec URL, how long to wait for the spec to download")
+	specSHA256 = flag.String(
+		"spec-sha256", "",
+		"With a spec URL, the SHA-256 digest the spec must have")
+	specCacheDir = flag.String(
+		"spec-cache-dir", remote.DefaultCacheDir(),
+		"With a spec URL, the directory to cache the spec in; if empty, the spec is not cached")
+	localRefsOnly = flag.Bool(
+		"local-refs-only", false,
+		"Only resolve `$ref`s of the spec to other files, not to URLs, for reproducible builds")
+	k8sVersion = flag.String(
+		"k8s-version", "",
+		"Download the spec of this Kubernetes release (e.g., 1.9.3) from the kubernetes/kubernetes repository, and record the commit it was tagged at")
+	helmValuesSchema = flag.String(
+		"helm-values-schema", "",
+		"Instead of ksonnet-lib, emit a library for building the values of the Helm chart with this `values.schema.json`")
+)
 
 func main() {
-	if len(os.Args) != 3 {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := ksonnet.CheckFailOn(*failOn); err != nil {
+		log.Fatal(err)
+	}
+	if err := ksonnet.CheckUnknownTypes(*unknownTypes); err != nil {
+		log.Fatal(err)
+	}
+	if err := ksonnet.CheckSplit(*split); err != nil {
+		log.Fatal(err)
+	}
+	if err := ksonnet.CheckNamingConvention(*namingConvention); err != nil {
+		log.Fatal(err)
+	}
+
+	if *helmValuesSchema != "" {
+		if flag.NArg() != 1 {
+			log.Fatal(usage)
+		}
+		emitHelmValues(*helmValuesSchema, flag.Arg(0))
+		return
+	}
+
+	// The specs to merge, in order of precedence: the cluster's, the
+	// positional argument, the release's, and then every `--spec`.
+	sources := []string{}
+	if flag.NArg() == 2 {
+		sources = append(sources, flag.Arg(0))
+	}
+	var releaseTag string
+	if *k8sVersion != "" {
+		tag, err := remote.ReleaseTag(*k8sVersion)
+		if err != nil {
+			log.Fatal(err)
+		}
+		releaseTag = tag
+		sources = append(sources, remote.ReleaseSpecURL(tag))
+	}
+	sources = append(sources, specs...)
+	if flag.NArg() < 1 || flag.NArg() > 2 || len(sources) == 0 && !*fromCluster {
 		log.Fatal(usage)
 	}
+	readsStdin := false
+	for _, source := range sources {
+		if source == stdinSource && readsStdin {
+			log.Fatal("Only one spec can be read from stdin")
+		}
+		readsStdin = readsStdin || source == stdinSource
+	}
+
+	if err := run(sources, releaseTag); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	swaggerPath := os.Args[1]
-	text, err := ioutil.ReadFile(swaggerPath)
+// run generates the library from `sources` (and from the spec of the
+// cluster, if `--from-cluster` was passed), and writes it to the
+// output directory. It returns an error rather than exiting, so that
+// the profiles are still written when generation fails.
+func run(sources []string, releaseTag string) (err error) {
+	stopProfiling, err := startProfiling()
 	if err != nil {
-		log.Fatalf("Could not read file at '%s':\n%v", swaggerPath, err)
+		return err
 	}
+	defer func() {
+		if stopErr := stopProfiling(); err == nil {
+			err = stopErr
+		}
+	}()
 
-	// Deserialize the API object.
-	s := kubespec.APISpec{}
-	err = json.Unmarshal(text, &s)
+	outputDir := flag.Arg(flag.NArg() - 1)
+	timings := []ksonnet.PhaseTiming{}
+
+	start := time.Now()
+	loaded := []*kubespec.APISpec{}
+	if *fromCluster {
+		text, err := fetchClusterSpec()
+		if err != nil {
+			return err
+		}
+		spec, err := parseSpec(text)
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, spec)
+	}
+	for _, source := range sources {
+		spec, err := readSpec(source)
+		if err != nil {
+			return err
+		}
+		if !remote.IsURL(source) && source != stdinSource {
+			spec.FilePath = filepath.Dir(source)
+		}
+		loaded = append(loaded, spec)
+	}
+	s := loaded[0]
+	if len(loaded) > 1 {
+		merged, err := kubespec.Merge(loaded...)
+		if err != nil {
+			return fmt.Errorf("Could not merge specs:\n%v", err)
+		}
+		s = merged
+	}
+	timings = append(timings, ksonnet.PhaseTiming{
+		Phase: "spec loading", Duration: time.Since(start)})
+
+	// Emit Jsonnet code. A spec fetched from a cluster or a URL, or read
+	// from stdin, has no repository to record the revision of, unless it
+	// is the spec of a Kubernetes release.
+	ksonnetLibSHA, err := getSHARevision(".")
+	if err != nil {
+		return err
+	}
+	var k8sSHA *string
+	switch {
+	case s.FilePath != "":
+		sha, err := getSHARevision(s.FilePath)
+		if err != nil {
+			return err
+		}
+		k8sSHA = &sha
+	case releaseTag != "":
+		sha, err := remote.ReleaseCommit(releaseTag, remote.Options{
+			Timeout:  *requestTimeout,
+			CacheDir: *specCacheDir,
+		})
+		if err != nil {
+			return err
+		}
+		k8sSHA = &sha
+	}
+	opts := ksonnet.Options{
+		Backend:              *backend,
+		ConstructorsTakeName: *namedConstructors,
+		RequiredConstructors: *requiredConstructors,
+		FlattenDepth:         *flattenDepth,
+		PatchStrategyMixins:  *patchStrategyMixins,
+		KustomizeHelpers:     *kustomizeHelpers,
+		PodTemplateHelpers:   *podTemplateHelpers,
+		JSONSchemas:          *jsonSchemas,
+		Docs:                 *emitDocs,
+		Site:                 *emitSite,
+		FunctionIndex:        *functionIndex,
+		Snippets:             *snippets,
+		Prototypes:           *prototypes,
+		TestSuite:            *testSuite,
+		NameMap:              *nameMap,
+		SourceMap:            *sourceMap,
+		SkippedReport:        *skippedReport,
+		RenderHelpers:        *renderHelpers,
+		CompatShim:           *compatShim,
+		FromJSON:             *fromJSON,
+		Docsonnet:            *docsonnet,
+		Verify:               *verify,
+		ReflectionIndex:      *reflectionIndex,
+		UtilHelpers:          *utilHelpers,
+		QuantityHelpers:      *quantityHelpers,
+		EnvHelpers:           *envHelpers,
+		ExposeTypes:          *exposeTypes,
+		ShortNames:           *shortNames,
+		GVKLookup:            *gvkLookup,
+		DiffAnchors:          *diffAnchors,
+		NoComments:           *noComments,
+		Minify:               *minify,
+		Strict:               *strict,
+		TypeAssertions:       *typeAssertions,
+		UnknownTypes:         *unknownTypes,
+		Split:                *split,
+		NamingConvention:     *namingConvention,
+		GoPackage:            *goPackage,
+		Include:              includeKinds,
+		Exclude:              excludeKinds,
+	}
+	if len(manifestPaths) > 0 {
+		kinds, err := readManifestKinds(manifestPaths)
+		if err != nil {
+			return err
+		}
+		opts.Kinds = kinds
+	}
+	if *overlay != "" {
+		overlayText, err := ioutil.ReadFile(*overlay)
+		if err != nil {
+			return fmt.Errorf("Could not read file at '%s':\n%v", *overlay, err)
+		}
+		opts.Overlay = overlayText
+	}
+	if *header != "" {
+		headerText, err := ioutil.ReadFile(*header)
+		if err != nil {
+			return fmt.Errorf("Could not read file at '%s':\n%v", *header, err)
+		}
+		opts.Header = string(headerText)
+	}
+	if *jsonnetFmt {
+		formatOpts, err := ksonnet.NewFormatOptions(
+			*fmtIndent, *fmtMaxBlankLines, *fmtStringStyle, *fmtCommentStyle)
+		if err != nil {
+			return err
+		}
+		opts.Format = formatOpts
+	}
+	if *previousNameMap != "" {
+		names, err := readNameMap(*previousNameMap)
+		if err != nil {
+			return err
+		}
+		opts.PreviousNameMap = names
+	}
+	generatedAt, err := generationTime()
+	if err != nil {
+		return err
+	}
+	opts.GeneratedAt = generatedAt
+	files, report, err := ksonnet.EmitFiles(s, &ksonnetLibSHA, k8sSHA, opts)
+	if report != nil {
+		printWarnings(report)
+		if err := writeReport(report); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Could not write ksonnet library:\n%v", err)
+	}
+
+	timings = append(timings, report.Timings...)
+
+	if fails, _ := report.Fails(*failOn); fails {
+		return fmt.Errorf("Generation reported problems, and --fail-on=%s", *failOn)
+	}
+	if changelog := report.Changelog; changelog != nil {
+		log.Printf(
+			"Since the previous library: %d added, %d removed, %d changed; suggested version bump: %s",
+			len(changelog.Added), len(changelog.Removed), len(changelog.Changed),
+			changelog.SuggestedBump())
+	}
+
+	outfiles := make(map[string][]byte)
+	for name, data := range files {
+		outfiles[filepath.Join(outputDir, name)] = data
+	}
+
+	if *dryRun {
+		printSummary(s, report, outfiles)
+		printTimings(timings)
+		return nil
+	}
+
+	// Write out.
+	start = time.Now()
+	for outfile, data := range outfiles {
+		err = os.MkdirAll(filepath.Dir(outfile), 0755)
+		if err != nil {
+			return fmt.Errorf("Could not create directory for `%s`:\n%v", outfile, err)
+		}
+
+		err = ioutil.WriteFile(outfile, data, 0644)
+		if err != nil {
+			return fmt.Errorf("Could not write `%s`:\n%v", outfile, err)
+		}
+	}
+	timings = append(timings, ksonnet.PhaseTiming{
+		Phase: "writing", Duration: time.Since(start)})
+
+	printTimings(timings)
+	return nil
+}
+
+// loadSpec reads the spec at `source`, which is either the path of a
+// file, a URL, or `stdinSource`, and decompresses it if it is
+// compressed (see `kubespec.Decompress`).
+func loadSpec(source string) ([]byte, error) {
+	var text []byte
+	var err error
+	switch {
+	case source == stdinSource:
+		text, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read spec from stdin:\n%v", err)
+		}
+	case remote.IsURL(source):
+		text, err = remote.Fetch(source, remote.Options{
+			Timeout:  *requestTimeout,
+			SHA256:   *specSHA256,
+			CacheDir: *specCacheDir,
+		})
+		if err != nil {
+			return nil, err
+		}
+	default:
+		text, err = ioutil.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read file at '%s':\n%v", source, err)
+		}
+	}
+
+	decompressed, err := kubespec.Decompress(text)
+	if err != nil {
+		return nil, fmt.Errorf("Could not load spec from '%s':\n%v", source, err)
+	}
+	return decompressed, nil
+}
+
+// resolveRefs stitches the definitions that the `$ref`s of the spec
+// `text`, loaded from `source`, refer to in other files or at URLs into
+// it (see `kubespec.ResolveExternalRefs`).
+func resolveRefs(text []byte, source string) ([]byte, error) {
+	opts := kubespec.ResolveOptions{
+		LocalOnly: *localRefsOnly,
+		Fetch: func(url string) ([]byte, error) {
+			return remote.Fetch(url, remote.Options{
+				Timeout:  *requestTimeout,
+				CacheDir: *specCacheDir,
+			})
+		},
+	}
+	resolved, err := kubespec.ResolveExternalRefs(text, source, opts)
 	if err != nil {
-		log.Fatalf("Could not deserialize schema:\n%v", err)
+		return nil, fmt.Errorf("Could not resolve the references of '%s':\n%v", source, err)
+	}
+	return resolved, nil
+}
+
+// parseSpec deserializes the spec `text`.
+func parseSpec(text []byte) (*kubespec.APISpec, error) {
+	s := kubespec.APISpec{}
+	if err := json.Unmarshal(text, &s); err != nil {
+		return nil, fmt.Errorf("Could not deserialize schema:\n%v", err)
 	}
 	s.Text = text
-	s.FilePath = filepath.Dir(swaggerPath)
+	return &s, nil
+}
 
-	// Emit Jsonnet code.
-	ksonnetLibSHA := getSHARevision(".")
-	k8sSHA := getSHARevision(s.FilePath)
-	kBytes, k8sBytes, err := ksonnet.Emit(&s, &ksonnetLibSHA, &k8sSHA)
+// readSpec loads the spec at `source` (see `loadSpec`), resolves its
+// references (see `resolveRefs`), and deserializes it.
+func readSpec(source string) (*kubespec.APISpec, error) {
+	text, err := loadSpec(source)
 	if err != nil {
-		log.Fatalf("Could not write ksonnet library:\n%v", err)
+		return nil, err
 	}
+	text, err = resolveRefs(text, source)
+	if err != nil {
+		return nil, err
+	}
+	return parseSpec(text)
+}
 
-	// Write out.
-	k8sOutfile := fmt.Sprintf("%s/%s", os.Args[2], "k8s.libsonnet")
-	err = ioutil.WriteFile(k8sOutfile, k8sBytes, 0644)
+// readManifestKinds returns the kinds of the objects of the manifests
+// at `paths`, each of which is either a file, or a directory to read
+// every `.yaml`, `.yml`, and `.json` file of, recursively.
+func readManifestKinds(paths []string) ([]ksonnet.ManifestKind, error) {
+	manifests := [][]byte{}
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(file)) {
+			case ".yaml", ".yml", ".json":
+			default:
+				// Files named explicitly are read whatever their extension.
+				if file != path {
+					return nil
+				}
+			}
+			text, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if _, err := ksonnet.ManifestKinds(text); err != nil {
+				return fmt.Errorf("Could not read the kinds of '%s':\n%v", file, err)
+			}
+			manifests = append(manifests, text)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Could not read manifests at '%s':\n%v", path, err)
+		}
+	}
+
+	kinds, err := ksonnet.ManifestKinds(manifests...)
+	if err != nil {
+		return nil, err
+	}
+	if len(kinds) == 0 {
+		return nil, fmt.Errorf("The manifests at '%s' have no objects", strings.Join(paths, "', '"))
+	}
+	return kinds, nil
+}
+
+// fetchClusterSpec fetches the spec from the API server of the cluster
+// of the context `--context` in the kubeconfig `--kubeconfig`.
+func fetchClusterSpec() ([]byte, error) {
+	path := *kubeconfig
+	if path == "" {
+		path = cluster.DefaultConfigPath()
+	}
+	config, err := cluster.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := config.Endpoint(*kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	text, err := endpoint.FetchSpec(*requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return text, nil
+}
+
+// emitHelmValues generates `values.libsonnet` from a Helm chart's
+// values schema, and writes it to `outputDir`.
+func emitHelmValues(schemaPath, outputDir string) {
+	text, err := ioutil.ReadFile(schemaPath)
+	if err != nil {
+		log.Fatalf("Could not read file at '%s':\n%v", schemaPath, err)
+	}
+
+	valuesBytes, report, err := ksonnet.EmitHelmValues(text)
+	if err != nil {
+		log.Fatalf("Could not write values library:\n%v", err)
+	}
+
+	printWarnings(report)
+	if err := writeReport(report); err != nil {
+		log.Fatal(err)
+	}
+	if fails, _ := report.Fails(*failOn); fails {
+		log.Fatalf("Generation reported problems, and --fail-on=%s", *failOn)
+	}
+
+	outfile := filepath.Join(outputDir, ksonnet.HelmValuesFile)
+	if *dryRun {
+		fmt.Println("Would write:")
+		fmt.Printf("  %s (%d bytes)\n", outfile, len(valuesBytes))
+		return
+	}
+
+	err = ioutil.WriteFile(outfile, valuesBytes, 0644)
 	if err != nil {
-		log.Fatalf("Could not write `k8s.libsonnet`:\n%v", err)
+		log.Fatalf("Could not write `%s`:\n%v", outfile, err)
+	}
+}
+
+// printWarnings logs every warning in `report`, prefixed with its
+// severity.
+func printWarnings(report *ksonnet.Report) {
+	for _, warning := range report.Warnings {
+		log.Printf("%s: %s", strings.ToUpper(string(warning.Severity)), warning)
+	}
+}
+
+// writeReport writes `report` as JSON to the file `--report` names, if
+// any.
+func writeReport(report *ksonnet.Report) error {
+	if *reportPath == "" {
+		return nil
+	}
+	text, err := report.JSON()
+	if err != nil {
+		return fmt.Errorf("Could not serialize report:\n%v", err)
+	}
+	if err := ioutil.WriteFile(*reportPath, text, 0644); err != nil {
+		return fmt.Errorf("Could not write report to '%s':\n%v", *reportPath, err)
+	}
+	return nil
+}
+
+// printTimings logs the time spent in each phase of generation, if
+// `--trace-timing` was passed.
+func printTimings(timings []ksonnet.PhaseTiming) {
+	if !*traceTiming {
+		return
+	}
+
+	var total time.Duration
+	log.Println("Timing:")
+	for _, timing := range timings {
+		log.Printf("  %-20s %v", timing.Phase, timing.Duration)
+		total += timing.Duration
 	}
+	log.Printf("  %-20s %v", "total", total)
+}
+
+// printSummary reports what a run of `ksonnet-gen` would have done,
+// without writing anything: the statistics of the generated library,
+// the number of warnings, and the files that would have been written.
+func printSummary(
+	s *kubespec.APISpec, report *ksonnet.Report, files map[string][]byte,
+) {
+	stats := report.Stats
+	fmt.Printf("Kubernetes version: %s\n", s.Info.Version)
+	fmt.Printf("Definitions:        %d\n", len(s.Definitions))
+	fmt.Printf("Groups:             %d\n", stats.Groups)
+	fmt.Printf("Versioned APIs:     %d\n", stats.VersionedAPIs)
+	fmt.Printf("Top-level objects:  %d\n", stats.TopLevelObjects)
+	fmt.Printf("Hidden objects:     %d\n", stats.HiddenObjects)
+	fmt.Printf("Properties:         %d\n", stats.Properties)
+	fmt.Printf("Type aliases:       %d\n", stats.TypeAliases)
+	fmt.Printf("Warnings:           %d\n", len(report.Warnings))
+
+	fmt.Println("Would write:")
+	paths := []string{}
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Printf("  %s (%d bytes)\n", path, len(files[path]))
+	}
+}
 
-	kOutfile := fmt.Sprintf("%s/%s", os.Args[2], "k.libsonnet")
-	err = ioutil.WriteFile(kOutfile, kBytes, 0644)
+// startProfiling starts the CPU profiler if `--cpuprofile` was
+// passed, and returns a function that stops it and writes the heap
+// profile if `--memprofile` was passed. The returned function should
+// be called once generation is complete, whether or not it failed.
+func startProfiling() (func() error, error) {
+	var cpuFile *os.File
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not create CPU profile at '%s':\n%v", *cpuProfile, err)
+		}
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Could not start CPU profile:\n%v", err)
+		}
+		cpuFile = f
+	}
+
+	return func() error {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+
+		if *memProfile != "" {
+			f, err := os.Create(*memProfile)
+			if err != nil {
+				return fmt.Errorf("Could not create heap profile at '%s':\n%v", *memProfile, err)
+			}
+			defer f.Close()
+
+			// Get up-to-date statistics on the heap before writing it.
+			runtime.GC()
+			err = pprof.WriteHeapProfile(f)
+			if err != nil {
+				return fmt.Errorf("Could not write heap profile:\n%v", err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// generationTime returns the time to record as the time the library
+// was generated at: that of `--generated-at`, or else that of
+// `SOURCE_DATE_EPOCH`, if either is set, and the zero time (i.e.,
+// none) otherwise, so that the library is the same every time it is
+// generated unless asked otherwise.
+func generationTime() (time.Time, error) {
+	if *generatedAt != "" {
+		at, err := time.Parse(time.RFC3339, *generatedAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("--generated-at '%s' is not an RFC 3339 time:\n%v", *generatedAt, err)
+		}
+		return at, nil
+	}
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
 	if err != nil {
-		log.Fatalf("Could not write `k.libsonnet`:\n%v", err)
+		return time.Time{}, fmt.Errorf("SOURCE_DATE_EPOCH '%s' is not a number of seconds:\n%v", epoch, err)
 	}
+	return time.Unix(seconds, 0), nil
 }
 
-func getSHARevision(dir string) string {
+func getSHARevision(dir string) (string, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
-		log.Fatalf("Could get working directory:\n%v", err)
+		return "", fmt.Errorf("Could get working directory:\n%v", err)
 	}
 
 	err = os.Chdir(dir)
 	if err != nil {
-		log.Fatalf("Could cd to directory of repository at '%s':\n%v", dir, err)
+		return "", fmt.Errorf("Could cd to directory of repository at '%s':\n%v", dir, err)
 	}
 
 	sha, err := exec.Command("sh", "-c", "git rev-parse HEAD").Output()
 	if err != nil {
-		log.Fatalf("Could not find SHA of HEAD:\n%v", err)
+		return "", fmt.Errorf("Could not find SHA of HEAD:\n%v", err)
 	}
 
 	err = os.Chdir(cwd)
 	if err != nil {
-		log.Fatalf("Could cd back to current directory '%s':\n%v", cwd, err)
+		return "", fmt.Errorf("Could cd back to current directory '%s':\n%v", cwd, err)
 	}
 
-	return strings.TrimSpace(string(sha))
+	return strings.TrimSpace(string(sha)), nil
 }
 
 func init() {
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var dryRunSpec = `{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.Widget": {
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "apps", "Version": "v1beta1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.apps.v1beta1.WidgetSpec": {
+      "properties": {
+        "replicas": {"type": "integer"},
+        "template": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.apps.v1beta1.%s"}
+      }
+    }
+  }
+}`
+
+// `runDryRun` runs `ksonnet-gen --dry-run` on `spec`, which it reads
+// from stdin, since a spec read from a file is expected to be in a
+// repository, and returns what it printed, and the files left in the
+// output directory.
+func runDryRun(t *testing.T, spec string) (string, []string, error) {
+	dir, err := ioutil.TempDir("", "ksonnet-gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stdin, stdout := os.Stdin, os.Stdout
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+	in := filepath.Join(dir, "swagger.json")
+	out := filepath.Join(dir, "stdout")
+	if err := ioutil.WriteFile(in, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if os.Stdin, err = os.Open(in); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Stdin.Close()
+	if os.Stdout, err = os.Create(out); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Stdout.Close()
+
+	outputDir := filepath.Join(dir, "lib")
+	if err := flag.CommandLine.Parse([]string{"--dry-run", stdinSource, outputDir}); err != nil {
+		t.Fatal(err)
+	}
+	defer flag.Set("dry-run", "false")
+	runErr := run([]string{stdinSource}, "")
+
+	printed, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := []string{}
+	filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			written = append(written, path)
+		}
+		return nil
+	})
+	return string(printed), written, runErr
+}
+
+func TestDryRun(t *testing.T) {
+	printed, written, err := runDryRun(t, strings.Replace(dryRunSpec, "%s", "WidgetSpec", 1))
+	if err != nil {
+		t.Fatalf("Dry run failed:\n%v", err)
+	}
+	if len(written) != 0 {
+		t.Errorf("Expected a dry run to write no files, but it wrote %v", written)
+	}
+
+	for _, expected := range []string{
+		"Kubernetes version: v1.7.0\n",
+		"Groups:             1\n",
+		"Versioned APIs:     2\n",
+		"Top-level objects:  1\n",
+		"Hidden objects:     1\n",
+		"Properties:         5\n",
+		"Type aliases:       2\n",
+		"k8s.libsonnet (",
+		"k.libsonnet (",
+	} {
+		if !strings.Contains(printed, expected) {
+			t.Errorf("Expected '%s' in the summary, got:\n%s", strings.TrimSpace(expected), printed)
+		}
+	}
+}
+
+func TestDryRunValidates(t *testing.T) {
+	_, written, err := runDryRun(t, strings.Replace(dryRunSpec, "%s", "Missing", 1))
+	if err == nil {
+		t.Errorf("Expected a dry run to validate the spec, and reject a dangling reference")
+	}
+	if len(written) != 0 {
+		t.Errorf("Expected a dry run to write no files, but it wrote %v", written)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/ksonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/migrate"
+)
+
+var migrateUsage = `Usage: ksonnet-gen migrate --from=[old names.json] --to=[new names.json] [flags] [Jsonnet files]`
+
+// runMigrate implements `ksonnet-gen migrate`, which rewrites Jsonnet
+// files written against one version of ksonnet-lib to use another,
+// using the name maps emitted with `--name-map` for both.
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := flags.String(
+		"from", "", "The name map of the library the files are written against")
+	to := flags.String(
+		"to", "", "The name map of the library to migrate the files to")
+	write := flags.Bool(
+		"w", false, "Rewrite the files in place, rather than printing them")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *from == "" || *to == "" || flags.NArg() == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	fromNames, err := readNameMap(*from)
+	if err != nil {
+		log.Fatal(err)
+	}
+	toNames, err := readNameMap(*to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	migration := migrate.NewMigration(fromNames, toNames)
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Could not read file at '%s':\n%v", path, err)
+		}
+
+		text, warnings, err := migration.Rewrite(path, source)
+		if err != nil {
+			log.Fatalf("Could not migrate '%s':\n%v", path, err)
+		}
+		for _, warning := range warnings {
+			log.Printf("WARNING: %s", warning)
+		}
+
+		if !*write {
+			fmt.Print(string(text))
+			continue
+		}
+		err = ioutil.WriteFile(path, text, 0644)
+		if err != nil {
+			log.Fatalf("Could not write `%s`:\n%v", path, err)
+		}
+	}
+}
+
+func readNameMap(path string) (*ksonnet.NameMap, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read file at '%s':\n%v", path, err)
+	}
+	names, err := ksonnet.ParseNameMap(text)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read name map at '%s':\n%v", path, err)
+	}
+	return names, nil
+}
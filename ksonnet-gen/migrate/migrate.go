@@ -0,0 +1,316 @@
+// Package migrate rewrites Jsonnet code written against one version
+// of ksonnet-lib so that it uses another, e.g., after an object moved
+// from the `extensions` group to `apps`, or after a setter was
+// renamed. It is driven by the name maps (see `ksonnet.NameMap`) that
+// `ksonnet-gen` emits alongside each library.
+package migrate
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	gojsonnet "github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/google/go-jsonnet/toolutils"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/ksonnet"
+)
+
+// libraryFiles are the files that, when imported, evaluate to the root
+// of ksonnet-lib.
+var libraryFiles = map[string]bool{
+	"k.libsonnet":   true,
+	"k8s.libsonnet": true,
+}
+
+// Migration maps the paths of the functions and namespaces of an old
+// library to their paths in a new one.
+type Migration struct {
+	renames map[string]string // e.g., `extensions.v1beta1.deployment` to `apps.v1beta1.deployment`.
+	removed map[string]string // old path to the reason it can't be migrated.
+}
+
+// NewMigration builds the migration from the library described by
+// `from` to the one described by `to`. A name is migrated to the name
+// that stands for the same thing in `to` (see `ksonnet.Name.Key`), or,
+// failing that, to the only name that stands for the same field of an
+// object of the same kind, in any group and version.
+func NewMigration(from, to *ksonnet.NameMap) *Migration {
+	byKey := make(map[string]string)
+	byKind := make(map[string][]string)
+	for _, name := range to.Names {
+		byKey[name.Key()] = name.Path
+		kindKey := kindKey(name)
+		byKind[kindKey] = append(byKind[kindKey], name.Path)
+	}
+
+	migration := Migration{
+		renames: make(map[string]string),
+		removed: make(map[string]string),
+	}
+	for _, name := range from.Names {
+		if newPath, ok := byKey[name.Key()]; ok {
+			if newPath != name.Path {
+				migration.renames[name.Path] = newPath
+			}
+			continue
+		}
+
+		switch candidates := byKind[kindKey(name)]; len(candidates) {
+		case 0:
+			migration.removed[name.Path] = "it has no equivalent in the new library"
+		case 1:
+			migration.renames[name.Path] = candidates[0]
+		default:
+			sort.Strings(candidates)
+			migration.removed[name.Path] = fmt.Sprintf(
+				"it could be any of: %s", strings.Join(candidates, ", "))
+		}
+	}
+	return &migration
+}
+
+func kindKey(name ksonnet.Name) string {
+	return fmt.Sprintf("%s:%s:%s", name.Kind, name.Field, name.Role)
+}
+
+// Rewrite returns `source`, the text of the Jsonnet file `filename`,
+// with every reference to a migrated function or namespace rewritten,
+// along with a warning for every reference that can't be rewritten
+// automatically.
+//
+// References are found by following `local`s bound to imports of
+// `k.libsonnet` or `k8s.libsonnet`, and to fields of those, e.g.,
+//
+//	local k = import "k.libsonnet";
+//	local deployment = k.extensions.v1beta1.deployment;
+//	deployment.new("nginx", 1, [])
+//
+// Other ways of referring to the library (e.g., passing it as a
+// function argument) are not followed.
+func (migration *Migration) Rewrite(
+	filename string, source []byte,
+) ([]byte, []string, error) {
+	node, err := gojsonnet.SnippetToAST(filename, string(source))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := rewriter{
+		migration: migration,
+		source:    newSourceText(source),
+		bindings:  make(map[ast.Identifier]string),
+		warnings:  []string{},
+	}
+	r.visit(node, false)
+
+	// Apply edits back to front, so that offsets remain valid.
+	sort.Slice(r.edits, func(i, j int) bool {
+		return r.edits[i].begin > r.edits[j].begin
+	})
+	text := string(source)
+	for _, e := range r.edits {
+		text = text[:e.begin] + e.text + text[e.end:]
+	}
+	return []byte(text), r.warnings, nil
+}
+
+type edit struct {
+	begin, end int // byte offsets.
+	text       string
+}
+
+type rewriter struct {
+	migration *Migration
+	source    sourceText
+	// Variables bound to the library, or to a field of it, and the
+	// (old) path they're bound to, which is "" for the library itself.
+	bindings map[ast.Identifier]string
+	edits    []edit
+	warnings []string
+}
+
+// `visit` finds every reference to the library in `node`. `inChain`
+// is true if `node` is the target of an index, and so is part of a
+// longer reference.
+func (r *rewriter) visit(node ast.Node, inChain bool) {
+	switch node := node.(type) {
+	case nil:
+		return
+	case *ast.Local:
+		for _, bind := range node.Binds {
+			if base, ok := r.resolve(bind.Body); ok {
+				r.bindings[bind.Variable] = base
+			}
+			r.visit(bind.Body, false)
+		}
+		r.visit(node.Body, false)
+		return
+	case *ast.Index:
+		if !inChain {
+			r.rewriteChain(node)
+		}
+		r.visit(node.Target, true)
+		r.visit(node.Index, false)
+		return
+	}
+
+	for _, child := range toolutils.Children(node) {
+		r.visit(child, false)
+	}
+}
+
+// `resolve` returns the path to the part of the library `node` refers
+// to, if it is a reference to the library.
+func (r *rewriter) resolve(node ast.Node) (string, bool) {
+	base, segments, ok := r.chain(node)
+	if !ok {
+		return "", false
+	}
+	return joinPath(base, segmentIDs(segments)...), true
+}
+
+// `chain` splits a reference to the library, e.g., `k.apps.v1beta1`,
+// into the path of its root (e.g., "" for `k`), and the `Index` node
+// of every segment after it (e.g., for `apps` and `v1beta1`).
+func (r *rewriter) chain(node ast.Node) (string, []*ast.Index, bool) {
+	switch node := node.(type) {
+	case *ast.Var:
+		base, ok := r.bindings[node.Id]
+		return base, nil, ok
+	case *ast.Import:
+		return "", nil, libraryFiles[path.Base(node.File.Value)]
+	case *ast.Index:
+		if _, ok := node.Index.(*ast.LiteralString); !ok {
+			return "", nil, false
+		}
+		base, segments, ok := r.chain(node.Target)
+		if !ok {
+			return "", nil, false
+		}
+		return base, append(segments, node), true
+	}
+	return "", nil, false
+}
+
+// `rewriteChain` rewrites the longest prefix of a reference to the
+// library that has been migrated, e.g., `deployment.mixin.spec` in
+// `deployment.mixin.spec.withReplicas`.
+func (r *rewriter) rewriteChain(node *ast.Index) {
+	base, segments, ok := r.chain(node)
+	if !ok {
+		return
+	}
+
+	ids := segmentIDs(segments)
+	for n := len(ids); n > 0; n-- {
+		oldPath := joinPath(base, ids[:n]...)
+		if reason, ok := r.migration.removed[oldPath]; ok {
+			r.warnf(segments[n-1], "can't migrate '%s', because %s", oldPath, reason)
+			return
+		}
+		newPath, ok := r.migration.renames[oldPath]
+		if !ok {
+			continue
+		}
+
+		// The root of the reference is migrated separately (e.g., where
+		// its `local` is bound), so only the segments after it can be
+		// rewritten here.
+		newBase := base
+		if renamed, ok := r.migration.renames[base]; ok {
+			newBase = renamed
+		}
+		prefix := newBase + "."
+		if newBase == "" {
+			prefix = ""
+		}
+		if !strings.HasPrefix(newPath, prefix) {
+			r.warnf(segments[n-1], "can't migrate '%s' to '%s' in place", oldPath, newPath)
+			return
+		}
+		r.replace(segments[:n], strings.Split(strings.TrimPrefix(newPath, prefix), "."))
+		return
+	}
+}
+
+// `replace` replaces the identifiers of `segments` with `ids`, one by
+// one if there are as many of each (preserving any whitespace and
+// comments between them), or otherwise all at once.
+func (r *rewriter) replace(segments []*ast.Index, ids []string) {
+	spans := []edit{}
+	for i, segment := range segments {
+		end := r.source.offset(segment.Loc().End)
+		id := segment.Index.(*ast.LiteralString).Value
+		begin := end - len(id)
+		if begin < 0 || r.source.text[begin:end] != id {
+			// E.g., `k["apps"]`.
+			r.warnf(segment, "can't migrate '%s', which is not a plain field access", id)
+			return
+		}
+		text := ""
+		if i < len(ids) {
+			text = ids[i]
+		}
+		spans = append(spans, edit{begin: begin, end: end, text: text})
+	}
+
+	if len(ids) == len(segments) {
+		r.edits = append(r.edits, spans...)
+		return
+	}
+
+	// Replace everything from the first identifier to the last.
+	r.edits = append(r.edits, edit{
+		begin: spans[0].begin,
+		end:   spans[len(spans)-1].end,
+		text:  strings.Join(ids, "."),
+	})
+}
+
+func (r *rewriter) warnf(node ast.Node, format string, args ...interface{}) {
+	loc := node.Loc()
+	r.warnings = append(r.warnings, fmt.Sprintf(
+		"%s:%d:%d: %s", loc.FileName, loc.Begin.Line, loc.Begin.Column, fmt.Sprintf(format, args...)))
+}
+
+func segmentIDs(segments []*ast.Index) []string {
+	ids := []string{}
+	for _, segment := range segments {
+		ids = append(ids, segment.Index.(*ast.LiteralString).Value)
+	}
+	return ids
+}
+
+func joinPath(base string, ids ...string) string {
+	if base == "" {
+		return strings.Join(ids, ".")
+	}
+	return strings.Join(append([]string{base}, ids...), ".")
+}
+
+// `sourceText` converts go-jsonnet locations to byte offsets.
+type sourceText struct {
+	text       string
+	lineStarts []int
+}
+
+func newSourceText(source []byte) sourceText {
+	lineStarts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return sourceText{text: string(source), lineStarts: lineStarts}
+}
+
+// `offset` returns the byte offset of `loc`, whose line is 1-based,
+// and whose column is the 1-based byte offset in that line.
+func (s sourceText) offset(loc ast.Location) int {
+	if loc.Line < 1 || loc.Line > len(s.lineStarts) {
+		return -1
+	}
+	return s.lineStarts[loc.Line-1] + loc.Column - 1
+}
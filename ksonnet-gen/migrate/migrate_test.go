@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/ksonnet"
+	"github.com/ksonnet/ksonnet-lib/ksonnet-gen/kubespec"
+)
+
+// `widgetSpec` returns a spec with a `Widget` in group `group`, and,
+// optionally, a `Gadget` next to it.
+func widgetSpec(group string, gadget bool) string {
+	definitions := []string{fmt.Sprintf(`
+    "io.k8s.kubernetes.pkg.apis.%[1]s.v1beta1.Widget": {
+      "properties": {
+        "spec": {"$ref": "#/definitions/io.k8s.kubernetes.pkg.apis.%[1]s.v1beta1.WidgetSpec"}
+      },
+      "x-kubernetes-group-version-kind": [{"Group": "%[1]s", "Version": "v1beta1", "Kind": "Widget"}]
+    },
+    "io.k8s.kubernetes.pkg.apis.%[1]s.v1beta1.WidgetSpec": {
+      "properties": {"replicas": {"type": "integer"}}
+    }`, group)}
+	if gadget {
+		definitions = append(definitions, fmt.Sprintf(`
+    "io.k8s.kubernetes.pkg.apis.%[1]s.v1beta1.Gadget": {
+      "properties": {"size": {"type": "integer"}},
+      "x-kubernetes-group-version-kind": [{"Group": "%[1]s", "Version": "v1beta1", "Kind": "Gadget"}]
+    }`, group))
+	}
+	return fmt.Sprintf(`{
+  "swagger": "2.0",
+  "info": {"title": "Kubernetes", "version": "v1.7.0"},
+  "definitions": {%s}
+}`, strings.Join(definitions, ","))
+}
+
+func nameMap(t *testing.T, text string) *ksonnet.NameMap {
+	s := kubespec.APISpec{}
+	if err := json.Unmarshal([]byte(text), &s); err != nil {
+		t.Fatalf("Could not deserialize schema:\n%v", err)
+	}
+	s.Text = []byte(text)
+
+	files, _, err := ksonnet.EmitFiles(&s, nil, nil, ksonnet.Options{NameMap: true})
+	if err != nil {
+		t.Fatalf("Failed to emit:\n%v", err)
+	}
+	names, err := ksonnet.ParseNameMap(files[ksonnet.NamesFile])
+	if err != nil {
+		t.Fatalf("Failed to parse name map:\n%v", err)
+	}
+	return names
+}
+
+var source = `local k = import "k.libsonnet";
+local widget = k.extensions.v1beta1.widget;
+{
+  local spec = widget.mixin.spec,
+  a: widget.new() + spec.withReplicas(3),
+  b: k.extensions.
+    // Comments are kept.
+    v1beta1.widget.mixin.spec.withReplicas(1),
+  c: k.extensions.v1beta1.gadget.new(),
+  d: k.extensions["v1beta1"].widget.new(),
+}
+`
+
+var expected = `local k = import "k.libsonnet";
+local widget = k.apps.v1beta1.widget;
+{
+  local spec = widget.mixin.spec,
+  a: widget.new() + spec.withReplicas(3),
+  b: k.apps.
+    // Comments are kept.
+    v1beta1.widget.mixin.spec.withReplicas(1),
+  c: k.extensions.v1beta1.gadget.new(),
+  d: k.extensions["v1beta1"].widget.new(),
+}
+`
+
+func TestRewrite(t *testing.T) {
+	migration := NewMigration(
+		nameMap(t, widgetSpec("extensions", true)), nameMap(t, widgetSpec("apps", false)))
+
+	text, warnings, err := migration.Rewrite("app.jsonnet", []byte(source))
+	if err != nil {
+		t.Fatalf("Failed to migrate:\n%v", err)
+	}
+	if string(text) != expected {
+		t.Errorf("Expected migrated file:\n%s\ngot:\n%s", expected, text)
+	}
+
+	expectedWarnings := []string{
+		"app.jsonnet:9:6: can't migrate 'extensions.v1beta1.gadget.new', because it has no equivalent in the new library",
+		"app.jsonnet:10:6: can't migrate 'v1beta1', which is not a plain field access",
+	}
+	if len(warnings) != len(expectedWarnings) {
+		t.Fatalf("Expected warnings:\n%s\ngot:\n%s",
+			strings.Join(expectedWarnings, "\n"), strings.Join(warnings, "\n"))
+	}
+	for i, warning := range expectedWarnings {
+		if warnings[i] != warning {
+			t.Errorf("Expected warning '%s', got '%s'", warning, warnings[i])
+		}
+	}
+}
+
+func TestRewriteParseError(t *testing.T) {
+	migration := NewMigration(&ksonnet.NameMap{}, &ksonnet.NameMap{})
+	if _, _, err := migration.Rewrite("app.jsonnet", []byte("{")); err == nil {
+		t.Errorf("Expected invalid Jsonnet to fail")
+	}
+}
@@ -0,0 +1,59 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// The hosts the specs and commits of Kubernetes releases are fetched
+// from; variables, so that tests can point them at a fake server.
+var (
+	githubRawURL = "https://raw.githubusercontent.com"
+	githubAPIURL = "https://api.github.com"
+)
+
+const kubernetesRepo = "kubernetes/kubernetes"
+
+// releasePattern matches the versions of Kubernetes releases, e.g.,
+// `1.9.3`, `v1.9.3`, or `v1.10.0-beta.1`.
+var releasePattern = regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+(-(alpha|beta|rc)\.[0-9]+)?$`)
+
+// ReleaseTag returns the tag of the Kubernetes release `version` in
+// the kubernetes/kubernetes repository, e.g., `v1.9.3` for `1.9.3`.
+func ReleaseTag(version string) (string, error) {
+	if !releasePattern.MatchString(version) {
+		return "", fmt.Errorf(
+			"Kubernetes version '%s' is not of the form 'MAJOR.MINOR.PATCH', e.g., '1.9.3'", version)
+	}
+	if version[0] != 'v' {
+		version = "v" + version
+	}
+	return version, nil
+}
+
+// ReleaseSpecURL returns the URL of the OpenAPI spec (`swagger.json`)
+// of the Kubernetes release tagged `tag`.
+func ReleaseSpecURL(tag string) string {
+	return fmt.Sprintf("%s/%s/%s/api/openapi-spec/swagger.json", githubRawURL, kubernetesRepo, tag)
+}
+
+// ReleaseCommit returns the SHA of the commit of the kubernetes/kubernetes
+// repository that is tagged `tag`. Tags don't move, so it is cached like
+// a spec is (see `Fetch`); `opts.SHA256` is ignored.
+func ReleaseCommit(tag string, opts Options) (string, error) {
+	url := fmt.Sprintf("%s/repos/%s/commits/%s", githubAPIURL, kubernetesRepo, tag)
+	opts.SHA256 = ""
+	text, err := Fetch(url, opts)
+	if err != nil {
+		return "", fmt.Errorf("Could not find the commit of Kubernetes release '%s':\n%v", tag, err)
+	}
+
+	commit := struct {
+		SHA string `json:"sha"`
+	}{}
+	if err := json.Unmarshal(text, &commit); err != nil || commit.SHA == "" {
+		return "", fmt.Errorf("Could not find the commit of Kubernetes release '%s' in '%s'", tag, url)
+	}
+	return commit.SHA, nil
+}
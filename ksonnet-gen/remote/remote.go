@@ -0,0 +1,130 @@
+// Package remote downloads API specs from URLs, and caches them on
+// disk, so that repeated generation runs don't hit the network.
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Options configures `Fetch`.
+type Options struct {
+	// Timeout bounds how long to wait for the download, or, if it is
+	// 0, doesn't.
+	Timeout time.Duration
+	// SHA256, if set, is the hex-encoded SHA-256 digest the spec must
+	// have; if it doesn't, `Fetch` fails.
+	SHA256 string
+	// CacheDir is the directory downloaded specs are cached in. If it is
+	// "", nothing is cached.
+	CacheDir string
+}
+
+// IsURL reports whether `path` is an `http` or `https` URL, rather
+// than the path of a file.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// DefaultCacheDir returns the directory specs are cached in by default,
+// `ksonnet-gen` in the user's cache directory (e.g.,
+// `~/.cache/ksonnet-gen`), or "" if there is none.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ksonnet-gen")
+}
+
+// Fetch returns the contents of `url`, from the cache if a previous
+// call downloaded it with the same `opts.SHA256`, and otherwise from
+// the network, after which it is cached.
+func Fetch(url string, opts Options) ([]byte, error) {
+	expected := strings.ToLower(opts.SHA256)
+
+	var cachePath string
+	if opts.CacheDir != "" {
+		cachePath = filepath.Join(opts.CacheDir, cacheKey(url, expected))
+		if text, err := ioutil.ReadFile(cachePath); err == nil && verify(text, expected) == nil {
+			return text, nil
+		}
+	}
+
+	client := http.Client{Timeout: opts.Timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Could not fetch spec from '%s':\n%v", url, err)
+	}
+	defer resp.Body.Close()
+
+	text, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read spec from '%s':\n%v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Could not fetch spec from '%s': %s", url, resp.Status)
+	}
+	if err := verify(text, expected); err != nil {
+		return nil, fmt.Errorf("Spec fetched from '%s' is not the one expected:\n%v", url, err)
+	}
+
+	if cachePath != "" {
+		if err := writeCache(cachePath, text); err != nil {
+			return nil, err
+		}
+	}
+	return text, nil
+}
+
+// `cacheKey` returns the name of the cache file of `url`, downloaded
+// with the digest `sha` (which may be "").
+func cacheKey(url, sha string) string {
+	digest := sha256.Sum256([]byte(url + "\n" + sha))
+	return hex.EncodeToString(digest[:]) + ".json"
+}
+
+// `verify` returns an error if `text` does not have the SHA-256 digest
+// `expected`, unless `expected` is "".
+func verify(text []byte, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	digest := sha256.Sum256(text)
+	if actual := hex.EncodeToString(digest[:]); actual != expected {
+		return fmt.Errorf("Expected SHA-256 '%s', got '%s'", expected, actual)
+	}
+	return nil
+}
+
+// `writeCache` writes `text` to `path` atomically, so that concurrent
+// runs never read a partial file.
+func writeCache(path string, text []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("Could not create cache directory:\n%v", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".download-")
+	if err != nil {
+		return fmt.Errorf("Could not write to cache:\n%v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(text); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Could not write to cache:\n%v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Could not write to cache:\n%v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Could not write to cache:\n%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package remote
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const spec = `{"swagger": "2.0"}`
+
+func TestFetch(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, spec)
+	}))
+	defer server.Close()
+
+	digest := sha256.Sum256([]byte(spec))
+	opts := Options{SHA256: hex.EncodeToString(digest[:]), CacheDir: t.TempDir()}
+
+	for i := 0; i < 2; i++ {
+		text, err := Fetch(server.URL, opts)
+		if err != nil {
+			t.Fatalf("Failed to fetch spec:\n%v", err)
+		}
+		if string(text) != spec {
+			t.Errorf("Unexpected spec '%s'", text)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the second fetch to hit the cache, but the server got %d requests", requests)
+	}
+
+	// A different digest is a different cache entry, and is checked.
+	opts.SHA256 = "0000"
+	if _, err := Fetch(server.URL, opts); err == nil {
+		t.Errorf("Expected a spec with the wrong digest to be rejected")
+	}
+	if requests != 2 {
+		t.Errorf("Expected a fetch with a different digest to miss the cache")
+	}
+
+	// Without a cache, every fetch hits the network.
+	if _, err := Fetch(server.URL, Options{}); err != nil {
+		t.Fatalf("Failed to fetch spec:\n%v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected a fetch without a cache to hit the network")
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/swagger.json": true,
+		"http://localhost:8001/openapi/v2": true,
+		"swagger.json":                     false,
+		"/tmp/http/swagger.json":           false,
+	}
+	for path, expected := range tests {
+		if IsURL(path) != expected {
+			t.Errorf("Expected IsURL('%s') to be %v", path, expected)
+		}
+	}
+}
+
+func TestReleaseTag(t *testing.T) {
+	tests := map[string]string{
+		"1.9.3":          "v1.9.3",
+		"v1.9.3":         "v1.9.3",
+		"v1.10.0-beta.1": "v1.10.0-beta.1",
+		"1.9":            "",
+		"latest":         "",
+	}
+	for version, expected := range tests {
+		tag, err := ReleaseTag(version)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("[%s] Expected malformed version to be rejected, got '%s'", version, tag)
+			}
+			continue
+		}
+		if err != nil || tag != expected {
+			t.Errorf("[%s] Expected tag '%s', got '%s' (%v)", version, expected, tag, err)
+		}
+	}
+}
+
+func TestReleaseCommit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/kubernetes/kubernetes/commits/v1.9.3" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"sha": "d2835416544f298f919e2ead3be3d0864b52323b"}`)
+	}))
+	defer server.Close()
+	defer func(url string) { githubAPIURL = url }(githubAPIURL)
+	githubAPIURL = server.URL
+
+	sha, err := ReleaseCommit("v1.9.3", Options{SHA256: "0000"})
+	if err != nil {
+		t.Fatalf("Failed to find commit:\n%v", err)
+	}
+	if sha != "d2835416544f298f919e2ead3be3d0864b52323b" {
+		t.Errorf("Unexpected commit '%s'", sha)
+	}
+	if _, err := ReleaseCommit("v0.0.0", Options{}); err == nil {
+		t.Error("Expected an unknown release to fail")
+	}
+}
@@ -0,0 +1,141 @@
+// Package ksonnetlib embeds the generated releases of ksonnet-lib
+// (e.g., `ksonnet.beta.3/k.libsonnet` and `k8s.libsonnet`), so that Go
+// programs that evaluate Jsonnet can bundle the library without
+// depending on the filesystem or the network, e.g.:
+//
+//	files, err := ksonnetlib.Lookup("v1.7.0")
+//	...
+//	vm.Importer(&jsonnet.MemoryImporter{Data: ...})
+package ksonnetlib
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed ksonnet.beta.2/*.libsonnet ksonnet.beta.3/*.libsonnet
+var generated embed.FS
+
+// `k8sFile` is the generated file of each release, whose header
+// records the Kubernetes version it was generated for.
+const k8sFile = "k8s.libsonnet"
+
+// `k8sVersionHeader` prefixes the line of the header of `k8sFile`
+// that records the Kubernetes version.
+const k8sVersionHeader = "// Kubernetes version: "
+
+// Release is one embedded release of ksonnet-lib.
+type Release struct {
+	// Name is the name of the release, which is also the directory it
+	// is in, e.g., `ksonnet.beta.3`.
+	Name string
+	// KubernetesVersion is the version of the Kubernetes spec the
+	// release was generated from, e.g., `v1.7.0`.
+	KubernetesVersion string
+}
+
+// Releases returns every embedded release, oldest first.
+func Releases() ([]Release, error) {
+	entries, err := fs.ReadDir(generated, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	releases := []Release{}
+	for _, entry := range entries {
+		k8sVersion, err := kubernetesVersion(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, Release{
+			Name:              entry.Name(),
+			KubernetesVersion: k8sVersion,
+		})
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Name < releases[j].Name
+	})
+	return releases, nil
+}
+
+// KubernetesVersions returns the Kubernetes versions there are
+// embedded releases for, sorted.
+func KubernetesVersions() ([]string, error) {
+	releases, err := Releases()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	versions := []string{}
+	for _, release := range releases {
+		if !seen[release.KubernetesVersion] {
+			seen[release.KubernetesVersion] = true
+			versions = append(versions, release.KubernetesVersion)
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Lookup returns the files of the newest embedded release generated
+// for the Kubernetes version `k8sVersion` (e.g., `v1.7.0`), keyed by
+// file name (e.g., `k.libsonnet`), which is how the library imports
+// them.
+func Lookup(k8sVersion string) (map[string][]byte, error) {
+	releases, err := Releases()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(releases) - 1; i >= 0; i-- {
+		if releases[i].KubernetesVersion == k8sVersion {
+			return LookupRelease(releases[i].Name)
+		}
+	}
+	return nil, fmt.Errorf(
+		"No release of ksonnet-lib is embedded for Kubernetes version '%s'", k8sVersion)
+}
+
+// LookupRelease returns the files of the embedded release named
+// `name` (e.g., `ksonnet.beta.3`), keyed by file name.
+func LookupRelease(name string) (map[string][]byte, error) {
+	entries, err := fs.ReadDir(generated, name)
+	if err != nil {
+		return nil, fmt.Errorf("No release of ksonnet-lib named '%s' is embedded", name)
+	}
+
+	files := make(map[string][]byte)
+	for _, entry := range entries {
+		data, err := fs.ReadFile(generated, path.Join(name, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = data
+	}
+	return files, nil
+}
+
+// `kubernetesVersion` reads the Kubernetes version of the release
+// named `name` from the header of its `k8sFile`.
+func kubernetesVersion(name string) (string, error) {
+	data, err := fs.ReadFile(generated, path.Join(name, k8sFile))
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		if strings.HasPrefix(line, k8sVersionHeader) {
+			return strings.TrimSpace(strings.TrimPrefix(line, k8sVersionHeader)), nil
+		}
+	}
+	return "", fmt.Errorf(
+		"Could not find the Kubernetes version in the header of '%s'", path.Join(name, k8sFile))
+}
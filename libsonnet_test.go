@@ -0,0 +1,49 @@
+package ksonnetlib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleases(t *testing.T) {
+	releases, err := Releases()
+	if err != nil {
+		t.Fatalf("Failed to list releases:\n%v", err)
+	}
+	if len(releases) != 2 || releases[0].Name != "ksonnet.beta.2" || releases[1].Name != "ksonnet.beta.3" {
+		t.Fatalf("Unexpected releases %v", releases)
+	}
+	for _, release := range releases {
+		if release.KubernetesVersion != "v1.7.0" {
+			t.Errorf("Unexpected Kubernetes version '%s' of '%s'", release.KubernetesVersion, release.Name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	files, err := Lookup("v1.7.0")
+	if err != nil {
+		t.Fatalf("Failed to look up release:\n%v", err)
+	}
+
+	// The newest release for the version wins.
+	newest, err := LookupRelease("ksonnet.beta.3")
+	if err != nil {
+		t.Fatalf("Failed to look up release:\n%v", err)
+	}
+	for _, name := range []string{"k.libsonnet", "k8s.libsonnet"} {
+		if len(files[name]) == 0 || string(files[name]) != string(newest[name]) {
+			t.Errorf("Expected '%s' of 'ksonnet.beta.3'", name)
+		}
+	}
+	if !strings.Contains(string(files["k.libsonnet"]), `import "k8s.libsonnet"`) {
+		t.Errorf("Expected 'k.libsonnet' to import 'k8s.libsonnet'")
+	}
+
+	if _, err := Lookup("v0.1.0"); err == nil {
+		t.Errorf("Expected an unsupported Kubernetes version to be rejected")
+	}
+	if _, err := LookupRelease("ksonnet.beta.0"); err == nil {
+		t.Errorf("Expected an unknown release to be rejected")
+	}
+}